@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/service"
+	"github.com/urfave/cli/v2"
+)
+
+var agentCommands = []*cli.Command{
+	{
+		Name:  "agents",
+		Usage: "Check and apply agent definitions",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "validate",
+				Usage:     "Check a directory of agent definitions without applying them",
+				ArgsUsage: "DIRECTORY",
+				Action: func(cli *cli.Context) error {
+					dir := cli.Args().Get(0)
+					if dir == "" {
+						return fmt.Errorf("you must pass the directory of definitions")
+					}
+
+					definitions, err := loadDefinitions(dir)
+					if err != nil {
+						return err
+					}
+
+					for _, definition := range definitions {
+						fmt.Fprintf(os.Stderr, "  %-20s %s\n", definition.ID, definition.Source)
+					}
+					fmt.Fprintf(os.Stderr, "%d definitions are valid\n", len(definitions))
+					return nil
+				},
+			},
+			{
+				Name:      "apply",
+				Usage:     "Apply a directory of agent definitions to a database, replacing the agents they define",
+				ArgsUsage: "DATABASE DIRECTORY",
+				Action: func(cli *cli.Context) error {
+					database, dir := cli.Args().Get(0), cli.Args().Get(1)
+					if database == "" || dir == "" {
+						return fmt.Errorf("you must pass the database and the directory of definitions")
+					}
+
+					definitions, err := loadDefinitions(dir)
+					if err != nil {
+						return err
+					}
+
+					cfg, err := config.LoadConfig(cli.String("config"))
+					if err != nil {
+						return err
+					}
+
+					db, err := openStore(database, cfg)
+					if err != nil {
+						return fmt.Errorf("unable to open %s: %w", database, err)
+					}
+
+					// Applying definitions never calls an LLM
+					results, err := service.NewService(db, nil, cfg).ApplyAgentDefinitions(definitions, dir, true)
+					if err != nil {
+						return err
+					}
+
+					printDefinitionResults(results)
+					return nil
+				},
+			},
+		},
+	},
+}
+
+func loadDefinitions(dir string) ([]*agents.Definition, error) {
+	definitions, err := agents.LoadDefinitions(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.ValidateDefinitions(definitions)
+	if err != nil {
+		return nil, err
+	}
+
+	return definitions, nil
+}
+
+func printDefinitionResults(results []*service.DefinitionResult) {
+	for _, result := range results {
+		fmt.Fprintf(os.Stderr, "  %-20s %-10s v%d (%s)\n", result.Agent, result.Outcome, result.Version, result.Source)
+	}
+}
+
+/*
+syncAgents adds the bundled agents if they're missing, then
+applies the configured directory of definitions, if it
+exists
+*/
+func syncAgents(svc *service.Service, cfg config.AgentsConfig) error {
+	if cfg.Seed {
+		seeds, err := agents.LoadDefinitions(prompts.Seeds())
+		if err != nil {
+			return err
+		}
+		_, err = svc.ApplyAgentDefinitions(seeds, "seed", false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.Directory == "" {
+		return nil
+	} else if _, err := os.Stat(cfg.Directory); os.IsNotExist(err) {
+		return nil
+	}
+
+	definitions, err := loadDefinitions(cfg.Directory)
+	if err != nil {
+		return err
+	}
+	results, err := svc.ApplyAgentDefinitions(definitions, cfg.Directory, true)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Applied agent definitions from %s:\n", cfg.Directory)
+	printDefinitionResults(results)
+	return nil
+}
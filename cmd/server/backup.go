@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hlfshell/coppermind/internal/store/sqlite"
+	"github.com/urfave/cli/v2"
+)
+
+var backupCommands = []*cli.Command{
+	{
+		Name:      "backup",
+		Usage:     "Back a sqlite database up to a new file, even while the server is running",
+		ArgsUsage: "DATABASE FILE",
+		Action: func(cli *cli.Context) error {
+			database, file := cli.Args().Get(0), cli.Args().Get(1)
+			if database == "" || file == "" {
+				return fmt.Errorf("you must pass the database and the file to back it up to")
+			}
+
+			db, err := sqlite.NewSqliteStore(strings.TrimPrefix(database, "sqlite://"))
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			err = db.Backup(file)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Backed up %s to %s\n", database, file)
+			return nil
+		},
+	},
+	{
+		Name:      "restore",
+		Usage:     "Replace a sqlite database with a backup or snapshot; stop the server first",
+		ArgsUsage: "BACKUP DATABASE",
+		Action: func(cli *cli.Context) error {
+			backup, database := cli.Args().Get(0), cli.Args().Get(1)
+			if backup == "" || database == "" {
+				return fmt.Errorf("you must pass the backup and the database to restore it to")
+			}
+
+			err := sqlite.Restore(backup, strings.TrimPrefix(database, "sqlite://"))
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Restored %s from %s\n", database, backup)
+			return nil
+		},
+	},
+}
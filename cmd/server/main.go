@@ -5,19 +5,24 @@ import (
 	"log"
 	"os"
 
-	"github.com/hlfshell/coppermind/internal/agent"
-	"github.com/hlfshell/coppermind/internal/llm/openai"
-	"github.com/hlfshell/coppermind/internal/prompts"
 	"github.com/hlfshell/coppermind/internal/protocol/http"
-	"github.com/hlfshell/coppermind/internal/service"
 	"github.com/hlfshell/coppermind/internal/store/sqlite"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/service"
 	"github.com/urfave/cli/v2"
 )
 
 func main() {
 	app := &cli.App{
-		Name:  "coppermind-http-server",
-		Usage: "Simple HTTP endpoint",
+		Name:     "coppermind-http-server",
+		Usage:    "Simple HTTP endpoint",
+		Commands: append(append(append(transferCommands, backupCommands...), agentCommands...), promptCommands...),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "A JSON file of configuration to use over the defaults",
+			},
+		},
 		Action: func(cli *cli.Context) error {
 			args := cli.Args()
 			sqliteFile := args.Get(0)
@@ -30,7 +35,12 @@ func main() {
 				port = ":8080"
 			}
 
-			serve(sqliteFile, port)
+			cfg, err := config.LoadConfig(cli.String("config"))
+			if err != nil {
+				return err
+			}
+
+			serve(sqliteFile, port, cfg)
 			return nil
 		},
 	}
@@ -40,15 +50,14 @@ func main() {
 
 }
 
-func serve(sqliteFile string, port string) {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		fmt.Println("Open AI Key must be specified")
+func serve(sqliteFile string, port string, cfg *config.Config) {
+	client, err := service.NewLLMFromConfig(cfg)
+	if err != nil {
+		fmt.Println("LLM error")
+		fmt.Println(err)
 		os.Exit(3)
 	}
 
-	client := openai.NewOpenAI(apiKey)
-
 	db, err := sqlite.NewSqliteStore(sqliteFile)
 	if err != nil {
 		fmt.Println("SQL error")
@@ -62,9 +71,33 @@ func serve(sqliteFile string, port string) {
 		os.Exit(3)
 	}
 
-	agent := agent.NewAgent("Rose", db, prompts.Rose, client)
+	blobs, err := service.NewBlobStoreFromConfig(cfg)
+	if err != nil {
+		fmt.Println("Blob store error")
+		fmt.Println(err)
+		os.Exit(3)
+	} else if blobs != nil {
+		db.SetBlobStore(blobs)
+	}
+
+	service := service.NewService(db, client, cfg)
+
+	// Ensure our agents exist, as defined
+	err = syncAgents(service, cfg.Agents)
+	if err != nil {
+		fmt.Println("Agent error")
+		fmt.Println(err)
+		os.Exit(3)
+	}
+
+	err = loadPrompts(service, cfg.Prompts)
+	if err != nil {
+		fmt.Println("Prompt error")
+		fmt.Println(err)
+		os.Exit(3)
+	}
 
-	service := service.NewService(agent)
+	service.LaunchDaemons()
 
 	server := http.NewHttpAPI(service, port)
 	err = server.Serve()
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/service"
+	"github.com/urfave/cli/v2"
+)
+
+var promptCommands = []*cli.Command{
+	{
+		Name:  "prompts",
+		Usage: "Check prompt templates",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "validate",
+				Usage:     "Check a directory of prompt templates without loading them",
+				ArgsUsage: "DIRECTORY",
+				Action: func(cli *cli.Context) error {
+					dir := cli.Args().Get(0)
+					if dir == "" {
+						return fmt.Errorf("you must pass the directory of templates")
+					}
+
+					templates, err := prompts.LoadTemplates(os.DirFS(dir))
+					if err != nil {
+						return err
+					}
+
+					count := printTemplates(templates)
+					fmt.Fprintf(os.Stderr, "%d templates are valid\n", count)
+					return nil
+				},
+			},
+		},
+	},
+}
+
+// printTemplates lists templates by agent and name, returning how many there are
+func printTemplates(templates *prompts.Templates) int {
+	count := 0
+	for _, name := range sortedKeys(templates.Shared) {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", "(shared)", name)
+		count++
+	}
+	for _, agentId := range sortedKeys(templates.Agents) {
+		for _, name := range sortedKeys(templates.Agents[agentId]) {
+			fmt.Fprintf(os.Stderr, "  %-20s %s\n", agentId, name)
+			count++
+		}
+	}
+	return count
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*
+loadPrompts loads the configured directory of prompt
+templates, if it exists
+*/
+func loadPrompts(svc *service.Service, cfg config.PromptsConfig) error {
+	if cfg.Directory == "" {
+		return nil
+	} else if _, err := os.Stat(cfg.Directory); os.IsNotExist(err) {
+		return nil
+	}
+
+	templates, err := prompts.LoadTemplates(os.DirFS(cfg.Directory))
+	if err != nil {
+		return err
+	}
+	svc.Prompts.SetDirectory(templates)
+
+	fmt.Fprintf(os.Stderr, "Loaded prompt templates from %s:\n", cfg.Directory)
+	printTemplates(templates)
+	return nil
+}
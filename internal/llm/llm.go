@@ -1,22 +1,41 @@
 package llm
 
 import (
+	"errors"
+
+	"github.com/hlfshell/coppermind/internal/prompts"
 	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
+	"github.com/hlfshell/coppermind/pkg/usage"
 )
 
+/*
+LLM is the interface for any large language model backend.
+Each call is given the agent it is made on behalf of, and
+is expected to honour the agent's LLM settings for that
+type of call.
+*/
 type LLM interface {
 	/*
 		SendMessage will send a new message in a given conversation and
-		generate a response per the identity of the agent.
+		generate a response per the identity of the agent. If the
+		toolbox can run, the model may request tool calls; these are
+		run through the toolbox and their results fed back to the
+		model until it gives a final response. Any document chunks
+		given are included as numbered sources, which the response
+		should cite by number, ie [1].
 	*/
 	SendMessage(
 		agent *agents.Agent,
 		conversation *chat.Conversation,
 		previousConversations []*memory.Summary,
 		knowledge []*memory.Knowledge,
+		documents []*documents.Chunk,
 		message *chat.Message,
+		toolbox *tools.Toolbox,
 	) (*chat.Message, error)
 
 	/*
@@ -25,6 +44,7 @@ type LLM interface {
 		or a new conversation entirely.
 	*/
 	ConversationContinuance(
+		agent *agents.Agent,
 		message *chat.Message,
 		conversation *chat.Conversation,
 		summary *memory.Summary,
@@ -37,6 +57,7 @@ type LLM interface {
 		conversations.
 	*/
 	Summarize(
+		agent *agents.Agent,
 		history *chat.Conversation,
 		previousSummary *memory.Summary,
 	) (*memory.Summary, error)
@@ -47,10 +68,23 @@ type LLM interface {
 		the agent's memory.
 	*/
 	Learn(
+		agent *agents.Agent,
 		history *chat.Conversation,
 		summary *memory.Summary,
 	) ([]*memory.Knowledge, error)
 
+	/*
+		CompressKnowledge will take the current knowledge of an
+		agent and user and identify the facts that duplicate or
+		contradict one another, so that they can be merged or
+		replaced.
+	*/
+	CompressKnowledge(
+		agent *agents.Agent,
+		user string,
+		knowledge []*memory.Knowledge,
+	) (*memory.Compression, error)
+
 	/*
 		EstimateTokens will take a string and attempt to estimate
 		the total token count. This is generally a rule of thumb
@@ -58,3 +92,82 @@ type LLM interface {
 	*/
 	EstimateTokens(text string) int
 }
+
+/*
+ErrEmbeddingsUnsupported is returned by an Embedder that
+can not currently produce embeddings, ie a router with no
+backend that supports them.
+*/
+var ErrEmbeddingsUnsupported = errors.New("embeddings are not supported")
+
+/*
+Embedder is an optional interface for LLMs that can embed
+text for retrieval. Each text is embedded separately, in
+order. If set, the LLM is expected to record the usage of
+every call to Embed.
+*/
+type Embedder interface {
+	Embed(
+		agent *agents.Agent,
+		user string,
+		conversation string,
+		texts []string,
+	) ([]documents.Embedding, error)
+}
+
+/*
+UsageRecorder is anything that can persist the token usage
+of an LLM call - generally the store.
+*/
+type UsageRecorder interface {
+	SaveUsage(usage *usage.Usage) error
+}
+
+/*
+UsageTracker is an optional interface for LLMs that can
+report the token usage of each call they make. If set,
+the LLM is expected to record a usage record for every
+call to SendMessage, ConversationContinuance, Summarize,
+Learn, and CompressKnowledge.
+*/
+type UsageTracker interface {
+	SetUsageRecorder(recorder UsageRecorder)
+}
+
+/*
+RouteRecorder is anything that can persist the routing
+decisions made by an LLM that wraps multiple backends -
+generally the store.
+*/
+type RouteRecorder interface {
+	SaveRoute(route *usage.Route) error
+}
+
+/*
+RouteTracker is an optional interface for LLMs that route
+calls across multiple backends. If set, the LLM is expected
+to record a route record for every backend it attempts.
+*/
+type RouteTracker interface {
+	SetRouteRecorder(recorder RouteRecorder)
+}
+
+/*
+TemplateSource is anything that can resolve the prompt
+template an LLM should use on behalf of an agent - generally
+the service, which allows templates to be overridden per
+agent.
+*/
+type TemplateSource interface {
+	Template(agent *agents.Agent, name string) (*prompts.Template, error)
+}
+
+/*
+TemplateUser is an optional interface for LLMs whose prompts
+are templated. If set, the LLM is expected to fill the
+template the source resolves for each call in place of its
+own.
+*/
+type TemplateUser interface {
+	SetTemplateSource(source TemplateSource)
+}
@@ -2,12 +2,22 @@ package mock
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm"
 	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
+	"github.com/hlfshell/coppermind/pkg/usage"
 )
 
+// MockModel is the model name reported in usage records
+const MockModel = "mock"
+
 type MockLLM struct {
 	sendMessageResponses []*chat.Message
 	sendMessageErrors    []error
@@ -25,7 +35,14 @@ type MockLLM struct {
 	learnErrors    []error
 	learnInputs    []interface{}
 
+	compressResponses []*memory.Compression
+	compressErrors    []error
+	compressInputs    []interface{}
+
 	charsPerToken int
+	delay         time.Duration
+
+	usageRecorder llm.UsageRecorder
 }
 
 func NewMockLLM() *MockLLM {
@@ -46,6 +63,10 @@ func NewMockLLM() *MockLLM {
 		learnErrors:    []error{},
 		learnInputs:    []interface{}{},
 
+		compressResponses: []*memory.Compression{},
+		compressErrors:    []error{},
+		compressInputs:    []interface{}{},
+
 		charsPerToken: 4,
 	}
 }
@@ -66,20 +87,65 @@ func (llm *MockLLM) ClearMemory() {
 	llm.learnResponses = [][]*memory.Knowledge{}
 	llm.learnErrors = []error{}
 	llm.learnInputs = []interface{}{}
+
+	llm.compressResponses = []*memory.Compression{}
+	llm.compressErrors = []error{}
+	llm.compressInputs = []interface{}{}
 }
 
 func (llm *MockLLM) SetCharsPerToken(charsPerToken int) {
 	llm.charsPerToken = charsPerToken
 }
 
+/*
+SetDelay will cause every call to the mock to wait for the
+given duration before responding, to simulate a slow LLM.
+*/
+func (llm *MockLLM) SetDelay(delay time.Duration) {
+	llm.delay = delay
+}
+
+func (llm *MockLLM) SetUsageRecorder(recorder llm.UsageRecorder) {
+	llm.usageRecorder = recorder
+}
+
+/*
+recordUsage mimics a real LLM's usage reporting by estimating
+the tokens of the prompt and completion content given.
+*/
+func (llm *MockLLM) recordUsage(
+	callType string,
+	agent string,
+	user string,
+	conversation string,
+	prompt string,
+	completion string,
+) {
+	if llm.usageRecorder == nil {
+		return
+	}
+
+	llm.usageRecorder.SaveUsage(&usage.Usage{
+		ID:               uuid.New().String(),
+		Agent:            agent,
+		User:             user,
+		Conversation:     conversation,
+		Type:             callType,
+		Model:            MockModel,
+		PromptTokens:     llm.EstimateTokens(prompt),
+		CompletionTokens: llm.EstimateTokens(completion),
+		CreatedAt:        time.Now(),
+	})
+}
+
 func (llm *MockLLM) AddSendMessageResponse(msg *chat.Message, err error) {
 	llm.sendMessageResponses = append(llm.sendMessageResponses, msg)
 	llm.sendMessageErrors = append(llm.sendMessageErrors, err)
 }
 
-func (llm *MockLLM) GetSendMessageInputs() (*agents.Agent, *chat.Conversation, []*memory.Summary, []*memory.Knowledge, *chat.Message) {
+func (llm *MockLLM) GetSendMessageInputs() (*agents.Agent, *chat.Conversation, []*memory.Summary, []*memory.Knowledge, []*documents.Chunk, *chat.Message, *tools.Toolbox) {
 	if len(llm.sendMessageInputs) == 0 {
-		return nil, nil, nil, nil, nil
+		return nil, nil, nil, nil, nil, nil, nil
 	}
 
 	// Pop the correct amount if tems from the sendMessageInputs and return
@@ -88,11 +154,13 @@ func (llm *MockLLM) GetSendMessageInputs() (*agents.Agent, *chat.Conversation, [
 	conversation := llm.sendMessageInputs[1].(*chat.Conversation)
 	previousConversations := llm.sendMessageInputs[2].([]*memory.Summary)
 	knowledge := llm.sendMessageInputs[3].([]*memory.Knowledge)
-	message := llm.sendMessageInputs[4].(*chat.Message)
+	sources := llm.sendMessageInputs[4].([]*documents.Chunk)
+	message := llm.sendMessageInputs[5].(*chat.Message)
+	toolbox := llm.sendMessageInputs[6].(*tools.Toolbox)
 
-	llm.sendMessageInputs = llm.sendMessageInputs[5:]
+	llm.sendMessageInputs = llm.sendMessageInputs[7:]
 
-	return agent, conversation, previousConversations, knowledge, message
+	return agent, conversation, previousConversations, knowledge, sources, message, toolbox
 }
 
 func (llm *MockLLM) AddConversationContinuanceResponse(continueConversation bool, err error) {
@@ -100,20 +168,21 @@ func (llm *MockLLM) AddConversationContinuanceResponse(continueConversation bool
 	llm.conversationContinuanceErrors = append(llm.conversationContinuanceErrors, err)
 }
 
-func (llm *MockLLM) GetConversationContinuanceInputs() (*chat.Message, *chat.Conversation, *memory.Summary) {
+func (llm *MockLLM) GetConversationContinuanceInputs() (*agents.Agent, *chat.Message, *chat.Conversation, *memory.Summary) {
 	if len(llm.conversationContinuanceInputs) == 0 {
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 
 	// Pop the correct amount if tems from the sendMessageInputs and return
 	// them typecasted to the correct type
-	message := llm.conversationContinuanceInputs[0].(*chat.Message)
-	conversation := llm.conversationContinuanceInputs[1].(*chat.Conversation)
-	summary := llm.conversationContinuanceInputs[2].(*memory.Summary)
+	agent := llm.conversationContinuanceInputs[0].(*agents.Agent)
+	message := llm.conversationContinuanceInputs[1].(*chat.Message)
+	conversation := llm.conversationContinuanceInputs[2].(*chat.Conversation)
+	summary := llm.conversationContinuanceInputs[3].(*memory.Summary)
 
-	llm.conversationContinuanceInputs = llm.conversationContinuanceInputs[3:]
+	llm.conversationContinuanceInputs = llm.conversationContinuanceInputs[4:]
 
-	return message, conversation, summary
+	return agent, message, conversation, summary
 }
 
 func (llm *MockLLM) AddSummarizeResponse(summary *memory.Summary, err error) {
@@ -121,19 +190,20 @@ func (llm *MockLLM) AddSummarizeResponse(summary *memory.Summary, err error) {
 	llm.summarizeErrors = append(llm.summarizeErrors, err)
 }
 
-func (llm *MockLLM) GetSummarizeInputs() (*chat.Conversation, *memory.Summary) {
+func (llm *MockLLM) GetSummarizeInputs() (*agents.Agent, *chat.Conversation, *memory.Summary) {
 	if len(llm.summarizeInputs) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	// Pop the correct amount if tems from the sendMessageInputs and return
 	// them typecasted to the correct type
-	history := llm.summarizeInputs[0].(*chat.Conversation)
-	summary := llm.summarizeInputs[1].(*memory.Summary)
+	agent := llm.summarizeInputs[0].(*agents.Agent)
+	history := llm.summarizeInputs[1].(*chat.Conversation)
+	summary := llm.summarizeInputs[2].(*memory.Summary)
 
-	llm.summarizeInputs = llm.summarizeInputs[2:]
+	llm.summarizeInputs = llm.summarizeInputs[3:]
 
-	return history, summary
+	return agent, history, summary
 }
 
 func (llm *MockLLM) AddLearnResponse(knowledge []*memory.Knowledge, err error) {
@@ -141,19 +211,39 @@ func (llm *MockLLM) AddLearnResponse(knowledge []*memory.Knowledge, err error) {
 	llm.learnErrors = append(llm.learnErrors, err)
 }
 
-func (llm *MockLLM) GetLearnInputs() (*chat.Conversation, *memory.Summary) {
+func (llm *MockLLM) GetLearnInputs() (*agents.Agent, *chat.Conversation, *memory.Summary) {
 	if len(llm.learnInputs) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	// Pop the correct amount if tems from the sendMessageInputs and return
 	// them typecasted to the correct type
-	history := llm.learnInputs[0].(*chat.Conversation)
-	summary := llm.learnInputs[1].(*memory.Summary)
+	agent := llm.learnInputs[0].(*agents.Agent)
+	history := llm.learnInputs[1].(*chat.Conversation)
+	summary := llm.learnInputs[2].(*memory.Summary)
 
-	llm.learnInputs = llm.learnInputs[2:]
+	llm.learnInputs = llm.learnInputs[3:]
 
-	return history, summary
+	return agent, history, summary
+}
+
+func (llm *MockLLM) AddCompressKnowledgeResponse(compression *memory.Compression, err error) {
+	llm.compressResponses = append(llm.compressResponses, compression)
+	llm.compressErrors = append(llm.compressErrors, err)
+}
+
+func (llm *MockLLM) GetCompressKnowledgeInputs() (*agents.Agent, string, []*memory.Knowledge) {
+	if len(llm.compressInputs) == 0 {
+		return nil, "", nil
+	}
+
+	agent := llm.compressInputs[0].(*agents.Agent)
+	user := llm.compressInputs[1].(string)
+	knowledge := llm.compressInputs[2].([]*memory.Knowledge)
+
+	llm.compressInputs = llm.compressInputs[3:]
+
+	return agent, user, knowledge
 }
 
 func (llm *MockLLM) SendMessage(
@@ -161,33 +251,64 @@ func (llm *MockLLM) SendMessage(
 	conversation *chat.Conversation,
 	previousConversations []*memory.Summary,
 	knowledge []*memory.Knowledge,
+	sources []*documents.Chunk,
 	message *chat.Message,
+	toolbox *tools.Toolbox,
 ) (*chat.Message, error) {
+	time.Sleep(llm.delay)
+
 	if len(llm.sendMessageResponses) == 0 {
 		return nil, fmt.Errorf("no mocked responses included")
 	}
 
-	llm.sendMessageInputs = append(llm.sendMessageInputs, agent, conversation, previousConversations, knowledge, message)
+	llm.sendMessageInputs = append(llm.sendMessageInputs, agent, conversation, previousConversations, knowledge, sources, message, toolbox)
 
+	for {
+		response, err := llm.popSendMessageResponse(agent, message)
+
+		// A mocked response with tool calls is treated as the
+		// model requesting them; they are run and the next mocked
+		// response is used as the model's reply.
+		if err != nil || response == nil || len(response.ToolCalls) == 0 || !toolbox.CanRun() {
+			return response, err
+		}
+		toolbox.Run(response.ToolCalls)
+
+		if len(llm.sendMessageResponses) == 0 {
+			return nil, fmt.Errorf("no mocked responses included")
+		}
+	}
+}
+
+func (llm *MockLLM) popSendMessageResponse(agent *agents.Agent, message *chat.Message) (*chat.Message, error) {
 	response := llm.sendMessageResponses[0]
 	llm.sendMessageResponses = llm.sendMessageResponses[1:]
 
 	err := llm.sendMessageErrors[0]
 	llm.sendMessageErrors = llm.sendMessageErrors[1:]
 
+	completion := ""
+	if response != nil {
+		completion = response.Content
+	}
+	llm.recordUsage(usage.CallChat, agent.ID, message.User, message.Conversation, message.Content, completion)
+
 	return response, err
 }
 
 func (llm *MockLLM) ConversationContinuance(
+	agent *agents.Agent,
 	message *chat.Message,
 	conversation *chat.Conversation,
 	summary *memory.Summary,
 ) (bool, error) {
+	time.Sleep(llm.delay)
+
 	if len(llm.conversationContinuanceResponses) == 0 {
 		return false, fmt.Errorf("no mocked responses included")
 	}
 
-	llm.conversationContinuanceInputs = append(llm.conversationContinuanceInputs, message, conversation, summary)
+	llm.conversationContinuanceInputs = append(llm.conversationContinuanceInputs, agent, message, conversation, summary)
 
 	response := llm.conversationContinuanceResponses[0]
 	llm.conversationContinuanceResponses = llm.conversationContinuanceResponses[1:]
@@ -195,18 +316,23 @@ func (llm *MockLLM) ConversationContinuance(
 	err := llm.conversationContinuanceErrors[0]
 	llm.conversationContinuanceErrors = llm.conversationContinuanceErrors[1:]
 
+	llm.recordUsage(usage.CallContinuance, message.Agent, message.User, conversation.ID, message.Content, fmt.Sprint(response))
+
 	return response, err
 }
 
 func (llm *MockLLM) Summarize(
+	agent *agents.Agent,
 	history *chat.Conversation,
 	previousSummary *memory.Summary,
 ) (*memory.Summary, error) {
+	time.Sleep(llm.delay)
+
 	if len(llm.summarizeResponses) == 0 {
 		return nil, fmt.Errorf("no mocked responses included")
 	}
 
-	llm.summarizeInputs = append(llm.summarizeInputs, history, previousSummary)
+	llm.summarizeInputs = append(llm.summarizeInputs, agent, history, previousSummary)
 
 	response := llm.summarizeResponses[0]
 	llm.summarizeResponses = llm.summarizeResponses[1:]
@@ -214,18 +340,27 @@ func (llm *MockLLM) Summarize(
 	err := llm.summarizeErrors[0]
 	llm.summarizeErrors = llm.summarizeErrors[1:]
 
+	completion := ""
+	if response != nil {
+		completion = response.Summary
+	}
+	llm.recordUsage(usage.CallSummarize, history.Agent, history.User, history.ID, conversationContent(history), completion)
+
 	return response, err
 }
 
 func (llm *MockLLM) Learn(
+	agent *agents.Agent,
 	history *chat.Conversation,
 	summary *memory.Summary,
 ) ([]*memory.Knowledge, error) {
+	time.Sleep(llm.delay)
+
 	if len(llm.learnResponses) == 0 {
 		return nil, fmt.Errorf("no mocked responses included")
 	}
 
-	llm.learnInputs = append(llm.learnInputs, history, summary)
+	llm.learnInputs = append(llm.learnInputs, agent, history, summary)
 
 	response := llm.learnResponses[0]
 	llm.learnResponses = llm.learnResponses[1:]
@@ -233,9 +368,68 @@ func (llm *MockLLM) Learn(
 	err := llm.learnErrors[0]
 	llm.learnErrors = llm.learnErrors[1:]
 
+	completion := ""
+	for _, fact := range response {
+		completion += fact.String()
+	}
+	llm.recordUsage(usage.CallLearn, history.Agent, history.User, history.ID, conversationContent(history), completion)
+
+	return response, err
+}
+
+func (llm *MockLLM) CompressKnowledge(
+	agent *agents.Agent,
+	user string,
+	knowledge []*memory.Knowledge,
+) (*memory.Compression, error) {
+	time.Sleep(llm.delay)
+
+	if len(llm.compressResponses) == 0 {
+		return nil, fmt.Errorf("no mocked responses included")
+	}
+
+	llm.compressInputs = append(llm.compressInputs, agent, user, knowledge)
+
+	response := llm.compressResponses[0]
+	llm.compressResponses = llm.compressResponses[1:]
+
+	err := llm.compressErrors[0]
+	llm.compressErrors = llm.compressErrors[1:]
+
+	prompt := ""
+	for _, fact := range knowledge {
+		prompt += fact.String() + "\n"
+	}
+	llm.recordUsage(usage.CallCompress, agent.ID, user, "", prompt, "")
+
 	return response, err
 }
 
+/*
+Embed uses documents.HashEmbedder, so that embeddings are
+deterministic and need no mocked responses.
+*/
+func (llm *MockLLM) Embed(
+	agent *agents.Agent,
+	user string,
+	conversation string,
+	texts []string,
+) ([]documents.Embedding, error) {
+	time.Sleep(llm.delay)
+
+	llm.recordUsage(usage.CallEmbed, agent.ID, user, conversation, strings.Join(texts, "\n"), "")
+
+	return documents.NewHashEmbedder().Embed(texts), nil
+}
+
 func (llm *MockLLM) EstimateTokens(input string) int {
 	return len(input) / llm.charsPerToken
 }
+
+func conversationContent(conversation *chat.Conversation) string {
+	content := ""
+	for _, msg := range conversation.Messages {
+		content += msg.SimpleString() + "\n"
+	}
+	return content
+}
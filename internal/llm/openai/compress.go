@@ -0,0 +1,72 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/sashabaranov/go-openai"
+)
+
+func (ai *OpenAI) CompressKnowledge(
+	agent *agents.Agent,
+	user string,
+	knowledge []*memory.Knowledge,
+) (*memory.Compression, error) {
+	instructions, err := ai.instructions(agent, prompts.TemplateCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	data := ai.prepareCompressionMessage(instructions, knowledge)
+
+	var compression *memory.Compression
+	err = ai.completeStructured(
+		usage.CallCompress,
+		agent.LLM.KnowledgeProfile(),
+		agent.ID,
+		user,
+		"",
+		data,
+		compressionFunction,
+		func(raw string) error {
+			var err error
+			compression, err = memory.ParseCompressionResponse(raw, knowledge)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return compression, nil
+}
+
+func (ai *OpenAI) prepareCompressionMessage(
+	instructions string,
+	knowledge []*memory.Knowledge,
+) []openai.ChatCompletionMessage {
+	content := strings.Builder{}
+
+	content.WriteString(instructions)
+	content.WriteString("\n")
+
+	for index, fact := range knowledge {
+		content.WriteString(fmt.Sprintf(
+			"%d - %s (learned %s)\n",
+			index,
+			fact.String(),
+			fact.CreatedAt.Format("Jan 2 2006"),
+		))
+	}
+
+	content.WriteString("Output:\n")
+
+	return []openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: content.String(),
+	}}
+}
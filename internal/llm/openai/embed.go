@@ -0,0 +1,63 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/sashabaranov/go-openai"
+)
+
+// embeddingModel is the model used to embed text
+const embeddingModel = openai.AdaEmbeddingV2
+
+func (ai *OpenAI) Embed(
+	agent *agents.Agent,
+	user string,
+	conversation string,
+	texts []string,
+) ([]documents.Embedding, error) {
+	if len(texts) == 0 {
+		return []documents.Embedding{}, nil
+	}
+
+	resp, err := ai.client.CreateEmbeddings(
+		context.Background(),
+		openai.EmbeddingRequest{
+			Input: texts,
+			Model: embeddingModel,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ai.recordUsage(
+		usage.CallEmbed,
+		embeddingModel.String(),
+		agent.ID,
+		user,
+		conversation,
+		resp.Usage,
+	)
+
+	if len(resp.Data) != len(texts) {
+		return nil, OpenAIResponseError{msg: "Embeddings were not returned for every input"}
+	}
+
+	// Embeddings are returned with the index of their input,
+	// which may not be their order
+	embeddings := make([]documents.Embedding, len(texts))
+	for _, data := range resp.Data {
+		if data.Index < 0 || data.Index >= len(texts) {
+			return nil, OpenAIResponseError{msg: "Embedding returned for an unknown input"}
+		}
+		embeddings[data.Index] = documents.Embedding{
+			Model:  embeddingModel.String(),
+			Vector: data.Embedding,
+		}
+	}
+
+	return embeddings, nil
+}
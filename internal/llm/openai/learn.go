@@ -1,48 +1,53 @@
 package openai
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
 	"strings"
 
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/chat"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/usage"
 	"github.com/sashabaranov/go-openai"
 )
 
 func (ai *OpenAI) Learn(
+	agent *agents.Agent,
 	history *chat.Conversation,
 	summary *memory.Summary,
 ) ([]*memory.Knowledge, error) {
+	instructions, err := ai.instructions(agent, prompts.TemplateKnowledge)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := ai.prepareLearnMessage(
-		ai.knowledgePrompt,
+		instructions,
 		history,
 		summary,
 	)
-	fmt.Println("prepped")
-	// fmt.Println(data)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:    openai.GPT3Dot5Turbo,
-			Messages: data,
+	var facts []*memory.Knowledge
+	err = ai.completeStructured(
+		usage.CallLearn,
+		agent.LLM.KnowledgeProfile(),
+		history.Agent,
+		history.User,
+		history.ID,
+		data,
+		knowledgeFunction,
+		func(raw string) error {
+			facts, err = ai.parseLearnResponse(history, raw)
+			return err
 		},
 	)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Knowledge extacted", resp.Usage)
-	fmt.Println("resp", resp.Choices[0].Message.Content)
-	facts, err := ai.parseLearnResponse(history, resp.Choices[0].Message.Content)
-	if err != nil {
-		return nil, err
-	}
-	fmt.Println("facts", facts)
+
 	return facts, nil
 }
 
@@ -79,20 +84,26 @@ func (ai *OpenAI) parseLearnResponse(
 	conversation *chat.Conversation,
 	raw string,
 ) ([]*memory.Knowledge, error) {
-	var responses []*memory.LearnResponse
-
-	err := json.Unmarshal([]byte(raw), &responses)
+	responses, err := memory.ParseKnowledgeResponse(raw)
 	if err != nil {
 		return nil, err
 	}
-	derivedFacts := []*memory.Knowledge{}
 
+	// Knowledge is extracted from the last line of the
+	// conversation, so that is where each fact came from
+	sources := []string{}
+	if len(conversation.Messages) > 0 {
+		sources = append(sources, conversation.Messages[len(conversation.Messages)-1].ID)
+	}
+
+	derivedFacts := []*memory.Knowledge{}
 	for _, response := range responses {
 		fact, err := memory.ToKnowledge(
 			response,
 			conversation.Agent,
 			conversation.User,
 			conversation.ID,
+			sources,
 		)
 		if err != nil {
 			return nil, err
@@ -100,5 +111,5 @@ func (ai *OpenAI) parseLearnResponse(
 		derivedFacts = append(derivedFacts, fact)
 	}
 
-	return derivedFacts, err
+	return derivedFacts, nil
 }
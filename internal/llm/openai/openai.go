@@ -1,7 +1,14 @@
 package openai
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm"
 	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/usage"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -11,11 +18,16 @@ type OpenAI struct {
 	tokenMax int
 	maxInput int
 
-	// Prompts
-	chatPrompt                      string
-	conversationalContinuancePrompt string
-	summaryPrompt                   string
-	knowledgePrompt                 string
+	// How many times to ask the LLM to correct
+	// unparseable structured output
+	maxCorrections int
+
+	// Where prompt templates are resolved from; the
+	// bundled templates are used if nil
+	templates llm.TemplateSource
+
+	// Usage tracking; nil if not tracking
+	usageRecorder llm.UsageRecorder
 }
 
 func NewOpenAI(apiKey string) *OpenAI {
@@ -25,10 +37,7 @@ func NewOpenAI(apiKey string) *OpenAI {
 		tokenMax: 4025,
 		maxInput: 2800,
 
-		chatPrompt:                      prompts.Instructions,
-		conversationalContinuancePrompt: prompts.ConversationContinuance,
-		summaryPrompt:                   prompts.Summary,
-		knowledgePrompt:                 prompts.Knowledge,
+		maxCorrections: 2,
 	}
 }
 
@@ -36,6 +45,101 @@ func (ai *OpenAI) EstimateTokens(text string) int {
 	return int(len(text) / 4)
 }
 
+func (ai *OpenAI) SetUsageRecorder(recorder llm.UsageRecorder) {
+	ai.usageRecorder = recorder
+}
+
+func (ai *OpenAI) SetTemplateSource(source llm.TemplateSource) {
+	ai.templates = source
+}
+
+/*
+template returns the prompt template of the given name to
+use for an agent
+*/
+func (ai *OpenAI) template(agent *agents.Agent, name string) (*prompts.Template, error) {
+	if ai.templates == nil {
+		return prompts.DefaultTemplate(name), nil
+	}
+	return ai.templates.Template(agent, name)
+}
+
+/*
+instructions fills the template of the given name that has
+no placeholders, as the summary, knowledge, and compression
+templates are, for an agent
+*/
+func (ai *OpenAI) instructions(agent *agents.Agent, name string) (string, error) {
+	tmpl, err := ai.template(agent, name)
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Execute(nil)
+}
+
+/*
+recordUsage will save the token usage of a given call
+if a usage recorder is set. Failing to record usage is
+not considered fatal to the call itself, so errors are
+only reported.
+*/
+func (ai *OpenAI) recordUsage(
+	callType string,
+	model string,
+	agent string,
+	user string,
+	conversation string,
+	tokens openai.Usage,
+) {
+	if ai.usageRecorder == nil {
+		return
+	}
+
+	err := ai.usageRecorder.SaveUsage(&usage.Usage{
+		ID:               uuid.New().String(),
+		Agent:            agent,
+		User:             user,
+		Conversation:     conversation,
+		Type:             callType,
+		Model:            model,
+		PromptTokens:     tokens.PromptTokens,
+		CompletionTokens: tokens.CompletionTokens,
+		CreatedAt:        time.Now(),
+	})
+	if err != nil {
+		fmt.Println("Unable to record usage", err)
+	}
+}
+
+// defaultModel is used when an agent's profile sets no model
+const defaultModel = openai.GPT3Dot5Turbo
+
+/*
+newRequest builds a chat completion request for the given
+messages, applying the model and generation settings of
+the given agent profile.
+*/
+func (ai *OpenAI) newRequest(
+	profile agents.Profile,
+	messages []openai.ChatCompletionMessage,
+) openai.ChatCompletionRequest {
+	model := profile.Model
+	if model == "" {
+		model = defaultModel
+	}
+
+	return openai.ChatCompletionRequest{
+		Model:            model,
+		Messages:         messages,
+		Temperature:      profile.Temperature,
+		TopP:             profile.TopP,
+		MaxTokens:        profile.MaxTokens,
+		Stop:             profile.Stop,
+		PresencePenalty:  profile.PresencePenalty,
+		FrequencyPenalty: profile.FrequencyPenalty,
+	}
+}
+
 type OpenAIResponseError struct {
 	msg string
 }
@@ -2,19 +2,20 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
 
-	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/prompts"
 	"github.com/hlfshell/coppermind/internal/utils"
 	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
+	"github.com/hlfshell/coppermind/pkg/usage"
 	"github.com/sashabaranov/go-openai"
-	"github.com/wissance/stringFormatter"
 )
 
 func (ai *OpenAI) SendMessage(
@@ -22,60 +23,123 @@ func (ai *OpenAI) SendMessage(
 	conversation *chat.Conversation,
 	previousConversations []*memory.Summary,
 	knowledge []*memory.Knowledge,
+	sources []*documents.Chunk,
 	message *chat.Message,
+	toolbox *tools.Toolbox,
 ) (*chat.Message, error) {
+	prompt, err := ai.template(agent, prompts.TemplateChat)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := ai.prepareChatMessage(
-		ai.chatPrompt,
+		prompt,
 		agent.Identity,
 		conversation.Messages,
 		previousConversations,
 		knowledge,
+		sources,
 		message,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:    openai.GPT3Dot5Turbo,
-			Messages: []openai.ChatCompletionMessage{data},
-		},
-	)
+	messages := []openai.ChatCompletionMessage{data}
 
-	if err != nil {
-		return nil, err
-	} else if len(resp.Choices) < 1 {
-		return nil, OpenAIResponseError{msg: "No proper response returned"}
-	}
-
-	fmt.Println("Token usage", resp.Usage)
-	fmt.Println(resp.Choices[0].Message.Content)
-
-	return &chat.Message{
-		ID:           uuid.New().String(),
-		Agent:        agent.Name,
-		User:         message.User,
-		From:         agent.ID,
-		Conversation: message.Conversation,
-		CreatedAt:    time.Now(),
-		Content:      utils.FilterNamePrepend(agent.Name, resp.Choices[0].Message.Content),
-		Artifacts:    []*artifacts.ArtifactData{},
-	}, nil
+	// Each pass either ends with the model's response, or with
+	// the tool calls it requested being run and their results
+	// added to the messages for the next pass. Once the
+	// toolbox can no longer run, tools are no longer offered
+	// and the model must respond.
+	for {
+		request := ai.newRequest(agent.LLM.ChatProfile(), messages)
+		if toolbox.CanRun() {
+			request.Tools = openAITools(toolbox.Tools())
+		}
+
+		resp, err := ai.client.CreateChatCompletion(context.Background(), request)
+		if err != nil {
+			return nil, err
+		}
+
+		ai.recordUsage(
+			usage.CallChat,
+			request.Model,
+			agent.ID,
+			message.User,
+			message.Conversation,
+			resp.Usage,
+		)
+
+		if len(resp.Choices) < 1 {
+			return nil, OpenAIResponseError{msg: "No proper response returned"}
+		}
+		reply := resp.Choices[0].Message
+
+		// Any links the model cites in its response are
+		// attached to it as artifacts
+		if len(reply.ToolCalls) == 0 || len(request.Tools) == 0 {
+			content := utils.FilterNamePrepend(agent.Name, reply.Content)
+			response := &chat.Response{
+				Content:   content,
+				Artifacts: artifacts.ExtractLinks(content),
+			}
+			return response.ToMessage(message.User, agent.ID, message.Conversation), nil
+		}
+
+		calls := []*chat.ToolCall{}
+		for _, toolCall := range reply.ToolCalls {
+			calls = append(calls, &chat.ToolCall{
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: json.RawMessage(toolCall.Function.Arguments),
+			})
+		}
+		toolbox.Run(calls)
+
+		messages = append(messages, reply)
+		for _, call := range calls {
+			content := call.Result
+			if call.Error != "" {
+				content = "error: " + call.Error
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    content,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+func openAITools(available []tools.Tool) []openai.Tool {
+	converted := []openai.Tool{}
+	for _, tool := range available {
+		converted = append(converted, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  tool.Schema(),
+			},
+		})
+	}
+	return converted
 }
 
 func (ai *OpenAI) prepareChatMessage(
-	prompt string,
+	prompt *prompts.Template,
 	identity string,
 	history []*chat.Message,
 	previousConversations []*memory.Summary,
 	knowledge []*memory.Knowledge,
+	sources []*documents.Chunk,
 	message *chat.Message,
 ) (openai.ChatCompletionMessage, error) {
 	var tokenCount int
 
-	tokenCount += ai.EstimateTokens(prompt)
+	tokenCount += ai.EstimateTokens(prompt.Text)
 
 	tokenCount += ai.EstimateTokens(identity)
 
@@ -118,6 +182,16 @@ func (ai *OpenAI) prepareChatMessage(
 		tokenCount += ai.EstimateTokens(knowledgeString)
 	}
 
+	var documentsString string
+	if len(sources) > 0 {
+		documentsString = prompts.Documents
+		for index, chunk := range sources {
+			documentsString += fmt.Sprintf("\n[%d] %s:\n%s\n", index+1, chunk.Source(), chunk.Content)
+		}
+
+		tokenCount += ai.EstimateTokens(documentsString)
+	}
+
 	var messagesHistory string
 	if len(history) > 0 {
 		messagesHistory = `The following is the message log, where it shares when the message occured, who is talking, and the message itself, delimited by the "|" character.`
@@ -131,35 +205,41 @@ func (ai *OpenAI) prepareChatMessage(
 
 	tokenCount += ai.EstimateTokens(message.DatedString() + "\n")
 
-	prompt = stringFormatter.FormatComplex(
-		prompt,
-		map[string]interface{}{
+	content, err := prompt.Execute(
+		map[string]string{
 			"name":             message.Agent,
 			"identity":         identity,
 			"summaries":        summariesString,
 			"knowledge":        knowledgeString,
+			"documents":        documentsString,
 			"previous_summary": previousSummaryString,
 			"message_history":  messagesHistory,
 			"message":          message.DatedString() + "\n",
 		},
 	)
-
-	fmt.Println("output", prompt)
-	fmt.Println("estimated tokens", tokenCount)
+	if err != nil {
+		return openai.ChatCompletionMessage{}, err
+	}
 
 	return openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleSystem,
-		Content: prompt,
+		Content: content,
 	}, nil
 }
 
 func (ai *OpenAI) ConversationContinuance(
+	agent *agents.Agent,
 	msg *chat.Message,
 	conversation *chat.Conversation,
 	summary *memory.Summary,
 ) (bool, error) {
+	instructions, err := ai.template(agent, prompts.TemplateContinuance)
+	if err != nil {
+		return false, err
+	}
+
 	data, err := ai.prepareConversationContinuanceMessage(
-		prompts.ConversationContinuance,
+		instructions,
 		msg,
 		conversation,
 		summary,
@@ -167,38 +247,44 @@ func (ai *OpenAI) ConversationContinuance(
 	if err != nil {
 		return false, err
 	}
-	fmt.Println("Continuance prompt")
-	fmt.Println(data)
-
-	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: openai.GPT3Dot5Turbo,
-			Messages: []openai.ChatCompletionMessage{{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: data,
-			}},
-		},
+
+	request := ai.newRequest(
+		agent.LLM.ChatProfile(),
+		[]openai.ChatCompletionMessage{{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: data,
+		}},
 	)
+
+	resp, err := ai.client.CreateChatCompletion(context.Background(), request)
 	if err != nil {
 		return false, err
 	}
-	fmt.Println("continuance test", resp)
+
+	ai.recordUsage(
+		usage.CallContinuance,
+		request.Model,
+		agent.ID,
+		msg.User,
+		conversation.ID,
+		resp.Usage,
+	)
+
+	if len(resp.Choices) < 1 {
+		return false, OpenAIResponseError{msg: "No proper response returned"}
+	}
 	return ai.parseConversationContinuanceResponse(resp.Choices[0].Message.Content), nil
 }
 
 func (ai *OpenAI) prepareConversationContinuanceMessage(
-	instructions string,
+	instructions *prompts.Template,
 	msg *chat.Message,
 	conversation *chat.Conversation,
 	summary *memory.Summary,
 ) (string, error) {
 	tokenCount := 0
 
-	var output string
-
-	output += instructions
-	tokenCount += ai.EstimateTokens(output)
+	tokenCount += ai.EstimateTokens(instructions.Text)
 
 	var summaryText string
 	if summary != nil {
@@ -224,16 +310,13 @@ func (ai *OpenAI) prepareConversationContinuanceMessage(
 		messageContent = fmt.Sprintf("%s%s", content, messageContent)
 	}
 
-	output = stringFormatter.FormatComplex(
-		output,
-		map[string]interface{}{
+	return instructions.Execute(
+		map[string]string{
 			"summary":         summaryText,
 			"message_history": messageContent,
 			"new_message":     msg.SimpleString(),
 		},
 	)
-
-	return output, nil
 }
 
 func (ai *OpenAI) parseConversationContinuanceResponse(raw string) bool {
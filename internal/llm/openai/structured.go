@@ -0,0 +1,165 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/wissance/stringFormatter"
+)
+
+var summaryFunction = openai.FunctionDefinition{
+	Name:        "record_summary",
+	Description: "Record the keywords and brief summary of a conversation",
+	Parameters: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"keywords": {
+				Type:        jsonschema.Array,
+				Description: "Two or three short keywords categorizing the conversation",
+				Items:       &jsonschema.Definition{Type: jsonschema.String},
+			},
+			"summary": {
+				Type:        jsonschema.String,
+				Description: `A single short sentence summarizing the conversation, or "none"`,
+			},
+		},
+		Required: []string{"keywords", "summary"},
+	},
+}
+
+var knowledgeFunction = openai.FunctionDefinition{
+	Name:        "record_knowledge",
+	Description: "Record the knowledge sets extracted from a conversation",
+	Parameters: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"knowledge": {
+				Type: jsonschema.Array,
+				Items: &jsonschema.Definition{
+					Type: jsonschema.Object,
+					Properties: map[string]jsonschema.Definition{
+						"subject":   {Type: jsonschema.String},
+						"predicate": {Type: jsonschema.String},
+						"object":    {Type: jsonschema.String},
+						"expires": {
+							Type:        jsonschema.String,
+							Description: `A number and unit of time, ie "3 days", or "never"`,
+						},
+						"confidence": {
+							Type:        jsonschema.Number,
+							Description: "How certain it is that the knowledge set is true, from 0 to 1",
+						},
+					},
+					Required: []string{"subject", "predicate", "object", "expires"},
+				},
+			},
+		},
+		Required: []string{"knowledge"},
+	},
+}
+
+var compressionFunction = openai.FunctionDefinition{
+	Name:        "record_compression",
+	Description: "Record the groups of facts that duplicate or contradict one another",
+	Parameters: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"duplicates": {
+				Type:        jsonschema.Array,
+				Description: "Groups of fact numbers conveying the same information, the best expression first",
+				Items: &jsonschema.Definition{
+					Type:  jsonschema.Array,
+					Items: &jsonschema.Definition{Type: jsonschema.Integer},
+				},
+			},
+			"contradictions": {
+				Type:        jsonschema.Array,
+				Description: "Groups of fact numbers that can not all be true, the one true now first",
+				Items: &jsonschema.Definition{
+					Type:  jsonschema.Array,
+					Items: &jsonschema.Definition{Type: jsonschema.Integer},
+				},
+			},
+		},
+		Required: []string{"duplicates", "contradictions"},
+	},
+}
+
+/*
+completeStructured requests a completion that is forced to
+call the given function, and hands the function's arguments
+to parse. If parse fails, the LLM is shown the error and
+asked to correct its output, up to ai.maxCorrections times.
+Every attempt's usage is recorded.
+*/
+func (ai *OpenAI) completeStructured(
+	callType string,
+	profile agents.Profile,
+	agent string,
+	user string,
+	conversation string,
+	messages []openai.ChatCompletionMessage,
+	function openai.FunctionDefinition,
+	parse func(raw string) error,
+) error {
+	var err error
+
+	for attempt := 0; attempt <= ai.maxCorrections; attempt++ {
+		request := ai.newRequest(profile, messages)
+		request.Tools = []openai.Tool{{
+			Type:     openai.ToolTypeFunction,
+			Function: function,
+		}}
+		request.ToolChoice = openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: function.Name},
+		}
+
+		resp, requestErr := ai.client.CreateChatCompletion(context.Background(), request)
+		if requestErr != nil {
+			return requestErr
+		}
+
+		ai.recordUsage(callType, request.Model, agent, user, conversation, resp.Usage)
+
+		if len(resp.Choices) < 1 {
+			return OpenAIResponseError{msg: "No proper response returned"}
+		}
+
+		// Models that ignore the tool choice respond in
+		// the message content instead
+		message := resp.Choices[0].Message
+		raw := message.Content
+		if len(message.ToolCalls) > 0 {
+			raw = message.ToolCalls[0].Function.Arguments
+		}
+
+		err = parse(raw)
+		if err == nil {
+			return nil
+		}
+
+		messages = append(
+			messages,
+			openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: raw,
+			},
+			openai.ChatCompletionMessage{
+				Role: openai.ChatMessageRoleSystem,
+				Content: stringFormatter.FormatComplex(
+					prompts.Correction,
+					map[string]interface{}{"error": err.Error()},
+				),
+			},
+		)
+	}
+
+	return OpenAIResponseError{
+		msg: fmt.Sprintf("unable to parse %s response: %s", callType, err),
+	}
+}
@@ -1,49 +1,51 @@
 package openai
 
 import (
-	"context"
-	"fmt"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/chat"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/usage"
 	"github.com/sashabaranov/go-openai"
 	"github.com/wissance/stringFormatter"
 )
 
 func (ai *OpenAI) Summarize(
+	agent *agents.Agent,
 	conversation *chat.Conversation,
 	previousSummary *memory.Summary,
 ) (*memory.Summary, error) {
-	data, lastMessage, err := ai.prepareSummaryMessage(ai.summaryPrompt, conversation, previousSummary)
-	fmt.Println("prepped")
-	fmt.Println(data)
+	instructions, err := ai.instructions(agent, prompts.TemplateSummary)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model:    openai.GPT3Dot5Turbo,
-			Messages: data,
-		},
-	)
-
+	data, lastMessage, err := ai.prepareSummaryMessage(instructions, conversation, previousSummary)
 	if err != nil {
 		return nil, err
-	} else if len(resp.Choices) < 1 {
-		return nil, OpenAIResponseError{msg: "No proper response returned"}
 	}
 
-	fmt.Println(resp.Usage)
-
-	summary, err := ai.parseSummaryResponse(conversation, resp.Choices[0].Message.Content)
+	var summary *memory.Summary
+	err = ai.completeStructured(
+		usage.CallSummarize,
+		agent.LLM.SummaryProfile(),
+		conversation.Agent,
+		conversation.User,
+		conversation.ID,
+		data,
+		summaryFunction,
+		func(raw string) error {
+			summary, err = ai.parseSummaryResponse(conversation, raw)
+			return err
+		},
+	)
 	if err != nil {
 		return nil, err
+	} else if summary == nil {
+		return nil, nil
 	} else if lastMessage.ID != conversation.Messages[len(conversation.Messages)-1].ID {
 		// If our lastMessage is NOT the last message in the conversation,
 		// then we were cut short due to the token limit. We need to update
@@ -113,24 +115,20 @@ func (ai *OpenAI) prepareSummaryMessage(
 }
 
 func (ai *OpenAI) parseSummaryResponse(conversation *chat.Conversation, raw string) (*memory.Summary, error) {
-	split := strings.Split(raw, "|")
-	//Validate and protect this
-	split[0] = strings.TrimSpace(split[0])
-	split[1] = strings.TrimSpace(split[1])
-
-	if split[0] == "none" {
+	response, err := memory.ParseSummaryResponse(raw)
+	if err != nil {
+		return nil, err
+	} else if response == nil {
 		return nil, nil
 	}
 
-	summary := &memory.Summary{
+	return &memory.Summary{
 		ID:           uuid.New().String(),
 		Agent:        conversation.Agent,
 		Conversation: conversation.ID,
-		Summary:      split[1],
+		Keywords:     response.Keywords,
+		Summary:      response.Summary,
 		User:         conversation.User,
 		UpdatedAt:    time.Now(),
-	}
-
-	summary.StringToKeywords(split[0])
-	return summary, nil
+	}, nil
 }
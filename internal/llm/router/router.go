@@ -0,0 +1,378 @@
+package router
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
+	"github.com/hlfshell/coppermind/pkg/usage"
+)
+
+// DefaultRoute is used for any call type without its own route
+const DefaultRoute = "default"
+
+/*
+Route is the ordered list of backends a type of call is
+sent to. The first backend is the primary; each following
+backend is tried in turn if the prior one errors or takes
+longer than Timeout. A Timeout of 0 waits indefinitely.
+*/
+type Route struct {
+	Backends []string
+	Timeout  time.Duration
+}
+
+type TimeoutError struct {
+	Backend string
+	Timeout time.Duration
+}
+
+func (err *TimeoutError) Error() string {
+	return fmt.Sprintf("backend %s timed out after %s", err.Backend, err.Timeout)
+}
+
+/*
+Router is an LLM that wraps several named backends and
+routes each call by its type (see usage.Call*), falling
+back across backends on failure. If an agent's profile for
+a call names a Provider that is a known backend, it is
+tried first.
+*/
+type Router struct {
+	backends map[string]llm.LLM
+	routes   map[string]Route
+
+	// Route tracking; nil if not tracking
+	routeRecorder llm.RouteRecorder
+}
+
+func NewRouter(backends map[string]llm.LLM, routes map[string]Route) (*Router, error) {
+	if _, ok := routes[DefaultRoute]; !ok {
+		return nil, fmt.Errorf("a %s route must be set", DefaultRoute)
+	}
+
+	for callType, route := range routes {
+		if len(route.Backends) == 0 {
+			return nil, fmt.Errorf("route %s has no backends", callType)
+		}
+		for _, backend := range route.Backends {
+			if _, ok := backends[backend]; !ok {
+				return nil, fmt.Errorf("route %s uses unknown backend %s", callType, backend)
+			}
+		}
+	}
+
+	return &Router{
+		backends: backends,
+		routes:   routes,
+	}, nil
+}
+
+func (router *Router) SetRouteRecorder(recorder llm.RouteRecorder) {
+	router.routeRecorder = recorder
+}
+
+/*
+SetUsageRecorder passes the recorder on to every backend
+that tracks its usage.
+*/
+func (router *Router) SetUsageRecorder(recorder llm.UsageRecorder) {
+	for _, backend := range router.backends {
+		if tracker, ok := backend.(llm.UsageTracker); ok {
+			tracker.SetUsageRecorder(recorder)
+		}
+	}
+}
+
+/*
+SetTemplateSource passes the source on to every backend
+whose prompts are templated.
+*/
+func (router *Router) SetTemplateSource(source llm.TemplateSource) {
+	for _, backend := range router.backends {
+		if user, ok := backend.(llm.TemplateUser); ok {
+			user.SetTemplateSource(source)
+		}
+	}
+}
+
+/*
+route returns the route for a given call type, with the
+agent's preferred provider (if any) moved to the front.
+*/
+func (router *Router) route(callType string, provider string) Route {
+	route, ok := router.routes[callType]
+	if !ok {
+		route = router.routes[DefaultRoute]
+	}
+
+	if _, ok := router.backends[provider]; !ok || provider == route.Backends[0] {
+		return route
+	}
+
+	backends := []string{provider}
+	for _, backend := range route.Backends {
+		if backend != provider {
+			backends = append(backends, backend)
+		}
+	}
+
+	return Route{
+		Backends: backends,
+		Timeout:  route.Timeout,
+	}
+}
+
+func (router *Router) recordRoute(route *usage.Route) {
+	if router.routeRecorder == nil {
+		return
+	}
+
+	err := router.routeRecorder.SaveRoute(route)
+	if err != nil {
+		fmt.Println("Unable to record route", err)
+	}
+}
+
+type result[T any] struct {
+	value T
+	err   error
+}
+
+/*
+call will attempt the given function against each backend
+of the route for the call type until one succeeds,
+recording each attempt. If all backends fail, the last
+error is returned. Each attempt is given its own attempt of
+the toolbox, if any, which is cancelled once the attempt is
+over; only the steps of the successful attempt are kept.
+*/
+func call[T any](
+	router *Router,
+	callType string,
+	provider string,
+	agent string,
+	user string,
+	conversation string,
+	toolbox *tools.Toolbox,
+	fn func(backend llm.LLM, toolbox *tools.Toolbox) (T, error),
+) (T, error) {
+	route := router.route(callType, provider)
+
+	var value T
+	var err error
+
+	for attempt, name := range route.Backends {
+		backend := router.backends[name]
+		start := time.Now()
+
+		// The call is made in its own goroutine so that we can
+		// abandon it on timeout; its result is then discarded,
+		// and its toolbox cancelled so it runs no more tools.
+		attemptToolbox, cancel := toolbox.Attempt()
+		results := make(chan result[T], 1)
+		go func() {
+			value, err := fn(backend, attemptToolbox)
+			results <- result[T]{value: value, err: err}
+		}()
+
+		var timeout <-chan time.Time
+		if route.Timeout > 0 {
+			timeout = time.After(route.Timeout)
+		}
+
+		select {
+		case r := <-results:
+			value, err = r.value, r.err
+		case <-timeout:
+			err = &TimeoutError{Backend: name, Timeout: route.Timeout}
+		}
+		if err == nil {
+			toolbox.Keep(attemptToolbox)
+		}
+		cancel()
+
+		record := &usage.Route{
+			ID:           uuid.New().String(),
+			Agent:        agent,
+			User:         user,
+			Conversation: conversation,
+			Type:         callType,
+			Backend:      name,
+			Attempt:      attempt,
+			Success:      err == nil,
+			Duration:     time.Since(start).Milliseconds(),
+			CreatedAt:    start,
+		}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		router.recordRoute(record)
+
+		if err == nil {
+			return value, nil
+		}
+	}
+
+	var empty T
+	return empty, fmt.Errorf("all backends failed for %s: %w", callType, err)
+}
+
+func (router *Router) SendMessage(
+	agent *agents.Agent,
+	conversation *chat.Conversation,
+	previousConversations []*memory.Summary,
+	knowledge []*memory.Knowledge,
+	sources []*documents.Chunk,
+	message *chat.Message,
+	toolbox *tools.Toolbox,
+) (*chat.Message, error) {
+	return call(
+		router,
+		usage.CallChat,
+		agent.LLM.ChatProfile().Provider,
+		agent.ID,
+		message.User,
+		message.Conversation,
+		toolbox,
+		func(backend llm.LLM, toolbox *tools.Toolbox) (*chat.Message, error) {
+			return backend.SendMessage(agent, conversation, previousConversations, knowledge, sources, message, toolbox)
+		},
+	)
+}
+
+func (router *Router) ConversationContinuance(
+	agent *agents.Agent,
+	message *chat.Message,
+	conversation *chat.Conversation,
+	summary *memory.Summary,
+) (bool, error) {
+	return call(
+		router,
+		usage.CallContinuance,
+		agent.LLM.ChatProfile().Provider,
+		agent.ID,
+		message.User,
+		conversation.ID,
+		nil,
+		func(backend llm.LLM, _ *tools.Toolbox) (bool, error) {
+			return backend.ConversationContinuance(agent, message, conversation, summary)
+		},
+	)
+}
+
+func (router *Router) Summarize(
+	agent *agents.Agent,
+	history *chat.Conversation,
+	previousSummary *memory.Summary,
+) (*memory.Summary, error) {
+	return call(
+		router,
+		usage.CallSummarize,
+		agent.LLM.SummaryProfile().Provider,
+		agent.ID,
+		history.User,
+		history.ID,
+		nil,
+		func(backend llm.LLM, _ *tools.Toolbox) (*memory.Summary, error) {
+			return backend.Summarize(agent, history, previousSummary)
+		},
+	)
+}
+
+func (router *Router) Learn(
+	agent *agents.Agent,
+	history *chat.Conversation,
+	summary *memory.Summary,
+) ([]*memory.Knowledge, error) {
+	return call(
+		router,
+		usage.CallLearn,
+		agent.LLM.KnowledgeProfile().Provider,
+		agent.ID,
+		history.User,
+		history.ID,
+		nil,
+		func(backend llm.LLM, _ *tools.Toolbox) ([]*memory.Knowledge, error) {
+			return backend.Learn(agent, history, summary)
+		},
+	)
+}
+
+func (router *Router) CompressKnowledge(
+	agent *agents.Agent,
+	user string,
+	knowledge []*memory.Knowledge,
+) (*memory.Compression, error) {
+	return call(
+		router,
+		usage.CallCompress,
+		agent.LLM.KnowledgeProfile().Provider,
+		agent.ID,
+		user,
+		"",
+		nil,
+		func(backend llm.LLM, _ *tools.Toolbox) (*memory.Compression, error) {
+			return backend.CompressKnowledge(agent, user, knowledge)
+		},
+	)
+}
+
+/*
+Embed is routed like any other call, but only to backends
+that can embed text; if none on the route can,
+llm.ErrEmbeddingsUnsupported is returned. As embeddings from
+different models can't be compared, fallback backends should
+generally use the same embedding model as the primary.
+*/
+func (router *Router) Embed(
+	agent *agents.Agent,
+	user string,
+	conversation string,
+	texts []string,
+) ([]documents.Embedding, error) {
+	route := router.route(usage.CallEmbed, "")
+
+	supported := false
+	for _, name := range route.Backends {
+		if _, ok := router.backends[name].(llm.Embedder); ok {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, llm.ErrEmbeddingsUnsupported
+	}
+
+	return call(
+		router,
+		usage.CallEmbed,
+		"",
+		agent.ID,
+		user,
+		conversation,
+		nil,
+		func(backend llm.LLM, _ *tools.Toolbox) ([]documents.Embedding, error) {
+			embedder, ok := backend.(llm.Embedder)
+			if !ok {
+				return nil, llm.ErrEmbeddingsUnsupported
+			}
+			return embedder.Embed(agent, user, conversation, texts)
+		},
+	)
+}
+
+/*
+EstimateTokens uses the primary chat backend's estimate, as
+token counts are generally used to size chat prompts.
+*/
+func (router *Router) EstimateTokens(text string) int {
+	route := router.route(usage.CallChat, "")
+	return router.backends[route.Backends[0]].EstimateTokens(text)
+}
@@ -0,0 +1,331 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type routeRecorder struct {
+	routes []*usage.Route
+}
+
+func (recorder *routeRecorder) SaveRoute(route *usage.Route) error {
+	recorder.routes = append(recorder.routes, route)
+	return nil
+}
+
+var testAgent = &agents.Agent{
+	ID:       "rose",
+	Name:     "Rose",
+	Identity: "Sassy and cynical",
+}
+
+func createRouter(t *testing.T, routes map[string]Route) (*Router, *mock.MockLLM, *mock.MockLLM, *routeRecorder) {
+	primary := mock.NewMockLLM()
+	secondary := mock.NewMockLLM()
+
+	router, err := NewRouter(
+		map[string]llm.LLM{
+			"primary":   primary,
+			"secondary": secondary,
+		},
+		routes,
+	)
+	require.Nil(t, err)
+
+	recorder := &routeRecorder{}
+	router.SetRouteRecorder(recorder)
+
+	return router, primary, secondary, recorder
+}
+
+func newMessage() *chat.Message {
+	return &chat.Message{
+		ID:           uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         "Keith",
+		From:         "Keith",
+		Content:      "Hello Rose",
+		Conversation: uuid.New().String(),
+		CreatedAt:    time.Now(),
+	}
+}
+
+func TestNewRouter(t *testing.T) {
+	backends := map[string]llm.LLM{
+		"primary": mock.NewMockLLM(),
+	}
+
+	// A default route is required
+	_, err := NewRouter(backends, map[string]Route{
+		usage.CallChat: {Backends: []string{"primary"}},
+	})
+	assert.NotNil(t, err)
+
+	// Routes must only use known backends
+	_, err = NewRouter(backends, map[string]Route{
+		DefaultRoute: {Backends: []string{"primary", "unknown"}},
+	})
+	assert.NotNil(t, err)
+
+	// Routes must have at least one backend
+	_, err = NewRouter(backends, map[string]Route{
+		DefaultRoute:   {Backends: []string{"primary"}},
+		usage.CallChat: {Backends: []string{}},
+	})
+	assert.NotNil(t, err)
+
+	_, err = NewRouter(backends, map[string]Route{
+		DefaultRoute: {Backends: []string{"primary"}},
+	})
+	assert.Nil(t, err)
+}
+
+func TestRouteByCallType(t *testing.T) {
+	router, primary, secondary, recorder := createRouter(t, map[string]Route{
+		DefaultRoute:       {Backends: []string{"primary"}},
+		usage.CallChat:     {Backends: []string{"secondary"}},
+		usage.CallLearn:    {Backends: []string{"secondary", "primary"}},
+		usage.CallCompress: {Backends: []string{"secondary"}},
+	})
+
+	// Chat goes to its own route
+	msg := newMessage()
+	reply := &chat.Message{ID: uuid.New().String(), Content: "Oh, it's you"}
+	secondary.AddSendMessageResponse(reply, nil)
+
+	response, err := router.SendMessage(testAgent, &chat.Conversation{ID: msg.Conversation}, nil, nil, nil, msg, nil)
+	require.Nil(t, err)
+	assert.Equal(t, reply, response)
+
+	// Summarize falls to the default route
+	conversation := &chat.Conversation{ID: msg.Conversation, User: msg.User, Messages: []*chat.Message{msg}}
+	summary := &memory.Summary{ID: uuid.New().String(), Summary: "Keith said hello"}
+	primary.AddSummarizeResponse(summary, nil)
+
+	returnedSummary, err := router.Summarize(testAgent, conversation, nil)
+	require.Nil(t, err)
+	assert.Equal(t, summary, returnedSummary)
+
+	// Compression has its own route too
+	compression := &memory.Compression{Duplicates: [][]string{{"a", "b"}}}
+	secondary.AddCompressKnowledgeResponse(compression, nil)
+
+	returnedCompression, err := router.CompressKnowledge(testAgent, msg.User, []*memory.Knowledge{})
+	require.Nil(t, err)
+	assert.Equal(t, compression, returnedCompression)
+
+	require.Len(t, recorder.routes, 3)
+	assert.Equal(t, usage.CallCompress, recorder.routes[2].Type)
+	assert.Equal(t, "secondary", recorder.routes[2].Backend)
+	assert.Equal(t, usage.CallChat, recorder.routes[0].Type)
+	assert.Equal(t, "secondary", recorder.routes[0].Backend)
+	assert.True(t, recorder.routes[0].Success)
+	assert.Equal(t, testAgent.ID, recorder.routes[0].Agent)
+	assert.Equal(t, msg.User, recorder.routes[0].User)
+	assert.Equal(t, msg.Conversation, recorder.routes[0].Conversation)
+	assert.Equal(t, usage.CallSummarize, recorder.routes[1].Type)
+	assert.Equal(t, "primary", recorder.routes[1].Backend)
+	assert.True(t, recorder.routes[1].Success)
+}
+
+func TestFallbackOnError(t *testing.T) {
+	router, primary, secondary, recorder := createRouter(t, map[string]Route{
+		DefaultRoute: {Backends: []string{"primary", "secondary"}},
+	})
+
+	msg := newMessage()
+	reply := &chat.Message{ID: uuid.New().String(), Content: "Oh, it's you"}
+	primary.AddSendMessageResponse(nil, fmt.Errorf("service unavailable"))
+	secondary.AddSendMessageResponse(reply, nil)
+
+	response, err := router.SendMessage(testAgent, &chat.Conversation{ID: msg.Conversation}, nil, nil, nil, msg, nil)
+	require.Nil(t, err)
+	assert.Equal(t, reply, response)
+
+	require.Len(t, recorder.routes, 2)
+	assert.Equal(t, "primary", recorder.routes[0].Backend)
+	assert.Equal(t, 0, recorder.routes[0].Attempt)
+	assert.False(t, recorder.routes[0].Success)
+	assert.Equal(t, "service unavailable", recorder.routes[0].Error)
+	assert.Equal(t, "secondary", recorder.routes[1].Backend)
+	assert.Equal(t, 1, recorder.routes[1].Attempt)
+	assert.True(t, recorder.routes[1].Success)
+
+	// If every backend fails, the last error is returned
+	recorder.routes = nil
+	primary.AddSendMessageResponse(nil, fmt.Errorf("service unavailable"))
+	secondary.AddSendMessageResponse(nil, fmt.Errorf("rate limited"))
+
+	response, err = router.SendMessage(testAgent, &chat.Conversation{ID: msg.Conversation}, nil, nil, nil, msg, nil)
+	require.NotNil(t, err)
+	assert.Nil(t, response)
+	assert.Contains(t, err.Error(), "rate limited")
+	require.Len(t, recorder.routes, 2)
+	assert.False(t, recorder.routes[0].Success)
+	assert.False(t, recorder.routes[1].Success)
+}
+
+func TestFallbackOnTimeout(t *testing.T) {
+	router, primary, secondary, recorder := createRouter(t, map[string]Route{
+		DefaultRoute: {
+			Backends: []string{"primary", "secondary"},
+			Timeout:  50 * time.Millisecond,
+		},
+	})
+
+	primary.SetDelay(time.Second)
+	primary.AddConversationContinuanceResponse(false, nil)
+	secondary.AddConversationContinuanceResponse(true, nil)
+
+	msg := newMessage()
+	shouldContinue, err := router.ConversationContinuance(testAgent, msg, &chat.Conversation{ID: msg.Conversation}, nil)
+	require.Nil(t, err)
+	assert.True(t, shouldContinue)
+
+	require.Len(t, recorder.routes, 2)
+	assert.Equal(t, "primary", recorder.routes[0].Backend)
+	assert.False(t, recorder.routes[0].Success)
+	assert.Contains(t, recorder.routes[0].Error, "timed out")
+	assert.Equal(t, "secondary", recorder.routes[1].Backend)
+	assert.True(t, recorder.routes[1].Success)
+}
+
+func TestFallbackKeepsOnlyWinningSteps(t *testing.T) {
+	router, primary, secondary, _ := createRouter(t, map[string]Route{
+		DefaultRoute: {
+			Backends: []string{"primary", "secondary"},
+			Timeout:  50 * time.Millisecond,
+		},
+	})
+
+	msg := newMessage()
+	toolbox := tools.NewToolbox(
+		[]tools.Tool{tools.NewCalculatorTool()},
+		tools.Scope{Agent: testAgent.ID, User: msg.User, Conversation: msg.Conversation},
+		5,
+		time.Second,
+	)
+	calculate := func(expression string) *chat.Message {
+		return &chat.Message{
+			ID: uuid.New().String(),
+			ToolCalls: []*chat.ToolCall{
+				{ID: uuid.New().String(), Name: "calculator", Arguments: []byte(`{"expression": "` + expression + `"}`)},
+			},
+		}
+	}
+
+	// The primary times out before it gets to its tools...
+	primary.SetDelay(100 * time.Millisecond)
+	primary.AddSendMessageResponse(calculate("1 + 1"), nil)
+	primary.AddSendMessageResponse(&chat.Message{ID: uuid.New().String(), Content: "Two"}, nil)
+	secondary.AddSendMessageResponse(calculate("6 * 7"), nil)
+	secondary.AddSendMessageResponse(&chat.Message{ID: uuid.New().String(), Content: "42"}, nil)
+
+	response, err := router.SendMessage(testAgent, &chat.Conversation{ID: msg.Conversation}, nil, nil, nil, msg, toolbox)
+	require.Nil(t, err)
+	assert.Equal(t, "42", response.Content)
+
+	// ...so only the secondary's steps are kept, even once
+	// the primary is done
+	time.Sleep(150 * time.Millisecond)
+	steps := toolbox.Steps()
+	require.Len(t, steps, 1)
+	assert.Equal(t, "42", steps[0].ToolCalls[0].Result)
+}
+
+func TestAgentPreferredProvider(t *testing.T) {
+	router, _, secondary, recorder := createRouter(t, map[string]Route{
+		DefaultRoute: {Backends: []string{"primary"}},
+	})
+
+	agent := &agents.Agent{
+		ID:       "hal",
+		Name:     "Hal",
+		Identity: "Super helpful, nothing but",
+		LLM: agents.LLMSettings{
+			Chat: &agents.Profile{Provider: "secondary"},
+		},
+	}
+
+	// The agent's chat provider is tried before the route's
+	msg := newMessage()
+	reply := &chat.Message{ID: uuid.New().String(), Content: "I'm sorry Keith"}
+	secondary.AddSendMessageResponse(reply, nil)
+
+	response, err := router.SendMessage(agent, &chat.Conversation{ID: msg.Conversation}, nil, nil, nil, msg, nil)
+	require.Nil(t, err)
+	assert.Equal(t, reply, response)
+
+	require.Len(t, recorder.routes, 1)
+	assert.Equal(t, "secondary", recorder.routes[0].Backend)
+
+	// ...and, as the summary profile falls back to chat, for
+	// summaries as well
+	recorder.routes = nil
+	conversation := &chat.Conversation{ID: msg.Conversation, User: msg.User, Messages: []*chat.Message{msg}}
+	secondary.AddSummarizeResponse(&memory.Summary{ID: uuid.New().String()}, nil)
+
+	_, err = router.Summarize(agent, conversation, nil)
+	require.Nil(t, err)
+	require.Len(t, recorder.routes, 1)
+	assert.Equal(t, "secondary", recorder.routes[0].Backend)
+}
+
+// chatOnly hides the embedding support of the LLM it wraps
+type chatOnly struct {
+	llm.LLM
+}
+
+func TestEmbed(t *testing.T) {
+	router, _, _, recorder := createRouter(t, map[string]Route{
+		DefaultRoute: {Backends: []string{"primary"}},
+	})
+
+	embeddings, err := router.Embed(testAgent, "Keith", "conversation", []string{"Hello", "Rose"})
+	require.Nil(t, err)
+	require.Len(t, embeddings, 2)
+	require.Len(t, recorder.routes, 1)
+	assert.Equal(t, usage.CallEmbed, recorder.routes[0].Type)
+	assert.True(t, recorder.routes[0].Success)
+
+	// Backends that can't embed are skipped over...
+	router, err = NewRouter(
+		map[string]llm.LLM{
+			"chat":  chatOnly{mock.NewMockLLM()},
+			"embed": mock.NewMockLLM(),
+		},
+		map[string]Route{
+			DefaultRoute: {Backends: []string{"chat", "embed"}},
+		},
+	)
+	require.Nil(t, err)
+	embeddings, err = router.Embed(testAgent, "Keith", "conversation", []string{"Hello"})
+	require.Nil(t, err)
+	assert.Len(t, embeddings, 1)
+
+	// ...and if none can, embeddings are unsupported
+	router, err = NewRouter(
+		map[string]llm.LLM{
+			"chat": chatOnly{mock.NewMockLLM()},
+		},
+		map[string]Route{
+			DefaultRoute: {Backends: []string{"chat"}},
+		},
+	)
+	require.Nil(t, err)
+	_, err = router.Embed(testAgent, "Keith", "conversation", []string{"Hello"})
+	assert.ErrorIs(t, err, llm.ErrEmbeddingsUnsupported)
+}
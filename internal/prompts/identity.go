@@ -1,7 +1,8 @@
 package prompts
 
 import (
-	_ "embed"
+	"embed"
+	"io/fs"
 )
 
 //go:embed identities/rose.prompt
@@ -15,3 +16,16 @@ var Syl string
 
 //go:embed identities/marcus.prompt
 var Marcus string
+
+//go:embed identities
+var identities embed.FS
+
+/*
+Seeds returns the definitions of the bundled agents, along
+with the identity files they refer to
+*/
+func Seeds() fs.FS {
+	// The directory is embedded, so it always exists
+	seeds, _ := fs.Sub(identities, "identities")
+	return seeds
+}
@@ -17,6 +17,9 @@ var PreviousSummary string
 //go:embed instructions/conversation.continuance.prompt
 var ConversationContinuance string
 
+//go:embed instructions/chat.documents.prompt
+var Documents string
+
 // ============
 // Summary Prompts
 // ============
@@ -36,3 +39,10 @@ var Knowledge string
 
 //go:embed instructions/knowledge.compression.prompt
 var KnoweldgeCompression string
+
+// ============
+// Structured Output Prompts
+// ============
+
+//go:embed instructions/correction.prompt
+var Correction string
@@ -0,0 +1,289 @@
+package prompts
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+/*
+These are the names of the instruction prompts that can be
+templated, which are also the names of the files they are
+loaded from, less the .prompt extension.
+*/
+const (
+	TemplateChat          = "chat"
+	TemplateContinuance   = "conversation.continuance"
+	TemplateSummary       = "summary"
+	TemplateKnowledge     = "knowledge"
+	TemplateCompression   = "knowledge.compression"
+	templateFileExtension = ".prompt"
+)
+
+/*
+placeholders are the values each template is filled with.
+Required placeholders must appear in the template; the rest
+may be left out. The summary, knowledge, and compression
+templates are followed by what they instruct on, so they
+have none.
+*/
+type placeholders struct {
+	required []string
+	optional []string
+}
+
+var templatePlaceholders = map[string]placeholders{
+	TemplateChat: {
+		required: []string{"identity", "message_history", "message"},
+		optional: []string{"name", "summaries", "knowledge", "documents", "previous_summary"},
+	},
+	TemplateContinuance: {
+		required: []string{"message_history", "new_message"},
+		optional: []string{"summary"},
+	},
+	TemplateSummary:     {},
+	TemplateKnowledge:   {},
+	TemplateCompression: {},
+}
+
+// TemplateNames are the names of every template, sorted
+func TemplateNames() []string {
+	names := []string{}
+	for name := range templatePlaceholders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+Template is a parsed prompt template. Templates are Go
+text/templates filled with a map of placeholders, ie
+{{.identity}}, so that optional sections can be wrapped in
+conditionals such as {{if .knowledge}}...{{end}}. The
+original {identity} form of a placeholder is also accepted.
+*/
+type Template struct {
+	Name string
+	Text string
+
+	template *template.Template
+}
+
+// legacyPlaceholder matches a placeholder in its original {name} form
+var legacyPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+/*
+ParseTemplate parses and validates the template of the given
+name. It is an error for the template to be missing a
+required placeholder, or to use one that it will never be
+given.
+*/
+func ParseTemplate(name string, text string) (*Template, error) {
+	expected, ok := templatePlaceholders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template %s", name)
+	}
+
+	known := map[string]bool{}
+	for _, key := range append(expected.required, expected.optional...) {
+		known[key] = true
+	}
+
+	// Only known placeholders are converted, so that JSON
+	// examples and actions such as {{end}} are left alone
+	converted := legacyPlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		key := match[1 : len(match)-1]
+		if !known[key] {
+			return match
+		}
+		return "{{." + key + "}}"
+	})
+
+	parsed, err := template.New(name).Option("missingkey=zero").Parse(converted)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, tmpl := range parsed.Templates() {
+		if tmpl.Tree != nil {
+			collectPlaceholders(tmpl.Tree.Root, used)
+		}
+	}
+	for key := range used {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown placeholder %s", key)
+		}
+	}
+	for _, key := range expected.required {
+		if !used[key] {
+			return nil, fmt.Errorf("missing placeholder %s", key)
+		}
+	}
+
+	return &Template{Name: name, Text: text, template: parsed}, nil
+}
+
+// collectPlaceholders adds every field referenced under node to used
+func collectPlaceholders(node parse.Node, used map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			collectPlaceholders(child, used)
+		}
+	case *parse.ActionNode:
+		collectPlaceholders(node.Pipe, used)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, command := range node.Cmds {
+			collectPlaceholders(command, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			collectPlaceholders(arg, used)
+		}
+	case *parse.ChainNode:
+		collectPlaceholders(node.Node, used)
+	case *parse.FieldNode:
+		used[node.Ident[0]] = true
+	case *parse.IfNode:
+		collectBranch(&node.BranchNode, used)
+	case *parse.RangeNode:
+		collectBranch(&node.BranchNode, used)
+	case *parse.WithNode:
+		collectBranch(&node.BranchNode, used)
+	case *parse.TemplateNode:
+		collectPlaceholders(node.Pipe, used)
+	}
+}
+
+func collectBranch(node *parse.BranchNode, used map[string]bool) {
+	collectPlaceholders(node.Pipe, used)
+	collectPlaceholders(node.List, used)
+	collectPlaceholders(node.ElseList, used)
+}
+
+/*
+Execute fills the template with the given placeholders. Any
+placeholder not given is empty.
+*/
+func (tmpl *Template) Execute(values map[string]string) (string, error) {
+	if values == nil {
+		values = map[string]string{}
+	}
+
+	var output strings.Builder
+	err := tmpl.template.Execute(&output, values)
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+var defaultTemplates = map[string]*Template{}
+
+func init() {
+	for name, text := range map[string]string{
+		TemplateChat:        Instructions,
+		TemplateContinuance: ConversationContinuance,
+		TemplateSummary:     Summary,
+		TemplateKnowledge:   Knowledge,
+		TemplateCompression: KnoweldgeCompression,
+	} {
+		tmpl, err := ParseTemplate(name, text)
+		if err != nil {
+			panic(err)
+		}
+		defaultTemplates[name] = tmpl
+	}
+}
+
+// DefaultTemplate is the bundled template of the given name
+func DefaultTemplate(name string) *Template {
+	return defaultTemplates[name]
+}
+
+/*
+Templates is a set of templates loaded from a directory;
+those shared by every agent, and those overriding them for
+a single agent.
+*/
+type Templates struct {
+	Shared map[string]*Template
+	Agents map[string]map[string]*Template
+}
+
+/*
+LoadTemplates reads a directory of templates. Each .prompt
+file at the root is shared by every agent, ie chat.prompt,
+and each subdirectory holds the templates overriding them
+for the agent it is named for. Every template is validated
+as it is loaded.
+*/
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+	templates := &Templates{
+		Agents: map[string]map[string]*Template{},
+	}
+
+	shared, err := loadTemplateDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	templates.Shared = shared
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		overrides, err := loadTemplateDir(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		templates.Agents[entry.Name()] = overrides
+	}
+
+	return templates, nil
+}
+
+func loadTemplateDir(fsys fs.FS, dir string) (map[string]*Template, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := map[string]*Template{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != templateFileExtension {
+			continue
+		}
+		file := path.Join(dir, entry.Name())
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := ParseTemplate(strings.TrimSuffix(entry.Name(), templateFileExtension), string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		templates[tmpl.Name] = tmpl
+	}
+
+	return templates, nil
+}
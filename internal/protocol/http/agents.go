@@ -0,0 +1,84 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+Each agent version endpoint acts on behalf of the
+authenticated user, who must be an admin or agent editor of
+the agent's organization.
+*/
+
+// ListAgentVersions returns every version of an agent's identity
+func (api *HttpAPI) ListAgentVersions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := api.service.Agents.ListRevisions(actorFromRequest(r), mux.Vars(r)["agent"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
+
+/*
+DiffAgentVersions returns the line by line difference between
+the versions of an agent's identity given by the from and to
+query parameters
+*/
+func (api *HttpAPI) DiffAgentVersions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, err := parseVersion(query.Get("from"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseVersion(query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	diff, err := api.service.Agents.DiffRevisions(actorFromRequest(r), mux.Vars(r)["agent"], from, to)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diff)
+}
+
+/*
+RollbackAgentVersion restores an earlier version of an
+agent's identity as its newest, returning the agent
+*/
+func (api *HttpAPI) RollbackAgentVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	version, err := parseVersion(vars["version"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	agent, err := api.service.Agents.Rollback(actorFromRequest(r), vars["agent"], version)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, agent)
+}
+
+func parseVersion(raw string) (int, error) {
+	version, err := strconv.Atoi(raw)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("version must be a positive number, not %q", raw)
+	}
+	return version, nil
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/service"
+)
+
+// The largest artifact that can be uploaded
+const maxArtifactUploadBytes = 10 << 20
+
+/*
+UploadArtifact attaches a new artifact to an existing
+message. It expects a multipart form with the artifact in
+the file field, and optionally its type in the type field
+(otherwise it is guessed from the file's content type) and
+a JSON object of string metadata in the metadata field.
+The artifact is returned without its data.
+*/
+func (api *HttpAPI) UploadArtifact(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxArtifactUploadBytes)
+	err := r.ParseMultipartForm(maxArtifactUploadBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var metadata map[string]string
+	if raw := r.FormValue("metadata"); raw != "" {
+		err = json.Unmarshal([]byte(raw), &metadata)
+		if err != nil {
+			http.Error(w, "metadata must be a JSON object of strings", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Clients commonly send octet-stream when they don't know
+	// the content type; treat it as unset so that it is
+	// detected instead
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "application/octet-stream" {
+		mimeType = ""
+	}
+
+	artifact, err := api.service.Artifacts.Upload(actorFromRequest(r), &service.UploadArtifactRequest{
+		Message:  mux.Vars(r)["message"],
+		Type:     r.FormValue("type"),
+		MimeType: mimeType,
+		Filename: header.Filename,
+		Metadata: metadata,
+		Data:     data,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	withoutData := *artifact
+	withoutData.Data = nil
+	resp, err := json.Marshal(withoutData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+/*
+DownloadArtifact returns the raw data of a message's
+artifact, with its content type and filename.
+*/
+func (api *HttpAPI) DownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	artifact, err := api.service.Artifacts.Download(actorFromRequest(r), vars["message"], vars["artifact"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	} else if artifact == nil {
+		http.Error(w, "artifact not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", artifact.MimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(artifact.Data)))
+	if artifact.Filename != "" {
+		w.Header().Set(
+			"Content-Disposition",
+			mime.FormatMediaType("attachment", map[string]string{"filename": artifact.Filename}),
+		)
+	}
+	w.Write(artifact.Data)
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+// actorKey is the request context key of the acting user's ID
+const actorKey contextKey = "actor"
+
+/*
+authenticate identifies the user acting in each request by
+their ID and password, sent with HTTP basic auth. Requests
+without credentials act as no one, and are left to each
+endpoint to refuse; wrong credentials are refused outright.
+*/
+func (api *HttpAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, password, ok := r.BasicAuth()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := api.service.Users.Authenticate(userId, password)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), actorKey, user.ID)))
+	})
+}
+
+/*
+actorFromRequest returns the ID of the user authenticated
+for a request, or "" if there is none.
+*/
+func actorFromRequest(r *http.Request) string {
+	actor, _ := r.Context().Value(actorKey).(string)
+	return actor
+}
@@ -2,10 +2,14 @@ package http
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/quota"
 )
 
 func (api *HttpAPI) SendMessage(w http.ResponseWriter, r *http.Request) {
@@ -16,11 +20,19 @@ func (api *HttpAPI) SendMessage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// Messages are only ever sent as the authenticated user
+	message.User = actorFromRequest(r)
+	message.From = message.User
 
-	response, err := api.service.Chat.SendMessage(&message)
-	if err != nil {
-		fmt.Println("Bad result", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	response, err := api.service.SendMessage(&message)
+	var quotaErr *quota.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		retryAfter := int(time.Until(quotaErr.RetryAfter).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		writeServiceError(w, err)
 		return
 	}
 
@@ -31,3 +43,29 @@ func (api *HttpAPI) SendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write(resp)
 }
+
+/*
+DeleteConversation deletes a conversation's messages on
+behalf of the authenticated user. If the
+forget_knowledge query parameter is true, the facts learned
+from the conversation are deleted too.
+*/
+func (api *HttpAPI) DeleteConversation(w http.ResponseWriter, r *http.Request) {
+	forgetKnowledge := false
+	if raw := r.URL.Query().Get("forget_knowledge"); raw != "" {
+		var err error
+		forgetKnowledge, err = strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "forget_knowledge must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := api.service.Messages.DeleteConversation(actorFromRequest(r), mux.Vars(r)["conversation"], forgetKnowledge)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+)
+
+/*
+The experiment endpoints act on behalf of the authenticated
+user. Experiments are created and
+listed under their agent, and ended and reported on by ID.
+*/
+
+/*
+CreateExperiment expects a JSON object with the experiment's
+name and variants in the body, and starts it on the agent in
+the path.
+*/
+func (api *HttpAPI) CreateExperiment(w http.ResponseWriter, r *http.Request) {
+	var experiment experiments.Experiment
+	err := json.NewDecoder(r.Body).Decode(&experiment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	experiment.Agent = mux.Vars(r)["agent"]
+
+	err = api.service.Experiments.CreateExperiment(actorFromRequest(r), &experiment)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, experiment)
+}
+
+// ListExperiments returns every experiment run on the agent
+func (api *HttpAPI) ListExperiments(w http.ResponseWriter, r *http.Request) {
+	found, err := api.service.Experiments.ListExperiments(actorFromRequest(r), mux.Vars(r)["agent"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, found)
+}
+
+// EndExperiment stops the experiment, returning it as ended
+func (api *HttpAPI) EndExperiment(w http.ResponseWriter, r *http.Request) {
+	experiment, err := api.service.Experiments.EndExperiment(actorFromRequest(r), mux.Vars(r)["experiment"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, experiment)
+}
+
+// GetExperimentReport compares the experiment's variants
+func (api *HttpAPI) GetExperimentReport(w http.ResponseWriter, r *http.Request) {
+	report, err := api.service.Experiments.Report(actorFromRequest(r), mux.Vars(r)["experiment"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/service"
+)
+
+/*
+The feedback endpoints act on behalf of the authenticated
+user. Feedback is submitted on a
+message, listed by the user that gave it at /feedback or by
+the agent it was given at /agents/{agent}/feedback, and
+reported on per agent.
+*/
+
+/*
+SubmitFeedback expects a JSON object with the rating, 1 or
+-1, and optionally a comment and tags in the body, and
+records it as the feedback the user gave the message in the
+path.
+*/
+func (api *HttpAPI) SubmitFeedback(w http.ResponseWriter, r *http.Request) {
+	var feedback chat.Feedback
+	err := json.NewDecoder(r.Body).Decode(&feedback)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	feedback.Message = mux.Vars(r)["message"]
+	feedback.User = actorFromRequest(r)
+
+	err = api.service.Feedback.SubmitFeedback(&feedback)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, feedback)
+}
+
+/*
+ListFeedback returns feedback, narrowed by the optional
+query parameters message, rating, tag, limit, and from and
+to as RFC3339 timestamps. Under an agent it lists what the
+agent was given, optionally by the user named in by;
+otherwise it lists what the authenticated user gave.
+*/
+func (api *HttpAPI) ListFeedback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	request, err := feedbackRequestFromQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request.Agent = mux.Vars(r)["agent"]
+	if request.Agent != "" {
+		request.User = query.Get("by")
+	} else {
+		request.User = actorFromRequest(r)
+	}
+
+	feedback, err := api.service.Feedback.ListFeedback(actorFromRequest(r), request)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, feedback)
+}
+
+/*
+GetFeedbackReport returns the feedback an agent was given
+over a time range. It expects the query parameters from
+and, optionally, to as RFC3339 timestamps, and interval -
+day, week, or month - to break it down by.
+*/
+func (api *HttpAPI) GetFeedbackReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	request := &service.FeedbackReportRequest{
+		Agent:    mux.Vars(r)["agent"],
+		Interval: query.Get("interval"),
+	}
+
+	var err error
+	request.From, request.To, err = timeRangeFromQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := api.service.Feedback.Report(actorFromRequest(r), request)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func feedbackRequestFromQuery(query url.Values) (*service.ListFeedbackRequest, error) {
+	request := &service.ListFeedbackRequest{
+		Message: query.Get("message"),
+		Tag:     query.Get("tag"),
+	}
+
+	var err error
+	if rating := query.Get("rating"); rating != "" {
+		request.Rating, err = strconv.Atoi(rating)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		request.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	request.From, request.To, err = timeRangeFromQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// timeRangeFromQuery parses the optional from and to query
+// parameters as RFC3339 timestamps
+func timeRangeFromQuery(query url.Values) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+
+	if value := query.Get("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, err
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return from, to, err
+		}
+	}
+
+	return from, to, nil
+}
@@ -4,7 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/hlfshell/coppermind/internal/service"
+	"github.com/hlfshell/coppermind/pkg/service"
 )
 
 type HttpAPI struct {
@@ -26,9 +26,80 @@ func NewHttpAPI(service *service.Service, port string) *HttpAPI {
 }
 
 func (api *HttpAPI) setupRouting() {
+	api.router.Use(api.authenticate)
+
 	chatRouter := api.router.PathPrefix("/chat").Subrouter()
 
 	chatRouter.HandleFunc("/send", api.SendMessage).Methods("POST")
+
+	api.router.HandleFunc("/conversations/{conversation}", api.DeleteConversation).Methods("DELETE")
+
+	messageRouter := api.router.PathPrefix("/messages/{message}").Subrouter()
+
+	messageRouter.HandleFunc("/artifacts", api.UploadArtifact).Methods("POST")
+	messageRouter.HandleFunc("/artifacts/{artifact}", api.DownloadArtifact).Methods("GET")
+	messageRouter.HandleFunc("/feedback", api.SubmitFeedback).Methods("POST")
+
+	api.router.HandleFunc("/feedback", api.ListFeedback).Methods("GET")
+
+	api.router.HandleFunc("/usage", api.GetUsage).Methods("GET")
+	api.router.HandleFunc("/usage/routes", api.ListRoutes).Methods("GET")
+
+	memoryRouter := api.router.PathPrefix("/memory").Subrouter()
+
+	memoryRouter.HandleFunc("", api.GetMemory).Methods("GET")
+	memoryRouter.HandleFunc("/facts/{fact}", api.CorrectFact).Methods("PATCH")
+	memoryRouter.HandleFunc("/facts/{fact}", api.DeleteFact).Methods("DELETE")
+	memoryRouter.HandleFunc("/facts/{fact}/pin", api.PinFact).Methods("PUT")
+	memoryRouter.HandleFunc("/facts/{fact}/pin", api.UnpinFact).Methods("DELETE")
+	memoryRouter.HandleFunc("/conversations/{conversation}/exclude", api.ExcludeConversation).Methods("POST")
+
+	userRouter := api.router.PathPrefix("/users/{user}").Subrouter()
+
+	userRouter.HandleFunc("/export", api.ExportUserData).Methods("GET")
+	userRouter.HandleFunc("/erase", api.EraseUser).Methods("POST")
+	userRouter.HandleFunc("/erasures", api.ListErasures).Methods("GET")
+
+	api.router.HandleFunc("/organizations", api.CreateOrganization).Methods("POST")
+
+	organizationRouter := api.router.PathPrefix("/organizations/{organization}").Subrouter()
+
+	organizationRouter.HandleFunc("", api.GetOrganization).Methods("GET")
+	organizationRouter.HandleFunc("", api.UpdateOrganization).Methods("PUT")
+	organizationRouter.HandleFunc("", api.DeleteOrganization).Methods("DELETE")
+	organizationRouter.HandleFunc("/agents", api.ListOrganizationAgents).Methods("GET")
+
+	agentRouter := api.router.PathPrefix("/agents/{agent}").Subrouter()
+
+	agentRouter.HandleFunc("/versions", api.ListAgentVersions).Methods("GET")
+	agentRouter.HandleFunc("/versions/diff", api.DiffAgentVersions).Methods("GET")
+	agentRouter.HandleFunc("/versions/{version}/rollback", api.RollbackAgentVersion).Methods("POST")
+	agentRouter.HandleFunc("/prompts", api.ListPromptTemplates).Methods("GET")
+	agentRouter.HandleFunc("/prompts/{name}", api.SavePromptTemplate).Methods("PUT")
+	agentRouter.HandleFunc("/prompts/{name}", api.DeletePromptTemplate).Methods("DELETE")
+	agentRouter.HandleFunc("/experiments", api.CreateExperiment).Methods("POST")
+	agentRouter.HandleFunc("/experiments", api.ListExperiments).Methods("GET")
+	agentRouter.HandleFunc("/feedback", api.ListFeedback).Methods("GET")
+	agentRouter.HandleFunc("/feedback/report", api.GetFeedbackReport).Methods("GET")
+
+	api.router.HandleFunc("/prompts", api.ListPromptTemplates).Methods("GET")
+	api.router.HandleFunc("/prompts/{name}", api.SavePromptTemplate).Methods("PUT")
+	api.router.HandleFunc("/prompts/{name}", api.DeletePromptTemplate).Methods("DELETE")
+
+	experimentRouter := api.router.PathPrefix("/experiments/{experiment}").Subrouter()
+
+	experimentRouter.HandleFunc("/end", api.EndExperiment).Methods("POST")
+	experimentRouter.HandleFunc("/report", api.GetExperimentReport).Methods("GET")
+
+	knowledgeRouter := api.router.PathPrefix("/knowledge").Subrouter()
+
+	knowledgeRouter.HandleFunc("/graph", api.ExportKnowledgeGraph).Methods("GET")
+	knowledgeRouter.HandleFunc("/entities/{entity}", api.GetEntity).Methods("GET")
+	knowledgeRouter.HandleFunc("/facts/{fact}", api.GetFactProvenance).Methods("GET")
+	knowledgeRouter.HandleFunc("/path", api.GetKnowledgePath).Methods("GET")
+	knowledgeRouter.HandleFunc("/predicates/{predicate}", api.ListKnowledgeByPredicate).Methods("GET")
+	knowledgeRouter.HandleFunc("/aliases", api.SaveAlias).Methods("POST")
+	knowledgeRouter.HandleFunc("/aliases/{alias}", api.DeleteAlias).Methods("DELETE")
 }
 
 func (api *HttpAPI) Serve() error {
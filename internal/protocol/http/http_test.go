@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/store/sqlite"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/service"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthentication(t *testing.T) {
+	store, err := sqlite.NewSqliteStore(":memory:")
+	require.Nil(t, err)
+	require.Nil(t, store.Migrate())
+
+	svc := service.NewService(store, mock.NewMockLLM(), &config.DefaultConfig)
+	for _, user := range []*users.User{
+		{ID: "admin", Name: "Admin", Role: users.RoleAdmin},
+		{ID: "keith", Name: "Keith"},
+	} {
+		user.CreatedAt = time.Now()
+		require.Nil(t, svc.Users.CreateUser(user, "supersecret"))
+	}
+	api := NewHttpAPI(svc, ":0")
+
+	request := func(user string, password string, target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, r)
+		return w
+	}
+
+	// Naming another user in the query doesn't act as them,
+	// with or without credentials of one's own
+	w := request("", "", "/users/admin/erasures?user=admin")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request("keith", "supersecret", "/users/admin/erasures?user=admin")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Wrong credentials, or those of no one, are refused
+	for _, credentials := range [][2]string{{"admin", "wrongpassword"}, {"nobody", "supersecret"}} {
+		w = request(credentials[0], credentials[1], "/users/admin/erasures")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	}
+
+	// Only the authenticated user is acted as
+	w = request("admin", "supersecret", "/users/keith/erasures")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request("keith", "supersecret", "/users/keith/erasures")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/service"
+)
+
+/*
+Each knowledge graph endpoint expects the agent query
+parameter, and queries what that agent knows about the
+authenticated user.
+*/
+func graphRequestFromQuery(r *http.Request) *service.GraphRequest {
+	return &service.GraphRequest{
+		Agent: r.URL.Query().Get("agent"),
+		User:  actorFromRequest(r),
+	}
+}
+
+// writeServiceError maps a service error to its HTTP status
+func writeServiceError(w http.ResponseWriter, err error) {
+	var notFoundErr *service.NotFoundError
+	var invalidErr *service.InvalidRequestError
+	var permissionErr *service.PermissionError
+	var authenticationErr *service.AuthenticationError
+	if errors.As(err, &notFoundErr) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	} else if errors.As(err, &invalidErr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else if errors.As(err, &permissionErr) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	} else if errors.As(err, &authenticationErr) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="coppermind"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	} else {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	resp, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+/*
+ExportKnowledgeGraph returns the whole knowledge graph. The
+optional format query parameter selects json (the default)
+or dot, for Graphviz.
+*/
+func (api *HttpAPI) ExportKnowledgeGraph(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = service.GraphFormatJSON
+	}
+
+	data, err := api.service.Knowledge.Export(graphRequestFromQuery(r), format)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if format == service.GraphFormatDOT {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Write(data)
+}
+
+/*
+GetEntity returns everything known about an entity - the
+facts it is part of - and the entities it neighbors.
+*/
+func (api *HttpAPI) GetEntity(w http.ResponseWriter, r *http.Request) {
+	request := graphRequestFromQuery(r)
+	entity := mux.Vars(r)["entity"]
+
+	facts, err := api.service.Knowledge.About(request, entity)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	neighbors, err := api.service.Knowledge.Neighbors(request, entity)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entity":    memory.NormalizeEntity(entity),
+		"facts":     facts,
+		"neighbors": neighbors,
+	})
+}
+
+/*
+GetKnowledgePath returns the shortest chain of facts linking
+the entities named by the from and to query parameters, or
+an empty list if they are not linked.
+*/
+func (api *HttpAPI) GetKnowledgePath(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	path, err := api.service.Knowledge.Path(graphRequestFromQuery(r), query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, path)
+}
+
+/*
+ListKnowledgeByPredicate returns every fact with the given
+predicate.
+*/
+func (api *HttpAPI) ListKnowledgeByPredicate(w http.ResponseWriter, r *http.Request) {
+	facts, err := api.service.Knowledge.ByPredicate(graphRequestFromQuery(r), mux.Vars(r)["predicate"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, facts)
+}
+
+/*
+GetFactProvenance returns a fact along with where it came
+from - its conversation, the messages it was extracted from,
+and the LLM's confidence in it.
+*/
+func (api *HttpAPI) GetFactProvenance(w http.ResponseWriter, r *http.Request) {
+	provenance, err := api.service.Knowledge.GetProvenance(graphRequestFromQuery(r), mux.Vars(r)["fact"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, provenance)
+}
+
+/*
+SaveAlias expects a JSON alias in the body, with its agent,
+user, alias, and entity, and returns it normalized.
+*/
+func (api *HttpAPI) SaveAlias(w http.ResponseWriter, r *http.Request) {
+	var alias memory.Alias
+	err := json.NewDecoder(r.Body).Decode(&alias)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = api.service.Knowledge.SaveAlias(&alias)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, alias)
+}
+
+// DeleteAlias forgets an alias of an entity
+func (api *HttpAPI) DeleteAlias(w http.ResponseWriter, r *http.Request) {
+	err := api.service.Knowledge.DeleteAlias(graphRequestFromQuery(r), mux.Vars(r)["alias"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/service"
+)
+
+/*
+Each memory endpoint expects the agent query parameter, and
+manages the authenticated user's memories of that agent; a
+user may only manage their own.
+*/
+func memoryRequestFromQuery(r *http.Request) *service.MemoryRequest {
+	return &service.MemoryRequest{
+		Agent: r.URL.Query().Get("agent"),
+		User:  actorFromRequest(r),
+	}
+}
+
+/*
+GetMemory answers "what do you remember about me?" - the
+summaries of the user's conversations and the facts the
+agent currently holds about them.
+*/
+func (api *HttpAPI) GetMemory(w http.ResponseWriter, r *http.Request) {
+	memory, err := api.service.Memory.GetMemory(memoryRequestFromQuery(r))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, memory)
+}
+
+/*
+CorrectFact expects a JSON body with any of the subject,
+predicate, object, and expires_at to change, and returns
+the corrected fact.
+*/
+func (api *HttpAPI) CorrectFact(w http.ResponseWriter, r *http.Request) {
+	var correction service.FactCorrection
+	err := json.NewDecoder(r.Body).Decode(&correction)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fact, err := api.service.Memory.CorrectFact(memoryRequestFromQuery(r), mux.Vars(r)["fact"], &correction)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fact)
+}
+
+// PinFact pins a fact so that it is never forgotten
+func (api *HttpAPI) PinFact(w http.ResponseWriter, r *http.Request) {
+	api.setPinned(w, r, true)
+}
+
+// UnpinFact lets a pinned fact expire again
+func (api *HttpAPI) UnpinFact(w http.ResponseWriter, r *http.Request) {
+	api.setPinned(w, r, false)
+}
+
+func (api *HttpAPI) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	fact, err := api.service.Memory.PinFact(memoryRequestFromQuery(r), mux.Vars(r)["fact"], pinned)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fact)
+}
+
+// DeleteFact forgets a single fact
+func (api *HttpAPI) DeleteFact(w http.ResponseWriter, r *http.Request) {
+	err := api.service.Memory.DeleteFact(memoryRequestFromQuery(r), mux.Vars(r)["fact"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+/*
+ExcludeConversation removes a conversation from memory - its
+summary and what was learned from it - while keeping its
+messages.
+*/
+func (api *HttpAPI) ExcludeConversation(w http.ResponseWriter, r *http.Request) {
+	err := api.service.Memory.ExcludeConversation(memoryRequestFromQuery(r), mux.Vars(r)["conversation"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
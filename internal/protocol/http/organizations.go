@@ -0,0 +1,89 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+)
+
+/*
+CreateOrganization creates an organization from the JSON
+body, returning it with its LLM keys masked
+*/
+func (api *HttpAPI) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var org tenants.Organization
+	err := json.NewDecoder(r.Body).Decode(&org)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = api.service.Tenants.CreateOrganization(actorFromRequest(r), &org)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	org.Settings = org.Settings.Redacted()
+	writeJSON(w, http.StatusCreated, org)
+}
+
+// GetOrganization returns an organization with its LLM keys masked
+func (api *HttpAPI) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, err := api.service.Tenants.GetOrganization(actorFromRequest(r), mux.Vars(r)["organization"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	org.Settings = org.Settings.Redacted()
+	writeJSON(w, http.StatusOK, org)
+}
+
+/*
+UpdateOrganization replaces an organization's name and
+settings with those of the JSON body. LLM keys that are
+omitted, empty, or masked are left as they were.
+*/
+func (api *HttpAPI) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	var org tenants.Organization
+	err := json.NewDecoder(r.Body).Decode(&org)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	org.ID = mux.Vars(r)["organization"]
+
+	err = api.service.Tenants.UpdateOrganization(actorFromRequest(r), &org)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	org.Settings = org.Settings.Redacted()
+	writeJSON(w, http.StatusOK, org)
+}
+
+// DeleteOrganization deletes an organization that owns nothing
+func (api *HttpAPI) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	err := api.service.Tenants.DeleteOrganization(actorFromRequest(r), mux.Vars(r)["organization"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListOrganizationAgents returns the agents of an organization
+func (api *HttpAPI) ListOrganizationAgents(w http.ResponseWriter, r *http.Request) {
+	agents, err := api.service.Agents.ListAgents(actorFromRequest(r), mux.Vars(r)["organization"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, agents)
+}
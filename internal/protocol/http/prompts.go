@@ -0,0 +1,66 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hlfshell/coppermind/pkg/agents"
+)
+
+/*
+The prompt template endpoints are served both at /prompts,
+for the templates shared by every agent, and under an agent,
+for its overrides. Each acts on behalf of the authenticated
+user.
+*/
+
+// ListPromptTemplates returns the stored templates
+func (api *HttpAPI) ListPromptTemplates(w http.ResponseWriter, r *http.Request) {
+	prompts, err := api.service.Prompts.ListTemplates(actorFromRequest(r), mux.Vars(r)["agent"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prompts)
+}
+
+/*
+SavePromptTemplate expects a JSON object with the template
+in the body, and stores it under the name in the path once
+it's validated.
+*/
+func (api *HttpAPI) SavePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	var prompt agents.PromptTemplate
+	err := json.NewDecoder(r.Body).Decode(&prompt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	prompt.Agent = vars["agent"]
+	prompt.Name = vars["name"]
+
+	err = api.service.Prompts.SaveTemplate(actorFromRequest(r), &prompt)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prompt)
+}
+
+// DeletePromptTemplate removes a stored template
+func (api *HttpAPI) DeletePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := api.service.Prompts.DeleteTemplate(actorFromRequest(r), vars["agent"], vars["name"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/service"
+)
+
+/*
+GetUsage returns the aggregated usage and cost over a
+time range. It expects the query parameters from and,
+optionally, to as RFC3339 timestamps, and optional agent
+and user parameters to narrow the report. Only admins see
+the usage of users other than the authenticated one.
+*/
+func (api *HttpAPI) GetUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	request, err := usageRequestFromQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := api.service.Usage.GetUsage(actorFromRequest(r), request)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resp)
+}
+
+/*
+ListRoutes returns which LLM backend was attempted for each
+call over a time range. It accepts the same query parameters
+as GetUsage, plus an optional conversation parameter.
+*/
+func (api *HttpAPI) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	usageRequest, err := usageRequestFromQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	routes, err := api.service.Usage.ListRoutes(actorFromRequest(r), &service.ListRoutesRequest{
+		GetUsageRequest: *usageRequest,
+		Conversation:    query.Get("conversation"),
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	resp, err := json.Marshal(routes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resp)
+}
+
+func usageRequestFromQuery(query url.Values) (*service.GetUsageRequest, error) {
+	request := &service.GetUsageRequest{
+		Agent: query.Get("agent"),
+		User:  query.Get("user"),
+	}
+
+	var err error
+	if from := query.Get("from"); from != "" {
+		request.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		request.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return request, request.Valid()
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+/*
+ExportUserData returns a zip archive of everything stored
+about a user. The archive is built before anything is sent
+so that a failure part way through is still reported.
+*/
+func (api *HttpAPI) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["user"]
+
+	var archive bytes.Buffer
+	err := api.service.Users.ExportUserData(actorFromRequest(r), user, &archive)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.Itoa(archive.Len()))
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": user + ".zip"}),
+	)
+	w.Write(archive.Bytes())
+}
+
+/*
+EraseUser removes everything stored about a user and returns
+the audit record of how much was erased. If the dry_run
+query parameter is true, it only counts what would be
+erased.
+*/
+func (api *HttpAPI) EraseUser(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	erasure, err := api.service.Users.EraseUser(actorFromRequest(r), mux.Vars(r)["user"], dryRun)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, erasure)
+}
+
+// ListErasures returns the audit records of a user's erasures
+func (api *HttpAPI) ListErasures(w http.ResponseWriter, r *http.Request) {
+	erasures, err := api.service.Users.ListErasures(actorFromRequest(r), mux.Vars(r)["user"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, erasures)
+}
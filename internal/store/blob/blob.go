@@ -0,0 +1,60 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when a requested blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+/*
+BlobStore stores the raw data of artifacts outside of the
+database. Blobs are content addressed - their key is the
+hash of their data (see Key) - so identical data is only
+ever stored once, and it is up to the caller to only
+delete a blob once nothing refers to it.
+*/
+type BlobStore interface {
+	/*
+		Put stores data under the given key. If a blob with
+		the key already exists it is left as is.
+	*/
+	Put(key string, data []byte) error
+
+	/*
+		Get returns the data of a blob, or ErrNotFound
+	*/
+	Get(key string) ([]byte, error)
+
+	/*
+		Delete removes a blob. Deleting a blob that does not
+		exist is not an error.
+	*/
+	Delete(key string) error
+}
+
+/*
+Key returns the content address of data - its hex encoded
+SHA-256 hash.
+*/
+func Key(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+/*
+validateKey ensures a key is a content address, which also
+ensures it is safe to use as a path.
+*/
+func validateKey(key string) error {
+	if len(key) != sha256.Size*2 {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
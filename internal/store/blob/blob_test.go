@@ -0,0 +1,144 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+fakeS3 is a minimal stand in for an S3 compatible server,
+checking that requests are signed and keeping objects in
+memory.
+*/
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+	puts    int
+}
+
+func (server *fakeS3) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	authorization := request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=access/") ||
+		!strings.Contains(authorization, "/us-east-1/s3/aws4_request") ||
+		!strings.Contains(authorization, "Signature=") {
+		writer.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	hash := sha256.Sum256(body)
+	if request.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(hash[:]) {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	path := request.URL.Path
+	switch request.Method {
+	case http.MethodHead, http.MethodGet:
+		data, ok := server.objects[path]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+		if request.Method == http.MethodGet {
+			writer.Write(data)
+		}
+	case http.MethodPut:
+		server.objects[path] = body
+		server.puts++
+	case http.MethodDelete:
+		delete(server.objects, path)
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func testBlobStore(t *testing.T, blobs BlobStore) {
+	data := []byte("hello, world")
+	key := Key(data)
+
+	_, err := blobs.Get(key)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.Nil(t, blobs.Put(key, data))
+	// Putting the same blob again is a no-op
+	require.Nil(t, blobs.Put(key, data))
+
+	retrieved, err := blobs.Get(key)
+	require.Nil(t, err)
+	assert.Equal(t, data, retrieved)
+
+	require.Nil(t, blobs.Delete(key))
+	_, err = blobs.Get(key)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Deleting a missing blob is not an error
+	assert.Nil(t, blobs.Delete(key))
+
+	// Keys must be content addresses
+	assert.NotNil(t, blobs.Put("../../etc/passwd", data))
+}
+
+func TestMemoryBlobStore(t *testing.T) {
+	testBlobStore(t, NewMemoryBlobStore())
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	blobs, err := NewLocalBlobStore(t.TempDir())
+	require.Nil(t, err)
+	testBlobStore(t, blobs)
+}
+
+func TestS3BlobStore(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	blobs, err := NewS3BlobStore(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "artifacts",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "coppermind/",
+	})
+	require.Nil(t, err)
+	testBlobStore(t, blobs)
+
+	// Existing objects are not uploaded again
+	data := []byte("deduplicated")
+	require.Nil(t, blobs.Put(Key(data), data))
+	require.Nil(t, blobs.Put(Key(data), data))
+	assert.Equal(t, 2, fake.puts)
+	assert.Contains(t, fake.objects, "/artifacts/coppermind/"+Key(data))
+
+	_, err = NewS3BlobStore(S3Config{Endpoint: server.URL})
+	assert.NotNil(t, err)
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(
+		t,
+		"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		Key([]byte("hello world")),
+	)
+	assert.Nil(t, validateKey(Key([]byte("anything"))))
+	assert.NotNil(t, validateKey("not a key"))
+}
@@ -0,0 +1,59 @@
+package blob
+
+import "sync"
+
+/*
+MemoryBlobStore keeps blobs in memory; it is meant for tests
+and other throwaway stores.
+*/
+type MemoryBlobStore struct {
+	lock  sync.RWMutex
+	blobs map[string][]byte
+}
+
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{
+		blobs: map[string][]byte{},
+	}
+}
+
+func (store *MemoryBlobStore) Put(key string, data []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if _, ok := store.blobs[key]; !ok {
+		store.blobs[key] = append([]byte{}, data...)
+	}
+	return nil
+}
+
+func (store *MemoryBlobStore) Get(key string) ([]byte, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	data, ok := store.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (store *MemoryBlobStore) Delete(key string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.blobs, key)
+	return nil
+}
+
+// Len is the number of blobs stored
+func (store *MemoryBlobStore) Len() int {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	return len(store.blobs)
+}
@@ -0,0 +1,242 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3TimeFormat    = "20060102T150405Z"
+	s3DateFormat    = "20060102"
+	s3DefaultRegion = "us-east-1"
+)
+
+/*
+S3Config is the configuration for an S3 compatible blob
+store, ie AWS S3, MinIO, or R2. Requests are made path style
+(endpoint/bucket/key) so that any compatible server works.
+*/
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	// Prefix is prepended to every key, allowing several
+	// stores to share a bucket
+	Prefix string
+}
+
+/*
+S3BlobStore keeps blobs as objects in an S3 compatible
+bucket, signing requests with AWS Signature Version 4.
+*/
+type S3BlobStore struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+
+	// now is replaced in tests for deterministic signatures
+	now func() time.Time
+}
+
+func NewS3BlobStore(config S3Config) (*S3BlobStore, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("s3 endpoint must be set")
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket must be set")
+	}
+	if config.Region == "" {
+		config.Region = s3DefaultRegion
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("s3 endpoint must be an http or https url")
+	}
+
+	return &S3BlobStore{
+		config:   config,
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		now: time.Now,
+	}, nil
+}
+
+func (store *S3BlobStore) objectURL(key string) *url.URL {
+	object := *store.endpoint
+	object.Path = strings.TrimSuffix(object.Path, "/") + "/" + store.config.Bucket + "/" + store.config.Prefix + key
+	object.RawQuery = ""
+	return &object
+}
+
+/*
+do makes a signed request for the object with the given
+key, returning the response. The caller must close its body.
+*/
+func (store *S3BlobStore) do(method string, key string, body []byte) (*http.Response, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(method, store.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.ContentLength = int64(len(body))
+		request.Header.Set("Content-Type", "application/octet-stream")
+	}
+	store.sign(request, body)
+
+	return store.client.Do(request)
+}
+
+func (store *S3BlobStore) Put(key string, data []byte) error {
+	// Blobs are content addressed, so an existing object
+	// already holds the same data
+	response, err := store.do(http.MethodHead, key, nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	if data == nil {
+		data = []byte{}
+	}
+	response, err = store.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return s3Error(response)
+	}
+	return nil
+}
+
+func (store *S3BlobStore) Get(key string) ([]byte, error) {
+	response, err := store.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(response.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, s3Error(response)
+	}
+}
+
+func (store *S3BlobStore) Delete(key string) error {
+	response, err := store.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(response)
+	}
+}
+
+func s3Error(response *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return fmt.Errorf("s3 request failed with %s: %s", response.Status, strings.TrimSpace(string(body)))
+}
+
+/*
+sign adds the AWS Signature Version 4 headers to a request.
+See https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+*/
+func (store *S3BlobStore) sign(request *http.Request, body []byte) {
+	now := store.now().UTC()
+	timestamp := now.Format(s3TimeFormat)
+	date := now.Format(s3DateFormat)
+
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+
+	request.Header.Set("Host", request.URL.Host)
+	request.Header.Set("X-Amz-Date", timestamp)
+	request.Header.Set("X-Amz-Content-Sha256", payload)
+
+	signedHeaders := []string{}
+	for name := range request.Header {
+		signedHeaders = append(signedHeaders, strings.ToLower(name))
+	}
+	sort.Strings(signedHeaders)
+
+	canonicalHeaders := ""
+	for _, name := range signedHeaders {
+		canonicalHeaders += name + ":" + strings.TrimSpace(request.Header.Get(name)) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.Query().Encode(),
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		payload,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+
+	credentialScope := strings.Join([]string{date, store.config.Region, s3Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		timestamp,
+		credentialScope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+store.config.SecretKey), date)
+	key = hmacSHA256(key, store.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm,
+		store.config.AccessKey,
+		credentialScope,
+		strings.Join(signedHeaders, ";"),
+		signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -2,6 +2,9 @@ package store
 
 import (
 	"time"
+
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/quota"
 )
 
 // HighLevelStore interface is the heart of our system's memory
@@ -27,6 +30,27 @@ type HighLevelStore interface {
 	*/
 	Migrate() error
 
+	//===============================
+	// Users
+	//===============================
+
+	/*
+		CountUserData counts every record of a user's data, by
+		kind, without changing anything - what EraseUser would
+		erase.
+	*/
+	CountUserData(user string) (map[string]int, error)
+
+	/*
+		EraseUser removes all of a user's data - their profile,
+		messages and artifacts, summaries, knowledge, usage,
+		and quotas - returning how many records of each kind
+		were erased. Blobs are deleted once no other artifact
+		refers to them. Agent revisions they authored are kept
+		for the agent, with the author blanked.
+	*/
+	EraseUser(user string) (map[string]int, error)
+
 	//===============================
 	// Messages
 	//===============================
@@ -78,10 +102,43 @@ type HighLevelStore interface {
 	*/
 	GetConversationsToSummarize(minMessages int, minAge time.Duration, maxLength int) ([]string, error)
 
+	//===============================
+	// Quotas
+	//===============================
+
+	/*
+		IncrementQuota atomically adds the given requests and
+		tokens to the counter for the given scope, period, and
+		period start, creating it if it does not exist yet. The
+		resulting counter is returned. Negative values may be
+		passed to roll back a prior increment.
+	*/
+	IncrementQuota(scope string, period string, periodStart time.Time, requests int, tokens int) (*quota.Counter, error)
+
+	/*
+		GetQuota returns the counter for the given scope, period,
+		and period start. If no counter exists, a zeroed counter
+		is returned.
+	*/
+	GetQuota(scope string, period string, periodStart time.Time) (*quota.Counter, error)
+
+	/*
+		ExpireQuotas deletes all counters whose period started
+		before the given time.
+	*/
+	ExpireQuotas(before time.Time) error
+
 	//===============================
 	// Knowledge
 	//===============================
 
+	/*
+		GetKnowledgeGraph returns an agent's current knowledge of
+		a user as a graph of entities, with their aliases
+		resolved. Expired and superseded facts are not included.
+	*/
+	GetKnowledgeGraph(agent string, user string) (*memory.Graph, error)
+
 	/*
 		SaveKnowledge takes a given bit of knowledge and saves
 		it.
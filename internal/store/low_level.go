@@ -2,8 +2,13 @@ package store
 
 import (
 	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/hlfshell/coppermind/pkg/experiments"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/usage"
 	"github.com/hlfshell/coppermind/pkg/users"
 
 	users_internal "github.com/hlfshell/coppermind/internal/users"
@@ -60,6 +65,11 @@ type LowLevelStore interface {
 	*/
 	GenerateUserPasswordResetToken(id string) (string, error)
 
+	/*
+		SetUserRole will change a user's role
+	*/
+	SetUserRole(id string, role string) error
+
 	/*
 		ResetPassword updates the user's password only.
 		Any rules around password changes are handled elsewhere.
@@ -73,10 +83,28 @@ type LowLevelStore interface {
 	/*
 		DeleteUser will delete a user given its ID.
 		Note that this does *not* remove any of the
-		user's histories or other data.
+		user's histories or other data - see EraseUser.
 	*/
 	DeleteUser(id string) error
 
+	/*
+		ListUsers will return all users in the store, oldest
+		first
+	*/
+	ListUsers() ([]*users.User, error)
+
+	/*
+		SaveErasure records that a user's data was erased.
+		Erasure records are write-once.
+	*/
+	SaveErasure(erasure *users.Erasure) error
+
+	/*
+		ListErasures will return all erasure records that match
+		a given filter's criteria, oldest first
+	*/
+	ListErasures(query Filter) ([]*users.Erasure, error)
+
 	//===============================
 	// Agents
 	//===============================
@@ -103,6 +131,96 @@ type LowLevelStore interface {
 	*/
 	ListAgents() ([]*agents.Agent, error)
 
+	/*
+		SaveAgentMember will add a user as a member of an
+		agent. Saving an existing membership does nothing.
+	*/
+	SaveAgentMember(member *agents.Member) error
+
+	/*
+		DeleteAgentMember will remove a user's membership of
+		an agent
+	*/
+	DeleteAgentMember(agent string, user string) error
+
+	/*
+		ListAgentMembers will return all memberships that match
+		a given filter's criteria, oldest first
+	*/
+	ListAgentMembers(filter Filter) ([]*agents.Member, error)
+
+	/*
+		SaveAgentRevision will save a version of an agent's
+		identity. Revisions are write-once.
+	*/
+	SaveAgentRevision(revision *agents.Revision) error
+
+	/*
+		GetAgentRevision will return a single version of an
+		agent's identity
+	*/
+	GetAgentRevision(agent string, version int) (*agents.Revision, error)
+
+	/*
+		ListAgentRevisions will return all revisions that match
+		a given filter's criteria, oldest version first
+	*/
+	ListAgentRevisions(filter Filter) ([]*agents.Revision, error)
+
+	/*
+		SavePromptTemplate will upsert save a prompt template,
+		keyed by its agent ("" for every agent) and name
+	*/
+	SavePromptTemplate(prompt *agents.PromptTemplate) error
+
+	/*
+		GetPromptTemplate will return the prompt template of
+		the given agent and name, or nil if there is none
+	*/
+	GetPromptTemplate(agent string, name string) (*agents.PromptTemplate, error)
+
+	/*
+		DeletePromptTemplate will delete the prompt template of
+		the given agent and name
+	*/
+	DeletePromptTemplate(agent string, name string) error
+
+	/*
+		ListPromptTemplates will return all prompt templates
+		that match a given filter's criteria, ordered by agent
+		and name
+	*/
+	ListPromptTemplates(filter Filter) ([]*agents.PromptTemplate, error)
+
+	//===============================
+	// Organizations
+	//===============================
+
+	/*
+		SaveOrganization will upsert save a given organization.
+		An organization's creation time is kept when it is
+		updated.
+	*/
+	SaveOrganization(org *tenants.Organization) error
+
+	/*
+		GetOrganization will return an organization given its
+		ID
+	*/
+	GetOrganization(id string) (*tenants.Organization, error)
+
+	/*
+		DeleteOrganization will delete an organization given
+		its ID. Its agents, users and their data are kept.
+	*/
+	DeleteOrganization(id string) error
+
+	/*
+		ListOrganizations will return all organizations in the
+		store, oldest first
+	*/
+	ListOrganizations() ([]*tenants.Organization, error)
+
 	//===============================
 	// Messages
 	//===============================
@@ -118,15 +236,72 @@ type LowLevelStore interface {
 	GetMessage(id string) (*chat.Message, error)
 
 	/*
-		DeleteMessage will delete a message given its ID
+		DeleteMessage will delete a message given its ID,
+		along with its artifacts
 	*/
 	DeleteMessage(id string) error
 
 	/*
-		ListMessages will return all messages in the store
+		ListMessages will return all messages in the store.
+		Their artifacts are included without their data; use
+		GetArtifact to load it.
 	*/
 	ListMessages(query Filter) ([]*chat.Message, error)
 
+	/*
+		SaveFeedback will upsert save a user's rating of a
+		message, keyed by the message and user
+	*/
+	SaveFeedback(feedback *chat.Feedback) error
+
+	/*
+		ListFeedback will return all feedback that matches a
+		given filter's criteria, oldest first
+	*/
+	ListFeedback(filter Filter) ([]*chat.Feedback, error)
+
+	//===============================
+	// Artifacts
+	//===============================
+
+	/*
+		SaveArtifact will validate and save a given artifact,
+		attaching it to its message. Artifacts included on a
+		message are saved with SaveMessage; this is for adding
+		artifacts to an existing message.
+	*/
+	SaveArtifact(artifact *artifacts.ArtifactData) error
+
+	/*
+		GetArtifact will return an artifact given its ID,
+		including its data
+	*/
+	GetArtifact(id string) (*artifacts.ArtifactData, error)
+
+	//===============================
+	// Documents
+	//===============================
+
+	/*
+		SaveChunks will save the chunks of an indexed document,
+		all or none of them
+	*/
+	SaveChunks(chunks []*documents.Chunk) error
+
+	/*
+		ListChunks will return all document chunks that match
+		a given filter's criteria, oldest document first and in
+		order within each document
+	*/
+	ListChunks(query Filter) ([]*documents.Chunk, error)
+
+	/*
+		DeleteChunks will delete the chunks of a document given
+		its artifact ID. Chunks are also deleted along with
+		their artifacts.
+	*/
+	DeleteChunks(document string) error
+
 	//===============================
 	// Conversations
 	//===============================
@@ -152,7 +327,7 @@ type LowLevelStore interface {
 
 	/*
 		DeleteConversation will delete a conversation given its
-		ID
+		ID, along with the artifacts of its messages
 	*/
 	DeleteConversation(id string) error
 
@@ -201,7 +376,126 @@ type LowLevelStore interface {
 	*/
 	DeleteSummaryExclusion(conversation string) error
 
+	/*
+		ListSummaryExclusions will return every conversation
+		excluded from summarization
+	*/
+	ListSummaryExclusions() ([]string, error)
+
 	//===============================
 	// Knowledge
 	//===============================
+
+	/*
+		SaveKnowledge will save a given fact into the store
+	*/
+	SaveKnowledge(fact *memory.Knowledge) error
+
+	/*
+		GetKnowledge will return a fact given its ID
+	*/
+	GetKnowledge(id string) (*memory.Knowledge, error)
+
+	/*
+		ListKnowledge will return all knowledge that matches a
+		given filter's criteria, oldest first
+	*/
+	ListKnowledge(query Filter) ([]*memory.Knowledge, error)
+
+	/*
+		CompressKnowledge saves the result of compressing an agent
+		and user's knowledge - the expiration and supersession of
+		each given fact - in a single transaction. Facts are never
+		deleted by compression, so that their history is kept.
+	*/
+	CompressKnowledge(agent string, user string, knowledge []*memory.Knowledge) error
+
+	/*
+		UpdateKnowledge saves a correction to a fact - its
+		subject, predicate, object, expiration, and whether it
+		is pinned
+	*/
+	UpdateKnowledge(fact *memory.Knowledge) error
+
+	/*
+		DeleteKnowledge will delete a fact given its ID. Facts
+		it superseded are made current again.
+	*/
+	DeleteKnowledge(id string) error
+
+	/*
+		DeleteConversationKnowledge deletes the facts extracted
+		from a conversation. Facts they superseded are made
+		current again.
+	*/
+	DeleteConversationKnowledge(conversation string) error
+
+	/*
+		SaveAlias saves another name for an entity in an agent's
+		knowledge of a user. Both names are normalized, and an
+		existing alias is pointed at the new entity.
+	*/
+	SaveAlias(alias *memory.Alias) error
+
+	/*
+		ListAliases will return all aliases that match a given
+		filter's criteria, oldest first
+	*/
+	ListAliases(query Filter) ([]*memory.Alias, error)
+
+	/*
+		DeleteAlias will delete an agent's alias for a user
+	*/
+	DeleteAlias(agent string, user string, alias string) error
+
+	//===============================
+	// Usage
+	//===============================
+
+	/*
+		SaveUsage records the token usage of a single LLM call.
+		Usage records are write-once.
+	*/
+	SaveUsage(usage *usage.Usage) error
+
+	/*
+		ListUsage will return all usage records that match a given
+		filter's criteria, oldest first
+	*/
+	ListUsage(query Filter) ([]*usage.Usage, error)
+
+	/*
+		SaveRoute records which backend the LLM router attempted
+		for a single call, and whether it succeeded. Route
+		records are write-once.
+	*/
+	SaveRoute(route *usage.Route) error
+
+	/*
+		ListRoutes will return all route records that match a
+		given filter's criteria, oldest first
+	*/
+	ListRoutes(query Filter) ([]*usage.Route, error)
+
+	//===============================
+	// Experiments
+	//===============================
+
+	/*
+		SaveExperiment will upsert save an experiment and its
+		variants
+	*/
+	SaveExperiment(experiment *experiments.Experiment) error
+
+	/*
+		GetExperiment will return an experiment given its ID,
+		or nil if there is none
+	*/
+	GetExperiment(id string) (*experiments.Experiment, error)
+
+	/*
+		ListExperiments will return all experiments that match
+		a given filter's criteria, oldest first
+	*/
+	ListExperiments(filter Filter) ([]*experiments.Experiment, error)
 }
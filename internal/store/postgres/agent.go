@@ -1,22 +1,55 @@
 package postgres
 
 import (
+	"database/sql"
+	"encoding/json"
+
 	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/wissance/stringFormatter"
 )
 
-const agentSelectColumns = `id, name, identity`
+const agentSelectColumns = `id, name, identity, llm, tools, tenant, visibility, version, memory`
 
 func (store *PostgresStore) SaveAgent(agent *agents.Agent) error {
-	query := `INSERT INTO {0} ({1}) VALUES($1, $2, $3)`
+	query := `INSERT INTO {0} ({1}) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			identity = EXCLUDED.identity,
+			llm = EXCLUDED.llm,
+			tools = EXCLUDED.tools,
+			tenant = EXCLUDED.tenant,
+			visibility = EXCLUDED.visibility,
+			version = EXCLUDED.version,
+			memory = EXCLUDED.memory`
 
 	query = stringFormatter.Format(query, AGENTS_TABLE, agentSelectColumns)
 
-	_, err := store.db.Exec(
+	settings, err := json.Marshal(agent.LLM)
+	if err != nil {
+		return err
+	}
+
+	tools, err := json.Marshal(agent.Tools)
+	if err != nil {
+		return err
+	}
+
+	memory, err := json.Marshal(agent.Memory)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
 		query,
 		agent.ID,
 		agent.Name,
 		agent.Identity,
+		string(settings),
+		string(tools),
+		agent.Tenant,
+		agent.Visibility,
+		agent.Version,
+		string(memory),
 	)
 
 	return err
@@ -31,19 +64,15 @@ func (store *PostgresStore) GetAgent(id string) (*agents.Agent, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var agent agents.Agent
-		err = rows.Scan(
-			&agent.ID,
-			&agent.Name,
-			&agent.Identity,
-		)
-		return &agent, err
+	foundAgents, err := store.sqlToAgents(rows)
+	if err != nil {
+		return nil, err
+	} else if len(foundAgents) == 0 {
+		return nil, nil
 	}
 
-	return nil, nil
+	return foundAgents[0], nil
 }
 
 func (store *PostgresStore) DeleteAgent(id string) error {
@@ -64,20 +93,46 @@ func (store *PostgresStore) ListAgents() ([]*agents.Agent, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	return store.sqlToAgents(rows)
+}
+
+func (store *PostgresStore) sqlToAgents(rows *sql.Rows) ([]*agents.Agent, error) {
 	defer rows.Close()
 
 	var foundAgents []*agents.Agent
 
 	for rows.Next() {
 		var agent agents.Agent
-		err = rows.Scan(
+		var settings string
+		var tools string
+		var memory string
+		err := rows.Scan(
 			&agent.ID,
 			&agent.Name,
 			&agent.Identity,
+			&settings,
+			&tools,
+			&agent.Tenant,
+			&agent.Visibility,
+			&agent.Version,
+			&memory,
 		)
 		if err != nil {
 			return nil, err
 		}
+		err = json.Unmarshal([]byte(settings), &agent.LLM)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(tools), &agent.Tools)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(memory), &agent.Memory)
+		if err != nil {
+			return nil, err
+		}
 		foundAgents = append(foundAgents, &agent)
 	}
 
@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/wissance/stringFormatter"
+)
+
+const chunkSelectColumns = `id, document, filename, agent, userId, conversation, idx, content, model, embedding, created_at`
+
+func (store *PostgresStore) SaveChunks(chunks []*documents.Chunk) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, {2})`
+
+	query = stringFormatter.Format(query, DOCUMENT_CHUNKS_TABLE, chunkSelectColumns, agentTenant(12))
+
+	// A document is indexed all at once or not at all
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, chunk := range chunks {
+		embedding, err := json.Marshal(chunk.Embedding)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			query,
+			chunk.ID,
+			chunk.Document,
+			chunk.Filename,
+			chunk.Agent,
+			chunk.User,
+			chunk.Conversation,
+			chunk.Index,
+			chunk.Content,
+			chunk.Model,
+			string(embedding),
+			chunk.CreatedAt,
+			chunk.Agent,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresStore) ListChunks(filter store.Filter) ([]*documents.Chunk, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC, idx ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": chunkSelectColumns,
+			"table":   DOCUMENT_CHUNKS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToChunks(rows)
+}
+
+func (store *PostgresStore) DeleteChunks(document string) error {
+	query := `DELETE FROM {0} WHERE document = $1`
+
+	query = stringFormatter.Format(query, DOCUMENT_CHUNKS_TABLE)
+
+	_, err := store.db.Exec(query, document)
+	return err
+}
+
+func (store *PostgresStore) sqlToChunks(rows *sql.Rows) ([]*documents.Chunk, error) {
+	defer rows.Close()
+
+	chunks := []*documents.Chunk{}
+
+	for rows.Next() {
+		var chunk documents.Chunk
+		var embedding string
+		err := rows.Scan(
+			&chunk.ID,
+			&chunk.Document,
+			&chunk.Filename,
+			&chunk.Agent,
+			&chunk.User,
+			&chunk.Conversation,
+			&chunk.Index,
+			&chunk.Content,
+			&chunk.Model,
+			&embedding,
+			&chunk.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(embedding), &chunk.Embedding)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, &chunk)
+	}
+
+	return chunks, nil
+}
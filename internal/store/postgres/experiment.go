@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/wissance/stringFormatter"
+)
+
+const experimentSelectColumns = `id, agent, name, variants, active, created_at, ended_at`
+
+func (store *PostgresStore) SaveExperiment(experiment *experiments.Experiment) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, {2})
+		ON CONFLICT (id) DO UPDATE SET
+			agent = EXCLUDED.agent,
+			name = EXCLUDED.name,
+			variants = EXCLUDED.variants,
+			active = EXCLUDED.active,
+			created_at = EXCLUDED.created_at,
+			ended_at = EXCLUDED.ended_at,
+			tenant = EXCLUDED.tenant`
+
+	query = stringFormatter.Format(query, EXPERIMENTS_TABLE, experimentSelectColumns, agentTenant(2))
+
+	variants, err := json.Marshal(experiment.Variants)
+	if err != nil {
+		return err
+	}
+
+	if experiment.CreatedAt.IsZero() {
+		experiment.CreatedAt = time.Now()
+	}
+
+	_, err = store.db.Exec(
+		query,
+		experiment.ID,
+		experiment.Agent,
+		experiment.Name,
+		string(variants),
+		experiment.Active,
+		experiment.CreatedAt,
+		nullTime(experiment.EndedAt),
+	)
+
+	return err
+}
+
+func (store *PostgresStore) GetExperiment(id string) (*experiments.Experiment, error) {
+	query := `SELECT {0} FROM {1} WHERE id = $1`
+
+	query = stringFormatter.Format(query, experimentSelectColumns, EXPERIMENTS_TABLE)
+
+	rows, err := store.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := store.sqlToExperiments(rows)
+	if err != nil {
+		return nil, err
+	} else if len(found) == 0 {
+		return nil, nil
+	}
+
+	return found[0], nil
+}
+
+func (store *PostgresStore) ListExperiments(filter store.Filter) ([]*experiments.Experiment, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": experimentSelectColumns,
+			"table":   EXPERIMENTS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToExperiments(rows)
+}
+
+func (store *PostgresStore) sqlToExperiments(rows *sql.Rows) ([]*experiments.Experiment, error) {
+	defer rows.Close()
+
+	found := []*experiments.Experiment{}
+
+	for rows.Next() {
+		var experiment experiments.Experiment
+		var variants string
+		var ended sql.NullTime
+		err := rows.Scan(
+			&experiment.ID,
+			&experiment.Agent,
+			&experiment.Name,
+			&variants,
+			&experiment.Active,
+			&experiment.CreatedAt,
+			&ended,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(variants), &experiment.Variants)
+		if err != nil {
+			return nil, err
+		}
+		if ended.Valid {
+			experiment.EndedAt = ended.Time
+		}
+		found = append(found, &experiment)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/wissance/stringFormatter"
+)
+
+const feedbackSelectColumns = `message, agent, userId, rating, comment, tags, created_at`
+
+func (store *PostgresStore) SaveFeedback(feedback *chat.Feedback) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, {2})
+		ON CONFLICT (message, userId) DO UPDATE SET
+			agent = EXCLUDED.agent,
+			rating = EXCLUDED.rating,
+			comment = EXCLUDED.comment,
+			tags = EXCLUDED.tags,
+			created_at = EXCLUDED.created_at`
+
+	query = stringFormatter.Format(query, FEEDBACK_TABLE, feedbackSelectColumns, messageTenant(1))
+
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+
+	tags, err := json.Marshal(feedback.Tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
+		query,
+		feedback.Message,
+		feedback.Agent,
+		feedback.User,
+		feedback.Rating,
+		feedback.Comment,
+		string(tags),
+		feedback.CreatedAt,
+	)
+
+	return err
+}
+
+func (store *PostgresStore) ListFeedback(filter store.Filter) ([]*chat.Feedback, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": feedbackSelectColumns,
+			"table":   FEEDBACK_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToFeedback(rows)
+}
+
+func (store *PostgresStore) sqlToFeedback(rows *sql.Rows) ([]*chat.Feedback, error) {
+	defer rows.Close()
+
+	feedback := []*chat.Feedback{}
+
+	for rows.Next() {
+		var rating chat.Feedback
+		var tags string
+		err := rows.Scan(
+			&rating.Message,
+			&rating.Agent,
+			&rating.User,
+			&rating.Rating,
+			&rating.Comment,
+			&tags,
+			&rating.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(tags), &rating.Tags)
+		if err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, &rating)
+	}
+
+	return feedback, nil
+}
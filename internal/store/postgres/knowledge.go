@@ -2,31 +2,27 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
+	"github.com/hlfshell/coppermind/internal/store"
 	"github.com/hlfshell/coppermind/pkg/memory"
 	"github.com/wissance/stringFormatter"
 )
 
+const knowledgeSelectColumns = `id, agent, userId, subject, predicate, object, created_at, expires_at, superseded_by, superseded_at, conversation, messages, confidence, pinned`
+
 func (store *PostgresStore) SaveKnowledge(fact *memory.Knowledge) error {
-	query := `
-		INSERT INTO {0}
-		(
-			id,
-			agent,
-			user,
-			subject,
-			predicate,
-			object,
-			created_at,
-			expires_at
-		)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
-	`
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, {2})`
 
-	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+	query = stringFormatter.Format(query, KNOWLEDGE_TABLE, knowledgeSelectColumns, agentTenant(2))
 
-	_, err := store.db.Exec(
+	messages, err := json.Marshal(fact.Messages)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
 		query,
 		fact.ID,
 		fact.Agent,
@@ -36,29 +32,21 @@ func (store *PostgresStore) SaveKnowledge(fact *memory.Knowledge) error {
 		fact.Object,
 		fact.CreatedAt,
 		fact.ExpiresAt,
+		fact.SupersededBy,
+		nullTime(fact.SupersededAt),
+		fact.Conversation,
+		string(messages),
+		fact.Confidence,
+		fact.Pinned,
 	)
 
 	return err
 }
 
 func (store *PostgresStore) GetKnowledge(id string) (*memory.Knowledge, error) {
-	query := `
-		SELECT
-			id,
-			agent,
-			user,
-			subject,
-			predicate,
-			object,
-			created_at,
-			expires_at
-		FROM
-			{0}
-		WHERE
-			id = ?
-	`
+	query := `SELECT {0} FROM {1} WHERE id = $1`
 
-	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+	query = stringFormatter.Format(query, knowledgeSelectColumns, KNOWLEDGE_TABLE)
 
 	rows, err := store.db.Query(query, id)
 	if err != nil {
@@ -75,6 +63,44 @@ func (store *PostgresStore) GetKnowledge(id string) (*memory.Knowledge, error) {
 	return knowledge[0], nil
 }
 
+func (store *PostgresStore) ListKnowledge(filter store.Filter) ([]*memory.Knowledge, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": knowledgeSelectColumns,
+			"table":   KNOWLEDGE_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToKnowledge(rows)
+}
+
 func (store *PostgresStore) GetKnowlegeByAgentAndUser(agent string, user string) ([]*memory.Knowledge, error) {
 	query := `
 		SELECT 
@@ -85,7 +111,13 @@ func (store *PostgresStore) GetKnowlegeByAgentAndUser(agent string, user string)
 			predicate,
 			object,
 			created_at,
-			expires_at
+			expires_at,
+			superseded_by,
+			superseded_at,
+			conversation,
+			messages,
+			confidence,
+			pinned
 		FROM
 			{0}
 		WHERE
@@ -106,7 +138,7 @@ func (store *PostgresStore) ExpireKnowledge() error {
 	query := `
 		DELETE FROM {0}
 		WHERE
-			expires_at < ?
+			expires_at < ? AND pinned = FALSE
 	`
 
 	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
@@ -125,7 +157,13 @@ func (store *PostgresStore) GetKnowledgeGroupedByAgentAndUser(agent string, user
 			predicate,
 			object,
 			created_at,
-			expires_at
+			expires_at,
+			superseded_by,
+			superseded_at,
+			conversation,
+			messages,
+			confidence,
+			pinned
 		FROM
 			{0}
 	`
@@ -199,57 +237,152 @@ func (store *PostgresStore) SetConversationAsKnowledgeExtracted(conversation str
 		INSERT INTO {0}
 		(
 			conversation,
-			updated_at
+			updated_at,
+			tenant
 		)
-		VALUES(?, ?)
+		VALUES($1, $2, {1})
 	`
 
-	query = stringFormatter.Format(query, KNOWLEDGE_EXTRACTION_TABLE)
+	query = stringFormatter.Format(query, KNOWLEDGE_EXTRACTION_TABLE, conversationTenant(1))
 
 	_, err := store.db.Exec(query, conversation, time.Now())
 	return err
 }
 
 func (store *PostgresStore) CompressKnowledge(agent string, user string, knowledge []*memory.Knowledge) error {
-	// Start a sqlite transaction
+	query := `
+		UPDATE {0}
+		SET
+			expires_at = $1,
+			superseded_by = $2,
+			superseded_at = $3
+		WHERE
+			id = $4 AND agent = $5 AND userId = $6
+	`
+
+	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+
+	// The knowledge is compressed all at once or not at all
 	tx, err := store.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	for _, fact := range knowledge {
+		_, err = tx.Exec(
+			query,
+			fact.ExpiresAt,
+			fact.SupersededBy,
+			nullTime(fact.SupersededAt),
+			fact.ID,
+			agent,
+			user,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresStore) UpdateKnowledge(fact *memory.Knowledge) error {
+	query := `
+		UPDATE {0}
+		SET
+			subject = $1,
+			predicate = $2,
+			object = $3,
+			expires_at = $4,
+			pinned = $5
+		WHERE
+			id = $6
+	`
 
-	// Delete all the knowledge that belongs to the agent and user, to be replaced by our incoming knowledge
-	query := `DELETE FROM {0} WHERE agent = ? AND user = ?`
 	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
-	_, err = tx.Exec(query, agent, user)
+
+	_, err := store.db.Exec(
+		query,
+		fact.Subject,
+		fact.Predicate,
+		fact.Object,
+		fact.ExpiresAt,
+		fact.Pinned,
+		fact.ID,
+	)
+
+	return err
+}
+
+func (store *PostgresStore) DeleteKnowledge(id string) error {
+	restore := `UPDATE {0} SET superseded_by = '', superseded_at = NULL WHERE superseded_by = $1`
+	restore = stringFormatter.Format(restore, KNOWLEDGE_TABLE)
+
+	query := `DELETE FROM {0} WHERE id = $1`
+	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+
+	tx, err := store.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Insert the new knowledge with our transaction all in one query
-	query = `
-		INSERT INTO {0}
-		(
-			id,
-			agent,
-			user,
-			subject,
-			predicate,
-			object,
-			created_at,
-			expires_at
-		)
-		VALUES 
-		`
+	// Facts that were superseded by the deleted fact are
+	// current again
+	_, err = tx.Exec(restore, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresStore) DeleteConversationKnowledge(conversation string) error {
+	// Facts from before provenance was recorded have no
+	// conversation, and must not be deleted together
+	if conversation == "" {
+		return nil
+	}
+
+	restore := `
+		UPDATE {0}
+		SET
+			superseded_by = '',
+			superseded_at = NULL
+		WHERE
+			superseded_by IN (SELECT id FROM {0} WHERE conversation = $1) AND
+			conversation != $2
+	`
+	restore = stringFormatter.Format(restore, KNOWLEDGE_TABLE)
+
+	query := `DELETE FROM {0} WHERE conversation = $1`
 	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
-	for i := 0; i < len(knowledge); i++ {
-		query += "(?, ?, ?, ?, ?, ?, ?, ?)"
-		if i < len(knowledge)-1 {
-			query += ", "
-		}
+
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	_, err = store.db.Exec(query, knowledge)
-	return err
+	// Facts that were superseded by the deleted facts are
+	// current again
+	_, err = tx.Exec(restore, conversation, conversation)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, conversation)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (store *PostgresStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge, error) {
@@ -259,8 +392,8 @@ func (store *PostgresStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge,
 
 	for rows.Next() {
 		var fact memory.Knowledge
-		var datetime string
-		var expiration string
+		var messages string
+		var expiration, superseded sql.NullTime
 		err := rows.Scan(
 			&fact.ID,
 			&fact.Agent,
@@ -268,22 +401,24 @@ func (store *PostgresStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge,
 			&fact.Subject,
 			&fact.Predicate,
 			&fact.Object,
-			&datetime,
+			&fact.CreatedAt,
 			&expiration,
+			&fact.SupersededBy,
+			&superseded,
+			&fact.Conversation,
+			&messages,
+			&fact.Confidence,
+			&fact.Pinned,
 		)
 		if err != nil {
 			return nil, err
 		}
-		timestamp, err := store.sqlTimestampToTime(datetime)
-		if err != nil {
-			return nil, err
-		}
-		fact.CreatedAt = timestamp
-		timestamp, err = store.sqlTimestampToTime(expiration)
+		err = json.Unmarshal([]byte(messages), &fact.Messages)
 		if err != nil {
 			return nil, err
 		}
-		fact.ExpiresAt = timestamp
+		fact.ExpiresAt = expiration.Time
+		fact.SupersededAt = superseded.Time
 		knowledge = append(knowledge, &fact)
 	}
 
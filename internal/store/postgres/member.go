@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/wissance/stringFormatter"
+)
+
+const memberSelectColumns = `agent, userId, created_at`
+
+func (store *PostgresStore) SaveAgentMember(member *agents.Member) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, {2})
+		ON CONFLICT (agent, userId) DO NOTHING`
+
+	query = stringFormatter.Format(query, AGENT_MEMBERS_TABLE, memberSelectColumns, agentTenant(1))
+
+	if member.CreatedAt.IsZero() {
+		member.CreatedAt = time.Now()
+	}
+
+	_, err := store.db.Exec(
+		query,
+		member.Agent,
+		member.User,
+		member.CreatedAt,
+	)
+
+	return err
+}
+
+func (store *PostgresStore) DeleteAgentMember(agent string, user string) error {
+	query := `DELETE FROM {0} WHERE agent = $1 AND userId = $2`
+
+	query = stringFormatter.Format(query, AGENT_MEMBERS_TABLE)
+
+	_, err := store.db.Exec(query, agent, user)
+	return err
+}
+
+func (store *PostgresStore) ListAgentMembers(filter store.Filter) ([]*agents.Member, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": memberSelectColumns,
+			"table":   AGENT_MEMBERS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToMembers(rows)
+}
+
+func (store *PostgresStore) sqlToMembers(rows *sql.Rows) ([]*agents.Member, error) {
+	defer rows.Close()
+
+	members := []*agents.Member{}
+
+	for rows.Next() {
+		var member agents.Member
+		err := rows.Scan(
+			&member.Agent,
+			&member.User,
+			&member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, &member)
+	}
+
+	return members, nil
+}
@@ -2,23 +2,52 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
 
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
 	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
 	"github.com/wissance/stringFormatter"
 )
 
-const messageSelectColumns = `id, conversation, userId, agent, author, content, created_at`
-const artifactDataSelectColumns = `id, message, type, data, created_at`
+const messageSelectColumns = `id, conversation, userId, agent, author, content, tool_calls, created_at, agent_version, experiment, variant, duration_ms`
+
+// Artifact data itself is only selected when a single artifact
+// is requested; messages are listed with their artifacts'
+// details alone
+const artifactDataSelectColumns = `id, message, type, mime_type, size, filename, metadata, blob, created_at`
+const artifactDataWithDataColumns = artifactDataSelectColumns + `, data`
+
+// Each artifact is inserted with its columns and the message
+// that its tenant is taken from
+const artifactDataColumnCount = 11
 
 func (store *PostgresStore) SaveMessage(msg *chat.Message) error {
-	query := `INSERT INTO {0} ({1}) VALUES($1, $2, $3, $4, $5, $6, $7)`
+	// Artifacts are validated first, so that an invalid
+	// artifact doesn't leave a message saved without it
+	for _, artifact := range msg.Artifacts {
+		if err := artifact.Validate(); err != nil {
+			return err
+		}
+	}
 
-	query = stringFormatter.Format(query, MESSAGES_TABLE, messageSelectColumns)
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, {2})`
+
+	query = stringFormatter.Format(query, MESSAGES_TABLE, messageSelectColumns, agentTenant(13))
+
+	// Tool calls are stored as JSON, or NULL if there are none
+	var toolCalls sql.NullString
+	if len(msg.ToolCalls) > 0 {
+		encoded, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			return err
+		}
+		toolCalls = sql.NullString{String: string(encoded), Valid: true}
+	}
 
 	_, err := store.db.Exec(
 		query,
@@ -28,7 +57,13 @@ func (store *PostgresStore) SaveMessage(msg *chat.Message) error {
 		msg.Agent,
 		msg.From,
 		msg.Content,
+		toolCalls,
 		msg.CreatedAt,
+		msg.AgentVersion,
+		msg.Experiment,
+		msg.Variant,
+		msg.Duration,
+		msg.Agent,
 	)
 	if err != nil {
 		return err
@@ -44,9 +79,9 @@ func (store *PostgresStore) saveArtifactData(data []*artifacts.ArtifactData) err
 		return nil
 	}
 
-	query := `INSERT INTO {0} ({1}) VALUES {2}`
+	query := `INSERT INTO {0} ({1}, tenant) VALUES {2}`
 
-	// We need a ($1, $2, $3, $4, $5) for each artifact data, with a comma
+	// We need a ($1, ..., $11) for each artifact data, with a comma
 	// between each set of values and obviously increasing over time
 	placeholders := ""
 	for i := 0; i < len(data); i++ {
@@ -55,22 +90,62 @@ func (store *PostgresStore) saveArtifactData(data []*artifacts.ArtifactData) err
 		}
 
 		// We need to do $# for each, starting at 1
-		// and jumping by 5 for each set
-		placeholders += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
+		// and jumping by the column count for each set
+		placeholders += "("
+		for column := 1; column <= artifactDataColumnCount; column++ {
+			if column > 1 {
+				placeholders += ", "
+			}
+			if column == artifactDataColumnCount {
+				placeholders += messageTenant(i*artifactDataColumnCount + column)
+			} else {
+				placeholders += fmt.Sprintf("$%d", i*artifactDataColumnCount+column)
+			}
+		}
+		placeholders += ")"
 	}
 
-	query = stringFormatter.Format(query, ARTIFACTS_TABLE, artifactDataSelectColumns, placeholders)
+	query = stringFormatter.Format(query, ARTIFACTS_TABLE, artifactDataWithDataColumns, placeholders)
 
 	// Now we need to flatten the data into a single array of values
 	values := []interface{}{}
 	for _, artifact := range data {
+		// Metadata is stored as JSON, or NULL if there is none
+		var metadata sql.NullString
+		if len(artifact.Metadata) > 0 {
+			encoded, err := json.Marshal(artifact.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = sql.NullString{String: string(encoded), Valid: true}
+		}
+
+		// If we have a blob store the data is written to it,
+		// keeping only its key here
+		var blobKey sql.NullString
+		inline := artifact.Data
+		if store.blobs != nil {
+			artifact.Blob = blob.Key(artifact.Data)
+			if err := store.blobs.Put(artifact.Blob, artifact.Data); err != nil {
+				return err
+			}
+			blobKey = sql.NullString{String: artifact.Blob, Valid: true}
+			inline = nil
+		}
+
 		values = append(
 			values,
 			artifact.ID,
 			artifact.Message,
 			artifact.Type,
-			artifact.Data,
+			artifact.MimeType,
+			artifact.Size,
+			artifact.Filename,
+			metadata,
+			blobKey,
 			artifact.CreatedAt,
+			inline,
+			artifact.Message,
 		)
 	}
 
@@ -78,6 +153,45 @@ func (store *PostgresStore) saveArtifactData(data []*artifacts.ArtifactData) err
 	return err
 }
 
+func (store *PostgresStore) SaveArtifact(artifact *artifacts.ArtifactData) error {
+	if err := artifact.Validate(); err != nil {
+		return err
+	}
+
+	return store.saveArtifactData([]*artifacts.ArtifactData{artifact})
+}
+
+func (store *PostgresStore) GetArtifact(id string) (*artifacts.ArtifactData, error) {
+	query := `SELECT {0} FROM {1} WHERE id = $1`
+
+	query = stringFormatter.Format(query, artifactDataWithDataColumns, ARTIFACTS_TABLE)
+
+	rows, err := store.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactData, err := store.sqlToArtifacts(rows, true)
+	if err != nil {
+		return nil, err
+	} else if len(artifactData) == 0 {
+		return nil, nil
+	}
+
+	artifact := &artifactData[0]
+	if artifact.Blob != "" {
+		if store.blobs == nil {
+			return nil, fmt.Errorf("artifact %s is kept in a blob store, but none is set", artifact.ID)
+		}
+		artifact.Data, err = store.blobs.Get(artifact.Blob)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load artifact %s: %w", artifact.ID, err)
+		}
+	}
+
+	return artifact, nil
+}
+
 func (store *PostgresStore) GetMessage(id string) (*chat.Message, error) {
 	query := `SELECT {0} FROM {1} WHERE id = $1`
 
@@ -127,7 +241,7 @@ func (store *PostgresStore) populateArtifacts(messages []*chat.Message) ([]*chat
 	if err != nil {
 		return nil, err
 	}
-	artifactData, err := store.sqlToArtifacts(rows)
+	artifactData, err := store.sqlToArtifacts(rows, false)
 	if err != nil {
 		return nil, err
 	}
@@ -151,10 +265,88 @@ func (store *PostgresStore) DeleteMessage(id string) error {
 		return err
 	}
 
-	query = `DELETE FROM {0} WHERE message = $1`
+	return store.deleteArtifacts(`message = $1`, id)
+}
+
+/*
+deleteArtifacts deletes the artifacts matching the where
+clause along with their document chunks, then any blobs no
+other artifact still refers to.
+*/
+func (store *PostgresStore) deleteArtifacts(where string, params ...interface{}) error {
+	keys, err := store.artifactBlobs(where, params...)
+	if err != nil {
+		return err
+	}
+
+	// Any documents among them are no longer searchable
+	query := `DELETE FROM {0} WHERE document IN (SELECT id FROM {1} WHERE {2})`
+	query = stringFormatter.Format(query, DOCUMENT_CHUNKS_TABLE, ARTIFACTS_TABLE, where)
+	_, err = store.db.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM {0} WHERE {1}`
+	query = stringFormatter.Format(query, ARTIFACTS_TABLE, where)
+	_, err = store.db.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+
+	return store.deleteUnreferencedBlobs(keys)
+}
+
+// artifactBlobs returns the blob keys of the matching artifacts
+func (store *PostgresStore) artifactBlobs(where string, params ...interface{}) ([]string, error) {
+	query := `SELECT DISTINCT blob FROM {0} WHERE blob IS NOT NULL AND {1}`
+	query = stringFormatter.Format(query, ARTIFACTS_TABLE, where)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+/*
+deleteUnreferencedBlobs deletes the given blobs once no
+artifact refers to them. Blobs are shared by every artifact
+with the same data, so they can't be deleted along with any
+one artifact.
+*/
+func (store *PostgresStore) deleteUnreferencedBlobs(keys []string) error {
+	if store.blobs == nil {
+		return nil
+	}
+
+	query := `SELECT COUNT(*) FROM {0} WHERE blob = $1`
 	query = stringFormatter.Format(query, ARTIFACTS_TABLE)
-	_, err = store.db.Exec(query, id)
-	return err
+	for _, key := range keys {
+		var references int
+		err := store.db.QueryRow(query, key).Scan(&references)
+		if err != nil {
+			return err
+		}
+		if references > 0 {
+			continue
+		}
+		if err := store.blobs.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (store *PostgresStore) ListMessages(filter store.Filter) ([]*chat.Message, error) {
@@ -217,6 +409,11 @@ func (store *PostgresStore) GetConversation(conversation string) (*chat.Conversa
 		return nil, nil
 	}
 
+	messages, err = store.populateArtifacts(messages)
+	if err != nil {
+		return nil, err
+	}
+
 	return &chat.Conversation{
 		ID:        conversation,
 		User:      messages[0].User,
@@ -227,11 +424,19 @@ func (store *PostgresStore) GetConversation(conversation string) (*chat.Conversa
 }
 
 func (store *PostgresStore) DeleteConversation(conversation string) error {
+	// Artifacts are found through their messages, so they
+	// must be deleted first
+	where := stringFormatter.Format(`message IN (SELECT id FROM {0} WHERE conversation = $1)`, MESSAGES_TABLE)
+	err := store.deleteArtifacts(where, conversation)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM {0} WHERE conversation = $1`
 
 	query = stringFormatter.Format(query, MESSAGES_TABLE)
 
-	_, err := store.db.Exec(query, conversation)
+	_, err = store.db.Exec(query, conversation)
 	return err
 }
 
@@ -449,6 +654,7 @@ func (store *PostgresStore) sqlToMessages(rows *sql.Rows) ([]*chat.Message, erro
 
 	for rows.Next() {
 		var msg chat.Message
+		var toolCalls sql.NullString
 		err := rows.Scan(
 			&msg.ID,
 			&msg.Conversation,
@@ -456,34 +662,66 @@ func (store *PostgresStore) sqlToMessages(rows *sql.Rows) ([]*chat.Message, erro
 			&msg.Agent,
 			&msg.From,
 			&msg.Content,
+			&toolCalls,
 			&msg.CreatedAt,
+			&msg.AgentVersion,
+			&msg.Experiment,
+			&msg.Variant,
+			&msg.Duration,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if toolCalls.String != "" {
+			err = json.Unmarshal([]byte(toolCalls.String), &msg.ToolCalls)
+			if err != nil {
+				return nil, err
+			}
+		}
 		messages = append(messages, &msg)
 	}
 
 	return messages, nil
 }
 
-func (store *PostgresStore) sqlToArtifacts(rows *sql.Rows) ([]artifacts.ArtifactData, error) {
+/*
+sqlToArtifacts reads artifacts selected with either
+artifactDataSelectColumns or, if withData is set,
+artifactDataWithDataColumns.
+*/
+func (store *PostgresStore) sqlToArtifacts(rows *sql.Rows, withData bool) ([]artifacts.ArtifactData, error) {
 	defer rows.Close()
 
 	artifactData := []artifacts.ArtifactData{}
 
 	for rows.Next() {
 		var data artifacts.ArtifactData
-		err := rows.Scan(
+		var metadata, blobKey sql.NullString
+		columns := []interface{}{
 			&data.ID,
 			&data.Message,
 			&data.Type,
-			&data.Data,
+			&data.MimeType,
+			&data.Size,
+			&data.Filename,
+			&metadata,
+			&blobKey,
 			&data.CreatedAt,
-		)
+		}
+		if withData {
+			columns = append(columns, &data.Data)
+		}
+		err := rows.Scan(columns...)
 		if err != nil {
 			return nil, err
 		}
+		data.Blob = blobKey.String
+		if metadata.Valid {
+			err = json.Unmarshal([]byte(metadata.String), &data.Metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
 		artifactData = append(artifactData, data)
 	}
 	return artifactData, nil
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/wissance/stringFormatter"
+)
+
+const organizationSelectColumns = `id, name, settings, created_at, updated_at`
+
+func (store *PostgresStore) SaveOrganization(org *tenants.Organization) error {
+	query := `INSERT INTO {0} ({1}) VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			settings = EXCLUDED.settings,
+			updated_at = EXCLUDED.updated_at`
+
+	query = stringFormatter.Format(query, ORGANIZATIONS_TABLE, organizationSelectColumns)
+
+	now := time.Now()
+	if org.CreatedAt.IsZero() {
+		org.CreatedAt = now
+	}
+	org.UpdatedAt = now
+
+	settings, err := json.Marshal(org.Settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
+		query,
+		org.ID,
+		org.Name,
+		string(settings),
+		org.CreatedAt,
+		org.UpdatedAt,
+	)
+
+	return err
+}
+
+func (store *PostgresStore) GetOrganization(id string) (*tenants.Organization, error) {
+	query := `SELECT {0} FROM {1} WHERE id = $1`
+
+	query = stringFormatter.Format(query, organizationSelectColumns, ORGANIZATIONS_TABLE)
+
+	rows, err := store.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := store.sqlToOrganizations(rows)
+	if err != nil {
+		return nil, err
+	} else if len(orgs) == 0 {
+		return nil, nil
+	}
+
+	return orgs[0], nil
+}
+
+func (store *PostgresStore) DeleteOrganization(id string) error {
+	query := `DELETE FROM {0} WHERE id = $1`
+
+	query = stringFormatter.Format(query, ORGANIZATIONS_TABLE)
+
+	_, err := store.db.Exec(query, id)
+	return err
+}
+
+func (store *PostgresStore) ListOrganizations() ([]*tenants.Organization, error) {
+	query := `SELECT {0} FROM {1} ORDER BY created_at ASC`
+
+	query = stringFormatter.Format(query, organizationSelectColumns, ORGANIZATIONS_TABLE)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToOrganizations(rows)
+}
+
+func (store *PostgresStore) sqlToOrganizations(rows *sql.Rows) ([]*tenants.Organization, error) {
+	defer rows.Close()
+
+	orgs := []*tenants.Organization{}
+
+	for rows.Next() {
+		var org tenants.Organization
+		var settings string
+		err := rows.Scan(
+			&org.ID,
+			&org.Name,
+			&settings,
+			&org.CreatedAt,
+			&org.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(settings), &org.Settings)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, &org)
+	}
+
+	return orgs, nil
+}
@@ -8,6 +8,9 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/hlfshell/coppermind/internal/store/blob"
+	"github.com/wissance/stringFormatter"
+
 	_ "github.com/lib/pq"
 )
 
@@ -19,6 +22,38 @@ const SUMMARIES_TABLE = "Summaries_V1"
 const SUMMARY_EXCLUSION_TABLE = "SummaryExclusion_V1"
 const KNOWLEDGE_TABLE = "Knowledge_V1"
 const KNOWLEDGE_EXTRACTION_TABLE = "KnowledgeExtraction_V1"
+const USAGE_TABLE = "Usage_V1"
+const QUOTAS_TABLE = "Quotas_V1"
+const ROUTES_TABLE = "Routes_V1"
+const DOCUMENT_CHUNKS_TABLE = "DocumentChunks_V1"
+const ENTITY_ALIASES_TABLE = "EntityAliases_V1"
+const ERASURES_TABLE = "Erasures_V1"
+const ORGANIZATIONS_TABLE = "Organizations_V1"
+const AGENT_MEMBERS_TABLE = "AgentMembers_V1"
+const AGENT_REVISIONS_TABLE = "AgentRevisions_V1"
+const PROMPT_TEMPLATES_TABLE = "PromptTemplates_V1"
+const EXPERIMENTS_TABLE = "Experiments_V1"
+const FEEDBACK_TABLE = "Feedback_V1"
+const MIGRATIONS_TABLE = "Migrations_V1"
+
+/*
+Records belong to the organization of the agent they were
+created by, or for artifacts, exclusions and extractions,
+of their message or conversation. These select that tenant
+for the ID bound to the given parameter, or the default
+tenant if there is no such agent or message.
+*/
+func agentTenant(param int) string {
+	return fmt.Sprintf(`COALESCE((SELECT tenant FROM %s WHERE id = $%d), '')`, AGENTS_TABLE, param)
+}
+
+func messageTenant(param int) string {
+	return fmt.Sprintf(`COALESCE((SELECT tenant FROM %s WHERE id = $%d), '')`, MESSAGES_TABLE, param)
+}
+
+func conversationTenant(param int) string {
+	return fmt.Sprintf(`COALESCE((SELECT tenant FROM %s WHERE conversation = $%d LIMIT 1), '')`, MESSAGES_TABLE, param)
+}
 
 //go:embed sql/*.sql
 var sqlFolder embed.FS
@@ -26,6 +61,10 @@ var sqlFolderPath = "sql"
 
 type PostgresStore struct {
 	db *sql.DB
+
+	// Where artifact data is kept; nil if it is kept in the
+	// database itself
+	blobs blob.BlobStore
 }
 
 func NewSqliteStore(username string, password, host string, port string, database string) (*PostgresStore, error) {
@@ -45,13 +84,47 @@ func NewSqliteStore(username string, password, host string, port string, databas
 	}, nil
 }
 
+/*
+SetBlobStore moves the data of any artifacts saved from now
+on out of the database and into the given blob store; only a
+reference to the blob is kept in the artifacts table.
+Artifacts saved before a blob store was set are still read
+from the database.
+*/
+func (store *PostgresStore) SetBlobStore(blobs blob.BlobStore) {
+	store.blobs = blobs
+}
+
+// migrationsTableQuery creates the table used to track which
+// migration files have been applied, so that each is only
+// ever ran once
+const migrationsTableQuery = `CREATE TABLE IF NOT EXISTS
+    {0}(
+        name TEXT NOT NULL PRIMARY KEY,
+        applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+    );`
+
 func (store *PostgresStore) Migrate() error {
+	_, err := store.db.Exec(stringFormatter.Format(migrationsTableQuery, MIGRATIONS_TABLE))
+	if err != nil {
+		return err
+	}
+
+	applied, err := store.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
 	entries, err := fs.ReadDir(sqlFolder, sqlFolderPath)
 	if err != nil {
 		return err
 	}
 
 	for _, entry := range entries {
+		if applied[entry.Name()] {
+			continue
+		}
+
 		path := filepath.Join(sqlFolderPath, entry.Name())
 
 		bytes, err := fs.ReadFile(sqlFolder, path)
@@ -64,11 +137,39 @@ func (store *PostgresStore) Migrate() error {
 		if err != nil {
 			return err
 		}
+
+		query := stringFormatter.Format(`INSERT INTO {0} (name, applied_at) VALUES($1, $2)`, MIGRATIONS_TABLE)
+		_, err = store.db.Exec(query, entry.Name(), time.Now())
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (store *PostgresStore) appliedMigrations() (map[string]bool, error) {
+	query := stringFormatter.Format(`SELECT name FROM {0}`, MIGRATIONS_TABLE)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+
+	return applied, nil
+}
+
 func (store *PostgresStore) sqlTimestampToTime(timestamp string) (time.Time, error) {
 	parsed, err := time.Parse(time.RFC3339, timestamp)
 	if err != nil {
@@ -79,3 +180,8 @@ func (store *PostgresStore) sqlTimestampToTime(timestamp string) (time.Time, err
 	}
 	return parsed, nil
 }
+
+// nullTime stores an unset time as NULL
+func nullTime(timestamp time.Time) sql.NullTime {
+	return sql.NullTime{Time: timestamp, Valid: !timestamp.IsZero()}
+}
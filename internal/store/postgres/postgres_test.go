@@ -14,6 +14,7 @@ import (
 	docker "github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
 	storeTest "github.com/hlfshell/coppermind/internal/test/store"
 	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/require"
@@ -217,20 +218,42 @@ func TestLowLevelSqlite(t *testing.T) {
 		"GenerateUserPasswordResetToken": storeTest.GenerateUserPasswordResetToken,
 		"ResetPassword":                  storeTest.ResetPassword,
 		"DeleteUser":                     storeTest.DeleteUser,
+		"ListUsers":                      storeTest.ListUsers,
 		"SaveAndGetMessage":              storeTest.SaveAndGetMessage,
+		"SaveAndGetArtifact":             storeTest.SaveAndGetArtifact,
+		"SaveMessageWithToolCalls":       storeTest.SaveMessageWithToolCalls,
+		"SaveResponseWithArtifacts":      storeTest.SaveResponseWithArtifacts,
 		"DeleteMessage":                  storeTest.DeleteMessage,
 		"ListMessages":                   storeTest.ListMessages,
 		"GetConversation":                storeTest.GetAndDeleteConversation,
 		"ListConversation":               storeTest.ListConversations,
 		"SaveAndGetAgent":                storeTest.SaveAndGetAgent,
+		"SaveAgentLLMSettings":           storeTest.SaveAgentLLMSettings,
+		"SaveAgentTools":                 storeTest.SaveAgentTools,
 		"DeleteAgent":                    storeTest.DeleteAgent,
 		"ListAgents":                     storeTest.ListAgents,
 		"SaveAndGetSummary":              storeTest.SaveAndGetSummary,
 		"DeleteSummary":                  storeTest.DeleteSummary,
 		"ListSummaries":                  storeTest.ListSummaries,
+		"ListSummaryExclusions":          storeTest.ListSummaryExclusions,
+		"SaveAndListKnowledge":           storeTest.SaveAndListKnowledge,
+		"CompressKnowledge":              storeTest.CompressKnowledge,
+		"KnowledgeProvenance":            storeTest.KnowledgeProvenance,
+		"UpdateAndDeleteKnowledge":       storeTest.UpdateAndDeleteKnowledge,
+		"SaveAndListUsage":               storeTest.SaveAndListUsage,
+		"SaveAndListRoutes":              storeTest.SaveAndListRoutes,
+		"SaveAndListChunks":              storeTest.SaveAndListChunks,
+		"SaveAndListOrganizations":       storeTest.SaveAndListOrganizations,
+		"TenantOwnership":                storeTest.TenantOwnership,
+		"AgentMembers":                   storeTest.AgentMembers,
+		"AgentRevisions":                 storeTest.AgentRevisions,
+		"PromptTemplates":                storeTest.PromptTemplates,
+		"Experiments":                    storeTest.Experiments,
+		"Feedback":                       storeTest.Feedback,
 	}
 
 	for name, _ := range tests {
+		name := name
 		t.Run("TestLowLevelPostgres"+name, func(t *testing.T) {
 			t.Parallel()
 			store, container, err := createPostgresStore(t)
@@ -245,16 +268,47 @@ func TestLowLevelSqlite(t *testing.T) {
 	}
 }
 
+func TestArtifactBlobsPostgres(t *testing.T) {
+	store, container, err := createPostgresStore(t)
+	require.Nil(t, err)
+	defer container.close()
+
+	err = store.Migrate()
+	require.Nil(t, err)
+
+	blobs := blob.NewMemoryBlobStore()
+	store.SetBlobStore(blobs)
+
+	storeTest.ArtifactBlobs(t, store, blobs)
+}
+
+func TestEraseUserPostgres(t *testing.T) {
+	store, container, err := createPostgresStore(t)
+	require.Nil(t, err)
+	defer container.close()
+
+	err = store.Migrate()
+	require.Nil(t, err)
+
+	blobs := blob.NewMemoryBlobStore()
+	store.SetBlobStore(blobs)
+
+	storeTest.EraseUser(t, store, blobs)
+}
+
 func TestHighLevelPostgres(t *testing.T) {
 	tests := map[string]func(t *testing.T, store store.Store){
 		"GetLatestConversation":          storeTest.GetLatestConversation,
 		"GetConversationsToSummarize":    storeTest.GetConversationsToSummarize,
 		"ExcludeConversationFromSummary": storeTest.ExcludeConversationFromSummary,
+		"IncrementAndGetQuota":           storeTest.IncrementAndGetQuota,
+		"GetKnowledgeGraph":              storeTest.GetKnowledgeGraph,
 		// "ExpireKnowledge":                     storeTest.ExpireKnowledge,
 		// "SetConversationAsKnowledgeExtracted": storeTest.SetConversationAsKnowledgeExtracted,
 	}
 
 	for name, _ := range tests {
+		name := name
 		t.Run("TestSqlite"+name, func(t *testing.T) {
 			t.Parallel()
 			store, container, err := createPostgresStore(t)
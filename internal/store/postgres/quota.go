@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/wissance/stringFormatter"
+)
+
+func (store *PostgresStore) IncrementQuota(scope string, period string, periodStart time.Time, requests int, tokens int) (*quota.Counter, error) {
+	query := `
+		INSERT INTO {0} (
+			scope,
+			period,
+			period_start,
+			requests,
+			tokens
+		) VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, period, period_start) DO UPDATE SET
+			requests = {0}.requests + EXCLUDED.requests,
+			tokens = {0}.tokens + EXCLUDED.tokens
+		RETURNING requests, tokens
+	`
+
+	query = stringFormatter.Format(query, QUOTAS_TABLE)
+
+	counter := &quota.Counter{
+		Scope:       scope,
+		Period:      period,
+		PeriodStart: periodStart,
+	}
+
+	err := store.db.QueryRow(
+		query,
+		scope,
+		period,
+		periodStart,
+		requests,
+		tokens,
+	).Scan(&counter.Requests, &counter.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return counter, nil
+}
+
+func (store *PostgresStore) GetQuota(scope string, period string, periodStart time.Time) (*quota.Counter, error) {
+	query := `SELECT requests, tokens FROM {0} WHERE scope = $1 AND period = $2 AND period_start = $3`
+
+	query = stringFormatter.Format(query, QUOTAS_TABLE)
+
+	rows, err := store.db.Query(query, scope, period, periodStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counter := &quota.Counter{
+		Scope:       scope,
+		Period:      period,
+		PeriodStart: periodStart,
+	}
+
+	for rows.Next() {
+		err = rows.Scan(&counter.Requests, &counter.Tokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counter, nil
+}
+
+func (store *PostgresStore) ExpireQuotas(before time.Time) error {
+	query := `DELETE FROM {0} WHERE period_start < $1`
+
+	query = stringFormatter.Format(query, QUOTAS_TABLE)
+
+	_, err := store.db.Exec(query, before.UTC())
+	return err
+}
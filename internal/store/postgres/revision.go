@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/wissance/stringFormatter"
+)
+
+const revisionSelectColumns = `agent, version, identity, author, created_at`
+
+func (store *PostgresStore) SaveAgentRevision(revision *agents.Revision) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, {2})`
+
+	query = stringFormatter.Format(query, AGENT_REVISIONS_TABLE, revisionSelectColumns, agentTenant(1))
+
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+
+	_, err := store.db.Exec(
+		query,
+		revision.Agent,
+		revision.Version,
+		revision.Identity,
+		revision.Author,
+		revision.CreatedAt,
+	)
+
+	return err
+}
+
+func (store *PostgresStore) GetAgentRevision(agent string, version int) (*agents.Revision, error) {
+	query := `SELECT {0} FROM {1} WHERE agent = $1 AND version = $2`
+
+	query = stringFormatter.Format(query, revisionSelectColumns, AGENT_REVISIONS_TABLE)
+
+	rows, err := store.db.Query(query, agent, version)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := store.sqlToRevisions(rows)
+	if err != nil {
+		return nil, err
+	} else if len(revisions) == 0 {
+		return nil, nil
+	}
+
+	return revisions[0], nil
+}
+
+func (store *PostgresStore) ListAgentRevisions(filter store.Filter) ([]*agents.Revision, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY agent ASC, version ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": revisionSelectColumns,
+			"table":   AGENT_REVISIONS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToRevisions(rows)
+}
+
+func (store *PostgresStore) sqlToRevisions(rows *sql.Rows) ([]*agents.Revision, error) {
+	defer rows.Close()
+
+	revisions := []*agents.Revision{}
+
+	for rows.Next() {
+		var revision agents.Revision
+		err := rows.Scan(
+			&revision.Agent,
+			&revision.Version,
+			&revision.Identity,
+			&revision.Author,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
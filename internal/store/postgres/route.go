@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/wissance/stringFormatter"
+)
+
+const routeSelectColumns = `id, agent, userId, conversation, type, backend, attempt, success, error, duration_ms, created_at`
+
+func (store *PostgresStore) SaveRoute(route *usage.Route) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, {2})`
+
+	query = stringFormatter.Format(query, ROUTES_TABLE, routeSelectColumns, agentTenant(2))
+
+	_, err := store.db.Exec(
+		query,
+		route.ID,
+		route.Agent,
+		route.User,
+		route.Conversation,
+		route.Type,
+		route.Backend,
+		route.Attempt,
+		route.Success,
+		route.Error,
+		route.Duration,
+		route.CreatedAt,
+	)
+
+	return err
+}
+
+func (store *PostgresStore) ListRoutes(filter store.Filter) ([]*usage.Route, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC, attempt ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": routeSelectColumns,
+			"table":   ROUTES_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToRoutes(rows)
+}
+
+func (store *PostgresStore) sqlToRoutes(rows *sql.Rows) ([]*usage.Route, error) {
+	defer rows.Close()
+
+	routes := []*usage.Route{}
+
+	for rows.Next() {
+		var route usage.Route
+		var routeError sql.NullString
+		err := rows.Scan(
+			&route.ID,
+			&route.Agent,
+			&route.User,
+			&route.Conversation,
+			&route.Type,
+			&route.Backend,
+			&route.Attempt,
+			&route.Success,
+			&routeError,
+			&route.Duration,
+			&route.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		route.Error = routeError.String
+		routes = append(routes, &route)
+	}
+
+	return routes, nil
+}
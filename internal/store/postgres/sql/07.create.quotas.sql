@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS
+    Quotas_V1(
+        scope TEXT NOT NULL,
+        period TEXT NOT NULL,
+        period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+        requests INTEGER NOT NULL DEFAULT 0,
+        tokens INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (scope, period, period_start)
+    );
+
+CREATE INDEX IF NOT EXISTS quotas_period_start_v1 ON Quotas_V1(period_start);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE Agents_V1 ADD COLUMN IF NOT EXISTS tools TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE Messages_V1 ADD COLUMN IF NOT EXISTS tool_calls TEXT;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE Artifacts_V1 ADD COLUMN IF NOT EXISTS mime_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE Artifacts_V1 ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE Artifacts_V1 ADD COLUMN IF NOT EXISTS filename TEXT NOT NULL DEFAULT '';
+ALTER TABLE Artifacts_V1 ADD COLUMN IF NOT EXISTS metadata TEXT;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS
+    EntityAliases_V1(
+        agent TEXT NOT NULL,
+        userId TEXT NOT NULL,
+        alias TEXT NOT NULL,
+        entity TEXT NOT NULL,
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (agent, userId, alias)
+    );
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE Knowledge_V1 ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
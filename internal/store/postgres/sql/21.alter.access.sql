@@ -0,0 +1,2 @@
+ALTER TABLE Users_V1 ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member';
+ALTER TABLE Agents_V1 ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public';
\ No newline at end of file
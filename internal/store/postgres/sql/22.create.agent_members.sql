@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS
+    AgentMembers_V1(
+        agent TEXT NOT NULL,
+        userId TEXT NOT NULL,
+        tenant TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (agent, userId)
+    );
+
+CREATE INDEX IF NOT EXISTS agent_members_user_v1 ON AgentMembers_V1(userId);
\ No newline at end of file
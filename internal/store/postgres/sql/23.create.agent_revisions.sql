@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS
+    AgentRevisions_V1(
+        agent TEXT NOT NULL,
+        version INTEGER NOT NULL,
+        identity TEXT NOT NULL,
+        author TEXT NOT NULL DEFAULT '',
+        tenant TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (agent, version)
+    );
\ No newline at end of file
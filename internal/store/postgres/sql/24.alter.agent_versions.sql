@@ -0,0 +1,2 @@
+ALTER TABLE Agents_V1 ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Messages_V1 ADD COLUMN IF NOT EXISTS agent_version INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
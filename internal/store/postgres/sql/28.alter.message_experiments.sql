@@ -0,0 +1,5 @@
+ALTER TABLE Messages_V1 ADD COLUMN IF NOT EXISTS experiment TEXT NOT NULL DEFAULT '';
+ALTER TABLE Messages_V1 ADD COLUMN IF NOT EXISTS variant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Messages_V1 ADD COLUMN IF NOT EXISTS duration_ms BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS messages_experiment_v1 ON Messages_V1(experiment);
\ No newline at end of file
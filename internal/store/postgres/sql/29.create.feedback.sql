@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS
+    Feedback_V1(
+        message TEXT NOT NULL,
+        userId TEXT NOT NULL,
+        rating INTEGER NOT NULL,
+        tenant TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (message, userId)
+    );
\ No newline at end of file
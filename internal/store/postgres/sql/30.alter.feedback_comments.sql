@@ -0,0 +1,7 @@
+ALTER TABLE Feedback_V1 ADD COLUMN IF NOT EXISTS agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE Feedback_V1 ADD COLUMN IF NOT EXISTS comment TEXT NOT NULL DEFAULT '';
+ALTER TABLE Feedback_V1 ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '[]';
+
+UPDATE Feedback_V1 SET agent = COALESCE((SELECT agent FROM Messages_V1 WHERE Messages_V1.id = Feedback_V1.message), '');
+
+CREATE INDEX IF NOT EXISTS feedback_agent_v1 ON Feedback_V1(agent, created_at);
\ No newline at end of file
@@ -14,7 +14,7 @@ import (
 const summaryColumns = `id, conversation, agent, userId, keywords, summary, conversation_started_at, updated_at`
 
 func (store *PostgresStore) SaveSummary(summary *memory.Summary) error {
-	query := `INSERT INTO {0} ({1}) VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	query := `INSERT INTO {0} ({1}, tenant) VALUES($1, $2, $3, $4, $5, $6, $7, $8, {3})
 		ON CONFLICT (id) DO UPDATE SET {2}`
 
 	summary.UpdatedAt = time.Now()
@@ -33,7 +33,7 @@ func (store *PostgresStore) SaveSummary(summary *memory.Summary) error {
 		updatePlaceholder.WriteString(fmt.Sprintf(" = $%d", i+2))
 	}
 
-	query = stringFormatter.Format(query, SUMMARIES_TABLE, summaryColumns, updatePlaceholder.String())
+	query = stringFormatter.Format(query, SUMMARIES_TABLE, summaryColumns, updatePlaceholder.String(), agentTenant(3))
 
 	_, err := store.db.Exec(
 		query,
@@ -253,11 +253,12 @@ func (store *PostgresStore) ExcludeConversationFromSummary(conversation string)
 	query := `
 		INSERT INTO {0} (
 			conversation,
-			created_at
-		) VALUES($1, $2)
+			created_at,
+			tenant
+		) VALUES($1, $2, {1})
 	`
 
-	query = stringFormatter.Format(query, SUMMARY_EXCLUSION_TABLE)
+	query = stringFormatter.Format(query, SUMMARY_EXCLUSION_TABLE, conversationTenant(1))
 
 	_, err := store.db.Exec(query, conversation, time.Now())
 	return err
@@ -272,6 +273,29 @@ func (store *PostgresStore) DeleteSummaryExclusion(conversation string) error {
 	return err
 }
 
+func (store *PostgresStore) ListSummaryExclusions() ([]string, error) {
+	query := `SELECT conversation FROM {0} ORDER BY created_at ASC`
+
+	query = stringFormatter.Format(query, SUMMARY_EXCLUSION_TABLE)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []string{}
+	for rows.Next() {
+		var conversation string
+		if err := rows.Scan(&conversation); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, nil
+}
+
 func (store *PostgresStore) sqlToSummmaries(rows *sql.Rows) ([]*memory.Summary, error) {
 	defer rows.Close()
 
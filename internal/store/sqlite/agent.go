@@ -1,22 +1,46 @@
 package sqlite
 
 import (
+	"database/sql"
+	"encoding/json"
+
 	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/wissance/stringFormatter"
 )
 
-const agentSelectColumns = `id, name, identity`
+const agentSelectColumns = `id, name, identity, llm, tools, tenant, visibility, version, memory`
 
 func (store *SqliteStore) SaveAgent(agent *agents.Agent) error {
-	query := `INSERT INTO {0} ({1}) VALUES(?, ?, ?)`
+	query := `INSERT OR REPLACE INTO {0} ({1}) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	query = stringFormatter.Format(query, AGENTS_TABLE, agentSelectColumns)
 
-	_, err := store.db.Exec(
+	settings, err := json.Marshal(agent.LLM)
+	if err != nil {
+		return err
+	}
+
+	tools, err := json.Marshal(agent.Tools)
+	if err != nil {
+		return err
+	}
+
+	memory, err := json.Marshal(agent.Memory)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
 		query,
 		agent.ID,
 		agent.Name,
 		agent.Identity,
+		string(settings),
+		string(tools),
+		agent.Tenant,
+		agent.Visibility,
+		agent.Version,
+		string(memory),
 	)
 
 	return err
@@ -31,19 +55,15 @@ func (store *SqliteStore) GetAgent(id string) (*agents.Agent, error) {
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var agent agents.Agent
-		err = rows.Scan(
-			&agent.ID,
-			&agent.Name,
-			&agent.Identity,
-		)
-		return &agent, err
+	foundAgents, err := store.sqlToAgents(rows)
+	if err != nil {
+		return nil, err
+	} else if len(foundAgents) == 0 {
+		return nil, nil
 	}
 
-	return nil, nil
+	return foundAgents[0], nil
 }
 
 func (store *SqliteStore) DeleteAgent(id string) error {
@@ -64,20 +84,46 @@ func (store *SqliteStore) ListAgents() ([]*agents.Agent, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	return store.sqlToAgents(rows)
+}
+
+func (store *SqliteStore) sqlToAgents(rows *sql.Rows) ([]*agents.Agent, error) {
 	defer rows.Close()
 
 	var foundAgents []*agents.Agent
 
 	for rows.Next() {
 		var agent agents.Agent
-		err = rows.Scan(
+		var settings string
+		var tools string
+		var memory string
+		err := rows.Scan(
 			&agent.ID,
 			&agent.Name,
 			&agent.Identity,
+			&settings,
+			&tools,
+			&agent.Tenant,
+			&agent.Visibility,
+			&agent.Version,
+			&memory,
 		)
 		if err != nil {
 			return nil, err
 		}
+		err = json.Unmarshal([]byte(settings), &agent.LLM)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(tools), &agent.Tools)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(memory), &agent.Memory)
+		if err != nil {
+			return nil, err
+		}
 		foundAgents = append(foundAgents, &agent)
 	}
 
@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const snapshotPrefix = "coppermind-"
+const snapshotSuffix = ".db"
+
+// snapshotTimeFormat sorts snapshots by name in the order
+// they were taken
+const snapshotTimeFormat = "20060102T150405.000000000Z"
+
+/*
+Backup writes a consistent copy of the database to the given
+path while it is in use, via VACUUM INTO. The copy is
+compacted, and is a plain database file that can be opened
+directly or put back with Restore. The path must not exist.
+*/
+func (store *SqliteStore) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup %s already exists", path)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	_, err := store.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+/*
+Snapshot backs the database up into a new, timestamped file
+within dir, then deletes the oldest snapshots there so that
+only the latest retain are kept. A retain of 0 keeps every
+snapshot. The new snapshot's path is returned.
+*/
+func (store *SqliteStore) Snapshot(dir string, retain int) (string, error) {
+	name := snapshotPrefix + time.Now().UTC().Format(snapshotTimeFormat) + snapshotSuffix
+	path := filepath.Join(dir, name)
+
+	err := store.Backup(path)
+	if err != nil {
+		return "", err
+	}
+
+	if retain <= 0 {
+		return path, nil
+	}
+
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return "", err
+	}
+	for len(snapshots) > retain {
+		if err := os.Remove(snapshots[0]); err != nil {
+			return "", err
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return path, nil
+}
+
+// ListSnapshots lists the snapshots within dir, oldest first
+func ListSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshots := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		snapshots = append(snapshots, filepath.Join(dir, name))
+	}
+	sort.Strings(snapshots)
+
+	return snapshots, nil
+}
+
+/*
+Restore replaces the database at dbFilePath with a backup,
+once the backup passes an integrity check. The database
+must not be in use - stop the server first. Its WAL files
+are removed so that none of its own recent writes are
+replayed over the backup.
+*/
+func Restore(backup string, dbFilePath string) error {
+	err := checkIntegrity(backup)
+	if err != nil {
+		return err
+	}
+
+	// The backup is copied alongside the database first so
+	// that the database is only replaced once it's complete
+	restoring := dbFilePath + ".restoring"
+	err = copyFile(backup, restoring)
+	if err != nil {
+		os.Remove(restoring)
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		err = os.Remove(dbFilePath + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			os.Remove(restoring)
+			return err
+		}
+	}
+
+	return os.Rename(restoring, dbFilePath)
+}
+
+// checkIntegrity checks that a file is a sound sqlite database
+func checkIntegrity(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	err = db.QueryRow(`PRAGMA integrity_check`).Scan(&result)
+	if err != nil {
+		return fmt.Errorf("%s is not a usable backup: %w", path, err)
+	} else if result != "ok" {
+		return fmt.Errorf("%s failed its integrity check: %s", path, result)
+	}
+
+	return nil
+}
+
+func copyFile(from string, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(destination, source)
+	if err != nil {
+		destination.Close()
+		return err
+	}
+	if err = destination.Sync(); err != nil {
+		destination.Close()
+		return err
+	}
+
+	return destination.Close()
+}
@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/wissance/stringFormatter"
+)
+
+const chunkSelectColumns = `id, document, filename, agent, user, conversation, idx, content, model, embedding, created_at`
+
+func (store *SqliteStore) SaveChunks(chunks []*documents.Chunk) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, {2})`
+
+	query = stringFormatter.Format(query, DOCUMENT_CHUNKS_TABLE, chunkSelectColumns, agentTenant)
+
+	// A document is indexed all at once or not at all
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, chunk := range chunks {
+		embedding, err := json.Marshal(chunk.Embedding)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			query,
+			chunk.ID,
+			chunk.Document,
+			chunk.Filename,
+			chunk.Agent,
+			chunk.User,
+			chunk.Conversation,
+			chunk.Index,
+			chunk.Content,
+			chunk.Model,
+			string(embedding),
+			chunk.CreatedAt,
+			chunk.Agent,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *SqliteStore) ListChunks(filter store.Filter) ([]*documents.Chunk, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC, idx ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": chunkSelectColumns,
+			"table":   DOCUMENT_CHUNKS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToChunks(rows)
+}
+
+func (store *SqliteStore) DeleteChunks(document string) error {
+	query := `DELETE FROM {0} WHERE document = ?`
+
+	query = stringFormatter.Format(query, DOCUMENT_CHUNKS_TABLE)
+
+	_, err := store.db.Exec(query, document)
+	return err
+}
+
+func (store *SqliteStore) sqlToChunks(rows *sql.Rows) ([]*documents.Chunk, error) {
+	defer rows.Close()
+
+	chunks := []*documents.Chunk{}
+
+	for rows.Next() {
+		var chunk documents.Chunk
+		var embedding string
+		var datetime string
+		err := rows.Scan(
+			&chunk.ID,
+			&chunk.Document,
+			&chunk.Filename,
+			&chunk.Agent,
+			&chunk.User,
+			&chunk.Conversation,
+			&chunk.Index,
+			&chunk.Content,
+			&chunk.Model,
+			&embedding,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(embedding), &chunk.Embedding)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		chunk.CreatedAt = timestamp
+		chunks = append(chunks, &chunk)
+	}
+
+	return chunks, nil
+}
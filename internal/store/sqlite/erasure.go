@@ -0,0 +1,239 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/wissance/stringFormatter"
+)
+
+const erasureSelectColumns = `id, user, actor, tenant, counts, created_at`
+
+/*
+userData is where one kind of a user's data is kept, as a
+table and a where clause taking a single parameter. If
+anonymize is set, that column is blanked on erasure rather
+than the rows deleted, for records that aren't the user's
+alone.
+*/
+type userData struct {
+	kind      string
+	table     string
+	where     string
+	param     interface{}
+	anonymize string
+}
+
+/*
+userArtifacts is where the artifacts of a user's messages
+are kept.
+*/
+func userArtifacts(user string) userData {
+	return userData{
+		kind:  users.DataArtifacts,
+		table: ARTIFACTS_TABLE,
+		where: stringFormatter.Format(`message IN (SELECT id FROM {0} WHERE user = ?)`, MESSAGES_TABLE),
+		param: user,
+	}
+}
+
+/*
+userDataTables lists every kind of a user's data. They are
+in order of deletion - anything found through the user's
+messages comes before the messages themselves.
+*/
+func userDataTables(user string) []userData {
+	conversations := stringFormatter.Format(
+		`conversation IN (SELECT conversation FROM {0} WHERE user = ?)`,
+		MESSAGES_TABLE,
+	)
+	// The chunks of the user's artifacts may have been
+	// indexed under another user
+	chunks := stringFormatter.Format(
+		`user = ?1 OR document IN (SELECT id FROM {0} WHERE message IN (SELECT id FROM {1} WHERE user = ?1))`,
+		ARTIFACTS_TABLE,
+		MESSAGES_TABLE,
+	)
+	// Feedback on the user's messages is theirs too, even if
+	// given by someone else
+	feedback := stringFormatter.Format(
+		`user = ?1 OR message IN (SELECT id FROM {0} WHERE user = ?1)`,
+		MESSAGES_TABLE,
+	)
+	return []userData{
+		{kind: users.DataDocumentChunks, table: DOCUMENT_CHUNKS_TABLE, where: chunks, param: user},
+		userArtifacts(user),
+		{kind: users.DataSummaryExclusions, table: SUMMARY_EXCLUSION_TABLE, where: conversations, param: user},
+		{kind: users.DataKnowledgeExtraction, table: KNOWLEDGE_EXTRACTION_TABLE, where: conversations, param: user},
+		{kind: users.DataFeedback, table: FEEDBACK_TABLE, where: feedback, param: user},
+		{kind: users.DataMessages, table: MESSAGES_TABLE, where: `user = ?`, param: user},
+		{kind: users.DataSummaries, table: SUMMARIES_TABLE, where: `user = ?`, param: user},
+		{kind: users.DataKnowledge, table: KNOWLEDGE_TABLE, where: `user = ?`, param: user},
+		{kind: users.DataAliases, table: ENTITY_ALIASES_TABLE, where: `user = ?`, param: user},
+		{kind: users.DataUsage, table: USAGE_TABLE, where: `user = ?`, param: user},
+		{kind: users.DataRoutes, table: ROUTES_TABLE, where: `user = ?`, param: user},
+		{kind: users.DataMemberships, table: AGENT_MEMBERS_TABLE, where: `user = ?`, param: user},
+		// Revisions of an agent's identity belong to the agent,
+		// so only their author is forgotten
+		{kind: users.DataAgentRevisions, table: AGENT_REVISIONS_TABLE, where: `author = ?`, param: user, anonymize: "author"},
+		{kind: users.DataQuotas, table: QUOTAS_TABLE, where: `scope = ?`, param: quota.Key(quota.ScopeUser, user)},
+		{kind: users.DataProfile, table: USERS_TABLE, where: `id = ?`, param: user},
+	}
+}
+
+func (store *SqliteStore) CountUserData(user string) (map[string]int, error) {
+	counts := map[string]int{}
+	for _, data := range userDataTables(user) {
+		query := `SELECT COUNT(*) FROM {0} WHERE {1}`
+		query = stringFormatter.Format(query, data.table, data.where)
+
+		var count int
+		err := store.db.QueryRow(query, data.param).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		counts[data.kind] = count
+	}
+	return counts, nil
+}
+
+func (store *SqliteStore) EraseUser(user string) (map[string]int, error) {
+	// The artifacts' blobs are found before their artifacts
+	// are gone, but only deleted once the erasure commits
+	artifacts := userArtifacts(user)
+	keys, err := store.artifactBlobs(artifacts.where, artifacts.param)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := store.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	counts := map[string]int{}
+	for _, data := range userDataTables(user) {
+		query := `DELETE FROM {0} WHERE {1}`
+		if data.anonymize != "" {
+			query = `UPDATE {0} SET {2} = '' WHERE {1}`
+		}
+		query = stringFormatter.Format(query, data.table, data.where, data.anonymize)
+
+		result, err := tx.Exec(query, data.param)
+		if err != nil {
+			return nil, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		counts[data.kind] = int(deleted)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, store.deleteUnreferencedBlobs(keys)
+}
+
+func (store *SqliteStore) SaveErasure(erasure *users.Erasure) error {
+	query := `INSERT INTO {0} ({1}) VALUES(?, ?, ?, ?, ?, ?)`
+
+	query = stringFormatter.Format(query, ERASURES_TABLE, erasureSelectColumns)
+
+	counts, err := json.Marshal(erasure.Counts)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
+		query,
+		erasure.ID,
+		erasure.User,
+		erasure.Actor,
+		erasure.Tenant,
+		string(counts),
+		erasure.CreatedAt,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) ListErasures(filter store.Filter) ([]*users.Erasure, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": erasureSelectColumns,
+			"table":   ERASURES_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToErasures(rows)
+}
+
+func (store *SqliteStore) sqlToErasures(rows *sql.Rows) ([]*users.Erasure, error) {
+	defer rows.Close()
+
+	erasures := []*users.Erasure{}
+
+	for rows.Next() {
+		var erasure users.Erasure
+		var counts string
+		var datetime string
+		err := rows.Scan(
+			&erasure.ID,
+			&erasure.User,
+			&erasure.Actor,
+			&erasure.Tenant,
+			&counts,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(counts), &erasure.Counts)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		erasure.CreatedAt = timestamp
+		erasures = append(erasures, &erasure)
+	}
+
+	return erasures, nil
+}
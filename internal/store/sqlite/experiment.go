@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/wissance/stringFormatter"
+)
+
+const experimentSelectColumns = `id, agent, name, variants, active, created_at, ended_at`
+
+func (store *SqliteStore) SaveExperiment(experiment *experiments.Experiment) error {
+	query := `INSERT OR REPLACE INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, ?, ?, {2})`
+
+	query = stringFormatter.Format(query, EXPERIMENTS_TABLE, experimentSelectColumns, agentTenant)
+
+	variants, err := json.Marshal(experiment.Variants)
+	if err != nil {
+		return err
+	}
+
+	if experiment.CreatedAt.IsZero() {
+		experiment.CreatedAt = time.Now()
+	}
+
+	_, err = store.db.Exec(
+		query,
+		experiment.ID,
+		experiment.Agent,
+		experiment.Name,
+		string(variants),
+		experiment.Active,
+		experiment.CreatedAt,
+		nullTime(experiment.EndedAt),
+		experiment.Agent,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) GetExperiment(id string) (*experiments.Experiment, error) {
+	query := `SELECT {0} FROM {1} WHERE id = ?`
+
+	query = stringFormatter.Format(query, experimentSelectColumns, EXPERIMENTS_TABLE)
+
+	rows, err := store.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := store.sqlToExperiments(rows)
+	if err != nil {
+		return nil, err
+	} else if len(found) == 0 {
+		return nil, nil
+	}
+
+	return found[0], nil
+}
+
+func (store *SqliteStore) ListExperiments(filter store.Filter) ([]*experiments.Experiment, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": experimentSelectColumns,
+			"table":   EXPERIMENTS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToExperiments(rows)
+}
+
+func (store *SqliteStore) sqlToExperiments(rows *sql.Rows) ([]*experiments.Experiment, error) {
+	defer rows.Close()
+
+	found := []*experiments.Experiment{}
+
+	for rows.Next() {
+		var experiment experiments.Experiment
+		var variants string
+		var datetime string
+		var ended sql.NullString
+		err := rows.Scan(
+			&experiment.ID,
+			&experiment.Agent,
+			&experiment.Name,
+			&variants,
+			&experiment.Active,
+			&datetime,
+			&ended,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(variants), &experiment.Variants)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		experiment.CreatedAt = timestamp
+		if ended.Valid {
+			timestamp, err = store.sqlTimestampToTime(ended.String)
+			if err != nil {
+				return nil, err
+			}
+			experiment.EndedAt = timestamp
+		}
+		found = append(found, &experiment)
+	}
+
+	return found, nil
+}
@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/wissance/stringFormatter"
+)
+
+const feedbackSelectColumns = `message, agent, user, rating, comment, tags, created_at`
+
+func (store *SqliteStore) SaveFeedback(feedback *chat.Feedback) error {
+	query := `INSERT OR REPLACE INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, ?, ?, {2})`
+
+	query = stringFormatter.Format(query, FEEDBACK_TABLE, feedbackSelectColumns, messageTenant)
+
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+
+	tags, err := json.Marshal(feedback.Tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
+		query,
+		feedback.Message,
+		feedback.Agent,
+		feedback.User,
+		feedback.Rating,
+		feedback.Comment,
+		string(tags),
+		feedback.CreatedAt,
+		feedback.Message,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) ListFeedback(filter store.Filter) ([]*chat.Feedback, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": feedbackSelectColumns,
+			"table":   FEEDBACK_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToFeedback(rows)
+}
+
+func (store *SqliteStore) sqlToFeedback(rows *sql.Rows) ([]*chat.Feedback, error) {
+	defer rows.Close()
+
+	feedback := []*chat.Feedback{}
+
+	for rows.Next() {
+		var rating chat.Feedback
+		var tags string
+		var datetime string
+		err := rows.Scan(
+			&rating.Message,
+			&rating.Agent,
+			&rating.User,
+			&rating.Rating,
+			&rating.Comment,
+			&tags,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		rating.CreatedAt = timestamp
+
+		err = json.Unmarshal([]byte(tags), &rating.Tags)
+		if err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, &rating)
+	}
+
+	return feedback, nil
+}
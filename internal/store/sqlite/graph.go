@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/wissance/stringFormatter"
+)
+
+const aliasSelectColumns = `agent, user, alias, entity, created_at`
+
+func (store *SqliteStore) SaveAlias(alias *memory.Alias) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, {2})
+		ON CONFLICT (agent, user, alias) DO UPDATE SET
+			entity = excluded.entity`
+
+	query = stringFormatter.Format(query, ENTITY_ALIASES_TABLE, aliasSelectColumns, agentTenant)
+
+	alias.Alias = memory.NormalizeEntity(alias.Alias)
+	alias.Entity = memory.NormalizeEntity(alias.Entity)
+	if alias.CreatedAt.IsZero() {
+		alias.CreatedAt = time.Now()
+	}
+
+	_, err := store.db.Exec(
+		query,
+		alias.Agent,
+		alias.User,
+		alias.Alias,
+		alias.Entity,
+		alias.CreatedAt,
+		alias.Agent,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) ListAliases(filter store.Filter) ([]*memory.Alias, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": aliasSelectColumns,
+			"table":   ENTITY_ALIASES_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToAliases(rows)
+}
+
+func (store *SqliteStore) DeleteAlias(agent string, user string, alias string) error {
+	query := `DELETE FROM {0} WHERE agent = ? AND user = ? AND alias = ?`
+
+	query = stringFormatter.Format(query, ENTITY_ALIASES_TABLE)
+
+	_, err := store.db.Exec(query, agent, user, memory.NormalizeEntity(alias))
+	return err
+}
+
+/*
+graphFilter matches an agent's knowledge of a user, or their
+aliases; current limits knowledge to facts not superseded
+*/
+func graphFilter(agent string, user string, current bool) store.Filter {
+	filter := store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "agent", Value: agent, Operation: store.EQ},
+			{Attribute: "user", Value: user, Operation: store.EQ},
+		},
+	}
+	if current {
+		filter.Attributes = append(filter.Attributes, &store.FilterAttribute{
+			Attribute: "superseded_by", Value: "", Operation: store.EQ,
+		})
+	}
+	return filter
+}
+
+func (store *SqliteStore) GetKnowledgeGraph(agent string, user string) (*memory.Graph, error) {
+	knowledge, err := store.ListKnowledge(graphFilter(agent, user, true))
+	if err != nil {
+		return nil, err
+	}
+
+	facts := []*memory.Knowledge{}
+	for _, fact := range knowledge {
+		if !fact.IsExpired() {
+			facts = append(facts, fact)
+		}
+	}
+
+	aliases, err := store.ListAliases(graphFilter(agent, user, false))
+	if err != nil {
+		return nil, err
+	}
+
+	return memory.NewGraph(agent, user, facts, aliases), nil
+}
+
+func (store *SqliteStore) sqlToAliases(rows *sql.Rows) ([]*memory.Alias, error) {
+	defer rows.Close()
+
+	aliases := []*memory.Alias{}
+
+	for rows.Next() {
+		var alias memory.Alias
+		var datetime string
+		err := rows.Scan(
+			&alias.Agent,
+			&alias.User,
+			&alias.Alias,
+			&alias.Entity,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		alias.CreatedAt = timestamp
+		aliases = append(aliases, &alias)
+	}
+
+	return aliases, nil
+}
@@ -2,12 +2,16 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
+	"github.com/hlfshell/coppermind/internal/store"
 	"github.com/hlfshell/coppermind/pkg/memory"
 	"github.com/wissance/stringFormatter"
 )
 
+const knowledgeSelectColumns = `id, agent, user, subject, predicate, object, created_at, expires_at, superseded_by, superseded_at, conversation, messages, confidence, pinned`
+
 func (store *SqliteStore) SaveKnowledge(fact *memory.Knowledge) error {
 	query := `
 		INSERT INTO {0}
@@ -19,14 +23,26 @@ func (store *SqliteStore) SaveKnowledge(fact *memory.Knowledge) error {
 			predicate,
 			object,
 			created_at,
-			expires_at
+			expires_at,
+			superseded_by,
+			superseded_at,
+			conversation,
+			messages,
+			confidence,
+			pinned,
+			tenant
 		)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, {1})
 	`
 
-	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+	query = stringFormatter.Format(query, KNOWLEDGE_TABLE, agentTenant)
 
-	_, err := store.db.Exec(
+	messages, err := json.Marshal(fact.Messages)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.db.Exec(
 		query,
 		fact.ID,
 		fact.Agent,
@@ -36,6 +52,13 @@ func (store *SqliteStore) SaveKnowledge(fact *memory.Knowledge) error {
 		fact.Object,
 		fact.CreatedAt,
 		fact.ExpiresAt,
+		fact.SupersededBy,
+		nullTime(fact.SupersededAt),
+		fact.Conversation,
+		string(messages),
+		fact.Confidence,
+		fact.Pinned,
+		fact.Agent,
 	)
 
 	return err
@@ -51,7 +74,13 @@ func (store *SqliteStore) GetKnowledge(id string) (*memory.Knowledge, error) {
 			predicate,
 			object,
 			created_at,
-			expires_at
+			expires_at,
+			superseded_by,
+			superseded_at,
+			conversation,
+			messages,
+			confidence,
+			pinned
 		FROM
 			{0}
 		WHERE
@@ -75,6 +104,44 @@ func (store *SqliteStore) GetKnowledge(id string) (*memory.Knowledge, error) {
 	return knowledge[0], nil
 }
 
+func (store *SqliteStore) ListKnowledge(filter store.Filter) ([]*memory.Knowledge, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": knowledgeSelectColumns,
+			"table":   KNOWLEDGE_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToKnowledge(rows)
+}
+
 func (store *SqliteStore) GetKnowlegeByAgentAndUser(agent string, user string) ([]*memory.Knowledge, error) {
 	query := `
 		SELECT 
@@ -85,7 +152,13 @@ func (store *SqliteStore) GetKnowlegeByAgentAndUser(agent string, user string) (
 			predicate,
 			object,
 			created_at,
-			expires_at
+			expires_at,
+			superseded_by,
+			superseded_at,
+			conversation,
+			messages,
+			confidence,
+			pinned
 		FROM
 			{0}
 		WHERE
@@ -106,7 +179,7 @@ func (store *SqliteStore) ExpireKnowledge() error {
 	query := `
 		DELETE FROM {0}
 		WHERE
-			expires_at < ?
+			expires_at < ? AND pinned = FALSE
 	`
 
 	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
@@ -125,7 +198,13 @@ func (store *SqliteStore) GetKnowledgeGroupedByAgentAndUser(agent string, user s
 			predicate,
 			object,
 			created_at,
-			expires_at
+			expires_at,
+			superseded_by,
+			superseded_at,
+			conversation,
+			messages,
+			confidence,
+			pinned
 		FROM
 			{0}
 	`
@@ -199,57 +278,152 @@ func (store *SqliteStore) SetConversationAsKnowledgeExtracted(conversation strin
 		INSERT INTO {0}
 		(
 			conversation,
-			updated_at
+			updated_at,
+			tenant
 		)
-		VALUES(?, ?)
+		VALUES(?, ?, {1})
 	`
 
-	query = stringFormatter.Format(query, KNOWLEDGE_EXTRACTION_TABLE)
+	query = stringFormatter.Format(query, KNOWLEDGE_EXTRACTION_TABLE, conversationTenant)
 
-	_, err := store.db.Exec(query, conversation, time.Now())
+	_, err := store.db.Exec(query, conversation, time.Now(), conversation)
 	return err
 }
 
 func (store *SqliteStore) CompressKnowledge(agent string, user string, knowledge []*memory.Knowledge) error {
-	// Start a sqlite transaction
+	query := `
+		UPDATE {0}
+		SET
+			expires_at = ?,
+			superseded_by = ?,
+			superseded_at = ?
+		WHERE
+			id = ? AND agent = ? AND user = ?
+	`
+
+	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+
+	// The knowledge is compressed all at once or not at all
 	tx, err := store.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	for _, fact := range knowledge {
+		_, err = tx.Exec(
+			query,
+			fact.ExpiresAt,
+			fact.SupersededBy,
+			nullTime(fact.SupersededAt),
+			fact.ID,
+			agent,
+			user,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *SqliteStore) UpdateKnowledge(fact *memory.Knowledge) error {
+	query := `
+		UPDATE {0}
+		SET
+			subject = ?,
+			predicate = ?,
+			object = ?,
+			expires_at = ?,
+			pinned = ?
+		WHERE
+			id = ?
+	`
+
+	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
+
+	_, err := store.db.Exec(
+		query,
+		fact.Subject,
+		fact.Predicate,
+		fact.Object,
+		fact.ExpiresAt,
+		fact.Pinned,
+		fact.ID,
+	)
+
+	return err
+}
 
-	// Delete all the knowledge that belongs to the agent and user, to be replaced by our incoming knowledge
-	query := `DELETE FROM {0} WHERE agent = ? AND user = ?`
+func (store *SqliteStore) DeleteKnowledge(id string) error {
+	restore := `UPDATE {0} SET superseded_by = '', superseded_at = NULL WHERE superseded_by = ?`
+	restore = stringFormatter.Format(restore, KNOWLEDGE_TABLE)
+
+	query := `DELETE FROM {0} WHERE id = ?`
 	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
-	_, err = tx.Exec(query, agent, user)
+
+	tx, err := store.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Insert the new knowledge with our transaction all in one query
-	query = `
-		INSERT INTO {0}
-		(
-			id,
-			agent,
-			user,
-			subject,
-			predicate,
-			object,
-			created_at,
-			expires_at
-		)
-		VALUES 
-		`
+	// Facts that were superseded by the deleted fact are
+	// current again
+	_, err = tx.Exec(restore, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *SqliteStore) DeleteConversationKnowledge(conversation string) error {
+	// Facts from before provenance was recorded have no
+	// conversation, and must not be deleted together
+	if conversation == "" {
+		return nil
+	}
+
+	restore := `
+		UPDATE {0}
+		SET
+			superseded_by = '',
+			superseded_at = NULL
+		WHERE
+			superseded_by IN (SELECT id FROM {0} WHERE conversation = ?) AND
+			conversation != ?
+	`
+	restore = stringFormatter.Format(restore, KNOWLEDGE_TABLE)
+
+	query := `DELETE FROM {0} WHERE conversation = ?`
 	query = stringFormatter.Format(query, KNOWLEDGE_TABLE)
-	for i := 0; i < len(knowledge); i++ {
-		query += "(?, ?, ?, ?, ?, ?, ?, ?)"
-		if i < len(knowledge)-1 {
-			query += ", "
-		}
+
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	_, err = store.db.Exec(query, knowledge)
-	return err
+	// Facts that were superseded by the deleted facts are
+	// current again
+	_, err = tx.Exec(restore, conversation, conversation)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query, conversation)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (store *SqliteStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge, error) {
@@ -259,8 +433,10 @@ func (store *SqliteStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge, e
 
 	for rows.Next() {
 		var fact memory.Knowledge
+		var messages string
 		var datetime string
 		var expiration string
+		var superseded sql.NullString
 		err := rows.Scan(
 			&fact.ID,
 			&fact.Agent,
@@ -270,10 +446,20 @@ func (store *SqliteStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge, e
 			&fact.Object,
 			&datetime,
 			&expiration,
+			&fact.SupersededBy,
+			&superseded,
+			&fact.Conversation,
+			&messages,
+			&fact.Confidence,
+			&fact.Pinned,
 		)
 		if err != nil {
 			return nil, err
 		}
+		err = json.Unmarshal([]byte(messages), &fact.Messages)
+		if err != nil {
+			return nil, err
+		}
 		timestamp, err := store.sqlTimestampToTime(datetime)
 		if err != nil {
 			return nil, err
@@ -284,6 +470,13 @@ func (store *SqliteStore) sqlToKnowledge(rows *sql.Rows) ([]*memory.Knowledge, e
 			return nil, err
 		}
 		fact.ExpiresAt = timestamp
+		if superseded.Valid {
+			timestamp, err = store.sqlTimestampToTime(superseded.String)
+			if err != nil {
+				return nil, err
+			}
+			fact.SupersededAt = timestamp
+		}
 		knowledge = append(knowledge, &fact)
 	}
 
@@ -2,23 +2,48 @@ package sqlite
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
 
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
 	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
 	"github.com/wissance/stringFormatter"
 )
 
-const messageSelectColumns = `id, conversation, user, agent, author, content, created_at`
-const artifactDataSelectColumns = `id, message, type, data, created_at`
+const messageSelectColumns = `id, conversation, user, agent, author, content, tool_calls, created_at, agent_version, experiment, variant, duration_ms`
+
+// Artifact data itself is only selected when a single artifact
+// is requested; messages are listed with their artifacts'
+// details alone
+const artifactDataSelectColumns = `id, message, type, mime_type, size, filename, metadata, blob, created_at`
+const artifactDataWithDataColumns = artifactDataSelectColumns + `, data`
 
 func (store *SqliteStore) SaveMessage(msg *chat.Message) error {
-	query := `INSERT INTO {0} ({1}) VALUES(?, ?, ?, ?, ?, ?, ?)`
+	// Artifacts are validated first, so that an invalid
+	// artifact doesn't leave a message saved without it
+	for _, artifact := range msg.Artifacts {
+		if err := artifact.Validate(); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, {2})`
 
-	query = stringFormatter.Format(query, MESSAGES_TABLE, messageSelectColumns)
+	query = stringFormatter.Format(query, MESSAGES_TABLE, messageSelectColumns, agentTenant)
+
+	// Tool calls are stored as JSON, or NULL if there are none
+	var toolCalls sql.NullString
+	if len(msg.ToolCalls) > 0 {
+		encoded, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			return err
+		}
+		toolCalls = sql.NullString{String: string(encoded), Valid: true}
+	}
 
 	_, err := store.db.Exec(
 		query,
@@ -28,7 +53,13 @@ func (store *SqliteStore) SaveMessage(msg *chat.Message) error {
 		msg.Agent,
 		msg.From,
 		msg.Content,
+		toolCalls,
 		msg.CreatedAt,
+		msg.AgentVersion,
+		msg.Experiment,
+		msg.Variant,
+		msg.Duration,
+		msg.Agent,
 	)
 	if err != nil {
 		return err
@@ -44,30 +75,59 @@ func (store *SqliteStore) saveArtifactData(data []*artifacts.ArtifactData) error
 		return nil
 	}
 
-	query := `INSERT INTO {0} ({1}) VALUES {2}`
+	query := `INSERT INTO {0} ({1}, tenant) VALUES {2}`
 
-	// We need a (?, ?, ?, ?, ?) for each artifact data, with a comma
-	// between each set of values
+	// We need a (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, tenant) for each
+	// artifact data, with a comma between each set of values
 	placeholders := ""
 	for i := 0; i < len(data); i++ {
 		if i > 0 {
 			placeholders += ", "
 		}
-		placeholders += "(?, ?, ?, ?, ?)"
+		placeholders += "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, " + messageTenant + ")"
 	}
 
-	query = stringFormatter.Format(query, ARTIFACTS_TABLE, artifactDataSelectColumns, placeholders)
+	query = stringFormatter.Format(query, ARTIFACTS_TABLE, artifactDataWithDataColumns, placeholders)
 
 	// Now we need to flatten the data into a single array of values
 	values := []interface{}{}
 	for _, artifact := range data {
+		// Metadata is stored as JSON, or NULL if there is none
+		var metadata sql.NullString
+		if len(artifact.Metadata) > 0 {
+			encoded, err := json.Marshal(artifact.Metadata)
+			if err != nil {
+				return err
+			}
+			metadata = sql.NullString{String: string(encoded), Valid: true}
+		}
+
+		// If we have a blob store the data is written to it,
+		// keeping only its key here
+		var blobKey sql.NullString
+		inline := artifact.Data
+		if store.blobs != nil {
+			artifact.Blob = blob.Key(artifact.Data)
+			if err := store.blobs.Put(artifact.Blob, artifact.Data); err != nil {
+				return err
+			}
+			blobKey = sql.NullString{String: artifact.Blob, Valid: true}
+			inline = nil
+		}
+
 		values = append(
 			values,
 			artifact.ID,
 			artifact.Message,
 			artifact.Type,
-			artifact.Data,
+			artifact.MimeType,
+			artifact.Size,
+			artifact.Filename,
+			metadata,
+			blobKey,
 			artifact.CreatedAt,
+			inline,
+			artifact.Message,
 		)
 	}
 
@@ -75,6 +135,45 @@ func (store *SqliteStore) saveArtifactData(data []*artifacts.ArtifactData) error
 	return err
 }
 
+func (store *SqliteStore) SaveArtifact(artifact *artifacts.ArtifactData) error {
+	if err := artifact.Validate(); err != nil {
+		return err
+	}
+
+	return store.saveArtifactData([]*artifacts.ArtifactData{artifact})
+}
+
+func (store *SqliteStore) GetArtifact(id string) (*artifacts.ArtifactData, error) {
+	query := `SELECT {0} FROM {1} WHERE id = ?`
+
+	query = stringFormatter.Format(query, artifactDataWithDataColumns, ARTIFACTS_TABLE)
+
+	rows, err := store.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactData, err := store.sqlToArtifacts(rows, true)
+	if err != nil {
+		return nil, err
+	} else if len(artifactData) == 0 {
+		return nil, nil
+	}
+
+	artifact := &artifactData[0]
+	if artifact.Blob != "" {
+		if store.blobs == nil {
+			return nil, fmt.Errorf("artifact %s is kept in a blob store, but none is set", artifact.ID)
+		}
+		artifact.Data, err = store.blobs.Get(artifact.Blob)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load artifact %s: %w", artifact.ID, err)
+		}
+	}
+
+	return artifact, nil
+}
+
 func (store *SqliteStore) GetMessage(id string) (*chat.Message, error) {
 	query := `SELECT {0} FROM {1} WHERE id = ?`
 
@@ -124,7 +223,7 @@ func (store *SqliteStore) populateArtifacts(messages []*chat.Message) ([]*chat.M
 	if err != nil {
 		return nil, err
 	}
-	artifactData, err := store.sqlToArtifacts(rows)
+	artifactData, err := store.sqlToArtifacts(rows, false)
 	if err != nil {
 		return nil, err
 	}
@@ -148,10 +247,88 @@ func (store *SqliteStore) DeleteMessage(id string) error {
 		return err
 	}
 
-	query = `DELETE FROM {0} WHERE message = ?`
+	return store.deleteArtifacts(`message = ?`, id)
+}
+
+/*
+deleteArtifacts deletes the artifacts matching the where
+clause along with their document chunks, then any blobs no
+other artifact still refers to.
+*/
+func (store *SqliteStore) deleteArtifacts(where string, params ...interface{}) error {
+	keys, err := store.artifactBlobs(where, params...)
+	if err != nil {
+		return err
+	}
+
+	// Any documents among them are no longer searchable
+	query := `DELETE FROM {0} WHERE document IN (SELECT id FROM {1} WHERE {2})`
+	query = stringFormatter.Format(query, DOCUMENT_CHUNKS_TABLE, ARTIFACTS_TABLE, where)
+	_, err = store.db.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+
+	query = `DELETE FROM {0} WHERE {1}`
+	query = stringFormatter.Format(query, ARTIFACTS_TABLE, where)
+	_, err = store.db.Exec(query, params...)
+	if err != nil {
+		return err
+	}
+
+	return store.deleteUnreferencedBlobs(keys)
+}
+
+// artifactBlobs returns the blob keys of the matching artifacts
+func (store *SqliteStore) artifactBlobs(where string, params ...interface{}) ([]string, error) {
+	query := `SELECT DISTINCT blob FROM {0} WHERE blob IS NOT NULL AND {1}`
+	query = stringFormatter.Format(query, ARTIFACTS_TABLE, where)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+/*
+deleteUnreferencedBlobs deletes the given blobs once no
+artifact refers to them. Blobs are shared by every artifact
+with the same data, so they can't be deleted along with any
+one artifact.
+*/
+func (store *SqliteStore) deleteUnreferencedBlobs(keys []string) error {
+	if store.blobs == nil {
+		return nil
+	}
+
+	query := `SELECT COUNT(*) FROM {0} WHERE blob = ?`
 	query = stringFormatter.Format(query, ARTIFACTS_TABLE)
-	_, err = store.db.Exec(query, id)
-	return err
+	for _, key := range keys {
+		var references int
+		err := store.db.QueryRow(query, key).Scan(&references)
+		if err != nil {
+			return err
+		}
+		if references > 0 {
+			continue
+		}
+		if err := store.blobs.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (store *SqliteStore) ListMessages(filter store.Filter) ([]*chat.Message, error) {
@@ -214,6 +391,11 @@ func (store *SqliteStore) GetConversation(conversation string) (*chat.Conversati
 		return nil, nil
 	}
 
+	messages, err = store.populateArtifacts(messages)
+	if err != nil {
+		return nil, err
+	}
+
 	return &chat.Conversation{
 		ID:        conversation,
 		User:      messages[0].User,
@@ -224,11 +406,19 @@ func (store *SqliteStore) GetConversation(conversation string) (*chat.Conversati
 }
 
 func (store *SqliteStore) DeleteConversation(conversation string) error {
+	// Artifacts are found through their messages, so they
+	// must be deleted first
+	where := stringFormatter.Format(`message IN (SELECT id FROM {0} WHERE conversation = ?)`, MESSAGES_TABLE)
+	err := store.deleteArtifacts(where, conversation)
+	if err != nil {
+		return err
+	}
+
 	query := `DELETE FROM {0} WHERE conversation = ?`
 
 	query = stringFormatter.Format(query, MESSAGES_TABLE)
 
-	_, err := store.db.Exec(query, conversation)
+	_, err = store.db.Exec(query, conversation)
 	return err
 }
 
@@ -455,6 +645,7 @@ func (store *SqliteStore) sqlToMessages(rows *sql.Rows) ([]*chat.Message, error)
 	for rows.Next() {
 		var msg chat.Message
 		var datetime string
+		var toolCalls sql.NullString
 		err := rows.Scan(
 			&msg.ID,
 			&msg.Conversation,
@@ -462,11 +653,22 @@ func (store *SqliteStore) sqlToMessages(rows *sql.Rows) ([]*chat.Message, error)
 			&msg.Agent,
 			&msg.From,
 			&msg.Content,
+			&toolCalls,
 			&datetime,
+			&msg.AgentVersion,
+			&msg.Experiment,
+			&msg.Variant,
+			&msg.Duration,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if toolCalls.String != "" {
+			err = json.Unmarshal([]byte(toolCalls.String), &msg.ToolCalls)
+			if err != nil {
+				return nil, err
+			}
+		}
 		timestamp, err := store.sqlTimestampToTime(datetime)
 		if err != nil {
 			return nil, err
@@ -478,24 +680,45 @@ func (store *SqliteStore) sqlToMessages(rows *sql.Rows) ([]*chat.Message, error)
 	return messages, nil
 }
 
-func (store *SqliteStore) sqlToArtifacts(rows *sql.Rows) ([]artifacts.ArtifactData, error) {
+/*
+sqlToArtifacts reads artifacts selected with either
+artifactDataSelectColumns or, if withData is set,
+artifactDataWithDataColumns.
+*/
+func (store *SqliteStore) sqlToArtifacts(rows *sql.Rows, withData bool) ([]artifacts.ArtifactData, error) {
 	defer rows.Close()
 
 	artifactData := []artifacts.ArtifactData{}
 
 	for rows.Next() {
 		var data artifacts.ArtifactData
+		var metadata, blobKey sql.NullString
 		var datetime string
-		err := rows.Scan(
+		columns := []interface{}{
 			&data.ID,
 			&data.Message,
 			&data.Type,
-			&data.Data,
+			&data.MimeType,
+			&data.Size,
+			&data.Filename,
+			&metadata,
+			&blobKey,
 			&datetime,
-		)
+		}
+		if withData {
+			columns = append(columns, &data.Data)
+		}
+		err := rows.Scan(columns...)
 		if err != nil {
 			return nil, err
 		}
+		data.Blob = blobKey.String
+		if metadata.Valid {
+			err = json.Unmarshal([]byte(metadata.String), &data.Metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
 		createdAt, err := store.sqlTimestampToTime(datetime)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/wissance/stringFormatter"
+)
+
+const promptSelectColumns = `agent, name, template, updated_at`
+
+func (store *SqliteStore) SavePromptTemplate(prompt *agents.PromptTemplate) error {
+	query := `INSERT OR REPLACE INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, {2})`
+
+	query = stringFormatter.Format(query, PROMPT_TEMPLATES_TABLE, promptSelectColumns, agentTenant)
+
+	if prompt.UpdatedAt.IsZero() {
+		prompt.UpdatedAt = time.Now()
+	}
+
+	_, err := store.db.Exec(
+		query,
+		prompt.Agent,
+		prompt.Name,
+		prompt.Template,
+		prompt.UpdatedAt,
+		prompt.Agent,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) GetPromptTemplate(agent string, name string) (*agents.PromptTemplate, error) {
+	query := `SELECT {0} FROM {1} WHERE agent = ? AND name = ?`
+
+	query = stringFormatter.Format(query, promptSelectColumns, PROMPT_TEMPLATES_TABLE)
+
+	rows, err := store.db.Query(query, agent, name)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, err := store.sqlToPromptTemplates(rows)
+	if err != nil {
+		return nil, err
+	} else if len(prompts) == 0 {
+		return nil, nil
+	}
+
+	return prompts[0], nil
+}
+
+func (store *SqliteStore) DeletePromptTemplate(agent string, name string) error {
+	query := `DELETE FROM {0} WHERE agent = ? AND name = ?`
+
+	query = stringFormatter.Format(query, PROMPT_TEMPLATES_TABLE)
+
+	_, err := store.db.Exec(query, agent, name)
+	return err
+}
+
+func (store *SqliteStore) ListPromptTemplates(filter store.Filter) ([]*agents.PromptTemplate, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY agent ASC, name ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": promptSelectColumns,
+			"table":   PROMPT_TEMPLATES_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToPromptTemplates(rows)
+}
+
+func (store *SqliteStore) sqlToPromptTemplates(rows *sql.Rows) ([]*agents.PromptTemplate, error) {
+	defer rows.Close()
+
+	prompts := []*agents.PromptTemplate{}
+
+	for rows.Next() {
+		var prompt agents.PromptTemplate
+		var datetime string
+		err := rows.Scan(
+			&prompt.Agent,
+			&prompt.Name,
+			&prompt.Template,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		prompt.UpdatedAt = timestamp
+		prompts = append(prompts, &prompt)
+	}
+
+	return prompts, nil
+}
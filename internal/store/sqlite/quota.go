@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/wissance/stringFormatter"
+)
+
+func (store *SqliteStore) IncrementQuota(scope string, period string, periodStart time.Time, requests int, tokens int) (*quota.Counter, error) {
+	query := `
+		INSERT INTO {0} (
+			scope,
+			period,
+			period_start,
+			requests,
+			tokens
+		) VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT (scope, period, period_start) DO UPDATE SET
+			requests = {0}.requests + excluded.requests,
+			tokens = {0}.tokens + excluded.tokens
+		RETURNING requests, tokens
+	`
+
+	query = stringFormatter.Format(query, QUOTAS_TABLE)
+
+	counter := &quota.Counter{
+		Scope:       scope,
+		Period:      period,
+		PeriodStart: periodStart,
+	}
+
+	err := store.db.QueryRow(
+		query,
+		scope,
+		period,
+		periodStart,
+		requests,
+		tokens,
+	).Scan(&counter.Requests, &counter.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return counter, nil
+}
+
+func (store *SqliteStore) GetQuota(scope string, period string, periodStart time.Time) (*quota.Counter, error) {
+	query := `SELECT requests, tokens FROM {0} WHERE scope = ? AND period = ? AND period_start = ?`
+
+	query = stringFormatter.Format(query, QUOTAS_TABLE)
+
+	rows, err := store.db.Query(query, scope, period, periodStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counter := &quota.Counter{
+		Scope:       scope,
+		Period:      period,
+		PeriodStart: periodStart,
+	}
+
+	for rows.Next() {
+		err = rows.Scan(&counter.Requests, &counter.Tokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counter, nil
+}
+
+func (store *SqliteStore) ExpireQuotas(before time.Time) error {
+	query := `DELETE FROM {0} WHERE period_start < ?`
+
+	query = stringFormatter.Format(query, QUOTAS_TABLE)
+
+	_, err := store.db.Exec(query, before.UTC())
+	return err
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/wissance/stringFormatter"
+)
+
+const revisionSelectColumns = `agent, version, identity, author, created_at`
+
+func (store *SqliteStore) SaveAgentRevision(revision *agents.Revision) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, {2})`
+
+	query = stringFormatter.Format(query, AGENT_REVISIONS_TABLE, revisionSelectColumns, agentTenant)
+
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+
+	_, err := store.db.Exec(
+		query,
+		revision.Agent,
+		revision.Version,
+		revision.Identity,
+		revision.Author,
+		revision.CreatedAt,
+		revision.Agent,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) GetAgentRevision(agent string, version int) (*agents.Revision, error) {
+	query := `SELECT {0} FROM {1} WHERE agent = ? AND version = ?`
+
+	query = stringFormatter.Format(query, revisionSelectColumns, AGENT_REVISIONS_TABLE)
+
+	rows, err := store.db.Query(query, agent, version)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := store.sqlToRevisions(rows)
+	if err != nil {
+		return nil, err
+	} else if len(revisions) == 0 {
+		return nil, nil
+	}
+
+	return revisions[0], nil
+}
+
+func (store *SqliteStore) ListAgentRevisions(filter store.Filter) ([]*agents.Revision, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY agent ASC, version ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": revisionSelectColumns,
+			"table":   AGENT_REVISIONS_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToRevisions(rows)
+}
+
+func (store *SqliteStore) sqlToRevisions(rows *sql.Rows) ([]*agents.Revision, error) {
+	defer rows.Close()
+
+	revisions := []*agents.Revision{}
+
+	for rows.Next() {
+		var revision agents.Revision
+		var datetime string
+		err := rows.Scan(
+			&revision.Agent,
+			&revision.Version,
+			&revision.Identity,
+			&revision.Author,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		revision.CreatedAt = timestamp
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
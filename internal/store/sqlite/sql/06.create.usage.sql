@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS
+    Usage_V1(
+        id TEXT NOT NULL PRIMARY KEY,
+        agent TEXT,
+        user TEXT,
+        conversation TEXT,
+        type TEXT NOT NULL,
+        model TEXT NOT NULL,
+        prompt_tokens INTEGER NOT NULL DEFAULT 0,
+        completion_tokens INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP NOT NULL DEFAULT NOW
+    );
+
+CREATE UNIQUE INDEX IF NOT EXISTS usage_id_v1 ON Usage_V1(id);
+CREATE INDEX IF NOT EXISTS usage_time_v1 ON Usage_V1(created_at);
+CREATE INDEX IF NOT EXISTS usage_agent_user_time_v1 ON Usage_V1(agent, user, created_at);
\ No newline at end of file
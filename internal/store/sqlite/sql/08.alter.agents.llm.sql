@@ -0,0 +1 @@
+ALTER TABLE Agents_V1 ADD COLUMN llm TEXT NOT NULL DEFAULT '{}';
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS
+    Routes_V1(
+        id TEXT NOT NULL PRIMARY KEY,
+        agent TEXT,
+        user TEXT,
+        conversation TEXT,
+        type TEXT NOT NULL,
+        backend TEXT NOT NULL,
+        attempt INTEGER NOT NULL DEFAULT 0,
+        success BOOLEAN NOT NULL DEFAULT FALSE,
+        error TEXT,
+        duration_ms INTEGER NOT NULL DEFAULT 0,
+        created_at TIMESTAMP NOT NULL DEFAULT NOW
+    );
+
+CREATE INDEX IF NOT EXISTS routes_time_v1 ON Routes_V1(created_at);
+CREATE INDEX IF NOT EXISTS routes_conversation_v1 ON Routes_V1(conversation, created_at);
\ No newline at end of file
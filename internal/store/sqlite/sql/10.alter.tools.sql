@@ -0,0 +1,2 @@
+ALTER TABLE Agents_V1 ADD COLUMN tools TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE Messages_V1 ADD COLUMN tool_calls TEXT;
\ No newline at end of file
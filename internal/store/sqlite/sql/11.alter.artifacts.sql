@@ -0,0 +1,4 @@
+ALTER TABLE Artifacts_V1 ADD COLUMN mime_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE Artifacts_V1 ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Artifacts_V1 ADD COLUMN filename TEXT NOT NULL DEFAULT '';
+ALTER TABLE Artifacts_V1 ADD COLUMN metadata TEXT;
\ No newline at end of file
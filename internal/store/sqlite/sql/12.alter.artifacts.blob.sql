@@ -0,0 +1,3 @@
+ALTER TABLE Artifacts_V1 ADD COLUMN blob TEXT;
+
+CREATE INDEX IF NOT EXISTS artifacts_blob_v1 ON Artifacts_V1(blob);
\ No newline at end of file
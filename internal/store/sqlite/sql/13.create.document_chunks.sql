@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS
+    DocumentChunks_V1(
+        id TEXT NOT NULL PRIMARY KEY,
+        document TEXT NOT NULL,
+        filename TEXT NOT NULL DEFAULT '',
+        agent TEXT NOT NULL,
+        user TEXT NOT NULL,
+        conversation TEXT NOT NULL DEFAULT '',
+        idx INTEGER NOT NULL,
+        content TEXT NOT NULL,
+        model TEXT NOT NULL,
+        embedding TEXT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT NOW
+    );
+
+CREATE INDEX IF NOT EXISTS document_chunks_document_v1 ON DocumentChunks_V1(document);
+CREATE INDEX IF NOT EXISTS document_chunks_user_conversation_v1 ON DocumentChunks_V1(user, conversation);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE Knowledge_V1 ADD COLUMN superseded_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE Knowledge_V1 ADD COLUMN superseded_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS knowledge_agent_user_superseded_v1 ON Knowledge_V1(agent, user, superseded_by);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE Knowledge_V1 ADD COLUMN conversation TEXT NOT NULL DEFAULT '';
+ALTER TABLE Knowledge_V1 ADD COLUMN messages TEXT NOT NULL DEFAULT 'null';
+ALTER TABLE Knowledge_V1 ADD COLUMN confidence REAL NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS knowledge_conversation_v1 ON Knowledge_V1(conversation);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE Knowledge_V1 ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
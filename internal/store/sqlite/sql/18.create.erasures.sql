@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS
+    Erasures_V1(
+        id TEXT NOT NULL PRIMARY KEY,
+        user TEXT NOT NULL,
+        counts TEXT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT NOW
+    );
+
+CREATE INDEX IF NOT EXISTS erasures_user_v1 ON Erasures_V1(user, created_at);
\ No newline at end of file
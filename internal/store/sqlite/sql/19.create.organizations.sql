@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS
+    Organizations_V1(
+        id TEXT NOT NULL PRIMARY KEY,
+        name TEXT NOT NULL,
+        settings TEXT NOT NULL,
+        created_at TIMESTAMP NOT NULL DEFAULT NOW,
+        updated_at TIMESTAMP NOT NULL DEFAULT NOW
+    );
\ No newline at end of file
@@ -0,0 +1,20 @@
+ALTER TABLE Agents_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Users_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Messages_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Artifacts_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Summaries_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE SummaryExclusion_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Knowledge_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE KnowledgeExtraction_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE EntityAliases_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE DocumentChunks_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Usage_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Routes_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Erasures_V1 ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS agents_tenant_v1 ON Agents_V1(tenant);
+CREATE INDEX IF NOT EXISTS users_tenant_v1 ON Users_V1(tenant);
+CREATE INDEX IF NOT EXISTS messages_tenant_time_v1 ON Messages_V1(tenant, created_at);
+CREATE INDEX IF NOT EXISTS summaries_tenant_v1 ON Summaries_V1(tenant);
+CREATE INDEX IF NOT EXISTS knowledge_tenant_v1 ON Knowledge_V1(tenant);
+CREATE INDEX IF NOT EXISTS usage_tenant_time_v1 ON Usage_V1(tenant, created_at);
\ No newline at end of file
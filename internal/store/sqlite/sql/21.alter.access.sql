@@ -0,0 +1,2 @@
+ALTER TABLE Users_V1 ADD COLUMN role TEXT NOT NULL DEFAULT 'member';
+ALTER TABLE Agents_V1 ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
\ No newline at end of file
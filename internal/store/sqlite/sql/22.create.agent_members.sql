@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS
+    AgentMembers_V1(
+        agent TEXT NOT NULL,
+        user TEXT NOT NULL,
+        tenant TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL DEFAULT NOW,
+        PRIMARY KEY (agent, user)
+    );
+
+CREATE INDEX IF NOT EXISTS agent_members_user_v1 ON AgentMembers_V1(user);
\ No newline at end of file
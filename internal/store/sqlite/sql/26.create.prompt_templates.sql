@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS
+    PromptTemplates_V1(
+        agent TEXT NOT NULL DEFAULT '',
+        name TEXT NOT NULL,
+        template TEXT NOT NULL,
+        tenant TEXT NOT NULL DEFAULT '',
+        updated_at TIMESTAMP NOT NULL DEFAULT NOW,
+        PRIMARY KEY (agent, name)
+    );
\ No newline at end of file
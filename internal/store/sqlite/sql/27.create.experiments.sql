@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS
+    Experiments_V1(
+        id TEXT NOT NULL PRIMARY KEY,
+        agent TEXT NOT NULL,
+        name TEXT NOT NULL,
+        variants TEXT NOT NULL DEFAULT '[]',
+        active BOOLEAN NOT NULL DEFAULT FALSE,
+        tenant TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL DEFAULT NOW,
+        ended_at TIMESTAMP
+    );
+
+CREATE INDEX IF NOT EXISTS experiments_agent_v1 ON Experiments_V1(agent, active);
\ No newline at end of file
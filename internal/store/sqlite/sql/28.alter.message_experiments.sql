@@ -0,0 +1,5 @@
+ALTER TABLE Messages_V1 ADD COLUMN experiment TEXT NOT NULL DEFAULT '';
+ALTER TABLE Messages_V1 ADD COLUMN variant TEXT NOT NULL DEFAULT '';
+ALTER TABLE Messages_V1 ADD COLUMN duration_ms INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS messages_experiment_v1 ON Messages_V1(experiment);
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS
+    Feedback_V1(
+        message TEXT NOT NULL,
+        user TEXT NOT NULL,
+        rating INTEGER NOT NULL,
+        tenant TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL DEFAULT NOW,
+        PRIMARY KEY (message, user)
+    );
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE Erasures_V1 ADD COLUMN actor TEXT NOT NULL DEFAULT '';
\ No newline at end of file
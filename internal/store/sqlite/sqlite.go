@@ -3,10 +3,15 @@ package sqlite
 import (
 	"database/sql"
 	"embed"
+	"fmt"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/hlfshell/coppermind/internal/store/blob"
+	"github.com/wissance/stringFormatter"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -18,6 +23,30 @@ const SUMMARIES_TABLE = "Summaries_V1"
 const SUMMARY_EXCLUSION_TABLE = "SummaryExclusion_V1"
 const KNOWLEDGE_TABLE = "Knowledge_V1"
 const KNOWLEDGE_EXTRACTION_TABLE = "KnowledgeExtraction_V1"
+const USAGE_TABLE = "Usage_V1"
+const QUOTAS_TABLE = "Quotas_V1"
+const ROUTES_TABLE = "Routes_V1"
+const DOCUMENT_CHUNKS_TABLE = "DocumentChunks_V1"
+const ENTITY_ALIASES_TABLE = "EntityAliases_V1"
+const ERASURES_TABLE = "Erasures_V1"
+const ORGANIZATIONS_TABLE = "Organizations_V1"
+const AGENT_MEMBERS_TABLE = "AgentMembers_V1"
+const AGENT_REVISIONS_TABLE = "AgentRevisions_V1"
+const PROMPT_TEMPLATES_TABLE = "PromptTemplates_V1"
+const EXPERIMENTS_TABLE = "Experiments_V1"
+const FEEDBACK_TABLE = "Feedback_V1"
+const MIGRATIONS_TABLE = "Migrations_V1"
+
+/*
+Records belong to the organization of the agent they were
+created by, or for artifacts, exclusions and extractions,
+of their message or conversation. These select that tenant
+for the ID bound to their placeholder, or the default
+tenant if there is no such agent or message.
+*/
+var agentTenant = stringFormatter.Format(`COALESCE((SELECT tenant FROM {0} WHERE id = ?), '')`, AGENTS_TABLE)
+var messageTenant = stringFormatter.Format(`COALESCE((SELECT tenant FROM {0} WHERE id = ?), '')`, MESSAGES_TABLE)
+var conversationTenant = stringFormatter.Format(`COALESCE((SELECT tenant FROM {0} WHERE conversation = ? LIMIT 1), '')`, MESSAGES_TABLE)
 
 //go:embed sql/*.sql
 var sqlFolder embed.FS
@@ -25,10 +54,27 @@ var sqlFolderPath = "sql"
 
 type SqliteStore struct {
 	db *sql.DB
+
+	// Where artifact data is kept; nil if it is kept in the
+	// database itself
+	blobs blob.BlobStore
 }
 
+// busyTimeout is how long a write waits on another to finish
+// before failing with "database is locked"
+const busyTimeout = 5 * time.Second
+
+/*
+NewSqliteStore opens the sqlite database at the given path.
+File databases are put in WAL mode, so that reads don't
+block on writes, and writes wait up to busyTimeout on each
+other. Transactions take their write lock immediately, as a
+transaction upgrading from a read lock can't wait on the
+busy timeout. This lets the daemons and HTTP handlers write
+concurrently.
+*/
 func NewSqliteStore(dbFilePath string) (*SqliteStore, error) {
-	db, err := sql.Open("sqlite3", dbFilePath)
+	db, err := sql.Open("sqlite3", connectionString(dbFilePath))
 	if err != nil {
 		return nil, err
 	}
@@ -38,13 +84,65 @@ func NewSqliteStore(dbFilePath string) (*SqliteStore, error) {
 	}, nil
 }
 
+// connectionString adds our connection options to a path
+func connectionString(dbFilePath string) string {
+	options := fmt.Sprintf("_busy_timeout=%d&_txlock=immediate", busyTimeout.Milliseconds())
+	if !strings.Contains(dbFilePath, ":memory:") && !strings.Contains(dbFilePath, "mode=memory") {
+		options += "&_journal_mode=WAL"
+	}
+
+	if strings.Contains(dbFilePath, "?") {
+		return dbFilePath + "&" + options
+	}
+	return dbFilePath + "?" + options
+}
+
+// Close closes the database
+func (store *SqliteStore) Close() error {
+	return store.db.Close()
+}
+
+/*
+SetBlobStore moves the data of any artifacts saved from now
+on out of the database and into the given blob store; only a
+reference to the blob is kept in the artifacts table.
+Artifacts saved before a blob store was set are still read
+from the database.
+*/
+func (store *SqliteStore) SetBlobStore(blobs blob.BlobStore) {
+	store.blobs = blobs
+}
+
+// migrationsTableQuery creates the table used to track which
+// migration files have been applied, so that each is only
+// ever ran once
+const migrationsTableQuery = `CREATE TABLE IF NOT EXISTS
+    {0}(
+        name TEXT NOT NULL PRIMARY KEY,
+        applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );`
+
 func (store *SqliteStore) Migrate() error {
+	_, err := store.db.Exec(stringFormatter.Format(migrationsTableQuery, MIGRATIONS_TABLE))
+	if err != nil {
+		return err
+	}
+
+	applied, err := store.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
 	entries, err := fs.ReadDir(sqlFolder, sqlFolderPath)
 	if err != nil {
 		return err
 	}
 
 	for _, entry := range entries {
+		if applied[entry.Name()] {
+			continue
+		}
+
 		path := filepath.Join(sqlFolderPath, entry.Name())
 
 		bytes, err := fs.ReadFile(sqlFolder, path)
@@ -57,11 +155,39 @@ func (store *SqliteStore) Migrate() error {
 		if err != nil {
 			return err
 		}
+
+		query := stringFormatter.Format(`INSERT INTO {0} (name, applied_at) VALUES(?, ?)`, MIGRATIONS_TABLE)
+		_, err = store.db.Exec(query, entry.Name(), time.Now())
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (store *SqliteStore) appliedMigrations() (map[string]bool, error) {
+	query := stringFormatter.Format(`SELECT name FROM {0}`, MIGRATIONS_TABLE)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+
+	return applied, nil
+}
+
 func (store *SqliteStore) sqlTimestampToTime(timestamp string) (time.Time, error) {
 	parsed, err := time.Parse(time.RFC3339, timestamp)
 	if err != nil {
@@ -72,3 +198,8 @@ func (store *SqliteStore) sqlTimestampToTime(timestamp string) (time.Time, error
 	}
 	return parsed, nil
 }
+
+// nullTime stores an unset time as NULL
+func nullTime(timestamp time.Time) sql.NullTime {
+	return sql.NullTime{Time: timestamp, Valid: !timestamp.IsZero()}
+}
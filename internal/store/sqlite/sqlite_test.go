@@ -1,10 +1,16 @@
 package sqlite
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
 	storeTest "github.com/hlfshell/coppermind/internal/test/store"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,6 +25,76 @@ func createSqlLiteStore() (*SqliteStore, error) {
 	return store, nil
 }
 
+func TestArtifactBlobsSqlite(t *testing.T) {
+	sqlite, err := createSqlLiteStore()
+	require.Nil(t, err)
+
+	blobs := blob.NewMemoryBlobStore()
+	sqlite.SetBlobStore(blobs)
+
+	storeTest.ArtifactBlobs(t, sqlite, blobs)
+}
+
+func TestEraseUserSqlite(t *testing.T) {
+	sqlite, err := createSqlLiteStore()
+	require.Nil(t, err)
+
+	blobs := blob.NewMemoryBlobStore()
+	sqlite.SetBlobStore(blobs)
+
+	storeTest.EraseUser(t, sqlite, blobs)
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "coppermind.db")
+
+	sqlite, err := NewSqliteStore(path)
+	require.Nil(t, err)
+	require.Nil(t, sqlite.Migrate())
+
+	var journalMode string
+	require.Nil(t, sqlite.db.QueryRow(`PRAGMA journal_mode`).Scan(&journalMode))
+	assert.Equal(t, "wal", journalMode)
+
+	user := &users.User{ID: "keith", Name: "Keith", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	require.Nil(t, sqlite.CreateUser(user, "supersecret"))
+
+	// Backups are taken while the store is in use...
+	backup := filepath.Join(dir, "backups", "backup.db")
+	require.Nil(t, sqlite.Backup(backup))
+	assert.NotNil(t, sqlite.Backup(backup))
+
+	// ...and snapshots keep only the latest few
+	for index := 0; index < 3; index++ {
+		_, err := sqlite.Snapshot(filepath.Join(dir, "snapshots"), 2)
+		require.Nil(t, err)
+	}
+	snapshots, err := ListSnapshots(filepath.Join(dir, "snapshots"))
+	require.Nil(t, err)
+	assert.Len(t, snapshots, 2)
+
+	require.Nil(t, sqlite.DeleteUser(user.ID))
+	require.Nil(t, sqlite.Close())
+
+	// A file that isn't a database is never restored
+	garbage := filepath.Join(dir, "garbage.db")
+	require.Nil(t, os.WriteFile(garbage, []byte("not a database"), 0644))
+	assert.NotNil(t, Restore(garbage, path))
+
+	require.Nil(t, Restore(snapshots[1], path))
+
+	restored, err := NewSqliteStore(path)
+	require.Nil(t, err)
+	defer restored.Close()
+	require.Nil(t, restored.Migrate())
+
+	found, err := restored.GetUser(user.ID)
+	require.Nil(t, err)
+	require.NotNil(t, found)
+	assert.True(t, user.Equal(found))
+}
+
 func TestMigrate(t *testing.T) {
 
 }
@@ -30,20 +106,42 @@ func TestLowLevelSqlite(t *testing.T) {
 		"GenerateUserPasswordResetToken": storeTest.GenerateUserPasswordResetToken,
 		"ResetPassword":                  storeTest.ResetPassword,
 		"DeleteUser":                     storeTest.DeleteUser,
+		"ListUsers":                      storeTest.ListUsers,
 		"SaveAndGetMessage":              storeTest.SaveAndGetMessage,
+		"SaveAndGetArtifact":             storeTest.SaveAndGetArtifact,
+		"SaveMessageWithToolCalls":       storeTest.SaveMessageWithToolCalls,
+		"SaveResponseWithArtifacts":      storeTest.SaveResponseWithArtifacts,
 		"DeleteMessage":                  storeTest.DeleteMessage,
 		"ListMessages":                   storeTest.ListMessages,
 		"GetConversation":                storeTest.GetAndDeleteConversation,
 		"ListConversation":               storeTest.ListConversations,
 		"SaveAndGetAgent":                storeTest.SaveAndGetAgent,
+		"SaveAgentLLMSettings":           storeTest.SaveAgentLLMSettings,
+		"SaveAgentTools":                 storeTest.SaveAgentTools,
 		"DeleteAgent":                    storeTest.DeleteAgent,
 		"ListAgents":                     storeTest.ListAgents,
 		"SaveAndGetSummary":              storeTest.SaveAndGetSummary,
 		"DeleteSummary":                  storeTest.DeleteSummary,
 		"ListSummaries":                  storeTest.ListSummaries,
+		"ListSummaryExclusions":          storeTest.ListSummaryExclusions,
+		"SaveAndListKnowledge":           storeTest.SaveAndListKnowledge,
+		"CompressKnowledge":              storeTest.CompressKnowledge,
+		"KnowledgeProvenance":            storeTest.KnowledgeProvenance,
+		"UpdateAndDeleteKnowledge":       storeTest.UpdateAndDeleteKnowledge,
+		"SaveAndListUsage":               storeTest.SaveAndListUsage,
+		"SaveAndListRoutes":              storeTest.SaveAndListRoutes,
+		"SaveAndListChunks":              storeTest.SaveAndListChunks,
+		"SaveAndListOrganizations":       storeTest.SaveAndListOrganizations,
+		"TenantOwnership":                storeTest.TenantOwnership,
+		"AgentMembers":                   storeTest.AgentMembers,
+		"AgentRevisions":                 storeTest.AgentRevisions,
+		"PromptTemplates":                storeTest.PromptTemplates,
+		"Experiments":                    storeTest.Experiments,
+		"Feedback":                       storeTest.Feedback,
 	}
 
 	for name, _ := range tests {
+		name := name
 		t.Run("TestLowLevelSqlite"+name, func(t *testing.T) {
 			t.Parallel()
 			sqlite, err := createSqlLiteStore()
@@ -59,11 +157,14 @@ func TestSqlite(t *testing.T) {
 		"GetLatestConversation":          storeTest.GetLatestConversation,
 		"GetConversationsToSummarize":    storeTest.GetConversationsToSummarize,
 		"ExcludeConversationFromSummary": storeTest.ExcludeConversationFromSummary,
+		"IncrementAndGetQuota":           storeTest.IncrementAndGetQuota,
+		"GetKnowledgeGraph":              storeTest.GetKnowledgeGraph,
 		// "ExpireKnowledge":                     storeTest.ExpireKnowledge,
 		// "SetConversationAsKnowledgeExtracted": storeTest.SetConversationAsKnowledgeExtracted,
 	}
 
 	for name, _ := range tests {
+		name := name
 		t.Run("TestSqlite"+name, func(t *testing.T) {
 			t.Parallel()
 			sqlite, err := createSqlLiteStore()
@@ -12,11 +12,11 @@ import (
 const summaryColumns = `id, conversation, agent, user, keywords, summary, conversation_started_at, updated_at`
 
 func (store *SqliteStore) SaveSummary(summary *memory.Summary) error {
-	query := `INSERT OR REPLACE INTO {0} ({1}) VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT OR REPLACE INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, ?, ?, ?, {2})`
 
 	summary.UpdatedAt = time.Now()
 
-	query = stringFormatter.Format(query, SUMMARIES_TABLE, summaryColumns)
+	query = stringFormatter.Format(query, SUMMARIES_TABLE, summaryColumns, agentTenant)
 
 	_, err := store.db.Exec(
 		query,
@@ -28,6 +28,7 @@ func (store *SqliteStore) SaveSummary(summary *memory.Summary) error {
 		summary.Summary,
 		summary.ConversationStartedAt,
 		summary.UpdatedAt,
+		summary.Agent,
 	)
 
 	return err
@@ -231,13 +232,14 @@ func (store *SqliteStore) ExcludeConversationFromSummary(conversation string) er
 	query := `
 		INSERT INTO {0} (
 			conversation,
-			created_at
-		) VALUES(?, ?)
+			created_at,
+			tenant
+		) VALUES(?, ?, {1})
 	`
 
-	query = stringFormatter.Format(query, SUMMARY_EXCLUSION_TABLE)
+	query = stringFormatter.Format(query, SUMMARY_EXCLUSION_TABLE, conversationTenant)
 
-	_, err := store.db.Exec(query, conversation, time.Now())
+	_, err := store.db.Exec(query, conversation, time.Now(), conversation)
 	return err
 }
 
@@ -250,6 +252,29 @@ func (store *SqliteStore) DeleteSummaryExclusion(conversation string) error {
 	return err
 }
 
+func (store *SqliteStore) ListSummaryExclusions() ([]string, error) {
+	query := `SELECT conversation FROM {0} ORDER BY created_at ASC`
+
+	query = stringFormatter.Format(query, SUMMARY_EXCLUSION_TABLE)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []string{}
+	for rows.Next() {
+		var conversation string
+		if err := rows.Scan(&conversation); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, nil
+}
+
 func (store *SqliteStore) sqlToSummmaries(rows *sql.Rows) ([]*memory.Summary, error) {
 	defer rows.Close()
 
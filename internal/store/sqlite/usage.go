@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/wissance/stringFormatter"
+)
+
+const usageSelectColumns = `id, agent, user, conversation, type, model, prompt_tokens, completion_tokens, created_at`
+
+func (store *SqliteStore) SaveUsage(record *usage.Usage) error {
+	query := `INSERT INTO {0} ({1}, tenant) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, {2})`
+
+	query = stringFormatter.Format(query, USAGE_TABLE, usageSelectColumns, agentTenant)
+
+	_, err := store.db.Exec(
+		query,
+		record.ID,
+		record.Agent,
+		record.User,
+		record.Conversation,
+		record.Type,
+		record.Model,
+		record.PromptTokens,
+		record.CompletionTokens,
+		record.CreatedAt,
+		record.Agent,
+	)
+
+	return err
+}
+
+func (store *SqliteStore) ListUsage(filter store.Filter) ([]*usage.Usage, error) {
+	query := `SELECT {columns} FROM {table} `
+	var filters string
+	var params []interface{}
+	var err error
+
+	if !filter.Empty() {
+		filters, params, err = filterToQueryParams(filter)
+		if err != nil {
+			return nil, err
+		}
+		query += `WHERE {filters} `
+	}
+
+	query += `ORDER BY created_at ASC `
+
+	if filter.Limit > 0 {
+		query += `LIMIT {limit} `
+	}
+
+	query = stringFormatter.FormatComplex(
+		query,
+		map[string]interface{}{
+			"columns": usageSelectColumns,
+			"table":   USAGE_TABLE,
+			"filters": filters,
+			"limit":   filter.Limit,
+		},
+	)
+
+	rows, err := store.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToUsage(rows)
+}
+
+func (store *SqliteStore) sqlToUsage(rows *sql.Rows) ([]*usage.Usage, error) {
+	defer rows.Close()
+
+	records := []*usage.Usage{}
+
+	for rows.Next() {
+		var record usage.Usage
+		var datetime string
+		err := rows.Scan(
+			&record.ID,
+			&record.Agent,
+			&record.User,
+			&record.Conversation,
+			&record.Type,
+			&record.Model,
+			&record.PromptTokens,
+			&record.CompletionTokens,
+			&datetime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, err := store.sqlTimestampToTime(datetime)
+		if err != nil {
+			return nil, err
+		}
+		record.CreatedAt = timestamp
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
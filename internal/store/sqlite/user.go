@@ -10,8 +10,8 @@ import (
 	"github.com/wissance/stringFormatter"
 )
 
-const userSelectAllColumns = `id, name, created_at, updated_at, password, reset_token, reset_token_attempts, reset_token_generated_at`
-const userSelectColumns = `id, name, created_at, updated_at`
+const userSelectAllColumns = `id, name, created_at, updated_at, tenant, role, password, reset_token, reset_token_attempts, reset_token_generated_at`
+const userSelectColumns = `id, name, created_at, updated_at, tenant, role`
 const userSelectAuthColumns = `id, password, reset_token, reset_token_attempts, reset_token_generated_at`
 
 func (store *SqliteStore) CreateUser(user *users.User, password string) error {
@@ -22,7 +22,7 @@ func (store *SqliteStore) CreateUser(user *users.User, password string) error {
 		return err
 	}
 
-	query := `INSERT INTO {0} ({1}) VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO {0} ({1}) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	query = stringFormatter.Format(query, USERS_TABLE, userSelectAllColumns)
 
@@ -32,6 +32,8 @@ func (store *SqliteStore) CreateUser(user *users.User, password string) error {
 		user.Name,
 		user.CreatedAt,
 		user.UpdatedAt,
+		user.Tenant,
+		user.Role,
 		auth.Password,
 		auth.ResetToken,
 		auth.ResetTokenAttempts,
@@ -100,6 +102,15 @@ func (store *SqliteStore) SaveUserAuth(auth *internal_users.UserAuth) error {
 	return err
 }
 
+func (store *SqliteStore) SetUserRole(id string, role string) error {
+	query := `UPDATE {0} SET role = ?, updated_at = ? WHERE id = ?`
+
+	query = stringFormatter.Format(query, USERS_TABLE)
+
+	_, err := store.db.Exec(query, role, time.Now(), id)
+	return err
+}
+
 func (store *SqliteStore) GenerateUserPasswordResetToken(id string) (string, error) {
 	auth, err := store.GetUserAuth(id)
 	if err != nil {
@@ -159,6 +170,19 @@ func (store *SqliteStore) DeleteUser(id string) error {
 	return err
 }
 
+func (store *SqliteStore) ListUsers() ([]*users.User, error) {
+	query := `SELECT {0} FROM {1} ORDER BY created_at ASC`
+
+	query = stringFormatter.Format(query, userSelectColumns, USERS_TABLE)
+
+	rows, err := store.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.sqlToUsers(rows)
+}
+
 func (store *SqliteStore) sqlToUsers(rows *sql.Rows) ([]*users.User, error) {
 	defer rows.Close()
 	var foundUsers []*users.User
@@ -169,6 +193,8 @@ func (store *SqliteStore) sqlToUsers(rows *sql.Rows) ([]*users.User, error) {
 			&user.Name,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.Tenant,
+			&user.Role,
 		)
 		if err != nil {
 			return nil, err
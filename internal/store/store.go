@@ -4,3 +4,12 @@ type Store interface {
 	LowLevelStore
 	HighLevelStore
 }
+
+/*
+Snapshotter is implemented by stores that can back themselves
+up to a local directory while in use, keeping only the
+latest retain snapshots.
+*/
+type Snapshotter interface {
+	Snapshot(dir string, retain int) (string, error)
+}
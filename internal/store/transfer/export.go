@@ -0,0 +1,389 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+)
+
+// exporter writes records, keeping count of each kind
+type exporter struct {
+	encoder  *json.Encoder
+	counts   Counts
+	progress Progress
+}
+
+func (exporter *exporter) write(kind string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	err = exporter.encoder.Encode(record{Kind: kind, Data: data})
+	if err != nil {
+		return err
+	}
+
+	exporter.counts[kind]++
+	if exporter.progress != nil && exporter.counts[kind]%progressInterval == 0 {
+		exporter.progress(kind, exporter.counts[kind])
+	}
+	return nil
+}
+
+// done reports that every record of a kind has been written
+func (exporter *exporter) done(kind string) {
+	if exporter.progress != nil {
+		exporter.progress(kind, exporter.counts[kind])
+	}
+}
+
+/*
+Export writes everything in a store to w - organizations,
+users with their authentication, agents with their members
+and identity revisions, prompt templates, experiments,
+messages with the data of their artifacts and their
+feedback, summaries and exclusions from summarization,
+knowledge and aliases, document chunks, usage and route
+records, and erasure audits. Quota counters
+are short lived, and are not exported. The number of records
+of each kind written is returned.
+*/
+func Export(db store.Store, w io.Writer, progress Progress) (Counts, error) {
+	buffered := bufio.NewWriter(w)
+	writer := &exporter{
+		encoder:  json.NewEncoder(buffered),
+		counts:   Counts{},
+		progress: progress,
+	}
+
+	err := writer.encoder.Encode(header{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []func(*exporter, store.Store) error{
+		exportOrganizations,
+		exportUsers,
+		exportAgents,
+		exportMembers,
+		exportRevisions,
+		exportPrompts,
+		exportExperiments,
+		exportMessages,
+		exportFeedback,
+		exportSummaries,
+		exportKnowledge,
+		exportChunks,
+		exportUsage,
+		exportErasures,
+	}
+	for _, step := range steps {
+		if err := step(writer, db); err != nil {
+			return nil, err
+		}
+	}
+
+	err = writer.encoder.Encode(record{Kind: kindEnd, Counts: writer.counts})
+	if err != nil {
+		return nil, err
+	}
+
+	return writer.counts, buffered.Flush()
+}
+
+func exportOrganizations(exporter *exporter, db store.Store) error {
+	orgs, err := db.ListOrganizations()
+	if err != nil {
+		return err
+	}
+
+	for _, org := range orgs {
+		if err := exporter.write(KindOrganization, org); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindOrganization)
+
+	return nil
+}
+
+func exportUsers(exporter *exporter, db store.Store) error {
+	found, err := db.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range found {
+		auth, err := db.GetUserAuth(user.ID)
+		if err != nil {
+			return err
+		}
+		err = exporter.write(KindUser, userRecord{User: user, Auth: auth})
+		if err != nil {
+			return err
+		}
+	}
+	exporter.done(KindUser)
+
+	return nil
+}
+
+func exportAgents(exporter *exporter, db store.Store) error {
+	agents, err := db.ListAgents()
+	if err != nil {
+		return err
+	}
+
+	for _, agent := range agents {
+		if err := exporter.write(KindAgent, agent); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindAgent)
+
+	return nil
+}
+
+func exportMembers(exporter *exporter, db store.Store) error {
+	members, err := db.ListAgentMembers(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if err := exporter.write(KindMember, member); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindMember)
+
+	return nil
+}
+
+func exportRevisions(exporter *exporter, db store.Store) error {
+	revisions, err := db.ListAgentRevisions(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, revision := range revisions {
+		if err := exporter.write(KindRevision, revision); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindRevision)
+
+	return nil
+}
+
+func exportPrompts(exporter *exporter, db store.Store) error {
+	prompts, err := db.ListPromptTemplates(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, prompt := range prompts {
+		if err := exporter.write(KindPrompt, prompt); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindPrompt)
+
+	return nil
+}
+
+func exportExperiments(exporter *exporter, db store.Store) error {
+	experiments, err := db.ListExperiments(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, experiment := range experiments {
+		if err := exporter.write(KindExperiment, experiment); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindExperiment)
+
+	return nil
+}
+
+/*
+exportMessages writes each message followed by its artifacts,
+which are loaded one at a time to carry their data. Which
+blob an artifact's data was kept in is left to the store it
+is imported into.
+*/
+func exportMessages(exporter *exporter, db store.Store) error {
+	messages, err := db.ListMessages(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		listed := msg.Artifacts
+		msg.Artifacts = nil
+		if err := exporter.write(KindMessage, msg); err != nil {
+			return err
+		}
+
+		for _, metadata := range listed {
+			artifact, err := db.GetArtifact(metadata.ID)
+			if err != nil {
+				return err
+			} else if artifact == nil {
+				return fmt.Errorf("artifact %s of message %s is missing", metadata.ID, msg.ID)
+			}
+
+			artifact.Blob = ""
+			if err := exporter.write(KindArtifact, artifact); err != nil {
+				return err
+			}
+		}
+	}
+	exporter.done(KindMessage)
+	exporter.done(KindArtifact)
+
+	return nil
+}
+
+func exportFeedback(exporter *exporter, db store.Store) error {
+	feedback, err := db.ListFeedback(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, rating := range feedback {
+		if err := exporter.write(KindFeedback, rating); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindFeedback)
+
+	return nil
+}
+
+func exportSummaries(exporter *exporter, db store.Store) error {
+	summaries, err := db.ListSummaries(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, summary := range summaries {
+		if err := exporter.write(KindSummary, summary); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindSummary)
+
+	exclusions, err := db.ListSummaryExclusions()
+	if err != nil {
+		return err
+	}
+
+	for _, conversation := range exclusions {
+		if err := exporter.write(KindExclusion, exclusionRecord{Conversation: conversation}); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindExclusion)
+
+	return nil
+}
+
+func exportKnowledge(exporter *exporter, db store.Store) error {
+	knowledge, err := db.ListKnowledge(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, fact := range knowledge {
+		if err := exporter.write(KindKnowledge, fact); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindKnowledge)
+
+	aliases, err := db.ListAliases(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range aliases {
+		if err := exporter.write(KindAlias, alias); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindAlias)
+
+	return nil
+}
+
+func exportChunks(exporter *exporter, db store.Store) error {
+	chunks, err := db.ListChunks(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if err := exporter.write(KindChunk, chunk); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindChunk)
+
+	return nil
+}
+
+func exportUsage(exporter *exporter, db store.Store) error {
+	records, err := db.ListUsage(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if err := exporter.write(KindUsage, record); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindUsage)
+
+	routes, err := db.ListRoutes(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		if err := exporter.write(KindRoute, route); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindRoute)
+
+	return nil
+}
+
+func exportErasures(exporter *exporter, db store.Store) error {
+	erasures, err := db.ListErasures(store.Filter{})
+	if err != nil {
+		return err
+	}
+
+	for _, erasure := range erasures {
+		if err := exporter.write(KindErasure, erasure); err != nil {
+			return err
+		}
+	}
+	exporter.done(KindErasure)
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/hlfshell/coppermind/pkg/users"
+)
+
+/*
+Import reads an export from r, saving each record through the
+store as it is read, and returns the number of records of
+each kind imported. The store is expected to be freshly
+migrated and empty. The import fails if the export is of an
+unknown format or version, or ends before its end record, or
+if its end record's counts don't match what was read.
+
+Summaries are marked as updated when imported, which only
+delays their next update.
+*/
+func Import(db store.Store, r io.Reader, progress Progress) (Counts, error) {
+	decoder := json.NewDecoder(r)
+
+	var head header
+	err := decoder.Decode(&head)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read export header: %w", err)
+	}
+	if head.Format != Format {
+		return nil, fmt.Errorf("not a %s export", Format)
+	} else if head.Version != Version {
+		return nil, fmt.Errorf("unsupported export version %d, expected %d", head.Version, Version)
+	}
+
+	counts := Counts{}
+	current := ""
+	for {
+		var next record
+		err := decoder.Decode(&next)
+		if err == io.EOF {
+			return nil, fmt.Errorf("export ended early, after %d %s records", counts[current], current)
+		} else if err != nil {
+			return nil, err
+		}
+
+		// Each kind is finished once the next begins
+		if next.Kind != current {
+			if current != "" && progress != nil {
+				progress(current, counts[current])
+			}
+			current = next.Kind
+		}
+
+		if next.Kind == kindEnd {
+			if diff := next.Counts.Diff(counts); diff != "" {
+				return nil, fmt.Errorf("export does not match its own counts (written != read) - %s", diff)
+			}
+			return counts, nil
+		}
+
+		err = importRecord(db, &next)
+		if err != nil {
+			return nil, fmt.Errorf("unable to import %s record %d: %w", next.Kind, counts[next.Kind]+1, err)
+		}
+
+		counts[next.Kind]++
+		if progress != nil && counts[next.Kind]%progressInterval == 0 {
+			progress(next.Kind, counts[next.Kind])
+		}
+	}
+}
+
+func importRecord(db store.Store, next *record) error {
+	switch next.Kind {
+	case KindOrganization:
+		var org tenants.Organization
+		if err := json.Unmarshal(next.Data, &org); err != nil {
+			return err
+		}
+		return db.SaveOrganization(&org)
+	case KindUser:
+		var user userRecord
+		if err := json.Unmarshal(next.Data, &user); err != nil {
+			return err
+		} else if user.User == nil {
+			return fmt.Errorf("user record has no user")
+		}
+
+		// Users are created with a throwaway password, which
+		// is then replaced by their exported hash
+		err := db.CreateUser(user.User, uuid.New().String())
+		if err != nil || user.Auth == nil {
+			return err
+		}
+		user.Auth.ID = user.User.ID
+		return db.SaveUserAuth(user.Auth)
+	case KindAgent:
+		var agent agents.Agent
+		if err := json.Unmarshal(next.Data, &agent); err != nil {
+			return err
+		}
+		return db.SaveAgent(&agent)
+	case KindMember:
+		var member agents.Member
+		if err := json.Unmarshal(next.Data, &member); err != nil {
+			return err
+		}
+		return db.SaveAgentMember(&member)
+	case KindRevision:
+		var revision agents.Revision
+		if err := json.Unmarshal(next.Data, &revision); err != nil {
+			return err
+		}
+		return db.SaveAgentRevision(&revision)
+	case KindPrompt:
+		var prompt agents.PromptTemplate
+		if err := json.Unmarshal(next.Data, &prompt); err != nil {
+			return err
+		}
+		return db.SavePromptTemplate(&prompt)
+	case KindExperiment:
+		var experiment experiments.Experiment
+		if err := json.Unmarshal(next.Data, &experiment); err != nil {
+			return err
+		}
+		return db.SaveExperiment(&experiment)
+	case KindMessage:
+		var msg chat.Message
+		if err := json.Unmarshal(next.Data, &msg); err != nil {
+			return err
+		}
+		return db.SaveMessage(&msg)
+	case KindArtifact:
+		var artifact artifacts.ArtifactData
+		if err := json.Unmarshal(next.Data, &artifact); err != nil {
+			return err
+		}
+		return db.SaveArtifact(&artifact)
+	case KindFeedback:
+		var feedback chat.Feedback
+		if err := json.Unmarshal(next.Data, &feedback); err != nil {
+			return err
+		}
+		return db.SaveFeedback(&feedback)
+	case KindSummary:
+		var summary memory.Summary
+		if err := json.Unmarshal(next.Data, &summary); err != nil {
+			return err
+		}
+		return db.SaveSummary(&summary)
+	case KindExclusion:
+		var exclusion exclusionRecord
+		if err := json.Unmarshal(next.Data, &exclusion); err != nil {
+			return err
+		}
+		return db.ExcludeConversationFromSummary(exclusion.Conversation)
+	case KindKnowledge:
+		var fact memory.Knowledge
+		if err := json.Unmarshal(next.Data, &fact); err != nil {
+			return err
+		}
+		return db.SaveKnowledge(&fact)
+	case KindAlias:
+		var alias memory.Alias
+		if err := json.Unmarshal(next.Data, &alias); err != nil {
+			return err
+		}
+		return db.SaveAlias(&alias)
+	case KindChunk:
+		var chunk documents.Chunk
+		if err := json.Unmarshal(next.Data, &chunk); err != nil {
+			return err
+		}
+		return db.SaveChunks([]*documents.Chunk{&chunk})
+	case KindUsage:
+		var record usage.Usage
+		if err := json.Unmarshal(next.Data, &record); err != nil {
+			return err
+		}
+		return db.SaveUsage(&record)
+	case KindRoute:
+		var route usage.Route
+		if err := json.Unmarshal(next.Data, &route); err != nil {
+			return err
+		}
+		return db.SaveRoute(&route)
+	case KindErasure:
+		var erasure users.Erasure
+		if err := json.Unmarshal(next.Data, &erasure); err != nil {
+			return err
+		}
+		return db.SaveErasure(&erasure)
+	default:
+		return fmt.Errorf("unknown kind of record")
+	}
+}
@@ -0,0 +1,295 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	users_internal "github.com/hlfshell/coppermind/internal/users"
+	"github.com/hlfshell/coppermind/pkg/users"
+)
+
+/*
+An export is a stream of newline delimited JSON. The first
+line is a header naming the format and its version. Every
+line after is a single record of one kind, in the order of
+the kinds below, and the last is an end record counting how
+many of each kind were written so that a truncated export is
+caught on import.
+*/
+const (
+	Format  = "coppermind"
+	Version = 1
+)
+
+// The kinds of records in an export, in the order written
+const (
+	KindOrganization = "organization"
+	KindUser         = "user"
+	KindAgent        = "agent"
+	KindMember       = "member"
+	KindRevision     = "revision"
+	KindPrompt       = "prompt"
+	KindExperiment   = "experiment"
+	KindMessage      = "message"
+	KindArtifact     = "artifact"
+	KindFeedback     = "feedback"
+	KindSummary      = "summary"
+	KindExclusion    = "exclusion"
+	KindKnowledge    = "knowledge"
+	KindAlias        = "alias"
+	KindChunk        = "chunk"
+	KindUsage        = "usage"
+	KindRoute        = "route"
+	KindErasure      = "erasure"
+	kindEnd          = "end"
+)
+
+var Kinds = []string{
+	KindOrganization,
+	KindUser,
+	KindAgent,
+	KindMember,
+	KindRevision,
+	KindPrompt,
+	KindExperiment,
+	KindMessage,
+	KindArtifact,
+	KindFeedback,
+	KindSummary,
+	KindExclusion,
+	KindKnowledge,
+	KindAlias,
+	KindChunk,
+	KindUsage,
+	KindRoute,
+	KindErasure,
+}
+
+// progressInterval is how many records of a kind pass
+// between each progress report
+const progressInterval = 1000
+
+/*
+Progress is called as records are exported or imported with
+the kind and how many of it have been handled so far, and
+once more when each kind is finished.
+*/
+type Progress func(kind string, count int)
+
+// Counts is the number of records of each kind
+type Counts map[string]int
+
+/*
+Diff describes every kind whose count differs between the
+two, or "" if they match.
+*/
+func (counts Counts) Diff(other Counts) string {
+	differences := []string{}
+	for _, kind := range Kinds {
+		if counts[kind] != other[kind] {
+			differences = append(differences, fmt.Sprintf("%s: %d != %d", kind, counts[kind], other[kind]))
+		}
+	}
+	return strings.Join(differences, ", ")
+}
+
+type header struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+type record struct {
+	Kind   string          `json:"kind"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Counts Counts          `json:"counts,omitempty"`
+}
+
+/*
+userRecord carries a user along with their authentication,
+so that their password hash survives the move.
+*/
+type userRecord struct {
+	User *users.User              `json:"user"`
+	Auth *users_internal.UserAuth `json:"auth,omitempty"`
+}
+
+type exclusionRecord struct {
+	Conversation string `json:"conversation"`
+}
+
+/*
+Count counts every record of each kind in a store, as an
+export of it would hold.
+*/
+func Count(db store.Store) (Counts, error) {
+	counts := Counts{}
+
+	orgs, err := db.ListOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	counts[KindOrganization] = len(orgs)
+
+	found, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	counts[KindUser] = len(found)
+
+	agents, err := db.ListAgents()
+	if err != nil {
+		return nil, err
+	}
+	counts[KindAgent] = len(agents)
+
+	members, err := db.ListAgentMembers(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindMember] = len(members)
+
+	revisions, err := db.ListAgentRevisions(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindRevision] = len(revisions)
+
+	prompts, err := db.ListPromptTemplates(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindPrompt] = len(prompts)
+
+	experiments, err := db.ListExperiments(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindExperiment] = len(experiments)
+
+	messages, err := db.ListMessages(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindMessage] = len(messages)
+	for _, msg := range messages {
+		counts[KindArtifact] += len(msg.Artifacts)
+	}
+
+	feedback, err := db.ListFeedback(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindFeedback] = len(feedback)
+
+	summaries, err := db.ListSummaries(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindSummary] = len(summaries)
+
+	exclusions, err := db.ListSummaryExclusions()
+	if err != nil {
+		return nil, err
+	}
+	counts[KindExclusion] = len(exclusions)
+
+	knowledge, err := db.ListKnowledge(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindKnowledge] = len(knowledge)
+
+	aliases, err := db.ListAliases(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindAlias] = len(aliases)
+
+	chunks, err := db.ListChunks(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindChunk] = len(chunks)
+
+	records, err := db.ListUsage(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindUsage] = len(records)
+
+	routes, err := db.ListRoutes(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindRoute] = len(routes)
+
+	erasures, err := db.ListErasures(store.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	counts[KindErasure] = len(erasures)
+
+	return counts, nil
+}
+
+/*
+Copy moves everything in one store into another, which is
+expected to be freshly migrated and empty, by streaming an
+export of the first straight into an import of the second.
+The destination is then counted to verify that nothing was
+lost along the way.
+*/
+func Copy(from store.Store, to store.Store, progress Progress) (Counts, error) {
+	reader, writer := io.Pipe()
+
+	exported := make(chan error, 1)
+	go func() {
+		_, err := Export(from, writer, nil)
+		writer.CloseWithError(err)
+		exported <- err
+	}()
+
+	counts, err := Import(to, reader, progress)
+	if err == nil {
+		// Whatever follows the end record is drained so the
+		// export can finish
+		_, err = io.Copy(io.Discard, reader)
+	} else {
+		// ...otherwise the export is unblocked to fail
+		reader.CloseWithError(err)
+	}
+	if exportErr := <-exported; exportErr != nil && err == nil {
+		err = exportErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = Verify(to, counts)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+/*
+Verify checks that a store holds the expected number of
+records of each kind.
+*/
+func Verify(db store.Store, expected Counts) error {
+	found, err := Count(db)
+	if err != nil {
+		return err
+	}
+
+	if diff := expected.Diff(found); diff != "" {
+		return fmt.Errorf("store does not match the expected counts (expected != found) - %s", diff)
+	}
+	return nil
+}
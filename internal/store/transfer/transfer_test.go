@@ -0,0 +1,249 @@
+package transfer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
+	"github.com/hlfshell/coppermind/internal/store/sqlite"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createStore(t *testing.T) *sqlite.SqliteStore {
+	db, err := sqlite.NewSqliteStore(":memory:")
+	require.Nil(t, err)
+	require.Nil(t, db.Migrate())
+	return db
+}
+
+func TestCopy(t *testing.T) {
+	from := createStore(t)
+
+	// The source keeps its artifacts' data in a blob store,
+	// the destination keeps it inline
+	from.SetBlobStore(blob.NewMemoryBlobStore())
+
+	require.Nil(t, from.SaveOrganization(&tenants.Organization{ID: "acme", Name: "Acme"}))
+	user := &users.User{ID: "keith", Name: "Keith", CreatedAt: time.Now(), UpdatedAt: time.Now(), Tenant: "acme"}
+	require.Nil(t, from.CreateUser(user, "supersecret"))
+	require.Nil(t, from.SaveAgent(&agents.Agent{ID: "rose", Name: "Rose", Identity: "A helpful agent", Tools: []string{"calculator"}, Tenant: "acme", Visibility: agents.VisibilityInviteOnly}))
+	require.Nil(t, from.SaveAgentMember(&agents.Member{Agent: "rose", User: "keith"}))
+	require.Nil(t, from.SaveAgentRevision(&agents.Revision{Agent: "rose", Version: 1, Identity: "A helpful agent", Author: "keith"}))
+	require.Nil(t, from.SavePromptTemplate(&agents.PromptTemplate{Agent: "rose", Name: "summary", Template: "Summarize this"}))
+	experiment := &experiments.Experiment{
+		ID:       uuid.New().String(),
+		Agent:    "rose",
+		Name:     "Shorter replies",
+		Variants: []*experiments.Variant{{Name: "control"}, {Name: "short", Prompts: map[string]string{"summary": "Be brief"}}},
+		Active:   true,
+	}
+	require.Nil(t, from.SaveExperiment(experiment))
+
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	conversation := uuid.New().String()
+	msgID := uuid.New().String()
+	require.Nil(t, from.SaveMessage(&chat.Message{
+		ID:           msgID,
+		Conversation: conversation,
+		Agent:        "rose",
+		User:         "keith",
+		From:         "keith",
+		Content:      "Here's my dog",
+		CreatedAt:    time.Now(),
+		Artifacts: []*artifacts.ArtifactData{
+			{
+				ID:        uuid.New().String(),
+				Message:   msgID,
+				Type:      artifacts.TypeImage,
+				Filename:  "cooper.png",
+				CreatedAt: time.Now(),
+				Data:      png,
+			},
+		},
+	}))
+	replyID := uuid.New().String()
+	require.Nil(t, from.SaveMessage(&chat.Message{
+		ID:           replyID,
+		Conversation: conversation,
+		Agent:        "rose",
+		User:         "keith",
+		From:         "rose",
+		Content:      "Cute!",
+		CreatedAt:    time.Now(),
+		Experiment:   experiment.ID,
+		Variant:      "short",
+	}))
+	require.Nil(t, from.SaveFeedback(&chat.Feedback{Message: replyID, User: "keith", Rating: chat.RatingUp}))
+	require.Nil(t, from.SaveSummary(&memory.Summary{
+		ID:                    uuid.New().String(),
+		Agent:                 "rose",
+		User:                  "keith",
+		Conversation:          conversation,
+		Keywords:              []string{"dog"},
+		Summary:               "Keith shared a picture of his dog",
+		ConversationStartedAt: time.Now(),
+	}))
+	require.Nil(t, from.ExcludeConversationFromSummary(uuid.New().String()))
+
+	old := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "rose",
+		User:      "keith",
+		Subject:   "Keith",
+		Predicate: "has dog",
+		Object:    "Rex",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	current := &memory.Knowledge{
+		ID:           uuid.New().String(),
+		Agent:        "rose",
+		User:         "keith",
+		Subject:      "Keith",
+		Predicate:    "has dog",
+		Object:       "Cooper",
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Conversation: conversation,
+		Messages:     []string{msgID},
+		Confidence:   0.9,
+		Pinned:       true,
+	}
+	old.Supersede(current)
+	require.Nil(t, from.SaveKnowledge(old))
+	require.Nil(t, from.SaveKnowledge(current))
+	require.Nil(t, from.SaveAlias(&memory.Alias{Agent: "rose", User: "keith", Alias: "the pup", Entity: "Cooper"}))
+	require.Nil(t, from.SaveUsage(&usage.Usage{
+		ID:           uuid.New().String(),
+		Agent:        "rose",
+		User:         "keith",
+		Conversation: conversation,
+		Type:         usage.CallChat,
+		Model:        "gpt-4",
+		PromptTokens: 10,
+		CreatedAt:    time.Now(),
+	}))
+	require.Nil(t, from.SaveErasure(&users.Erasure{
+		ID:        uuid.New().String(),
+		User:      "abby",
+		Counts:    map[string]int{users.DataMessages: 3},
+		CreatedAt: time.Now(),
+	}))
+
+	expected, err := Count(from)
+	require.Nil(t, err)
+	assert.Equal(t, 1, expected[KindUser])
+	assert.Equal(t, 2, expected[KindMessage])
+	assert.Equal(t, 1, expected[KindArtifact])
+	assert.Equal(t, 2, expected[KindKnowledge])
+	assert.Equal(t, 1, expected[KindMember])
+	assert.Equal(t, 1, expected[KindRevision])
+	assert.Equal(t, 1, expected[KindPrompt])
+	assert.Equal(t, 1, expected[KindExperiment])
+	assert.Equal(t, 1, expected[KindFeedback])
+
+	to := createStore(t)
+	reported := map[string]int{}
+	counts, err := Copy(from, to, func(kind string, count int) {
+		reported[kind] = count
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "", expected.Diff(counts))
+	assert.Equal(t, 2, reported[KindMessage])
+	assert.Equal(t, 1, reported[KindErasure])
+
+	// Users keep their passwords
+	auth, err := to.GetUserAuth("keith")
+	require.Nil(t, err)
+	require.NotNil(t, auth)
+	assert.True(t, auth.CheckPassword("supersecret"))
+
+	agent, err := to.GetAgent("rose")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"calculator"}, agent.Tools)
+	assert.Equal(t, agents.VisibilityInviteOnly, agent.Visibility)
+
+	// Records keep their organization
+	org, err := to.GetOrganization("acme")
+	require.Nil(t, err)
+	require.NotNil(t, org)
+	assert.Equal(t, "acme", agent.Tenant)
+	messages, err := to.ListMessages(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "tenant", Operation: store.EQ, Value: "acme"},
+		},
+	})
+	require.Nil(t, err)
+	assert.Len(t, messages, 2)
+
+	// Artifacts keep their data, now inline
+	copied, err := to.GetMessage(msgID)
+	require.Nil(t, err)
+	require.Len(t, copied.Artifacts, 1)
+	artifact, err := to.GetArtifact(copied.Artifacts[0].ID)
+	require.Nil(t, err)
+	assert.Equal(t, png, artifact.Data)
+	assert.Empty(t, artifact.Blob)
+
+	// Knowledge keeps its history and provenance
+	fact, err := to.GetKnowledge(old.ID)
+	require.Nil(t, err)
+	assert.True(t, old.Equal(fact))
+	fact, err = to.GetKnowledge(current.ID)
+	require.Nil(t, err)
+	assert.True(t, current.Equal(fact))
+
+	// A non-empty destination fails rather than duplicating
+	_, err = Copy(from, to, nil)
+	assert.NotNil(t, err)
+}
+
+func TestImportRejectsBadExports(t *testing.T) {
+	from := createStore(t)
+	for _, name := range []string{"Keith", "Abby"} {
+		require.Nil(t, from.CreateUser(&users.User{ID: name, Name: name, CreatedAt: time.Now()}, "supersecret"))
+	}
+
+	var exported bytes.Buffer
+	counts, err := Export(from, &exported, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, counts[KindUser])
+
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[0], `"version":1`)
+
+	// A truncated export is caught
+	_, err = Import(createStore(t), strings.NewReader(strings.Join(lines[:3], "\n")), nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "ended early")
+
+	// ...as is one missing records
+	_, err = Import(createStore(t), strings.NewReader(strings.Join([]string{lines[0], lines[1], lines[3]}, "\n")), nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "user: 2 != 1")
+
+	// ...or of another version
+	_, err = Import(createStore(t), strings.NewReader(strings.Replace(exported.String(), `"version":1`, `"version":2`, 1)), nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unsupported export version")
+
+	to := createStore(t)
+	counts, err = Import(to, &exported, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, counts[KindUser])
+	require.Nil(t, Verify(to, counts))
+}
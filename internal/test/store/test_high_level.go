@@ -6,8 +6,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/hlfshell/coppermind/pkg/users"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -299,6 +306,67 @@ func ExcludeConversationFromSummary(t *testing.T, store store.Store) {
 	assert.Equal(t, conversation, conversations[0])
 }
 
+func IncrementAndGetQuota(t *testing.T, store store.Store) {
+	key := quota.Key(quota.ScopeUser, "Keith")
+	periodStart := quota.PeriodStart(quota.PeriodMinute, time.Now())
+
+	// A counter that does not exist yet is zeroed
+	counter, err := store.GetQuota(key, quota.PeriodMinute, periodStart)
+	require.Nil(t, err)
+	require.NotNil(t, counter)
+	assert.Equal(t, 0, counter.Requests)
+	assert.Equal(t, 0, counter.Tokens)
+
+	// Incrementing creates the counter, and further increments
+	// are added to it
+	counter, err = store.IncrementQuota(key, quota.PeriodMinute, periodStart, 1, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 1, counter.Requests)
+	assert.Equal(t, 0, counter.Tokens)
+
+	counter, err = store.IncrementQuota(key, quota.PeriodMinute, periodStart, 1, 150)
+	require.Nil(t, err)
+	assert.Equal(t, 2, counter.Requests)
+	assert.Equal(t, 150, counter.Tokens)
+
+	// Negative increments roll back
+	counter, err = store.IncrementQuota(key, quota.PeriodMinute, periodStart, -1, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 1, counter.Requests)
+	assert.Equal(t, 150, counter.Tokens)
+
+	counter, err = store.GetQuota(key, quota.PeriodMinute, periodStart)
+	require.Nil(t, err)
+	assert.Equal(t, 1, counter.Requests)
+	assert.Equal(t, 150, counter.Tokens)
+
+	// Other periods and scopes are counted separately
+	dayStart := quota.PeriodStart(quota.PeriodDay, time.Now())
+	counter, err = store.GetQuota(key, quota.PeriodDay, dayStart)
+	require.Nil(t, err)
+	assert.Equal(t, 0, counter.Requests)
+
+	counter, err = store.GetQuota(quota.Key(quota.ScopeGlobal, ""), quota.PeriodMinute, periodStart)
+	require.Nil(t, err)
+	assert.Equal(t, 0, counter.Requests)
+
+	// Expiring counters removes those from old periods only
+	oldStart := quota.PeriodStart(quota.PeriodMinute, time.Now().Add(-3*time.Hour))
+	_, err = store.IncrementQuota(key, quota.PeriodMinute, oldStart, 4, 0)
+	require.Nil(t, err)
+
+	err = store.ExpireQuotas(time.Now().Add(-1 * time.Hour))
+	require.Nil(t, err)
+
+	counter, err = store.GetQuota(key, quota.PeriodMinute, oldStart)
+	require.Nil(t, err)
+	assert.Equal(t, 0, counter.Requests)
+
+	counter, err = store.GetQuota(key, quota.PeriodMinute, periodStart)
+	require.Nil(t, err)
+	assert.Equal(t, 1, counter.Requests)
+}
+
 // func ExpireKnowledge(t *testing.T, store store.Store) {
 // 	// Create three knowledge entries. Ensure they can be read back.
 // 	// Then ensure that we can remove them by age, leaving the non-
@@ -448,3 +516,271 @@ func ExcludeConversationFromSummary(t *testing.T, store store.Store) {
 // 	require.Equal(t, 1, len(conversations))
 // 	assert.Contains(t, conversations, msg1.Conversation)
 // }
+
+func GetKnowledgeGraph(t *testing.T, store store.Store) {
+	learn := func(user string, subject string, predicate string, object string, expiresIn time.Duration) *memory.Knowledge {
+		fact := &memory.Knowledge{
+			ID:        uuid.New().String(),
+			Agent:     "Rose",
+			User:      user,
+			Subject:   subject,
+			Predicate: predicate,
+			Object:    object,
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(expiresIn),
+		}
+		require.Nil(t, store.SaveKnowledge(fact))
+		return fact
+	}
+
+	employer := learn("Keith", "Keith", "works at", "Acme", time.Hour)
+	sibling := learn("Keith", "keith", "has sibling", "Kevin", time.Hour)
+	home := learn("Keith", "My brother", "lives in", "Boston", time.Hour)
+	learn("Keith", "Keith", "lived in", "Denver", -time.Hour)
+	learn("Abby", "Abby", "works at", "Acme", time.Hour)
+
+	oldEmployer := learn("Keith", "Keith", "works at", "Globex", time.Hour)
+	oldEmployer.Supersede(employer)
+	require.Nil(t, store.CompressKnowledge("Rose", "Keith", []*memory.Knowledge{oldEmployer}))
+
+	// Aliases are normalized, and saving one again repoints it
+	require.Nil(t, store.SaveAlias(&memory.Alias{Agent: "Rose", User: "Keith", Alias: "My Brother", Entity: "Keith"}))
+	require.Nil(t, store.SaveAlias(&memory.Alias{Agent: "Rose", User: "Keith", Alias: "my brother", Entity: "Kevin "}))
+	require.Nil(t, store.SaveAlias(&memory.Alias{Agent: "Rose", User: "Keith", Alias: "Bro", Entity: "Kevin"}))
+	require.Nil(t, store.SaveAlias(&memory.Alias{Agent: "Rose", User: "Abby", Alias: "Keith", Entity: "Abby"}))
+	require.Nil(t, store.DeleteAlias("Rose", "Keith", "BRO"))
+
+	// Expired, superseded, and other users' facts are left out
+	graph, err := store.GetKnowledgeGraph("Rose", "Keith")
+	require.Nil(t, err)
+	require.Len(t, graph.Facts(), 3)
+	assert.True(t, employer.Equal(graph.Facts()[0]))
+	assert.True(t, sibling.Equal(graph.Facts()[1]))
+	assert.True(t, home.Equal(graph.Facts()[2]))
+
+	assert.Equal(t, []string{"acme", "boston", "keith", "kevin"}, graph.Entities())
+	assert.Equal(t, "kevin", graph.Resolve("My Brother"))
+	assert.Equal(t, "bro", graph.Resolve("bro"))
+	assert.Len(t, graph.Path("Acme", "Boston", 0), 3)
+
+	// An unknown pair has an empty graph
+	graph, err = store.GetKnowledgeGraph("Rose", "Nobody")
+	require.Nil(t, err)
+	assert.Empty(t, graph.Facts())
+}
+
+func EraseUser(t *testing.T, s store.Store, blobs *blob.MemoryBlobStore) {
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	jpeg := []byte("\xFF\xD8\xFF\xE0")
+
+	// Keith and Abby both talk with Rose, and happen to share
+	// the same image
+	keep := func(user string, data []byte) (string, *chat.Message) {
+		conversation := uuid.New().String()
+		id := uuid.New().String()
+		msg := &chat.Message{
+			ID:           id,
+			Conversation: conversation,
+			Agent:        "Rose",
+			User:         user,
+			From:         user,
+			Content:      "Look at this",
+			CreatedAt:    time.Now(),
+			Artifacts: []*artifacts.ArtifactData{
+				{
+					ID:        uuid.New().String(),
+					Message:   id,
+					Type:      artifacts.TypeImage,
+					CreatedAt: time.Now(),
+					Data:      data,
+				},
+			},
+		}
+		require.Nil(t, s.SaveMessage(msg))
+		require.Nil(t, s.ExcludeConversationFromSummary(conversation))
+		require.Nil(t, s.SaveSummary(&memory.Summary{
+			ID:                    uuid.New().String(),
+			Agent:                 "Rose",
+			User:                  user,
+			Conversation:          conversation,
+			Summary:               "They shared an image",
+			UpdatedAt:             time.Now(),
+			ConversationStartedAt: msg.CreatedAt,
+		}))
+		require.Nil(t, s.SaveKnowledge(&memory.Knowledge{
+			ID:           uuid.New().String(),
+			Agent:        "Rose",
+			User:         user,
+			Subject:      user,
+			Predicate:    "likes",
+			Object:       "photography",
+			CreatedAt:    time.Now(),
+			ExpiresAt:    time.Now().Add(time.Hour),
+			Conversation: conversation,
+		}))
+		require.Nil(t, s.SaveAlias(&memory.Alias{Agent: "Rose", User: user, Alias: "me", Entity: user}))
+		require.Nil(t, s.SaveChunks([]*documents.Chunk{
+			{
+				ID:           uuid.New().String(),
+				Document:     msg.Artifacts[0].ID,
+				Agent:        "Rose",
+				User:         user,
+				Conversation: conversation,
+				Content:      "An image",
+				Model:        "embedder",
+				Embedding:    []float32{1, 0},
+				CreatedAt:    time.Now(),
+			},
+		}))
+		require.Nil(t, s.SaveUsage(&usage.Usage{
+			ID:           uuid.New().String(),
+			Agent:        "Rose",
+			User:         user,
+			Conversation: conversation,
+			Type:         usage.CallChat,
+			Model:        "model",
+			CreatedAt:    time.Now(),
+		}))
+		require.Nil(t, s.SaveRoute(&usage.Route{
+			ID:           uuid.New().String(),
+			Agent:        "Rose",
+			User:         user,
+			Conversation: conversation,
+			Type:         usage.CallChat,
+			Backend:      "backend",
+			Success:      true,
+			CreatedAt:    time.Now(),
+		}))
+		_, err := s.IncrementQuota(quota.Key(quota.ScopeUser, user), quota.PeriodDay, quota.PeriodStart(quota.PeriodDay, time.Now()), 1, 10)
+		require.Nil(t, err)
+		return conversation, msg
+	}
+
+	for _, user := range []string{"Keith", "Abby"} {
+		require.Nil(t, s.CreateUser(&users.User{ID: user, Name: user, CreatedAt: time.Now(), UpdatedAt: time.Now()}, "password"))
+		require.Nil(t, s.SaveAgentMember(&agents.Member{Agent: "Rose", User: user}))
+	}
+	_, keith := keep("Keith", png)
+	require.Nil(t, s.SaveFeedback(&chat.Feedback{Message: keith.ID, User: "Keith", Rating: chat.RatingUp}))
+	abby, _ := keep("Abby", png)
+	reply := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: abby,
+		Agent:        "Rose",
+		User:         "Abby",
+		From:         "Rose",
+		Content:      "Nice!",
+		CreatedAt:    time.Now(),
+	}
+	require.Nil(t, s.SaveMessage(reply))
+	require.Nil(t, s.SaveFeedback(&chat.Feedback{Message: reply.ID, User: "Abby", Rating: chat.RatingUp}))
+	_, private := keep("Abby", jpeg)
+	require.Equal(t, 2, blobs.Len())
+
+	// Abby wrote Rose's first identity, and Keith her second
+	for version, author := range []string{"Abby", "Keith"} {
+		require.Nil(t, s.SaveAgentRevision(&agents.Revision{
+			Agent:     "Rose",
+			Version:   version + 1,
+			Identity:  "Rose, as " + author + " wrote her",
+			Author:    author,
+			CreatedAt: time.Now(),
+		}))
+	}
+
+	expected := map[string]int{
+		users.DataProfile:             1,
+		users.DataMessages:            3,
+		users.DataArtifacts:           2,
+		users.DataSummaries:           2,
+		users.DataSummaryExclusions:   2,
+		users.DataKnowledge:           2,
+		users.DataKnowledgeExtraction: 0,
+		users.DataAliases:             1,
+		users.DataDocumentChunks:      2,
+		users.DataUsage:               2,
+		users.DataRoutes:              2,
+		users.DataQuotas:              1,
+		users.DataMemberships:         1,
+		users.DataFeedback:            1,
+		users.DataAgentRevisions:      1,
+	}
+
+	// Counting changes nothing
+	counts, err := s.CountUserData("Abby")
+	require.Nil(t, err)
+	assert.Equal(t, expected, counts)
+
+	counts, err = s.CountUserData("Abby")
+	require.Nil(t, err)
+	assert.Equal(t, expected, counts)
+
+	counts, err = s.EraseUser("Abby")
+	require.Nil(t, err)
+	assert.Equal(t, expected, counts)
+
+	// Nothing of Abby's is left...
+	counts, err = s.CountUserData("Abby")
+	require.Nil(t, err)
+	for kind, count := range counts {
+		assert.Zero(t, count, kind)
+	}
+
+	user, err := s.GetUser("Abby")
+	require.Nil(t, err)
+	assert.Nil(t, user)
+	artifact, err := s.GetArtifact(private.Artifacts[0].ID)
+	require.Nil(t, err)
+	assert.Nil(t, artifact)
+
+	// ...though the revision she wrote is still Rose's, only
+	// no longer hers
+	revision, err := s.GetAgentRevision("Rose", 1)
+	require.Nil(t, err)
+	require.NotNil(t, revision)
+	assert.Equal(t, "", revision.Author)
+	assert.Equal(t, "Rose, as Abby wrote her", revision.Identity)
+
+	// ...but Keith's data is untouched, including the image
+	// he shared with Abby
+	counts, err = s.CountUserData("Keith")
+	require.Nil(t, err)
+	assert.Equal(t, 1, counts[users.DataMessages])
+	assert.Equal(t, 1, counts[users.DataKnowledge])
+	assert.Equal(t, 1, counts[users.DataProfile])
+	assert.Equal(t, 1, counts[users.DataMemberships])
+	assert.Equal(t, 1, counts[users.DataFeedback])
+	assert.Equal(t, 1, counts[users.DataAgentRevisions])
+	assert.Equal(t, 1, blobs.Len())
+	data, err := blobs.Get(blob.Key(png))
+	require.Nil(t, err)
+	assert.Equal(t, png, data)
+
+	// Erasing again finds nothing
+	counts, err = s.EraseUser("Abby")
+	require.Nil(t, err)
+	assert.Zero(t, counts[users.DataMessages])
+
+	// The audit record keeps only who erased whom, and the
+	// counts
+	erasure := &users.Erasure{
+		ID:        uuid.New().String(),
+		User:      "Abby",
+		Actor:     "Keith",
+		Counts:    expected,
+		CreatedAt: time.Now(),
+	}
+	require.Nil(t, s.SaveErasure(erasure))
+
+	erasures, err := s.ListErasures(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "user", Value: "Abby", Operation: store.EQ},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, erasures, 1)
+	assert.Equal(t, erasure.ID, erasures[0].ID)
+	assert.Equal(t, "Keith", erasures[0].Actor)
+	assert.Equal(t, expected, erasures[0].Counts)
+	assert.Equal(t, 23, erasures[0].Total())
+}
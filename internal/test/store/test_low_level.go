@@ -7,10 +7,16 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/internal/store/blob"
 	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/hlfshell/coppermind/pkg/experiments"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/usage"
 	"github.com/hlfshell/coppermind/pkg/users"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,14 +38,15 @@ func SaveAndGetMessage(t *testing.T, store store.LowLevelStore) {
 			{
 				ID:        uuid.New().String(),
 				Message:   id,
-				Type:      "image",
+				Type:      artifacts.TypeLink,
 				CreatedAt: time.Now(),
-				Data:      json.RawMessage(`{"url": "https://www.picturesofdogs.com/img1"}`),
+				Data:      []byte("https://www.picturesofdogs.com/img1"),
 			},
 			{
 				ID:        uuid.New().String(),
 				Message:   id,
-				Type:      "voice",
+				Type:      artifacts.TypeAudio,
+				MimeType:  "audio/wav",
 				CreatedAt: time.Now(),
 				Data:      []byte{0, 1, 2},
 			},
@@ -60,6 +67,208 @@ func SaveAndGetMessage(t *testing.T, store store.LowLevelStore) {
 	assert.True(t, message.Equal(msg))
 }
 
+func SaveAndGetArtifact(t *testing.T, store store.LowLevelStore) {
+	id := uuid.New().String()
+	message := &chat.Message{
+		ID:           id,
+		User:         "Keith",
+		Agent:        "Rose",
+		From:         "Keith",
+		Content:      "Here's my itinerary",
+		Conversation: uuid.New().String(),
+		CreatedAt:    time.Now(),
+		Artifacts: []*artifacts.ArtifactData{
+			{
+				ID:        uuid.New().String(),
+				Message:   id,
+				Type:      artifacts.TypeText,
+				Filename:  "itinerary.md",
+				MimeType:  "text/markdown",
+				Metadata:  map[string]string{"source": "upload"},
+				CreatedAt: time.Now(),
+				Data:      []byte("# Day 1\nHike to the falls"),
+			},
+		},
+	}
+
+	err := store.SaveMessage(message)
+	require.Nil(t, err)
+
+	// Size is filled in on save
+	assert.Equal(t, int64(25), message.Artifacts[0].Size)
+
+	artifact, err := store.GetArtifact(message.Artifacts[0].ID)
+	require.Nil(t, err)
+	require.NotNil(t, artifact)
+	assert.True(t, message.Artifacts[0].Equal(artifact))
+
+	decoded, err := artifact.Decode()
+	require.Nil(t, err)
+	text, ok := decoded.(*artifacts.Text)
+	require.True(t, ok)
+	assert.Equal(t, "# Day 1\nHike to the falls", text.Text)
+	assert.Equal(t, "itinerary.md", text.Filename)
+
+	// Artifacts can be added to an existing message
+	image := &artifacts.ArtifactData{
+		ID:        uuid.New().String(),
+		Message:   id,
+		Type:      artifacts.TypeImage,
+		CreatedAt: time.Now(),
+		Data:      []byte("\x89PNG\x0D\x0A\x1A\x0A"),
+	}
+	err = store.SaveArtifact(image)
+	require.Nil(t, err)
+	assert.Equal(t, "image/png", image.MimeType)
+
+	msg, err := store.GetMessage(id)
+	require.Nil(t, err)
+	require.NotNil(t, msg)
+	assert.Len(t, msg.Artifacts, 2)
+
+	artifact, err = store.GetArtifact(uuid.New().String())
+	require.Nil(t, err)
+	assert.Nil(t, artifact)
+
+	// Invalid artifacts are rejected, and the message they
+	// are on is not saved
+	invalid := &chat.Message{
+		ID:           uuid.New().String(),
+		User:         "Keith",
+		Agent:        "Rose",
+		From:         "Keith",
+		Conversation: message.Conversation,
+		CreatedAt:    time.Now(),
+		Artifacts: []*artifacts.ArtifactData{
+			{
+				ID:        uuid.New().String(),
+				Type:      artifacts.TypeJSON,
+				CreatedAt: time.Now(),
+				Data:      []byte(`{"unclosed": `),
+			},
+		},
+	}
+	err = store.SaveMessage(invalid)
+	assert.NotNil(t, err)
+
+	msg, err = store.GetMessage(invalid.ID)
+	require.Nil(t, err)
+	assert.Nil(t, msg)
+
+	err = store.SaveArtifact(&artifacts.ArtifactData{
+		ID:      uuid.New().String(),
+		Message: id,
+		Type:    "hologram",
+		Data:    []byte{1},
+	})
+	assert.NotNil(t, err)
+}
+
+func SaveResponseWithArtifacts(t *testing.T, store store.LowLevelStore) {
+	content := "Here's the [trail map](https://coppermind.dev/trails) and a photo"
+	response := &chat.Response{
+		Content: content,
+		Artifacts: append(
+			artifacts.ExtractLinks(content),
+			&artifacts.ArtifactData{
+				Type:      artifacts.TypeImage,
+				Filename:  "falls.png",
+				CreatedAt: time.Now(),
+				Data:      []byte("\x89PNG\x0D\x0A\x1A\x0A"),
+			},
+		),
+	}
+
+	// The artifacts are carried over to the message
+	message := response.ToMessage("Keith", "Rose", uuid.New().String())
+	require.Len(t, message.Artifacts, 2)
+	for _, artifact := range message.Artifacts {
+		assert.NotEmpty(t, artifact.ID)
+		assert.Equal(t, message.ID, artifact.Message)
+	}
+
+	err := store.SaveMessage(message)
+	require.Nil(t, err)
+
+	// ...and are read back with it, without their data
+	msg, err := store.GetMessage(message.ID)
+	require.Nil(t, err)
+	require.NotNil(t, msg)
+	require.Len(t, msg.Artifacts, 2)
+	for _, artifact := range msg.Artifacts {
+		assert.Nil(t, artifact.Data)
+	}
+
+	conversation, err := store.GetConversation(message.Conversation)
+	require.Nil(t, err)
+	require.NotNil(t, conversation)
+	require.Len(t, conversation.Messages, 1)
+	assert.True(t, message.Equal(conversation.Messages[0]))
+
+	// The data itself is loaded with the artifact
+	for _, artifact := range message.Artifacts {
+		stored, err := store.GetArtifact(artifact.ID)
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.True(t, artifact.Equal(stored))
+	}
+
+	link, err := store.GetArtifact(message.Artifacts[0].ID)
+	require.Nil(t, err)
+	decoded, err := link.Decode()
+	require.Nil(t, err)
+	assert.Equal(t, "trail map", decoded.(*artifacts.Link).Title)
+	assert.Equal(t, "https://coppermind.dev/trails", decoded.(*artifacts.Link).URL)
+}
+
+func SaveMessageWithToolCalls(t *testing.T, store store.LowLevelStore) {
+	message := &chat.Message{
+		ID:           uuid.New().String(),
+		User:         "Keith",
+		Agent:        "Rose",
+		From:         "Rose",
+		Content:      "calculator({\"expression\": \"2 + 2\"}) -> 4",
+		Conversation: uuid.New().String(),
+		ToolCalls: []*chat.ToolCall{
+			{
+				ID:        "call_1",
+				Name:      "calculator",
+				Arguments: json.RawMessage(`{"expression": "2 + 2"}`),
+				Result:    "4",
+			},
+			{
+				ID:        "call_2",
+				Name:      "current_time",
+				Arguments: json.RawMessage(`{"timezone": "Mars/Olympus"}`),
+				Error:     "unknown timezone Mars/Olympus",
+			},
+		},
+		CreatedAt: time.Now(),
+	}
+
+	err := store.SaveMessage(message)
+	require.Nil(t, err)
+
+	msg, err := store.GetMessage(message.ID)
+	require.Nil(t, err)
+	require.NotNil(t, msg)
+	assert.True(t, message.Equal(msg))
+	require.Len(t, msg.ToolCalls, 2)
+	assert.Equal(t, "4", msg.ToolCalls[0].Result)
+	assert.Equal(t, "unknown timezone Mars/Olympus", msg.ToolCalls[1].Error)
+
+	// Messages without tool calls read back without any
+	message.ID = uuid.New().String()
+	message.ToolCalls = nil
+	err = store.SaveMessage(message)
+	require.Nil(t, err)
+
+	msg, err = store.GetMessage(message.ID)
+	require.Nil(t, err)
+	require.NotNil(t, msg)
+	assert.Empty(t, msg.ToolCalls)
+}
+
 func DeleteMessage(t *testing.T, store store.LowLevelStore) {
 	id := uuid.New().String()
 	message := &chat.Message{
@@ -73,14 +282,15 @@ func DeleteMessage(t *testing.T, store store.LowLevelStore) {
 			{
 				ID:        uuid.New().String(),
 				Message:   id,
-				Type:      "image",
+				Type:      artifacts.TypeLink,
 				CreatedAt: time.Now(),
-				Data:      json.RawMessage(`{"url": "https://www.picturesofdogs.com/img1"}`),
+				Data:      []byte("https://www.picturesofdogs.com/img1"),
 			},
 			{
 				ID:        uuid.New().String(),
 				Message:   id,
-				Type:      "voice",
+				Type:      artifacts.TypeAudio,
+				MimeType:  "audio/wav",
 				CreatedAt: time.Now(),
 				Data:      []byte{0, 1, 2},
 			},
@@ -123,9 +333,9 @@ func ListMessages(t *testing.T, s store.LowLevelStore) {
 			{
 				ID:        uuid.New().String(),
 				Message:   msg1Id,
-				Type:      "image",
+				Type:      artifacts.TypeLink,
 				CreatedAt: time.Now(),
-				Data:      json.RawMessage(`{"url": "https://www.picturesofdogs.com/img1"}`),
+				Data:      []byte("https://www.picturesofdogs.com/img1"),
 			},
 		},
 	}
@@ -151,7 +361,8 @@ func ListMessages(t *testing.T, s store.LowLevelStore) {
 			{
 				ID:        uuid.New().String(),
 				Message:   msg3Id,
-				Type:      "voice",
+				Type:      artifacts.TypeAudio,
+				MimeType:  "audio/wav",
 				CreatedAt: time.Now(),
 				Data:      []byte{0, 1, 2},
 			},
@@ -485,6 +696,279 @@ func ListSummaries(t *testing.T, db store.LowLevelStore) {
 	assert.True(t, summary1.Equal(summaries[0]))
 }
 
+// ===============================
+// Knowledge
+// ===============================
+
+func SaveAndListKnowledge(t *testing.T, db store.LowLevelStore) {
+	knowledge, err := db.ListKnowledge(store.Filter{})
+	require.Nil(t, err)
+	assert.Len(t, knowledge, 0)
+
+	fact1 := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   "Keith",
+		Predicate: "likes",
+		Object:    "hiking",
+		CreatedAt: time.Now().Add(-2 * time.Minute),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}
+	fact2 := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   "Keith's dog",
+		Predicate: "is named",
+		Object:    "Cooper",
+		CreatedAt: time.Now().Add(-1 * time.Minute),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}
+	fact3 := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Abby",
+		Subject:   "Abby",
+		Predicate: "is learning",
+		Object:    "the cello",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}
+	for _, fact := range []*memory.Knowledge{fact1, fact2, fact3} {
+		err = db.SaveKnowledge(fact)
+		require.Nil(t, err)
+	}
+
+	readFact, err := db.GetKnowledge(fact1.ID)
+	require.Nil(t, err)
+	require.NotNil(t, readFact)
+	assert.True(t, fact1.Equal(readFact))
+
+	readFact, err = db.GetKnowledge(uuid.New().String())
+	require.Nil(t, err)
+	assert.Nil(t, readFact)
+
+	knowledge, err = db.ListKnowledge(store.Filter{})
+	require.Nil(t, err)
+	assert.Len(t, knowledge, 3)
+
+	// Filtered knowledge is returned oldest first
+	knowledge, err = db.ListKnowledge(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     "Rose",
+				Operation: store.EQ,
+			},
+			{
+				Attribute: "user",
+				Value:     "Keith",
+				Operation: store.EQ,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, knowledge, 2)
+	assert.True(t, fact1.Equal(knowledge[0]))
+	assert.True(t, fact2.Equal(knowledge[1]))
+}
+
+func CompressKnowledge(t *testing.T, db store.LowLevelStore) {
+	employer := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   "Keith",
+		Predicate: "works at",
+		Object:    "Acme",
+		CreatedAt: time.Now().Add(-time.Hour),
+		ExpiresAt: time.Now().Add(24 * time.Hour),
+	}
+	newEmployer := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   "Keith",
+		Predicate: "works at",
+		Object:    "Initech",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	otherUser := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Abby",
+		Subject:   "Abby",
+		Predicate: "works at",
+		Object:    "Acme",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	for _, fact := range []*memory.Knowledge{employer, newEmployer, otherUser} {
+		require.Nil(t, db.SaveKnowledge(fact))
+	}
+
+	// The old employer is replaced, and the new one is kept
+	// for as long as the old one would have been
+	employer.Supersede(newEmployer)
+	newEmployer.ExpiresAt = employer.ExpiresAt
+
+	// Facts belonging to another user are left untouched
+	otherUser.Supersede(newEmployer)
+
+	err := db.CompressKnowledge("Rose", "Keith", []*memory.Knowledge{employer, newEmployer, otherUser})
+	require.Nil(t, err)
+
+	knowledge, err := db.ListKnowledge(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "user",
+				Value:     "Keith",
+				Operation: store.EQ,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, knowledge, 2)
+	assert.True(t, employer.Equal(knowledge[0]))
+	assert.True(t, newEmployer.Equal(knowledge[1]))
+
+	// Superseded facts are kept as history, but can be left
+	// out of what is currently known
+	current, err := db.ListKnowledge(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "user",
+				Value:     "Keith",
+				Operation: store.EQ,
+			},
+			{
+				Attribute: "superseded_by",
+				Value:     "",
+				Operation: store.EQ,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, current, 1)
+	assert.Equal(t, newEmployer.ID, current[0].ID)
+
+	readFact, err := db.GetKnowledge(otherUser.ID)
+	require.Nil(t, err)
+	assert.False(t, readFact.IsSuperseded())
+}
+
+func KnowledgeProvenance(t *testing.T, db store.LowLevelStore) {
+	conversation := uuid.New().String()
+	learn := func(conversation string, object string, messages []string) *memory.Knowledge {
+		fact := &memory.Knowledge{
+			ID:           uuid.New().String(),
+			Agent:        "Rose",
+			User:         "Keith",
+			Subject:      "Keith",
+			Predicate:    "works at",
+			Object:       object,
+			CreatedAt:    time.Now(),
+			ExpiresAt:    time.Now().Add(time.Hour),
+			Conversation: conversation,
+			Messages:     messages,
+			Confidence:   0.75,
+		}
+		require.Nil(t, db.SaveKnowledge(fact))
+		return fact
+	}
+
+	employer := learn(uuid.New().String(), "Acme", []string{uuid.New().String()})
+	newEmployer := learn(conversation, "Initech", []string{uuid.New().String(), uuid.New().String()})
+	legacy := learn("", "Globex", nil)
+
+	// Provenance is stored with the fact
+	readFact, err := db.GetKnowledge(newEmployer.ID)
+	require.Nil(t, err)
+	assert.True(t, newEmployer.Equal(readFact))
+	assert.Equal(t, conversation, readFact.Conversation)
+	assert.Equal(t, newEmployer.Messages, readFact.Messages)
+	assert.InDelta(t, 0.75, readFact.Confidence, 0.0001)
+
+	readFact, err = db.GetKnowledge(legacy.ID)
+	require.Nil(t, err)
+	assert.Empty(t, readFact.Messages)
+
+	employer.Supersede(newEmployer)
+	require.Nil(t, db.CompressKnowledge("Rose", "Keith", []*memory.Knowledge{employer}))
+
+	// Deleting the conversation's knowledge restores the
+	// fact it had superseded
+	require.Nil(t, db.DeleteConversationKnowledge(conversation))
+
+	readFact, err = db.GetKnowledge(newEmployer.ID)
+	require.Nil(t, err)
+	assert.Nil(t, readFact)
+
+	readFact, err = db.GetKnowledge(employer.ID)
+	require.Nil(t, err)
+	assert.False(t, readFact.IsSuperseded())
+	assert.True(t, readFact.SupersededAt.IsZero())
+
+	// Facts without a conversation are never deleted together
+	require.Nil(t, db.DeleteConversationKnowledge(""))
+
+	readFact, err = db.GetKnowledge(legacy.ID)
+	require.Nil(t, err)
+	assert.NotNil(t, readFact)
+}
+
+func UpdateAndDeleteKnowledge(t *testing.T, db store.LowLevelStore) {
+	employer := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   "Keith",
+		Predicate: "works at",
+		Object:    "Acme",
+		CreatedAt: time.Now().Add(-time.Hour),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	newEmployer := &memory.Knowledge{
+		ID:        uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   "Keith",
+		Predicate: "works at",
+		Object:    "Initeck",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.Nil(t, db.SaveKnowledge(employer))
+	require.Nil(t, db.SaveKnowledge(newEmployer))
+
+	employer.Supersede(newEmployer)
+	require.Nil(t, db.CompressKnowledge("Rose", "Keith", []*memory.Knowledge{employer}))
+
+	// Corrections and pins are saved
+	newEmployer.Object = "Initech"
+	newEmployer.Pinned = true
+	newEmployer.ExpiresAt = time.Now().Add(-time.Minute)
+	require.Nil(t, db.UpdateKnowledge(newEmployer))
+
+	readFact, err := db.GetKnowledge(newEmployer.ID)
+	require.Nil(t, err)
+	assert.True(t, newEmployer.Equal(readFact))
+	assert.False(t, readFact.IsExpired())
+
+	// Deleting a fact restores the fact it superseded
+	require.Nil(t, db.DeleteKnowledge(newEmployer.ID))
+
+	readFact, err = db.GetKnowledge(newEmployer.ID)
+	require.Nil(t, err)
+	assert.Nil(t, readFact)
+
+	readFact, err = db.GetKnowledge(employer.ID)
+	require.Nil(t, err)
+	assert.False(t, readFact.IsSuperseded())
+}
+
 // ===============================
 // Agents
 // ===============================
@@ -509,6 +993,77 @@ func SaveAndGetAgent(t *testing.T, store store.LowLevelStore) {
 	assert.Equal(t, agent, readAgent)
 }
 
+func SaveAgentLLMSettings(t *testing.T, store store.LowLevelStore) {
+	agent := &agents.Agent{
+		ID:       uuid.New().String(),
+		Name:     "Hal",
+		Identity: "Super helpful, nothing but",
+		LLM: agents.LLMSettings{
+			Chat: &agents.Profile{
+				Provider:    "openai",
+				Model:       "gpt-4",
+				Temperature: 0.7,
+				MaxTokens:   512,
+				Stop:        []string{"\n\n"},
+			},
+		},
+	}
+
+	err := store.SaveAgent(agent)
+	require.Nil(t, err)
+
+	readAgent, err := store.GetAgent(agent.ID)
+	require.Nil(t, err)
+	require.NotNil(t, readAgent)
+	assert.Equal(t, agent, readAgent)
+
+	// Saving again should update the agent in place
+	agent.LLM.Summary = &agents.Profile{
+		Model:       "gpt-3.5-turbo",
+		Temperature: 0.1,
+	}
+	err = store.SaveAgent(agent)
+	require.Nil(t, err)
+
+	readAgent, err = store.GetAgent(agent.ID)
+	require.Nil(t, err)
+	require.NotNil(t, readAgent)
+	assert.Equal(t, agent, readAgent)
+	assert.Equal(t, "gpt-3.5-turbo", readAgent.LLM.SummaryProfile().Model)
+	assert.Equal(t, "gpt-4", readAgent.LLM.KnowledgeProfile().Model)
+
+	allAgents, err := store.ListAgents()
+	require.Nil(t, err)
+	assert.Len(t, allAgents, 1)
+}
+
+func SaveAgentTools(t *testing.T, store store.LowLevelStore) {
+	agent := &agents.Agent{
+		ID:       uuid.New().String(),
+		Name:     "Rose",
+		Identity: "Sassy and cynical",
+		Tools:    []string{"calculator", "current_time"},
+	}
+
+	err := store.SaveAgent(agent)
+	require.Nil(t, err)
+
+	readAgent, err := store.GetAgent(agent.ID)
+	require.Nil(t, err)
+	require.NotNil(t, readAgent)
+	assert.Equal(t, agent.Tools, readAgent.Tools)
+
+	// Tools can be disabled by saving the agent again
+	agent.Tools = []string{"calculator"}
+	err = store.SaveAgent(agent)
+	require.Nil(t, err)
+
+	readAgent, err = store.GetAgent(agent.ID)
+	require.Nil(t, err)
+	require.NotNil(t, readAgent)
+	assert.Equal(t, []string{"calculator"}, readAgent.Tools)
+}
+
 func DeleteAgent(t *testing.T, store store.LowLevelStore) {
 	agent := &agents.Agent{
 		ID:       uuid.New().String(),
@@ -738,3 +1293,880 @@ func DeleteUser(t *testing.T, store store.LowLevelStore) {
 	require.Nil(t, err)
 	assert.Nil(t, readUser)
 }
+
+func ListUsers(t *testing.T, db store.LowLevelStore) {
+	found, err := db.ListUsers()
+	require.Nil(t, err)
+	assert.Empty(t, found)
+
+	created := []*users.User{}
+	for index, name := range []string{"Keith", "Abby", "Rose"} {
+		user := &users.User{
+			ID:        uuid.New().String(),
+			Name:      name,
+			CreatedAt: time.Now().Add(time.Duration(index) * time.Minute),
+			UpdatedAt: time.Now(),
+		}
+		require.Nil(t, db.CreateUser(user, "supersecret"))
+		created = append(created, user)
+	}
+
+	found, err = db.ListUsers()
+	require.Nil(t, err)
+	require.Len(t, found, len(created))
+	for index, user := range created {
+		assert.True(t, user.Equal(found[index]))
+	}
+}
+
+func ListSummaryExclusions(t *testing.T, db store.LowLevelStore) {
+	excluded, err := db.ListSummaryExclusions()
+	require.Nil(t, err)
+	assert.Empty(t, excluded)
+
+	first := uuid.New().String()
+	second := uuid.New().String()
+	require.Nil(t, db.ExcludeConversationFromSummary(first))
+	require.Nil(t, db.ExcludeConversationFromSummary(second))
+	require.Nil(t, db.ExcludeConversationFromSummary(uuid.New().String()))
+	excluded, err = db.ListSummaryExclusions()
+	require.Nil(t, err)
+	require.Len(t, excluded, 3)
+	assert.Equal(t, first, excluded[0])
+
+	require.Nil(t, db.DeleteSummaryExclusion(second))
+	excluded, err = db.ListSummaryExclusions()
+	require.Nil(t, err)
+	assert.Len(t, excluded, 2)
+	assert.NotContains(t, excluded, second)
+}
+
+// ===============================
+// Usage
+// ===============================
+
+func SaveAndListUsage(t *testing.T, db store.LowLevelStore) {
+	records, err := db.ListUsage(store.Filter{})
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(records))
+
+	usage1 := &usage.Usage{
+		ID:               uuid.New().String(),
+		Agent:            "Rose",
+		User:             "Keith",
+		Conversation:     uuid.New().String(),
+		Type:             usage.CallChat,
+		Model:            "gpt-3.5-turbo",
+		PromptTokens:     120,
+		CompletionTokens: 30,
+		CreatedAt:        time.Now().Add(-2 * time.Hour),
+	}
+	usage2 := &usage.Usage{
+		ID:               uuid.New().String(),
+		Agent:            "Rose",
+		User:             "Keith",
+		Conversation:     usage1.Conversation,
+		Type:             usage.CallSummarize,
+		Model:            "gpt-3.5-turbo",
+		PromptTokens:     300,
+		CompletionTokens: 12,
+		CreatedAt:        time.Now().Add(-1 * time.Hour),
+	}
+	usage3 := &usage.Usage{
+		ID:               uuid.New().String(),
+		Agent:            "Winston",
+		User:             "Abby",
+		Conversation:     uuid.New().String(),
+		Type:             usage.CallChat,
+		Model:            "gpt-4",
+		PromptTokens:     80,
+		CompletionTokens: 45,
+		CreatedAt:        time.Now(),
+	}
+
+	for _, record := range []*usage.Usage{usage3, usage1, usage2} {
+		err = db.SaveUsage(record)
+		require.Nil(t, err)
+	}
+
+	// All records are returned oldest first
+	records, err = db.ListUsage(store.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	assert.True(t, usage1.Equal(records[0]))
+	assert.True(t, usage2.Equal(records[1]))
+	assert.True(t, usage3.Equal(records[2]))
+
+	// Filter by agent and user
+	records, err = db.ListUsage(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Operation: store.EQ,
+				Value:     usage1.Agent,
+			},
+			{
+				Attribute: "user",
+				Operation: store.EQ,
+				Value:     usage1.User,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	assert.True(t, usage1.Equal(records[0]))
+	assert.True(t, usage2.Equal(records[1]))
+
+	// Filter by time range
+	records, err = db.ListUsage(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "created_at",
+				Operation: store.GTE,
+				Value:     time.Now().Add(-90 * time.Minute),
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(records))
+	assert.True(t, usage2.Equal(records[0]))
+	assert.True(t, usage3.Equal(records[1]))
+}
+
+func SaveAndListRoutes(t *testing.T, db store.LowLevelStore) {
+	routes, err := db.ListRoutes(store.Filter{})
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(routes))
+
+	conversation := uuid.New().String()
+	failed := &usage.Route{
+		ID:           uuid.New().String(),
+		Agent:        "Rose",
+		User:         "Keith",
+		Conversation: conversation,
+		Type:         usage.CallChat,
+		Backend:      "primary",
+		Attempt:      0,
+		Success:      false,
+		Error:        "backend primary timed out after 1s",
+		Duration:     1000,
+		CreatedAt:    time.Now().Add(-1 * time.Minute),
+	}
+	served := &usage.Route{
+		ID:           uuid.New().String(),
+		Agent:        "Rose",
+		User:         "Keith",
+		Conversation: conversation,
+		Type:         usage.CallChat,
+		Backend:      "secondary",
+		Attempt:      1,
+		Success:      true,
+		Duration:     250,
+		CreatedAt:    failed.CreatedAt.Add(time.Second),
+	}
+	other := &usage.Route{
+		ID:           uuid.New().String(),
+		Agent:        "Winston",
+		User:         "Abby",
+		Conversation: uuid.New().String(),
+		Type:         usage.CallSummarize,
+		Backend:      "primary",
+		Success:      true,
+		Duration:     100,
+		CreatedAt:    time.Now(),
+	}
+
+	for _, route := range []*usage.Route{other, served, failed} {
+		err = db.SaveRoute(route)
+		require.Nil(t, err)
+	}
+
+	// All routes are returned oldest first
+	routes, err = db.ListRoutes(store.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 3, len(routes))
+	assert.True(t, failed.Equal(routes[0]))
+	assert.True(t, served.Equal(routes[1]))
+	assert.True(t, other.Equal(routes[2]))
+
+	// Filter by conversation
+	routes, err = db.ListRoutes(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "conversation",
+				Operation: store.EQ,
+				Value:     conversation,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(routes))
+	assert.Equal(t, "primary", routes[0].Backend)
+	assert.False(t, routes[0].Success)
+	assert.Equal(t, "secondary", routes[1].Backend)
+	assert.True(t, routes[1].Success)
+}
+
+/*
+ArtifactBlobs tests a store whose artifact data is kept in
+the given blob store.
+*/
+func ArtifactBlobs(t *testing.T, s store.LowLevelStore, blobs *blob.MemoryBlobStore) {
+	conversation := uuid.New().String()
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+	// Two messages share the same image, which should only
+	// be stored once
+	messages := []*chat.Message{}
+	for index := 0; index < 2; index++ {
+		id := uuid.New().String()
+		message := &chat.Message{
+			ID:           id,
+			User:         "Keith",
+			Agent:        "Rose",
+			From:         "Keith",
+			Content:      "Look at this",
+			Conversation: conversation,
+			CreatedAt:    time.Now(),
+			Artifacts: []*artifacts.ArtifactData{
+				{
+					ID:        uuid.New().String(),
+					Message:   id,
+					Type:      artifacts.TypeImage,
+					CreatedAt: time.Now(),
+					Data:      png,
+				},
+			},
+		}
+		require.Nil(t, s.SaveMessage(message))
+		messages = append(messages, message)
+	}
+
+	assert.Equal(t, 1, blobs.Len())
+	assert.Equal(t, blob.Key(png), messages[0].Artifacts[0].Blob)
+
+	// Listed artifacts carry their blob but not its data
+	listed, err := s.ListMessages(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "conversation",
+				Value:     conversation,
+				Operation: store.EQ,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, listed, 2)
+	require.Len(t, listed[0].Artifacts, 1)
+	assert.Equal(t, blob.Key(png), listed[0].Artifacts[0].Blob)
+	assert.Empty(t, listed[0].Artifacts[0].Data)
+	assert.Equal(t, int64(len(png)), listed[0].Artifacts[0].Size)
+
+	// The data is loaded from the blob store on request
+	artifact, err := s.GetArtifact(messages[0].Artifacts[0].ID)
+	require.Nil(t, err)
+	require.NotNil(t, artifact)
+	assert.Equal(t, png, artifact.Data)
+
+	// The blob is kept while any artifact refers to it
+	require.Nil(t, s.DeleteMessage(messages[0].ID))
+	assert.Equal(t, 1, blobs.Len())
+	artifact, err = s.GetArtifact(messages[0].Artifacts[0].ID)
+	require.Nil(t, err)
+	assert.Nil(t, artifact)
+
+	// ...and is deleted with the last of them
+	require.Nil(t, s.DeleteConversation(conversation))
+	assert.Equal(t, 0, blobs.Len())
+	artifact, err = s.GetArtifact(messages[1].Artifacts[0].ID)
+	require.Nil(t, err)
+	assert.Nil(t, artifact)
+}
+
+func SaveAndListChunks(t *testing.T, s store.LowLevelStore) {
+	conversation := uuid.New().String()
+	messageID := uuid.New().String()
+	document := &artifacts.ArtifactData{
+		ID:        uuid.New().String(),
+		Message:   messageID,
+		Type:      artifacts.TypeDocument,
+		MimeType:  "text/markdown",
+		Filename:  "itinerary.md",
+		CreatedAt: time.Now(),
+		Data:      []byte("# Day 1\nHike to the falls\n# Day 2\nVisit the museum"),
+	}
+	err := s.SaveMessage(&chat.Message{
+		ID:           messageID,
+		User:         "Keith",
+		Agent:        "Rose",
+		From:         "Keith",
+		Content:      "Here's my itinerary",
+		Conversation: conversation,
+		CreatedAt:    time.Now(),
+		Artifacts:    []*artifacts.ArtifactData{document},
+	})
+	require.Nil(t, err)
+
+	chunks := []*documents.Chunk{}
+	for index, content := range []string{"# Day 1\nHike to the falls", "# Day 2\nVisit the museum"} {
+		chunks = append(chunks, &documents.Chunk{
+			ID:           uuid.New().String(),
+			Document:     document.ID,
+			Filename:     document.Filename,
+			Agent:        "Rose",
+			User:         "Keith",
+			Conversation: conversation,
+			Index:        index,
+			Content:      content,
+			Model:        documents.HashModel,
+			Embedding:    []float32{float32(index), 0.5, -1.25},
+			CreatedAt:    time.Now(),
+		})
+	}
+	// A library document, available in all conversations
+	library := &documents.Chunk{
+		ID:        uuid.New().String(),
+		Document:  uuid.New().String(),
+		Agent:     "Rose",
+		User:      "Keith",
+		Content:   "Keith's packing list",
+		Model:     documents.HashModel,
+		Embedding: []float32{1},
+		CreatedAt: time.Now(),
+	}
+	require.Nil(t, s.SaveChunks(append(chunks, library)))
+
+	retrieved, err := s.ListChunks(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "user",
+				Value:     "Keith",
+				Operation: store.EQ,
+			},
+			{
+				Attribute: "conversation",
+				Value:     conversation,
+				Operation: store.EQ,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, retrieved, 2)
+	for index, chunk := range retrieved {
+		assert.True(t, chunks[index].Equal(chunk))
+	}
+
+	retrieved, err = s.ListChunks(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "conversation",
+				Value:     "",
+				Operation: store.EQ,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, retrieved, 1)
+	assert.True(t, library.Equal(retrieved[0]))
+
+	// Chunks can be deleted directly...
+	require.Nil(t, s.DeleteChunks(library.Document))
+	retrieved, err = s.ListChunks(store.Filter{})
+	require.Nil(t, err)
+	assert.Len(t, retrieved, 2)
+
+	// ...or go along with their document
+	require.Nil(t, s.DeleteMessage(messageID))
+	retrieved, err = s.ListChunks(store.Filter{})
+	require.Nil(t, err)
+	assert.Empty(t, retrieved)
+}
+
+// ===============================
+// Organizations
+// ===============================
+
+func SaveAndListOrganizations(t *testing.T, db store.LowLevelStore) {
+	orgs, err := db.ListOrganizations()
+	require.Nil(t, err)
+	assert.Empty(t, orgs)
+
+	acme := &tenants.Organization{
+		ID:   uuid.New().String(),
+		Name: "Acme",
+		Settings: tenants.Settings{
+			LLMKeys: map[string]string{"openai": "sk-acme"},
+			Quota:   quota.Limits{RequestsPerDay: 1000},
+		},
+	}
+	require.Nil(t, db.SaveOrganization(acme))
+	assert.False(t, acme.CreatedAt.IsZero())
+
+	initech := &tenants.Organization{ID: uuid.New().String(), Name: "Initech"}
+	require.Nil(t, db.SaveOrganization(initech))
+
+	found, err := db.GetOrganization(acme.ID)
+	require.Nil(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "Acme", found.Name)
+	assert.Equal(t, acme.Settings, found.Settings)
+
+	// Updates keep when the organization was created
+	createdAt := found.CreatedAt
+	acme.CreatedAt = time.Time{}
+	acme.Settings.UserQuota = quota.Limits{TokensPerDay: 5000}
+	require.Nil(t, db.SaveOrganization(acme))
+	found, err = db.GetOrganization(acme.ID)
+	require.Nil(t, err)
+	assert.Equal(t, 5000, found.Settings.UserQuota.TokensPerDay)
+	assert.WithinDuration(t, createdAt, found.CreatedAt, time.Second)
+
+	orgs, err = db.ListOrganizations()
+	require.Nil(t, err)
+	require.Len(t, orgs, 2)
+	assert.Equal(t, acme.ID, orgs[0].ID)
+	assert.Equal(t, initech.ID, orgs[1].ID)
+
+	require.Nil(t, db.DeleteOrganization(acme.ID))
+	found, err = db.GetOrganization(acme.ID)
+	require.Nil(t, err)
+	assert.Nil(t, found)
+}
+
+/*
+TenantOwnership checks that agents and users keep their
+organization, and that every record an agent produces is
+owned by its organization and can be listed by it
+*/
+func TenantOwnership(t *testing.T, db store.LowLevelStore) {
+	tenantsOf := map[string]string{"Rose": "acme", "Winston": "initech"}
+	for agent, tenant := range tenantsOf {
+		require.Nil(t, db.SaveAgent(&agents.Agent{ID: agent, Name: agent, Tenant: tenant}))
+	}
+	user := &users.User{ID: uuid.New().String(), Name: "Keith", CreatedAt: time.Now(), UpdatedAt: time.Now(), Tenant: "acme"}
+	require.Nil(t, db.CreateUser(user, "supersecret"))
+
+	agent, err := db.GetAgent("Rose")
+	require.Nil(t, err)
+	assert.Equal(t, "acme", agent.Tenant)
+	found, err := db.GetUser(user.ID)
+	require.Nil(t, err)
+	assert.True(t, user.Equal(found))
+
+	conversations := map[string]string{}
+	for agent := range tenantsOf {
+		conversation := uuid.New().String()
+		conversations[agent] = conversation
+		msgID := uuid.New().String()
+		require.Nil(t, db.SaveMessage(&chat.Message{
+			ID:           msgID,
+			Conversation: conversation,
+			Agent:        agent,
+			User:         "Keith",
+			From:         "Keith",
+			Content:      "Hello",
+			CreatedAt:    time.Now(),
+		}))
+		require.Nil(t, db.SaveSummary(&memory.Summary{
+			ID:                    uuid.New().String(),
+			Agent:                 agent,
+			User:                  "Keith",
+			Conversation:          conversation,
+			Summary:               "Keith said hello",
+			ConversationStartedAt: time.Now(),
+		}))
+		require.Nil(t, db.SaveKnowledge(&memory.Knowledge{
+			ID:        uuid.New().String(),
+			Agent:     agent,
+			User:      "Keith",
+			Subject:   "Keith",
+			Predicate: "says",
+			Object:    "hello",
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}))
+		require.Nil(t, db.SaveUsage(&usage.Usage{
+			ID:           uuid.New().String(),
+			Agent:        agent,
+			User:         "Keith",
+			Conversation: conversation,
+			Type:         usage.CallChat,
+			Model:        "gpt-4",
+			CreatedAt:    time.Now(),
+		}))
+	}
+
+	tenant := func(id string) store.Filter {
+		return store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{Attribute: "tenant", Operation: store.EQ, Value: id},
+			},
+		}
+	}
+
+	for agent, id := range tenantsOf {
+		messages, err := db.ListMessages(tenant(id))
+		require.Nil(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, conversations[agent], messages[0].Conversation)
+
+		summaries, err := db.ListSummaries(tenant(id))
+		require.Nil(t, err)
+		require.Len(t, summaries, 1)
+		assert.Equal(t, agent, summaries[0].Agent)
+
+		knowledge, err := db.ListKnowledge(tenant(id))
+		require.Nil(t, err)
+		require.Len(t, knowledge, 1)
+		assert.Equal(t, agent, knowledge[0].Agent)
+
+		records, err := db.ListUsage(tenant(id))
+		require.Nil(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, agent, records[0].Agent)
+	}
+
+	// Records of an unknown agent belong to the default tenant
+	require.Nil(t, db.SaveMessage(&chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        "Unknown",
+		User:         "Keith",
+		From:         "Keith",
+		Content:      "Hello?",
+		CreatedAt:    time.Now(),
+	}))
+	messages, err := db.ListMessages(tenant(""))
+	require.Nil(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "Unknown", messages[0].Agent)
+}
+
+func AgentMembers(t *testing.T, db store.LowLevelStore) {
+	require.Nil(t, db.SaveAgent(&agents.Agent{ID: "Rose", Name: "Rose", Tenant: "acme", Visibility: agents.VisibilityPrivate}))
+	agent, err := db.GetAgent("Rose")
+	require.Nil(t, err)
+	assert.Equal(t, agents.VisibilityPrivate, agent.Visibility)
+
+	user := &users.User{ID: "Keith", Name: "Keith", CreatedAt: time.Now(), UpdatedAt: time.Now(), Tenant: "acme", Role: users.RoleMember}
+	require.Nil(t, db.CreateUser(user, "supersecret"))
+	require.Nil(t, db.SetUserRole("Keith", users.RoleAgentEditor))
+	found, err := db.GetUser("Keith")
+	require.Nil(t, err)
+	assert.Equal(t, users.RoleAgentEditor, found.Role)
+
+	for _, user := range []string{"Keith", "Abby"} {
+		require.Nil(t, db.SaveAgentMember(&agents.Member{Agent: "Rose", User: user}))
+	}
+	// Saving a membership again changes nothing
+	require.Nil(t, db.SaveAgentMember(&agents.Member{Agent: "Rose", User: "Keith"}))
+	require.Nil(t, db.SaveAgentMember(&agents.Member{Agent: "Winston", User: "Keith"}))
+
+	of := func(attribute string, value string) store.Filter {
+		return store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{Attribute: attribute, Operation: store.EQ, Value: value},
+			},
+		}
+	}
+
+	members, err := db.ListAgentMembers(of("agent", "Rose"))
+	require.Nil(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, "Keith", members[0].User)
+	assert.Equal(t, "Abby", members[1].User)
+	assert.False(t, members[0].CreatedAt.IsZero())
+
+	members, err = db.ListAgentMembers(of("user", "Keith"))
+	require.Nil(t, err)
+	assert.Len(t, members, 2)
+
+	// Memberships belong to their agent's tenant
+	members, err = db.ListAgentMembers(of("tenant", "acme"))
+	require.Nil(t, err)
+	assert.Len(t, members, 2)
+
+	require.Nil(t, db.DeleteAgentMember("Rose", "Keith"))
+	members, err = db.ListAgentMembers(of("agent", "Rose"))
+	require.Nil(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "Abby", members[0].User)
+}
+
+func AgentRevisions(t *testing.T, db store.LowLevelStore) {
+	require.Nil(t, db.SaveAgent(&agents.Agent{
+		ID:       "Rose",
+		Name:     "Rose",
+		Identity: "You are Rose",
+		Tenant:   "acme",
+		Version:  2,
+		Memory:   agents.MemorySettings{MaxSummaries: 3},
+	}))
+	agent, err := db.GetAgent("Rose")
+	require.Nil(t, err)
+	assert.Equal(t, 2, agent.Version)
+	assert.Equal(t, 3, agent.Memory.MaxSummaries)
+
+	for version, identity := range []string{"You are Rose", "You are Rose, a gardener"} {
+		require.Nil(t, db.SaveAgentRevision(&agents.Revision{
+			Agent:    "Rose",
+			Version:  version + 1,
+			Identity: identity,
+			Author:   "Keith",
+		}))
+	}
+	require.Nil(t, db.SaveAgentRevision(&agents.Revision{Agent: "Winston", Version: 1, Identity: "You are Winston"}))
+
+	// Revisions are write-once
+	assert.NotNil(t, db.SaveAgentRevision(&agents.Revision{Agent: "Rose", Version: 1, Identity: "Overwritten"}))
+
+	revision, err := db.GetAgentRevision("Rose", 2)
+	require.Nil(t, err)
+	require.NotNil(t, revision)
+	assert.Equal(t, "You are Rose, a gardener", revision.Identity)
+	assert.Equal(t, "Keith", revision.Author)
+	assert.False(t, revision.CreatedAt.IsZero())
+
+	revision, err = db.GetAgentRevision("Rose", 3)
+	require.Nil(t, err)
+	assert.Nil(t, revision)
+
+	of := func(attribute string, value string) store.Filter {
+		return store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{Attribute: attribute, Operation: store.EQ, Value: value},
+			},
+		}
+	}
+
+	revisions, err := db.ListAgentRevisions(of("agent", "Rose"))
+	require.Nil(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[0].Version)
+	assert.Equal(t, "You are Rose", revisions[0].Identity)
+	assert.Equal(t, 2, revisions[1].Version)
+
+	// Revisions belong to their agent's tenant
+	revisions, err = db.ListAgentRevisions(of("tenant", "acme"))
+	require.Nil(t, err)
+	assert.Len(t, revisions, 2)
+
+	// Messages keep the version of the agent that wrote them
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        "Rose",
+		User:         "Keith",
+		From:         "Rose",
+		Content:      "Hello",
+		CreatedAt:    time.Now(),
+		AgentVersion: 2,
+	}
+	require.Nil(t, db.SaveMessage(msg))
+	found, err := db.GetMessage(msg.ID)
+	require.Nil(t, err)
+	assert.Equal(t, 2, found.AgentVersion)
+}
+
+func PromptTemplates(t *testing.T, db store.LowLevelStore) {
+	require.Nil(t, db.SaveAgent(&agents.Agent{ID: "Rose", Name: "Rose", Identity: "You are Rose", Tenant: "acme"}))
+
+	require.Nil(t, db.SavePromptTemplate(&agents.PromptTemplate{Name: "summary", Template: "Summarize"}))
+	require.Nil(t, db.SavePromptTemplate(&agents.PromptTemplate{Agent: "Rose", Name: "summary", Template: "Summarize for Rose"}))
+	require.Nil(t, db.SavePromptTemplate(&agents.PromptTemplate{Agent: "Rose", Name: "knowledge", Template: "Learn"}))
+
+	prompt, err := db.GetPromptTemplate("Rose", "summary")
+	require.Nil(t, err)
+	require.NotNil(t, prompt)
+	assert.Equal(t, "Summarize for Rose", prompt.Template)
+	assert.False(t, prompt.UpdatedAt.IsZero())
+
+	prompt, err = db.GetPromptTemplate("", "summary")
+	require.Nil(t, err)
+	require.NotNil(t, prompt)
+	assert.Equal(t, "Summarize", prompt.Template)
+
+	prompt, err = db.GetPromptTemplate("Winston", "summary")
+	require.Nil(t, err)
+	assert.Nil(t, prompt)
+
+	// Saving again replaces the template
+	require.Nil(t, db.SavePromptTemplate(&agents.PromptTemplate{Agent: "Rose", Name: "knowledge", Template: "Learn more"}))
+	prompt, err = db.GetPromptTemplate("Rose", "knowledge")
+	require.Nil(t, err)
+	assert.Equal(t, "Learn more", prompt.Template)
+
+	of := func(attribute string, value string) store.Filter {
+		return store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{Attribute: attribute, Operation: store.EQ, Value: value},
+			},
+		}
+	}
+
+	prompts, err := db.ListPromptTemplates(of("agent", "Rose"))
+	require.Nil(t, err)
+	require.Len(t, prompts, 2)
+	assert.Equal(t, "knowledge", prompts[0].Name)
+	assert.Equal(t, "summary", prompts[1].Name)
+
+	// Templates belong to their agent's tenant
+	prompts, err = db.ListPromptTemplates(of("tenant", "acme"))
+	require.Nil(t, err)
+	assert.Len(t, prompts, 2)
+
+	require.Nil(t, db.DeletePromptTemplate("Rose", "summary"))
+	prompts, err = db.ListPromptTemplates(store.Filter{})
+	require.Nil(t, err)
+	require.Len(t, prompts, 2)
+	assert.Equal(t, "", prompts[0].Agent)
+	assert.Equal(t, "knowledge", prompts[1].Name)
+}
+
+// ===============================
+// Experiments
+// ===============================
+
+func Experiments(t *testing.T, db store.LowLevelStore) {
+	require.Nil(t, db.SaveAgent(&agents.Agent{ID: "Rose", Name: "Rose", Identity: "You are Rose", Tenant: "acme"}))
+
+	experiment := &experiments.Experiment{
+		ID:    uuid.New().String(),
+		Agent: "Rose",
+		Name:  "Shorter summaries",
+		Variants: []*experiments.Variant{
+			{Name: "control"},
+			{
+				Name:    "short",
+				Weight:  2,
+				Prompts: map[string]string{"summary": "Summarize briefly"},
+				LLM:     &agents.LLMSettings{Chat: &agents.Profile{Model: "gpt-4"}},
+			},
+		},
+		Active: true,
+	}
+	require.Nil(t, db.SaveExperiment(experiment))
+	require.Nil(t, db.SaveExperiment(&experiments.Experiment{ID: uuid.New().String(), Agent: "Winston", Name: "Other"}))
+
+	found, err := db.GetExperiment(experiment.ID)
+	require.Nil(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "Shorter summaries", found.Name)
+	assert.True(t, found.Active)
+	assert.Equal(t, experiment.Variants, found.Variants)
+	assert.False(t, found.CreatedAt.IsZero())
+	assert.True(t, found.EndedAt.IsZero())
+
+	found, err = db.GetExperiment(uuid.New().String())
+	require.Nil(t, err)
+	assert.Nil(t, found)
+
+	// Ending an experiment is saving it again
+	experiment.Active = false
+	experiment.EndedAt = time.Now()
+	require.Nil(t, db.SaveExperiment(experiment))
+	found, err = db.GetExperiment(experiment.ID)
+	require.Nil(t, err)
+	assert.False(t, found.Active)
+	assert.WithinDuration(t, experiment.EndedAt, found.EndedAt, time.Second)
+
+	// Experiments belong to their agent's tenant
+	tenanted, err := db.ListExperiments(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "tenant", Operation: store.EQ, Value: "acme"},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, tenanted, 1)
+	assert.Equal(t, experiment.ID, tenanted[0].ID)
+
+	all, err := db.ListExperiments(store.Filter{})
+	require.Nil(t, err)
+	assert.Len(t, all, 2)
+
+	// Messages keep the variant they were sent under
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        "Rose",
+		User:         "Keith",
+		From:         "Rose",
+		Content:      "Hello",
+		CreatedAt:    time.Now(),
+		Experiment:   experiment.ID,
+		Variant:      "short",
+		Duration:     1500,
+	}
+	require.Nil(t, db.SaveMessage(msg))
+	messages, err := db.ListMessages(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "experiment", Operation: store.EQ, Value: experiment.ID},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "short", messages[0].Variant)
+	assert.Equal(t, int64(1500), messages[0].Duration)
+}
+
+func Feedback(t *testing.T, db store.LowLevelStore) {
+	require.Nil(t, db.SaveAgent(&agents.Agent{ID: "Rose", Name: "Rose", Identity: "You are Rose", Tenant: "acme"}))
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        "Rose",
+		User:         "Keith",
+		From:         "Rose",
+		Content:      "Hello",
+		CreatedAt:    time.Now(),
+	}
+	require.Nil(t, db.SaveMessage(msg))
+
+	require.Nil(t, db.SaveFeedback(&chat.Feedback{
+		Message: msg.ID,
+		Agent:   "Rose",
+		User:    "Keith",
+		Rating:  chat.RatingDown,
+		Comment: "That's not what I asked",
+		Tags:    []string{"inaccurate", "off topic"},
+	}))
+	require.Nil(t, db.SaveFeedback(&chat.Feedback{Message: msg.ID, Agent: "Rose", User: "Abby", Rating: chat.RatingUp}))
+
+	of := func(attribute string, value string) store.Filter {
+		return store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{Attribute: attribute, Operation: store.EQ, Value: value},
+			},
+		}
+	}
+
+	feedback, err := db.ListFeedback(of("user", "Keith"))
+	require.Nil(t, err)
+	require.Len(t, feedback, 1)
+	assert.Equal(t, msg.ID, feedback[0].Message)
+	assert.Equal(t, "Rose", feedback[0].Agent)
+	assert.Equal(t, chat.RatingDown, feedback[0].Rating)
+	assert.Equal(t, "That's not what I asked", feedback[0].Comment)
+	assert.Equal(t, []string{"inaccurate", "off topic"}, feedback[0].Tags)
+	assert.False(t, feedback[0].CreatedAt.IsZero())
+
+	// A user's rating of a message replaces their last
+	require.Nil(t, db.SaveFeedback(&chat.Feedback{Message: msg.ID, Agent: "Rose", User: "Keith", Rating: chat.RatingUp}))
+	feedback, err = db.ListFeedback(of("message", msg.ID))
+	require.Nil(t, err)
+	require.Len(t, feedback, 2)
+	for _, rating := range feedback {
+		assert.Equal(t, chat.RatingUp, rating.Rating)
+		assert.Empty(t, rating.Comment)
+		assert.Empty(t, rating.Tags)
+	}
+
+	feedback, err = db.ListFeedback(of("agent", "Rose"))
+	require.Nil(t, err)
+	assert.Len(t, feedback, 2)
+
+	// Feedback belongs to its message's tenant
+	feedback, err = db.ListFeedback(of("tenant", "acme"))
+	require.Nil(t, err)
+	assert.Len(t, feedback, 2)
+}
@@ -1,7 +1,126 @@
 package agents
 
+import "time"
+
 type Agent struct {
-	ID       string `json:"id,omitempty" db:"id"`
-	Name     string `json:"name,omitempty" db:"name"`
-	Identity string `json:"identity,omitempty" db:"identity"`
+	ID         string         `json:"id,omitempty" db:"id"`
+	Name       string         `json:"name,omitempty" db:"name"`
+	Identity   string         `json:"identity,omitempty" db:"identity"`
+	LLM        LLMSettings    `json:"llm,omitempty" db:"llm"`
+	Tools      []string       `json:"tools,omitempty" db:"tools"`
+	Tenant     string         `json:"tenant,omitempty" db:"tenant"`
+	Visibility string         `json:"visibility,omitempty" db:"visibility"`
+	Version    int            `json:"version,omitempty" db:"version"`
+	Memory     MemorySettings `json:"memory,omitempty" db:"memory"`
+
+	// Prompts override the agent's prompt templates, by name,
+	// for a single call - ie by an experiment's variant. They
+	// are never stored.
+	Prompts map[string]string `json:"-" db:"-"`
+}
+
+/*
+MemorySettings override, for a single agent, how much of
+what it remembers it uses. Any unset (zero) value is left to
+the configured default. MaxSummaries is how many summaries
+of past conversations are included when chatting, and
+CompressionThreshold is how many facts about a user are kept
+before they are compressed.
+*/
+type MemorySettings struct {
+	MaxSummaries         int `json:"max_summaries,omitempty"`
+	CompressionThreshold int `json:"compression_threshold,omitempty"`
+}
+
+/*
+These are who an agent is available to within its
+organization. Public agents are available to every user;
+invite-only agents are listed to every user but only
+available to their members; private agents are only listed
+to, and available to, their members. Admins may reach every
+agent.
+*/
+const (
+	VisibilityPublic     = "public"
+	VisibilityInviteOnly = "invite-only"
+	VisibilityPrivate    = "private"
+)
+
+// ValidVisibility is whether visibility is a known visibility
+func ValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityInviteOnly, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+/*
+Member records that a user may reach an agent that isn't
+public.
+*/
+type Member struct {
+	Agent     string    `json:"agent,omitempty" db:"agent"`
+	User      string    `json:"user,omitempty" db:"user"`
+	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// HasTool is whether the agent has enabled the named tool
+func (agent *Agent) HasTool(name string) bool {
+	for _, tool := range agent.Tools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Profile is the set of model and generation settings used
+when calling an LLM on behalf of an agent. Any unset
+(zero) value is left to the LLM implementation's default.
+*/
+type Profile struct {
+	Provider         string   `json:"provider,omitempty"`
+	Model            string   `json:"model,omitempty"`
+	Temperature      float32  `json:"temperature,omitempty"`
+	TopP             float32  `json:"top_p,omitempty"`
+	MaxTokens        int      `json:"max_tokens,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  float32  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float32  `json:"frequency_penalty,omitempty"`
+}
+
+/*
+LLMSettings are the profiles an agent uses for each type
+of LLM call. Chat is used for conversing and as the
+fallback for any other unset profile; Summary and
+Knowledge are optional overrides for summarization and
+knowledge extraction respectively.
+*/
+type LLMSettings struct {
+	Chat      *Profile `json:"chat,omitempty"`
+	Summary   *Profile `json:"summary,omitempty"`
+	Knowledge *Profile `json:"knowledge,omitempty"`
+}
+
+func (settings LLMSettings) ChatProfile() Profile {
+	if settings.Chat == nil {
+		return Profile{}
+	}
+	return *settings.Chat
+}
+
+func (settings LLMSettings) SummaryProfile() Profile {
+	if settings.Summary == nil {
+		return settings.ChatProfile()
+	}
+	return *settings.Summary
+}
+
+func (settings LLMSettings) KnowledgeProfile() Profile {
+	if settings.Knowledge == nil {
+		return settings.ChatProfile()
+	}
+	return *settings.Knowledge
 }
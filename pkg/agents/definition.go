@@ -0,0 +1,169 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Definition declares an agent in a YAML or JSON file. Keys are
+the same in either format. The identity may be given inline,
+or as identity_file - a path to a file holding it, relative
+to the directory of definitions.
+*/
+type Definition struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Identity     string         `json:"identity,omitempty"`
+	IdentityFile string         `json:"identity_file,omitempty"`
+	LLM          LLMSettings    `json:"llm,omitempty"`
+	Tools        []string       `json:"tools,omitempty"`
+	Memory       MemorySettings `json:"memory,omitempty"`
+	Tenant       string         `json:"tenant,omitempty"`
+	Visibility   string         `json:"visibility,omitempty"`
+
+	// Source is the file the definition was read from
+	Source string `json:"-"`
+}
+
+// Agent is the agent a definition declares
+func (definition *Definition) Agent() *Agent {
+	visibility := definition.Visibility
+	if visibility == "" {
+		visibility = VisibilityPublic
+	}
+
+	return &Agent{
+		ID:         definition.ID,
+		Name:       definition.Name,
+		Identity:   definition.Identity,
+		LLM:        definition.LLM,
+		Tools:      definition.Tools,
+		Memory:     definition.Memory,
+		Tenant:     definition.Tenant,
+		Visibility: visibility,
+	}
+}
+
+// Validate checks that a definition declares a usable agent
+func (definition *Definition) Validate() error {
+	if definition.ID == "" {
+		return fmt.Errorf("id must be set")
+	}
+	if definition.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+	if strings.TrimSpace(definition.Identity) == "" {
+		return fmt.Errorf("identity must be set")
+	}
+	if definition.Visibility != "" && !ValidVisibility(definition.Visibility) {
+		return fmt.Errorf("unknown visibility %s", definition.Visibility)
+	}
+	if definition.Memory.MaxSummaries < 0 || definition.Memory.CompressionThreshold < 0 {
+		return fmt.Errorf("memory settings can't be negative")
+	}
+	return nil
+}
+
+/*
+ParseDefinition reads a single definition, as YAML if name
+ends in .yaml or .yml and as JSON otherwise. Unknown keys
+are rejected so that a misspelled setting isn't silently
+ignored.
+*/
+func ParseDefinition(name string, data []byte) (*Definition, error) {
+	// YAML is converted to JSON so that both are read with
+	// the same keys
+	if isYAML(name) {
+		var value interface{}
+		err := yaml.Unmarshal(data, &value)
+		if err != nil {
+			return nil, err
+		}
+		data, err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+
+	var definition Definition
+	err := decoder.Decode(&definition)
+	if err != nil {
+		return nil, err
+	}
+	definition.Source = name
+
+	return &definition, nil
+}
+
+/*
+LoadDefinitions reads every .yaml, .yml and .json file at
+the root of fsys as a definition, in name order, loading
+identity files and validating each. Two definitions of the
+same agent are an error.
+*/
+func LoadDefinitions(fsys fs.FS) ([]*Definition, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && (isYAML(entry.Name()) || path.Ext(entry.Name()) == ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	definitions := []*Definition{}
+	sources := map[string]string{}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		definition, err := ParseDefinition(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if definition.IdentityFile != "" {
+			if definition.Identity != "" {
+				return nil, fmt.Errorf("%s: only one of identity and identity_file may be set", name)
+			}
+			identity, err := fs.ReadFile(fsys, path.Clean(definition.IdentityFile))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			definition.Identity = string(identity)
+		}
+
+		if err := definition.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if other, ok := sources[definition.ID]; ok {
+			return nil, fmt.Errorf("%s: agent %s is already defined by %s", name, definition.ID, other)
+		}
+		sources[definition.ID] = name
+
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func isYAML(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,16 @@
+package agents
+
+import "time"
+
+/*
+PromptTemplate is a stored instruction prompt template. One
+with no agent replaces the bundled template of its name for
+every agent; one with an agent overrides it for that agent
+alone.
+*/
+type PromptTemplate struct {
+	Agent     string    `json:"agent,omitempty" db:"agent"`
+	Name      string    `json:"name,omitempty" db:"name"`
+	Template  string    `json:"template,omitempty" db:"template"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
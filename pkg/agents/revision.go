@@ -0,0 +1,86 @@
+package agents
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+Revision is a version of an agent's identity. A new revision
+is made each time the identity changes, numbered from 1, and
+the agent carries the version of its current identity.
+*/
+type Revision struct {
+	Agent     string    `json:"agent,omitempty" db:"agent"`
+	Version   int       `json:"version,omitempty" db:"version"`
+	Identity  string    `json:"identity,omitempty" db:"identity"`
+	Author    string    `json:"author,omitempty" db:"author"`
+	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// The operations of a line within a diff
+const (
+	DiffEqual  = "="
+	DiffInsert = "+"
+	DiffDelete = "-"
+)
+
+// DiffLine is a single line of a diff, and whether it was
+// kept, inserted or deleted
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+/*
+DiffIdentities compares two identities line by line, keeping
+the longest run of lines common to both, and returns every
+line of either - deleted lines of from before the lines of
+to that replaced them.
+*/
+func DiffIdentities(from string, to string) []DiffLine {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// common[i][j] is the length of the longest common
+	// subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	diff := []DiffLine{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: a[i]})
+			i++
+			j++
+		} else if common[i+1][j] >= common[i][j+1] {
+			diff = append(diff, DiffLine{Op: DiffDelete, Text: a[i]})
+			i++
+		} else {
+			diff = append(diff, DiffLine{Op: DiffInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{Op: DiffDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{Op: DiffInsert, Text: b[j]})
+	}
+
+	return diff
+}
@@ -1,6 +1,9 @@
 package artifacts
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 /*
 Artifact is a generic interface representing entities
@@ -10,6 +13,9 @@ messages. Examples of possible artifacts:
 2 - A document we are asking the AI questions about
 3 - An e-mail the AI generated to send
 4 - Voice input or output for the conversation
+
+Each type of artifact is registered by name (see Register)
+so that ArtifactData can be decoded into it.
 */
 type Artifact interface {
 	FromData(data ArtifactData) error
@@ -24,24 +30,98 @@ of possible artifacts:
 2 - A document we are asking the AI questions about
 3 - An e-mail the AI generated to send
 4 - Voice input or output for the conversation
+
+Type must be a registered artifact type, and Size is the
+length of Data. Data is only loaded when an artifact is
+fetched on its own; artifacts listed with their messages
+carry only their details. If the data is kept in a blob
+store, Blob is the key it is kept under.
 */
 type ArtifactData struct {
-	ID        string    `json:"id,omitempty" db:"id"`
-	Message   string    `json:"message,omitempty" db:"message"`
-	Type      string    `json:"type,omitempty" db:"type"`
-	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
-	Data      []byte    `json:"data,omitempty" db:"data"`
+	ID        string            `json:"id,omitempty" db:"id"`
+	Message   string            `json:"message,omitempty" db:"message"`
+	Type      string            `json:"type,omitempty" db:"type"`
+	MimeType  string            `json:"mime_type,omitempty" db:"mime_type"`
+	Size      int64             `json:"size,omitempty" db:"size"`
+	Filename  string            `json:"filename,omitempty" db:"filename"`
+	Metadata  map[string]string `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt time.Time         `json:"created_at,omitempty" db:"created_at"`
+	Blob      string            `json:"blob,omitempty" db:"blob"`
+	Data      []byte            `json:"data,omitempty" db:"data"`
 }
 
+/*
+Equal compares two artifacts. Their data is only compared if
+it is loaded for both, as listed artifacts do not carry it.
+*/
 func (data *ArtifactData) Equal(other *ArtifactData) bool {
 	createdAtDifference := data.CreatedAt.Sub(other.CreatedAt)
 	if createdAtDifference < 0 {
 		createdAtDifference = -createdAtDifference
 	}
 
+	if len(data.Metadata) != len(other.Metadata) {
+		return false
+	}
+	for key, value := range data.Metadata {
+		if otherValue, ok := other.Metadata[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+
 	return data.ID == other.ID &&
 		data.Type == other.Type &&
 		data.Message == other.Message &&
+		data.MimeType == other.MimeType &&
+		data.Size == other.Size &&
+		data.Filename == other.Filename &&
 		createdAtDifference < time.Second &&
-		string(data.Data) == string(other.Data)
+		(data.Blob == "" || other.Blob == "" || data.Blob == other.Blob) &&
+		(len(data.Data) == 0 || len(other.Data) == 0 || string(data.Data) == string(other.Data))
+}
+
+/*
+Decode converts the data into the Go type registered for its
+Type, ie an *Image for "image".
+*/
+func (data *ArtifactData) Decode() (Artifact, error) {
+	artifact, err := New(data.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	err = artifact.FromData(*data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s artifact: %w", data.Type, err)
+	}
+
+	return artifact, nil
+}
+
+/*
+Validate ensures the data decodes into its registered type,
+and fills in what can be determined from it - the size, and
+the MIME type if it was not set.
+*/
+func (data *ArtifactData) Validate() error {
+	if data.ID == "" {
+		return fmt.Errorf("artifact id must be set")
+	}
+	if data.Size != 0 && data.Size != int64(len(data.Data)) {
+		return fmt.Errorf("artifact size %d does not match its data (%d bytes)", data.Size, len(data.Data))
+	}
+
+	artifact, err := data.Decode()
+	if err != nil {
+		return err
+	}
+
+	normalized, err := artifact.GetData()
+	if err != nil {
+		return err
+	}
+	data.MimeType = normalized.MimeType
+	data.Size = int64(len(data.Data))
+
+	return nil
 }
@@ -0,0 +1,236 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+func TestDecode(t *testing.T) {
+	data := &ArtifactData{
+		ID:        "image",
+		Message:   "message",
+		Type:      TypeImage,
+		Filename:  "cooper.png",
+		Metadata:  map[string]string{"alt": "A very good dog"},
+		CreatedAt: time.Now(),
+		Data:      pngHeader,
+	}
+
+	artifact, err := data.Decode()
+	require.Nil(t, err)
+	image, ok := artifact.(*Image)
+	require.True(t, ok)
+	assert.Equal(t, "image/png", image.MimeType)
+	assert.Equal(t, "cooper.png", image.Filename)
+	assert.Equal(t, "A very good dog", image.Metadata["alt"])
+	assert.Equal(t, pngHeader, image.Data)
+
+	// And it should convert back to the same data
+	roundTrip, err := image.GetData()
+	require.Nil(t, err)
+	data.MimeType = "image/png"
+	data.Size = int64(len(pngHeader))
+	assert.True(t, data.Equal(&roundTrip))
+
+	// Unknown types can't be decoded
+	data.Type = "hologram"
+	_, err = data.Decode()
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		data     ArtifactData
+		valid    bool
+		mimeType string
+	}{
+		"image": {
+			data:     ArtifactData{Type: TypeImage, Data: pngHeader},
+			valid:    true,
+			mimeType: "image/png",
+		},
+		"image that isn't": {
+			data: ArtifactData{Type: TypeImage, Data: []byte("hello")},
+		},
+		"image with wrong mime type": {
+			data: ArtifactData{Type: TypeImage, MimeType: "audio/wav", Data: pngHeader},
+		},
+		"empty image": {
+			data: ArtifactData{Type: TypeImage, MimeType: "image/png"},
+		},
+		"audio": {
+			data:     ArtifactData{Type: TypeAudio, MimeType: "audio/ogg", Data: []byte{1, 2, 3}},
+			valid:    true,
+			mimeType: "audio/ogg",
+		},
+		"text": {
+			data:     ArtifactData{Type: TypeText, Data: []byte("Dear diary")},
+			valid:    true,
+			mimeType: "text/plain; charset=utf-8",
+		},
+		"markdown": {
+			data:     ArtifactData{Type: TypeText, MimeType: "text/markdown", Data: []byte("# Dear diary")},
+			valid:    true,
+			mimeType: "text/markdown",
+		},
+		"text that isn't UTF-8": {
+			data: ArtifactData{Type: TypeText, Data: []byte{0xff, 0xfe}},
+		},
+		"json": {
+			data:     ArtifactData{Type: TypeJSON, Data: []byte(`{"temperature": 72}`)},
+			valid:    true,
+			mimeType: "application/json",
+		},
+		"invalid json": {
+			data: ArtifactData{Type: TypeJSON, Data: []byte(`{"temperature": }`)},
+		},
+		"link": {
+			data:     ArtifactData{Type: TypeLink, Data: []byte("https://coppermind.dev/docs")},
+			valid:    true,
+			mimeType: "text/uri-list",
+		},
+		"relative link": {
+			data: ArtifactData{Type: TypeLink, Data: []byte("/docs")},
+		},
+		"javascript link": {
+			data: ArtifactData{Type: TypeLink, Data: []byte("javascript:alert(1)")},
+		},
+		"pdf document": {
+			data:     ArtifactData{Type: TypeDocument, Data: []byte("%PDF-1.4\n")},
+			valid:    true,
+			mimeType: "application/pdf",
+		},
+		"markdown document": {
+			data:     ArtifactData{Type: TypeDocument, MimeType: "text/markdown", Data: []byte("# Itinerary")},
+			valid:    true,
+			mimeType: "text/markdown",
+		},
+		"image document": {
+			data: ArtifactData{Type: TypeDocument, Data: pngHeader},
+		},
+		"wrong size": {
+			data: ArtifactData{Type: TypeText, Size: 100, Data: []byte("Dear diary")},
+		},
+		"unknown type": {
+			data: ArtifactData{Type: "hologram", Data: []byte{1}},
+		},
+	}
+
+	for name, test := range tests {
+		data := test.data
+		data.ID = "artifact"
+
+		err := data.Validate()
+		if !test.valid {
+			assert.NotNil(t, err, name)
+			continue
+		}
+		require.Nil(t, err, name)
+		assert.Equal(t, test.mimeType, data.MimeType, name)
+		assert.Equal(t, int64(len(data.Data)), data.Size, name)
+	}
+
+	// An ID is required
+	data := &ArtifactData{Type: TypeText, Data: []byte("Dear diary")}
+	assert.NotNil(t, data.Validate())
+}
+
+func TestLinkTitle(t *testing.T) {
+	link := &Link{
+		Header: Header{ID: "link"},
+		URL:    "https://coppermind.dev",
+		Title:  "Coppermind",
+	}
+
+	data, err := link.GetData()
+	require.Nil(t, err)
+	assert.Equal(t, TypeLink, data.Type)
+	assert.Equal(t, "Coppermind", data.Metadata["title"])
+
+	artifact, err := data.Decode()
+	require.Nil(t, err)
+	assert.Equal(t, link.Title, artifact.(*Link).Title)
+	assert.Equal(t, link.URL, artifact.(*Link).URL)
+}
+
+func TestExtractLinks(t *testing.T) {
+	text := "See [the docs](https://coppermind.dev/docs) and [the source](https://github.com/hlfshell/coppermind). " +
+		"The [docs again](https://coppermind.dev/docs), [somewhere](/relative), and https://bare.dev are ignored."
+
+	links := ExtractLinks(text)
+	require.Len(t, links, 2)
+
+	assert.Equal(t, TypeLink, links[0].Type)
+	assert.Equal(t, "https://coppermind.dev/docs", string(links[0].Data))
+	assert.Equal(t, "the docs", links[0].Metadata["title"])
+	assert.Equal(t, "https://github.com/hlfshell/coppermind", string(links[1].Data))
+
+	// They are ready to validate once given an ID
+	links[0].ID = "link"
+	assert.Nil(t, links[0].Validate())
+
+	assert.Empty(t, ExtractLinks("No links here"))
+}
+
+type email struct {
+	Header
+	Subject string `json:"subject"`
+}
+
+func (mail *email) FromData(data ArtifactData) error {
+	mail.Header = headerFromData(data)
+	return json.Unmarshal(data.Data, mail)
+}
+
+func (mail *email) GetData() (ArtifactData, error) {
+	encoded, err := json.Marshal(mail)
+	if err != nil {
+		return ArtifactData{}, err
+	}
+	return mail.Header.toData("email", encoded), nil
+}
+
+func TestRegister(t *testing.T) {
+	assert.Equal(t, []string{"audio", "document", "image", "json", "link", "text"}, Types())
+
+	err := Register("email", func() Artifact { return &email{} })
+	require.Nil(t, err)
+	defer func() {
+		registryLock.Lock()
+		delete(registry, "email")
+		registryLock.Unlock()
+	}()
+
+	// Types can only be registered once
+	assert.NotNil(t, Register("email", func() Artifact { return &email{} }))
+	assert.NotNil(t, Register(TypeImage, func() Artifact { return &Image{} }))
+
+	data := &ArtifactData{ID: "email", Type: "email", Data: []byte(`{"subject": "Hello"}`)}
+	artifact, err := data.Decode()
+	require.Nil(t, err)
+	assert.Equal(t, "Hello", artifact.(*email).Subject)
+}
+
+func TestTypeForMimeType(t *testing.T) {
+	tests := map[string]string{
+		"image/jpeg":                TypeImage,
+		"audio/mpeg":                TypeAudio,
+		"text/plain; charset=utf-8": TypeText,
+		"text/markdown":             TypeText,
+		"text/uri-list":             TypeLink,
+		"application/json":          TypeJSON,
+		"application/ld+json":       TypeJSON,
+		"application/pdf":           TypeDocument,
+		"":                          "",
+	}
+
+	for mimeType, expected := range tests {
+		assert.Equal(t, expected, TypeForMimeType(mimeType), mimeType)
+	}
+}
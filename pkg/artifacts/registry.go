@@ -0,0 +1,100 @@
+package artifacts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]func() Artifact{
+		TypeImage:    func() Artifact { return &Image{} },
+		TypeText:     func() Artifact { return &Text{} },
+		TypeAudio:    func() Artifact { return &Audio{} },
+		TypeJSON:     func() Artifact { return &JSON{} },
+		TypeLink:     func() Artifact { return &Link{} },
+		TypeDocument: func() Artifact { return &Document{} },
+	}
+)
+
+/*
+Register adds a new type of artifact, with a constructor for
+an empty artifact of that type to decode ArtifactData into.
+Types may only be registered once.
+*/
+func Register(artifactType string, constructor func() Artifact) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if artifactType == "" {
+		return fmt.Errorf("artifact type must be set")
+	} else if _, ok := registry[artifactType]; ok {
+		return fmt.Errorf("artifact type %s is already registered", artifactType)
+	}
+	registry[artifactType] = constructor
+
+	return nil
+}
+
+/*
+New creates an empty artifact of the given type.
+*/
+func New(artifactType string) (Artifact, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	constructor, ok := registry[artifactType]
+	if !ok {
+		return nil, fmt.Errorf("unknown artifact type %q", artifactType)
+	}
+	return constructor(), nil
+}
+
+/*
+Types returns every registered artifact type, sorted.
+*/
+func Types() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	types := []string{}
+	for artifactType := range registry {
+		types = append(types, artifactType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+/*
+TypeForMimeType guesses the built in artifact type for a
+MIME type, ie "image" for image/png. An empty string is
+returned if there is no sensible guess.
+*/
+func TypeForMimeType(mimeType string) string {
+	mediaType := baseMimeType(mimeType)
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return TypeImage
+	case strings.HasPrefix(mediaType, "audio/"):
+		return TypeAudio
+	case mediaType == mimeTypeLink:
+		return TypeLink
+	case mediaType == mimeTypePDF:
+		return TypeDocument
+	case mediaType == mimeTypeJSON || strings.HasSuffix(mediaType, "+json"):
+		return TypeJSON
+	case strings.HasPrefix(mediaType, "text/"):
+		return TypeText
+	}
+	return ""
+}
+
+// baseMimeType strips any parameters (ie "; charset=utf-8")
+// from a MIME type
+func baseMimeType(mimeType string) string {
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
@@ -0,0 +1,370 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The built in artifact types
+const (
+	TypeImage    = "image"
+	TypeText     = "text"
+	TypeAudio    = "audio"
+	TypeJSON     = "json"
+	TypeLink     = "link"
+	TypeDocument = "document"
+)
+
+const (
+	mimeTypeText = "text/plain; charset=utf-8"
+	mimeTypeJSON = "application/json"
+	mimeTypeLink = "text/uri-list"
+	mimeTypePDF  = "application/pdf"
+
+	// The metadata key a link's title is kept under
+	linkTitleKey = "title"
+)
+
+/*
+Header is the information common to every artifact type,
+regardless of its content.
+*/
+type Header struct {
+	ID        string
+	Message   string
+	MimeType  string
+	Filename  string
+	Metadata  map[string]string
+	CreatedAt time.Time
+}
+
+func headerFromData(data ArtifactData) Header {
+	return Header{
+		ID:        data.ID,
+		Message:   data.Message,
+		MimeType:  data.MimeType,
+		Filename:  data.Filename,
+		Metadata:  data.Metadata,
+		CreatedAt: data.CreatedAt,
+	}
+}
+
+func (header Header) toData(artifactType string, content []byte) ArtifactData {
+	return ArtifactData{
+		ID:        header.ID,
+		Message:   header.Message,
+		Type:      artifactType,
+		MimeType:  header.MimeType,
+		Size:      int64(len(content)),
+		Filename:  header.Filename,
+		Metadata:  header.Metadata,
+		CreatedAt: header.CreatedAt,
+		Data:      content,
+	}
+}
+
+/*
+mediaMimeType returns the MIME type of binary media, which
+must be of the given family (ie "image"). If no MIME type is
+set it is detected from the content.
+*/
+func mediaMimeType(mimeType string, content []byte, family string) (string, error) {
+	if len(content) == 0 {
+		return "", fmt.Errorf("%s has no data", family)
+	}
+
+	if mimeType == "" {
+		mimeType = http.DetectContentType(content)
+	}
+	if !strings.HasPrefix(baseMimeType(mimeType), family+"/") {
+		return "", fmt.Errorf("%s must have an %s/* mime type, not %s", family, family, mimeType)
+	}
+
+	return mimeType, nil
+}
+
+/*
+Image is a binary image, ie a PNG or JPEG.
+*/
+type Image struct {
+	Header
+	Data []byte
+}
+
+func (image *Image) FromData(data ArtifactData) error {
+	mimeType, err := mediaMimeType(data.MimeType, data.Data, TypeImage)
+	if err != nil {
+		return err
+	}
+
+	image.Header = headerFromData(data)
+	image.MimeType = mimeType
+	image.Data = data.Data
+	return nil
+}
+
+func (image *Image) GetData() (ArtifactData, error) {
+	mimeType, err := mediaMimeType(image.MimeType, image.Data, TypeImage)
+	if err != nil {
+		return ArtifactData{}, err
+	}
+
+	data := image.Header.toData(TypeImage, image.Data)
+	data.MimeType = mimeType
+	return data, nil
+}
+
+/*
+Audio is a binary audio clip, such as voice input or output
+for a conversation.
+*/
+type Audio struct {
+	Header
+	Data []byte
+}
+
+func (audio *Audio) FromData(data ArtifactData) error {
+	mimeType, err := mediaMimeType(data.MimeType, data.Data, TypeAudio)
+	if err != nil {
+		return err
+	}
+
+	audio.Header = headerFromData(data)
+	audio.MimeType = mimeType
+	audio.Data = data.Data
+	return nil
+}
+
+func (audio *Audio) GetData() (ArtifactData, error) {
+	mimeType, err := mediaMimeType(audio.MimeType, audio.Data, TypeAudio)
+	if err != nil {
+		return ArtifactData{}, err
+	}
+
+	data := audio.Header.toData(TypeAudio, audio.Data)
+	data.MimeType = mimeType
+	return data, nil
+}
+
+/*
+Text is a UTF-8 text document, such as plain text or
+markdown.
+*/
+type Text struct {
+	Header
+	Text string
+}
+
+func textMimeType(mimeType string) (string, error) {
+	if mimeType == "" {
+		return mimeTypeText, nil
+	}
+	if !strings.HasPrefix(baseMimeType(mimeType), "text/") {
+		return "", fmt.Errorf("text must have a text/* mime type, not %s", mimeType)
+	}
+	return mimeType, nil
+}
+
+func (text *Text) FromData(data ArtifactData) error {
+	if !utf8.Valid(data.Data) {
+		return fmt.Errorf("text must be valid UTF-8")
+	}
+	mimeType, err := textMimeType(data.MimeType)
+	if err != nil {
+		return err
+	}
+
+	text.Header = headerFromData(data)
+	text.MimeType = mimeType
+	text.Text = string(data.Data)
+	return nil
+}
+
+func (text *Text) GetData() (ArtifactData, error) {
+	if !utf8.ValidString(text.Text) {
+		return ArtifactData{}, fmt.Errorf("text must be valid UTF-8")
+	}
+	mimeType, err := textMimeType(text.MimeType)
+	if err != nil {
+		return ArtifactData{}, err
+	}
+
+	data := text.Header.toData(TypeText, []byte(text.Text))
+	data.MimeType = mimeType
+	return data, nil
+}
+
+/*
+JSON is an arbitrary JSON payload, such as structured output
+from a tool.
+*/
+type JSON struct {
+	Header
+	Payload json.RawMessage
+}
+
+func (payload *JSON) FromData(data ArtifactData) error {
+	if !json.Valid(data.Data) {
+		return fmt.Errorf("payload is not valid JSON")
+	}
+
+	payload.Header = headerFromData(data)
+	payload.MimeType = mimeTypeJSON
+	payload.Payload = json.RawMessage(data.Data)
+	return nil
+}
+
+func (payload *JSON) GetData() (ArtifactData, error) {
+	if !json.Valid(payload.Payload) {
+		return ArtifactData{}, fmt.Errorf("payload is not valid JSON")
+	}
+
+	data := payload.Header.toData(TypeJSON, payload.Payload)
+	data.MimeType = mimeTypeJSON
+	return data, nil
+}
+
+/*
+Link is a link to an http(s) URL, with an optional title.
+Its data is the URL itself, and the title is kept in the
+metadata.
+*/
+type Link struct {
+	Header
+	URL   string
+	Title string
+}
+
+func validateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("links must be absolute http or https urls")
+	}
+	return nil
+}
+
+func (link *Link) FromData(data ArtifactData) error {
+	raw := strings.TrimSpace(string(data.Data))
+	if err := validateURL(raw); err != nil {
+		return err
+	}
+
+	link.Header = headerFromData(data)
+	link.MimeType = mimeTypeLink
+	link.URL = raw
+	link.Title = data.Metadata[linkTitleKey]
+	return nil
+}
+
+func (link *Link) GetData() (ArtifactData, error) {
+	if err := validateURL(link.URL); err != nil {
+		return ArtifactData{}, err
+	}
+
+	data := link.Header.toData(TypeLink, []byte(link.URL))
+	data.MimeType = mimeTypeLink
+	if link.Title != "" {
+		metadata := map[string]string{}
+		for key, value := range link.Metadata {
+			metadata[key] = value
+		}
+		metadata[linkTitleKey] = link.Title
+		data.Metadata = metadata
+	}
+	return data, nil
+}
+
+// Markdown links, ie [Coppermind](https://coppermind.dev)
+var markdownLinkPattern = regexp.MustCompile(`\[([^\[\]]*)\]\((https?://[^\s)]+)\)`)
+
+/*
+ExtractLinks finds the markdown links within the given text
+and returns them as link artifacts, once per URL, in the
+order they first appear. The artifacts are not given an ID.
+*/
+func ExtractLinks(text string) []*ArtifactData {
+	links := []*ArtifactData{}
+	seen := map[string]bool{}
+
+	for _, match := range markdownLinkPattern.FindAllStringSubmatch(text, -1) {
+		title, address := strings.TrimSpace(match[1]), match[2]
+		if seen[address] {
+			continue
+		}
+
+		link := &Link{
+			Header: Header{CreatedAt: time.Now()},
+			URL:    address,
+			Title:  title,
+		}
+		data, err := link.GetData()
+		if err != nil {
+			continue
+		}
+		seen[address] = true
+		links = append(links, &data)
+	}
+
+	return links
+}
+
+/*
+Document is a document to be read, such as a PDF or a
+markdown or plain text file, that can be indexed so that the
+agent can answer questions about it.
+*/
+type Document struct {
+	Header
+	Data []byte
+}
+
+func documentMimeType(mimeType string, content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", fmt.Errorf("document has no data")
+	}
+
+	if mimeType == "" {
+		mimeType = http.DetectContentType(content)
+	}
+	base := baseMimeType(mimeType)
+	if base != mimeTypePDF && !strings.HasPrefix(base, "text/") {
+		return "", fmt.Errorf("documents must be pdf or text/*, not %s", mimeType)
+	}
+	if strings.HasPrefix(base, "text/") && !utf8.Valid(content) {
+		return "", fmt.Errorf("text documents must be valid UTF-8")
+	}
+
+	return mimeType, nil
+}
+
+func (document *Document) FromData(data ArtifactData) error {
+	mimeType, err := documentMimeType(data.MimeType, data.Data)
+	if err != nil {
+		return err
+	}
+
+	document.Header = headerFromData(data)
+	document.MimeType = mimeType
+	document.Data = data.Data
+	return nil
+}
+
+func (document *Document) GetData() (ArtifactData, error) {
+	mimeType, err := documentMimeType(document.MimeType, document.Data)
+	if err != nil {
+		return ArtifactData{}, err
+	}
+
+	data := document.Header.toData(TypeDocument, document.Data)
+	data.MimeType = mimeType
+	return data, nil
+}
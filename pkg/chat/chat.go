@@ -1,6 +1,7 @@
 package chat
 
 import (
+	"bytes"
 	"encoding/json"
 	"strings"
 	"time"
@@ -50,7 +51,56 @@ type Message struct {
 	From         string                    `json:"from,omitempty" db:"from"`
 	Content      string                    `json:"content,omitempty" db:"content"`
 	Artifacts    []*artifacts.ArtifactData `json:"artifacts,omitempty"`
+	ToolCalls    []*ToolCall               `json:"tool_calls,omitempty" db:"tool_calls"`
 	CreatedAt    time.Time                 `json:"created_at,omitempty" db:"created_at"`
+	AgentVersion int                       `json:"agent_version,omitempty" db:"agent_version"`
+	Experiment   string                    `json:"experiment,omitempty" db:"experiment"`
+	Variant      string                    `json:"variant,omitempty" db:"variant"`
+	Duration     int64                     `json:"duration_ms,omitempty" db:"duration_ms"`
+}
+
+/*
+ToolCall is a single request by the agent to use a tool,
+and the result (or error) of coppermind executing it. A
+message with tool calls is a step the agent took while
+forming its response, not a response itself.
+*/
+type ToolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+func (call *ToolCall) Equal(other *ToolCall) bool {
+	return call.ID == other.ID &&
+		call.Name == other.Name &&
+		compactJSON(call.Arguments) == compactJSON(other.Arguments) &&
+		call.Result == other.Result &&
+		call.Error == other.Error
+}
+
+// compactJSON drops insignificant whitespace, which isn't
+// kept when arguments are stored
+func compactJSON(raw json.RawMessage) string {
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, raw); err != nil {
+		return string(raw)
+	}
+	return compacted.String()
+}
+
+/*
+String describes the tool call and its outcome, ie
+"calculator({"expression": "2+2"}) -> 4"
+*/
+func (call *ToolCall) String() string {
+	outcome := call.Result
+	if call.Error != "" {
+		outcome = "error: " + call.Error
+	}
+	return call.Name + "(" + string(call.Arguments) + ") -> " + outcome
 }
 
 func (msg *Message) Equal(other *Message) bool {
@@ -82,6 +132,15 @@ func (msg *Message) Equal(other *Message) bool {
 		}
 	}
 
+	if len(msg.ToolCalls) != len(other.ToolCalls) {
+		return false
+	}
+	for index, call := range msg.ToolCalls {
+		if !call.Equal(other.ToolCalls[index]) {
+			return false
+		}
+	}
+
 	return msg.ID == other.ID &&
 		msg.Agent == other.Agent &&
 		msg.User == other.User &&
@@ -120,32 +179,41 @@ func (msg *Message) JSON() (string, error) {
 
 /*
 The Response struct is used to handle all the generated
-output from the LLM. For now, that's just the Content,
-and can ultimately be converted to a resulting Message.
-
-In the future, this will include types of Artifacts,
-such as generated images, search results, PDFs, sound
-files for generated voices, etc. The resulting service
-that receives the response has to then determine if it
-can and how it should utilize responses with artifacts.
+output from the LLM - its Content and any Artifacts it
+produced, such as links it cited, generated images, or
+sound files for generated voices. It is ultimately
+converted to a resulting Message to be stored.
 */
 type Response struct {
-	Content   string                   `json:"content,omitempty"`
-	Artifacts []artifacts.ArtifactData `json:"artifacts,omitempty"`
+	Content   string                    `json:"content,omitempty"`
+	Artifacts []*artifacts.ArtifactData `json:"artifacts,omitempty"`
 }
 
 /*
 ToMessage takes a response and converts it to a message
-given other required information.
+given other required information. The response's
+artifacts are carried over and attached to the new
+message, being given an ID if they lack one.
 */
 func (response *Response) ToMessage(user string, agent string, conversation string) *Message {
-	return &Message{
+	msg := &Message{
 		ID:           uuid.New().String(),
 		Agent:        agent,
 		User:         user,
 		From:         agent,
 		Content:      response.Content,
+		Artifacts:    []*artifacts.ArtifactData{},
 		CreatedAt:    time.Now(),
 		Conversation: conversation,
 	}
+
+	for _, artifact := range response.Artifacts {
+		if artifact.ID == "" {
+			artifact.ID = uuid.New().String()
+		}
+		artifact.Message = msg.ID
+		msg.Artifacts = append(msg.Artifacts, artifact)
+	}
+
+	return msg
 }
@@ -0,0 +1,224 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The ratings a user may give an agent's reply
+const (
+	RatingUp   = 1
+	RatingDown = -1
+)
+
+// ValidRating is whether rating is a known rating
+func ValidRating(rating int) bool {
+	return rating == RatingUp || rating == RatingDown
+}
+
+// Limits on what a user may say alongside a rating
+const (
+	MaxFeedbackCommentLength = 2000
+	MaxFeedbackTags          = 10
+	MaxFeedbackTagLength     = 32
+)
+
+/*
+Feedback is a user's rating of an agent's reply - a thumbs
+up or down - with an optional comment and tags saying why,
+ie "inaccurate" or "too long". A user has at most one
+rating of each reply.
+*/
+type Feedback struct {
+	Message   string    `json:"message,omitempty" db:"message"`
+	Agent     string    `json:"agent,omitempty" db:"agent"`
+	User      string    `json:"user,omitempty" db:"user"`
+	Rating    int       `json:"rating" db:"rating"`
+	Comment   string    `json:"comment,omitempty" db:"comment"`
+	Tags      []string  `json:"tags,omitempty" db:"tags"`
+	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+/*
+Validate checks the rating, comment, and tags of feedback.
+Tags are trimmed, lowercased, and deduplicated as they are
+checked, so that "Too long" and "too long " count as one.
+*/
+func (feedback *Feedback) Validate() error {
+	if !ValidRating(feedback.Rating) {
+		return fmt.Errorf("rating must be %d or %d", RatingUp, RatingDown)
+	}
+
+	feedback.Comment = strings.TrimSpace(feedback.Comment)
+	if len(feedback.Comment) > MaxFeedbackCommentLength {
+		return fmt.Errorf("comment can't be longer than %d characters", MaxFeedbackCommentLength)
+	}
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range feedback.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return fmt.Errorf("tags can't be empty")
+		} else if len(tag) > MaxFeedbackTagLength {
+			return fmt.Errorf("tag %s is longer than %d characters", tag, MaxFeedbackTagLength)
+		} else if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > MaxFeedbackTags {
+		return fmt.Errorf("feedback can have at most %d tags", MaxFeedbackTags)
+	}
+	feedback.Tags = tags
+
+	return nil
+}
+
+// HasTag is whether the feedback was given the tag
+func (feedback *Feedback) HasTag(tag string) bool {
+	for _, own := range feedback.Tags {
+		if own == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// The periods feedback can be aggregated over
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// ValidInterval is whether interval is a known period
+func ValidInterval(interval string) bool {
+	return interval == IntervalDay || interval == IntervalWeek || interval == IntervalMonth
+}
+
+/*
+periodStart returns the start of the period containing t, in
+UTC. Weeks start on Monday.
+*/
+func periodStart(t time.Time, interval string) time.Time {
+	year, month, day := t.UTC().Date()
+	switch interval {
+	case IntervalWeek:
+		start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	case IntervalMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func nextPeriod(start time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+/*
+FeedbackTotals is an aggregated count of ratings.
+PositiveRate is the share of ratings that were positive.
+*/
+type FeedbackTotals struct {
+	Ratings      int     `json:"ratings"`
+	Positive     int     `json:"positive"`
+	Negative     int     `json:"negative"`
+	Comments     int     `json:"comments"`
+	PositiveRate float64 `json:"positive_rate"`
+}
+
+func (totals *FeedbackTotals) add(feedback *Feedback) {
+	totals.Ratings++
+	if feedback.Rating > 0 {
+		totals.Positive++
+	} else if feedback.Rating < 0 {
+		totals.Negative++
+	}
+	if feedback.Comment != "" {
+		totals.Comments++
+	}
+	totals.PositiveRate = float64(totals.Positive) / float64(totals.Ratings)
+}
+
+// FeedbackPeriod is the feedback given within one period
+type FeedbackPeriod struct {
+	Start time.Time `json:"start"`
+	FeedbackTotals
+}
+
+/*
+FeedbackReport is the feedback an agent was given over a
+time range, in total, per period of the given interval, and
+per tag. Every period in the range is reported, including
+those without any feedback.
+*/
+type FeedbackReport struct {
+	Agent    string                     `json:"agent,omitempty"`
+	From     time.Time                  `json:"from"`
+	To       time.Time                  `json:"to"`
+	Interval string                     `json:"interval"`
+	Totals   FeedbackTotals             `json:"totals"`
+	Periods  []*FeedbackPeriod          `json:"periods"`
+	Tags     map[string]*FeedbackTotals `json:"tags"`
+}
+
+func NewFeedbackReport(
+	agent string,
+	from time.Time,
+	to time.Time,
+	interval string,
+	feedback []*Feedback,
+) *FeedbackReport {
+	report := &FeedbackReport{
+		Agent:    agent,
+		From:     from,
+		To:       to,
+		Interval: interval,
+		Periods:  []*FeedbackPeriod{},
+		Tags:     map[string]*FeedbackTotals{},
+	}
+
+	periods := map[time.Time]*FeedbackPeriod{}
+	for start := periodStart(from, interval); !start.After(to); start = nextPeriod(start, interval) {
+		period := &FeedbackPeriod{Start: start}
+		periods[start] = period
+		report.Periods = append(report.Periods, period)
+	}
+
+	for _, rating := range feedback {
+		report.Totals.add(rating)
+
+		start := periodStart(rating.CreatedAt, interval)
+		if _, ok := periods[start]; !ok {
+			periods[start] = &FeedbackPeriod{Start: start}
+			report.Periods = append(report.Periods, periods[start])
+		}
+		periods[start].add(rating)
+
+		for _, tag := range rating.Tags {
+			if _, ok := report.Tags[tag]; !ok {
+				report.Tags[tag] = &FeedbackTotals{}
+			}
+			report.Tags[tag].add(rating)
+		}
+	}
+
+	sort.Slice(report.Periods, func(i, j int) bool {
+		return report.Periods[i].Start.Before(report.Periods[j].Start)
+	})
+
+	return report
+}
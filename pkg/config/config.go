@@ -1,25 +1,93 @@
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/hlfshell/coppermind/pkg/usage"
+)
+
 type Config struct {
-	Chat    ChatConfig    `json:"chat"`
-	Summary SummaryConfig `json:"summary"`
+	Chat      ChatConfig      `json:"chat"`
+	Summary   SummaryConfig   `json:"summary"`
+	Usage     UsageConfig     `json:"usage"`
+	Quota     QuotaConfig     `json:"quota"`
+	LLM       LLMConfig       `json:"llm"`
+	Blob      BlobConfig      `json:"blob"`
+	Documents DocumentsConfig `json:"documents"`
+	Knowledge KnowledgeConfig `json:"knowledge"`
+	Backup    BackupConfig    `json:"backup"`
+	Agents    AgentsConfig    `json:"agents"`
+	Prompts   PromptsConfig   `json:"prompts"`
 }
 
 var DefaultConfig Config = Config{
-	Chat:    DefaultChatConfig,
-	Summary: DefaultSummaryConfig,
+	Chat:      DefaultChatConfig,
+	Summary:   DefaultSummaryConfig,
+	Usage:     DefaultUsageConfig,
+	Quota:     DefaultQuotaConfig,
+	LLM:       DefaultLLMConfig,
+	Blob:      DefaultBlobConfig,
+	Documents: DefaultDocumentsConfig,
+	Knowledge: DefaultKnowledgeConfig,
+	Backup:    DefaultBackupConfig,
+	Agents:    DefaultAgentsConfig,
+	Prompts:   DefaultPromptsConfig,
+}
+
+/*
+LoadConfig reads a JSON configuration file over the defaults,
+so that it need only set what it changes. A path of "" is
+just the defaults.
+*/
+func LoadConfig(path string) (*Config, error) {
+	// The defaults are copied through JSON so that the file
+	// can't change the maps they share
+	defaults, err := json.Marshal(DefaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	err = json.Unmarshal(defaults, &config)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return &config, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config %s: %w", path, err)
+	}
+
+	return &config, nil
 }
 
 type ChatConfig struct {
 	ConversationMaintainanceDurationSeconds int `json:"conversation_maintainance_duration_seconds"`
 	MaxConversationIdleTimeSeconds          int `json:"max_conversation_idle_time_seconds"`
 	MaxSummariesToInclude                   int `json:"max_summaries_to_include"`
+	MaxToolSteps                            int `json:"max_tool_steps"`
+	ToolTimeoutSeconds                      int `json:"tool_timeout_seconds"`
 }
 
 var DefaultChatConfig ChatConfig = ChatConfig{
 	ConversationMaintainanceDurationSeconds: 5,
 	MaxConversationIdleTimeSeconds:          6,
 	MaxSummariesToInclude:                   25,
+	MaxToolSteps:                            5,
+	ToolTimeoutSeconds:                      10,
 }
 
 type SummaryConfig struct {
@@ -35,3 +103,219 @@ var DefaultSummaryConfig SummaryConfig = SummaryConfig{
 	MinConversationTimeToWaitSeconds: 5,
 	MinMessagesToForceSummarization:  15,
 }
+
+type UsageConfig struct {
+	// Prices is keyed by model name
+	Prices map[string]usage.Price `json:"prices"`
+}
+
+var DefaultUsageConfig UsageConfig = UsageConfig{
+	Prices: map[string]usage.Price{
+		"gpt-3.5-turbo": {
+			PromptPer1K:     0.0015,
+			CompletionPer1K: 0.002,
+		},
+		"gpt-4": {
+			PromptPer1K:     0.03,
+			CompletionPer1K: 0.06,
+		},
+	},
+}
+
+/*
+QuotaConfig sets the request and token limits enforced on
+messages sent to agents. Users and Agents are keyed by
+their IDs and override DefaultUser and DefaultAgent
+respectively. Global limits apply across all users and
+agents combined.
+*/
+type QuotaConfig struct {
+	Global       quota.Limits            `json:"global"`
+	DefaultUser  quota.Limits            `json:"default_user"`
+	DefaultAgent quota.Limits            `json:"default_agent"`
+	Users        map[string]quota.Limits `json:"users"`
+	Agents       map[string]quota.Limits `json:"agents"`
+}
+
+// By default there are no limits
+var DefaultQuotaConfig QuotaConfig = QuotaConfig{
+	Users:  map[string]quota.Limits{},
+	Agents: map[string]quota.Limits{},
+}
+
+func (config *QuotaConfig) UserLimits(user string) quota.Limits {
+	if limits, ok := config.Users[user]; ok {
+		return limits
+	}
+	return config.DefaultUser
+}
+
+func (config *QuotaConfig) AgentLimits(agent string) quota.Limits {
+	if limits, ok := config.Agents[agent]; ok {
+		return limits
+	}
+	return config.DefaultAgent
+}
+
+/*
+LLMConfig sets the LLM backends (providers) available and
+how each type of call is routed across them. Providers are
+keyed by a name of your choosing; Routes are keyed by call
+type (chat, continuance, summarize, learn, embed) or "default"
+for any call type not otherwise set.
+*/
+type LLMConfig struct {
+	Providers map[string]ProviderConfig `json:"providers"`
+	Routes    map[string]RouteConfig    `json:"routes"`
+}
+
+/*
+ProviderConfig is a single LLM backend. Type is the kind of
+backend (openai or mock). The API key is read from APIKey
+if set, otherwise from the environment variable APIKeyEnv.
+*/
+type ProviderConfig struct {
+	Type      string `json:"type"`
+	APIKey    string `json:"api_key,omitempty"`
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+}
+
+/*
+RouteConfig is the ordered list of providers to try for a
+call type; the first is the primary and the rest are
+fallbacks. TimeoutSeconds of 0 waits indefinitely.
+*/
+type RouteConfig struct {
+	Providers      []string `json:"providers"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+var DefaultLLMConfig LLMConfig = LLMConfig{
+	Providers: map[string]ProviderConfig{
+		"openai": {
+			Type:      "openai",
+			APIKeyEnv: "OPENAI_API_KEY",
+		},
+	},
+	Routes: map[string]RouteConfig{
+		"default": {
+			Providers:      []string{"openai"},
+			TimeoutSeconds: 60,
+		},
+	},
+}
+
+/*
+BlobConfig sets where the data of artifacts is kept. Type is
+database (in the artifacts table itself), local (files under
+Path), or s3 (an S3 compatible bucket).
+*/
+type BlobConfig struct {
+	Type string   `json:"type"`
+	Path string   `json:"path,omitempty"`
+	S3   S3Config `json:"s3,omitempty"`
+}
+
+/*
+S3Config is an S3 compatible bucket, ie AWS S3 or MinIO.
+Keys are read from AccessKey and SecretKey if set, otherwise
+from the environment variables AccessKeyEnv and
+SecretKeyEnv. Prefix is prepended to every object's key.
+*/
+type S3Config struct {
+	Endpoint     string `json:"endpoint"`
+	Bucket       string `json:"bucket"`
+	Region       string `json:"region,omitempty"`
+	Prefix       string `json:"prefix,omitempty"`
+	AccessKey    string `json:"access_key,omitempty"`
+	AccessKeyEnv string `json:"access_key_env,omitempty"`
+	SecretKey    string `json:"secret_key,omitempty"`
+	SecretKeyEnv string `json:"secret_key_env,omitempty"`
+}
+
+var DefaultBlobConfig BlobConfig = BlobConfig{
+	Type: "local",
+	Path: "artifacts",
+}
+
+/*
+DocumentsConfig sets how document artifacts are split into
+chunks for retrieval, in characters, and how many of the
+chunks most relevant to a message are included with it.
+*/
+type DocumentsConfig struct {
+	ChunkSize    int `json:"chunk_size"`
+	ChunkOverlap int `json:"chunk_overlap"`
+	MaxChunks    int `json:"max_chunks"`
+}
+
+var DefaultDocumentsConfig DocumentsConfig = DocumentsConfig{
+	ChunkSize:    1000,
+	ChunkOverlap: 150,
+	MaxChunks:    4,
+}
+
+/*
+KnowledgeConfig sets how often each agent and user's
+knowledge is maintained, and how many current facts they
+must have before an LLM is asked to compress them. Exact
+duplicates are merged regardless of the threshold.
+*/
+type KnowledgeConfig struct {
+	MaintenanceIntervalSeconds int `json:"maintenance_interval_seconds"`
+	CompressionThreshold       int `json:"compression_threshold"`
+}
+
+var DefaultKnowledgeConfig KnowledgeConfig = KnowledgeConfig{
+	MaintenanceIntervalSeconds: 3600,
+	CompressionThreshold:       50,
+}
+
+/*
+BackupConfig sets how often the database is snapshotted into
+Directory while running, and how many of the latest
+snapshots are kept. An IntervalSeconds of 0 disables
+snapshots, as does a store that can't take them.
+*/
+type BackupConfig struct {
+	Directory       string `json:"directory"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	Retain          int    `json:"retain"`
+}
+
+var DefaultBackupConfig BackupConfig = BackupConfig{
+	Directory:       "backups",
+	IntervalSeconds: 0,
+	Retain:          7,
+}
+
+/*
+AgentsConfig sets where agent definitions are synced into the
+store from on startup. The bundled agents are only added if
+missing, and only if Seed is set; definitions in Directory
+are applied over whatever is stored. A Directory that doesn't
+exist is skipped.
+*/
+type AgentsConfig struct {
+	Directory string `json:"directory"`
+	Seed      bool   `json:"seed"`
+}
+
+var DefaultAgentsConfig AgentsConfig = AgentsConfig{
+	Directory: "agents",
+	Seed:      true,
+}
+
+/*
+PromptsConfig sets the directory prompt templates are loaded
+from on startup, overriding the bundled templates. Templates
+stored through the API override those in turn. A Directory
+that doesn't exist is skipped.
+*/
+type PromptsConfig struct {
+	Directory string `json:"directory"`
+}
+
+var DefaultPromptsConfig PromptsConfig = PromptsConfig{
+	Directory: "prompts",
+}
@@ -0,0 +1,118 @@
+package documents
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+/*
+Chunk is a passage of a document artifact, embedded so that
+it can be retrieved when relevant to a message. Chunks are
+indexed either for the conversation the document was shared
+in, or - if Conversation is empty - for the user's library,
+making them available in all of their conversations.
+*/
+type Chunk struct {
+	ID           string    `json:"id,omitempty" db:"id"`
+	Document     string    `json:"document,omitempty" db:"document"`
+	Filename     string    `json:"filename,omitempty" db:"filename"`
+	Agent        string    `json:"agent,omitempty" db:"agent"`
+	User         string    `json:"user,omitempty" db:"user"`
+	Conversation string    `json:"conversation,omitempty" db:"conversation"`
+	Index        int       `json:"index" db:"idx"`
+	Content      string    `json:"content,omitempty" db:"content"`
+	Model        string    `json:"model,omitempty" db:"model"`
+	Embedding    []float32 `json:"embedding,omitempty" db:"embedding"`
+	CreatedAt    time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+func (chunk *Chunk) Equal(other *Chunk) bool {
+	createdAtDifference := chunk.CreatedAt.Sub(other.CreatedAt)
+	if createdAtDifference < 0 {
+		createdAtDifference = -createdAtDifference
+	}
+
+	if len(chunk.Embedding) != len(other.Embedding) {
+		return false
+	}
+	for index, value := range chunk.Embedding {
+		if other.Embedding[index] != value {
+			return false
+		}
+	}
+
+	return chunk.ID == other.ID &&
+		chunk.Document == other.Document &&
+		chunk.Filename == other.Filename &&
+		chunk.Agent == other.Agent &&
+		chunk.User == other.User &&
+		chunk.Conversation == other.Conversation &&
+		chunk.Index == other.Index &&
+		chunk.Content == other.Content &&
+		chunk.Model == other.Model &&
+		createdAtDifference < time.Second
+}
+
+/*
+Source names where the chunk came from, ie
+"notes.md (part 2)".
+*/
+func (chunk *Chunk) Source() string {
+	name := chunk.Filename
+	if name == "" {
+		name = "document " + chunk.Document
+	}
+	return fmt.Sprintf("%s (part %d)", name, chunk.Index+1)
+}
+
+/*
+Citation is a reference from a response to a chunk it drew
+on. Number is the [n] marker used for it in the response.
+*/
+type Citation struct {
+	Number   int    `json:"number"`
+	Document string `json:"document"`
+	Filename string `json:"filename,omitempty"`
+	Chunk    string `json:"chunk"`
+	Index    int    `json:"index"`
+	Excerpt  string `json:"excerpt"`
+}
+
+// The longest excerpt of a chunk kept in a citation
+const maxExcerptLength = 200
+
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+/*
+Cite returns a citation for each of the sources referred to
+by an [n] marker in the content, where [1] is the first
+source, in the order they are first referred to.
+*/
+func Cite(content string, sources []*Chunk) []Citation {
+	citations := []Citation{}
+	cited := map[int]bool{}
+	for _, match := range citationMarker.FindAllStringSubmatch(content, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || number < 1 || number > len(sources) || cited[number] {
+			continue
+		}
+		cited[number] = true
+
+		chunk := sources[number-1]
+		excerpt := []rune(chunk.Content)
+		if len(excerpt) > maxExcerptLength {
+			excerpt = append(excerpt[:maxExcerptLength], '…')
+		}
+		citations = append(citations, Citation{
+			Number:   number,
+			Document: chunk.Document,
+			Filename: chunk.Filename,
+			Chunk:    chunk.ID,
+			Index:    chunk.Index,
+			Excerpt:  string(excerpt),
+		})
+	}
+	return citations
+}
@@ -0,0 +1,144 @@
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	assert.Empty(t, Split("   ", 100, 10))
+	assert.Equal(t, []string{"short"}, Split("short", 100, 10))
+
+	paragraphs := []string{}
+	for index := 0; index < 10; index++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Paragraph %d is about the number %d.", index, index))
+	}
+	text := strings.Join(paragraphs, "\n\n")
+
+	chunks := Split(text, 100, 20)
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 100)
+		assert.Equal(t, strings.TrimSpace(chunk), chunk)
+	}
+
+	// Every paragraph survives whole in some chunk
+	for _, paragraph := range paragraphs {
+		found := false
+		for _, chunk := range chunks {
+			if strings.Contains(chunk, paragraph) {
+				found = true
+				break
+			}
+		}
+		assert.True(t, found, paragraph)
+	}
+
+	// Text without any breaks is still split
+	chunks = Split(strings.Repeat("a", 250), 100, 10)
+	assert.Len(t, chunks, 3)
+}
+
+func TestRank(t *testing.T) {
+	embedder := NewHashEmbedder()
+	texts := []string{
+		"Cooper the dog loves to chase squirrels in the park",
+		"The quarterly budget review is scheduled for Tuesday",
+		"Bake the bread at 220 degrees for forty minutes",
+	}
+	embeddings := embedder.Embed(texts)
+
+	chunks := []*Chunk{}
+	for index, text := range texts {
+		chunks = append(chunks, &Chunk{
+			ID:        fmt.Sprint(index),
+			Content:   text,
+			Model:     embeddings[index].Model,
+			Embedding: embeddings[index].Vector,
+		})
+	}
+	// Chunks from other models are never compared
+	chunks = append(chunks, &Chunk{ID: "other", Model: "other", Embedding: embeddings[0].Vector})
+
+	query := embedder.Embed([]string{"When is the budget review?"})[0]
+	ranked := Rank(chunks, query, 2)
+	require.NotEmpty(t, ranked)
+	assert.Equal(t, "1", ranked[0].ID)
+	for _, chunk := range ranked {
+		assert.NotEqual(t, "other", chunk.ID)
+	}
+
+	assert.InDelta(t, 1.0, Similarity(query.Vector, query.Vector), 0.0001)
+	assert.Equal(t, 0.0, Similarity(query.Vector, []float32{1}))
+}
+
+func TestExtractText(t *testing.T) {
+	text, err := ExtractText(&artifacts.ArtifactData{
+		Type:     artifacts.TypeText,
+		MimeType: "text/markdown",
+		Data:     []byte("# Notes"),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "# Notes", text)
+
+	_, err = ExtractText(&artifacts.ArtifactData{
+		Type:     artifacts.TypeImage,
+		MimeType: "image/png",
+		Data:     []byte{1},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestExtractPDFText(t *testing.T) {
+	page := []byte("BT /F1 12 Tf 72 712 Td (Cooper is a \\(very\\) good dog.) Tj T* [(He was born in ) -20 (2018.)] TJ ET")
+
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write([]byte("BT (Caf\\351 hours are 9 to 5.) Tj ET"))
+	writer.Close()
+
+	pdf := bytes.Buffer{}
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	pdf.WriteString(fmt.Sprintf("4 0 obj\n<< /Length %d >>\nstream\n", len(page)))
+	pdf.Write(page)
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString(fmt.Sprintf("5 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len()))
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF")
+
+	text, err := ExtractText(&artifacts.ArtifactData{
+		Type:     artifacts.TypeDocument,
+		MimeType: "application/pdf",
+		Data:     pdf.Bytes(),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "Cooper is a (very) good dog.\nHe was born in 2018.\nCafé hours are 9 to 5.", text)
+
+	_, err = ExtractPDFText([]byte("%PDF-1.4\n%%EOF"))
+	assert.NotNil(t, err)
+	_, err = ExtractPDFText([]byte("not a pdf"))
+	assert.NotNil(t, err)
+}
+
+func TestCite(t *testing.T) {
+	sources := []*Chunk{
+		{ID: "a", Document: "notes", Filename: "notes.md", Content: "Cooper is a dog"},
+		{ID: "b", Document: "notes", Filename: "notes.md", Index: 1, Content: strings.Repeat("b", 300)},
+	}
+
+	citations := Cite("Cooper is a dog [1]. He is also very good [2][1]. See [3].", sources)
+	require.Len(t, citations, 2)
+	assert.Equal(t, Citation{Number: 1, Document: "notes", Filename: "notes.md", Chunk: "a", Excerpt: "Cooper is a dog"}, citations[0])
+	assert.Equal(t, 2, citations[1].Number)
+	assert.Equal(t, 1, citations[1].Index)
+	assert.Len(t, []rune(citations[1].Excerpt), maxExcerptLength+1)
+
+	assert.Empty(t, Cite("No sources used", sources))
+}
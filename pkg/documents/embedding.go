@@ -0,0 +1,129 @@
+package documents
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+Embedding is a vector representation of a piece of text.
+Embeddings are only comparable if they were produced by the
+same Model.
+*/
+type Embedding struct {
+	Model  string
+	Vector []float32
+}
+
+/*
+Similarity is the cosine similarity of two vectors, from -1
+to 1. Vectors of differing lengths have no similarity.
+*/
+func Similarity(a []float32, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for index := range a {
+		dot += float64(a[index]) * float64(b[index])
+		normA += float64(a[index]) * float64(a[index])
+		normB += float64(b[index]) * float64(b[index])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+/*
+Rank returns up to limit chunks most similar to the query,
+most similar first. Chunks embedded by a different model
+than the query, or with no similarity to it, are skipped.
+*/
+func Rank(chunks []*Chunk, query Embedding, limit int) []*Chunk {
+	type scored struct {
+		chunk *Chunk
+		score float64
+	}
+
+	candidates := []scored{}
+	for _, chunk := range chunks {
+		if chunk.Model != query.Model {
+			continue
+		}
+		score := Similarity(chunk.Embedding, query.Vector)
+		if score <= 0 {
+			continue
+		}
+		candidates = append(candidates, scored{chunk: chunk, score: score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	ranked := []*Chunk{}
+	for _, candidate := range candidates {
+		if limit > 0 && len(ranked) >= limit {
+			break
+		}
+		ranked = append(ranked, candidate.chunk)
+	}
+	return ranked
+}
+
+// HashModel is the model name of embeddings made by
+// HashEmbedder
+const HashModel = "hash"
+
+// hashDimensions is the length of HashEmbedder's vectors
+const hashDimensions = 512
+
+/*
+HashEmbedder embeds text locally by hashing its words into
+a fixed length vector. It captures word overlap rather than
+meaning, but needs no model and is deterministic, so it is
+used when no LLM backend can provide embeddings.
+*/
+type HashEmbedder struct{}
+
+func NewHashEmbedder() *HashEmbedder {
+	return &HashEmbedder{}
+}
+
+func (embedder *HashEmbedder) Embed(texts []string) []Embedding {
+	embeddings := []Embedding{}
+	for _, text := range texts {
+		vector := make([]float32, hashDimensions)
+		for _, word := range words(text) {
+			hash := fnv.New32a()
+			hash.Write([]byte(word))
+			vector[hash.Sum32()%hashDimensions]++
+		}
+		embeddings = append(embeddings, Embedding{
+			Model:  HashModel,
+			Vector: vector,
+		})
+	}
+	return embeddings
+}
+
+/*
+words splits text into lower case words, dropping
+punctuation and words too short to carry meaning.
+*/
+func words(text string) []string {
+	found := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) > 2 {
+			found = append(found, word)
+		}
+	}
+	return found
+}
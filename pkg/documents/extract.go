@@ -0,0 +1,54 @@
+package documents
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+)
+
+// LibraryKey is the artifact metadata key which, when set to
+// "true", indexes a document into the user's library rather
+// than only its conversation
+const LibraryKey = "library"
+
+const mimeTypePDF = "application/pdf"
+
+/*
+IsDocument returns whether an artifact is something that can
+be indexed for retrieval - a document or text artifact.
+*/
+func IsDocument(artifact *artifacts.ArtifactData) bool {
+	return artifact.Type == artifacts.TypeDocument || artifact.Type == artifacts.TypeText
+}
+
+/*
+InLibrary returns whether a document artifact belongs in the
+user's library rather than only its conversation.
+*/
+func InLibrary(artifact *artifacts.ArtifactData) bool {
+	return artifact.Metadata[LibraryKey] == "true"
+}
+
+/*
+ExtractText returns the text of a document artifact. Text
+documents (ie plain text or markdown) are returned as is,
+and the text of PDFs is extracted on a best effort basis.
+*/
+func ExtractText(artifact *artifacts.ArtifactData) (string, error) {
+	mimeType, _, _ := strings.Cut(artifact.MimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+
+	switch {
+	case mimeType == mimeTypePDF:
+		return ExtractPDFText(artifact.Data)
+	case strings.HasPrefix(mimeType, "text/"), mimeType == "":
+		if !utf8.Valid(artifact.Data) {
+			return "", fmt.Errorf("document is not valid UTF-8 text")
+		}
+		return string(artifact.Data), nil
+	default:
+		return "", fmt.Errorf("text can not be extracted from %s documents", artifact.MimeType)
+	}
+}
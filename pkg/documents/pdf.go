@@ -0,0 +1,189 @@
+package documents
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	pdfStream = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfEnd    = []byte("endstream")
+)
+
+/*
+ExtractPDFText extracts the text drawn by the content
+streams of a PDF. Only uncompressed and Flate compressed
+streams are read, and only text in literal strings is found,
+so PDFs using other encodings (ie scanned pages, or fonts
+with custom character maps) may yield little or no text.
+*/
+func ExtractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("document is not a pdf")
+	}
+
+	text := strings.Builder{}
+	for _, match := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dictionary := string(data[match[2]:match[3]])
+		start := match[1]
+		end := bytes.Index(data[start:], pdfEnd)
+		if end < 0 {
+			break
+		}
+		content := data[start : start+end]
+
+		// Streams with other filters (ie images) are skipped
+		if strings.Contains(dictionary, "/FlateDecode") {
+			reader, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			content, err = io.ReadAll(reader)
+			if err != nil && len(content) == 0 {
+				continue
+			}
+		} else if strings.Contains(dictionary, "/Filter") {
+			continue
+		}
+
+		text.WriteString(pdfContentText(content))
+	}
+
+	extracted := strings.TrimSpace(text.String())
+	if extracted == "" {
+		return "", fmt.Errorf("no text could be extracted from the pdf")
+	}
+	return extracted, nil
+}
+
+/*
+pdfContentText reads the text shown by the operators of a
+content stream - strings given to Tj, TJ, ' and " - starting
+a new line on the operators that move to one.
+*/
+func pdfContentText(content []byte) string {
+	text := strings.Builder{}
+	line := strings.Builder{}
+	flush := func() {
+		if strings.TrimSpace(line.String()) != "" {
+			text.WriteString(strings.TrimSpace(line.String()))
+			text.WriteString("\n")
+		}
+		line.Reset()
+	}
+
+	// Strings are collected until the operator that uses them
+	pending := []string{}
+	for index := 0; index < len(content); {
+		switch char := content[index]; {
+		case char == '(':
+			value, next := pdfString(content, index)
+			pending = append(pending, value)
+			index = next
+		case char == '%':
+			for index < len(content) && content[index] != '\n' && content[index] != '\r' {
+				index++
+			}
+		case isPDFOperatorChar(char):
+			start := index
+			for index < len(content) && isPDFOperatorChar(content[index]) {
+				index++
+			}
+			switch string(content[start:index]) {
+			case "Tj", "TJ":
+				line.WriteString(strings.Join(pending, ""))
+			case "'", "\"":
+				flush()
+				line.WriteString(strings.Join(pending, ""))
+			case "T*", "Td", "TD", "ET":
+				flush()
+			}
+			pending = pending[:0]
+		default:
+			index++
+		}
+	}
+	flush()
+
+	return text.String()
+}
+
+func isPDFOperatorChar(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || char == '*' || char == '\'' || char == '"'
+}
+
+/*
+pdfString reads the literal string starting at the "(" at
+start, returning it and the index just past its closing ")".
+*/
+func pdfString(content []byte, start int) (string, int) {
+	value := strings.Builder{}
+	depth := 0
+	for index := start; index < len(content); index++ {
+		char := content[index]
+		switch char {
+		case '(':
+			depth++
+			if depth > 1 {
+				value.WriteByte(char)
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return value.String(), index + 1
+			}
+			value.WriteByte(char)
+		case '\\':
+			index++
+			if index >= len(content) {
+				break
+			}
+			switch escaped := content[index]; escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// A line continuation
+			default:
+				if escaped >= '0' && escaped <= '7' {
+					// Up to three octal digits
+					code := 0
+					digits := 0
+					for digits < 3 && index < len(content) && content[index] >= '0' && content[index] <= '7' {
+						code = code*8 + int(content[index]-'0')
+						index++
+						digits++
+					}
+					index--
+					writePDFByte(&value, byte(code))
+				} else {
+					value.WriteByte(escaped)
+				}
+			}
+		default:
+			writePDFByte(&value, char)
+		}
+	}
+	return value.String(), len(content)
+}
+
+/*
+writePDFByte writes a character of a PDF string, treating
+it as Latin-1 - near enough to the PDF's own standard
+encoding - so that the text is valid UTF-8.
+*/
+func writePDFByte(value *strings.Builder, char byte) {
+	if char < 0x80 {
+		value.WriteByte(char)
+	} else {
+		value.WriteRune(rune(char))
+	}
+}
@@ -0,0 +1,71 @@
+package documents
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+Split breaks text into chunks of roughly size characters,
+each starting with up to overlap characters of the end of
+the chunk before it so that passages spanning a boundary
+are not lost. Chunks are broken at paragraph, then line,
+then word boundaries where possible.
+*/
+func Split(text string, size int, overlap int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return []string{}
+	}
+	if size <= 0 {
+		return []string{text}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	runes := []rune(text)
+	chunks := []string{}
+	start := 0
+	for start < len(runes) {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, strings.TrimSpace(string(runes[start:])))
+			break
+		}
+		end = breakPoint(runes, start, end)
+
+		chunks = append(chunks, strings.TrimSpace(string(runes[start:end])))
+
+		// Step back for the overlap, but always make progress
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		// ...and don't start the next chunk mid word
+		for next > start && next < end && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+/*
+breakPoint finds the best place at or before end to end a
+chunk starting at start - the last paragraph break, line
+break, or space in the back half of the chunk, in that order
+of preference. If there is none, end is used as is.
+*/
+func breakPoint(runes []rune, start int, end int) int {
+	minimum := start + (end-start)/2
+	text := string(runes[minimum:end])
+
+	for _, separator := range []string{"\n\n", "\n", " "} {
+		if index := strings.LastIndex(text, separator); index >= 0 {
+			return minimum + len([]rune(text[:index])) + len([]rune(separator))
+		}
+	}
+	return end
+}
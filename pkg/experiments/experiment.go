@@ -0,0 +1,115 @@
+package experiments
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/agents"
+)
+
+/*
+Experiment compares variants of an agent - of its prompt
+templates, or of the models it uses - across its users. Each
+user is assigned a variant by a stable hash of their ID, so
+they keep it in every conversation while the experiment
+runs, and the variant is recorded on their messages. An
+agent runs at most one experiment at a time.
+*/
+type Experiment struct {
+	ID        string     `json:"id,omitempty" db:"id"`
+	Agent     string     `json:"agent,omitempty" db:"agent"`
+	Name      string     `json:"name,omitempty" db:"name"`
+	Variants  []*Variant `json:"variants,omitempty" db:"variants"`
+	Active    bool       `json:"active" db:"active"`
+	CreatedAt time.Time  `json:"created_at,omitempty" db:"created_at"`
+	EndedAt   time.Time  `json:"ended_at,omitempty" db:"ended_at"`
+}
+
+/*
+Variant is one arm of an experiment. Prompts override the
+agent's prompt templates by name, and LLM, if set, replaces
+its LLM settings; a variant with neither is the control.
+Weight is the variant's share of users relative to the
+others, and is 1 if unset.
+*/
+type Variant struct {
+	Name    string              `json:"name"`
+	Weight  int                 `json:"weight,omitempty"`
+	Prompts map[string]string   `json:"prompts,omitempty"`
+	LLM     *agents.LLMSettings `json:"llm,omitempty"`
+}
+
+func (variant *Variant) weight() int {
+	if variant.Weight == 0 {
+		return 1
+	}
+	return variant.Weight
+}
+
+/*
+Validate checks that an experiment has at least two
+uniquely named variants, none with a negative weight
+*/
+func (experiment *Experiment) Validate() error {
+	if experiment.Agent == "" {
+		return fmt.Errorf("agent must be set")
+	}
+	if experiment.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+	if len(experiment.Variants) < 2 {
+		return fmt.Errorf("an experiment needs at least two variants")
+	}
+
+	names := map[string]bool{}
+	for _, variant := range experiment.Variants {
+		if variant.Name == "" {
+			return fmt.Errorf("every variant must be named")
+		} else if names[variant.Name] {
+			return fmt.Errorf("variant %s is named more than once", variant.Name)
+		} else if variant.Weight < 0 {
+			return fmt.Errorf("variant %s can't have a negative weight", variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	return nil
+}
+
+/*
+Assign returns the variant a user is assigned. The same user
+is always assigned the same variant of an experiment.
+*/
+func (experiment *Experiment) Assign(user string) *Variant {
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.weight()
+	}
+	if total == 0 {
+		return nil
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(experiment.ID + "/" + user))
+	point := int(hash.Sum32() % uint32(total))
+
+	for _, variant := range experiment.Variants {
+		point -= variant.weight()
+		if point < 0 {
+			return variant
+		}
+	}
+	return nil
+}
+
+// Apply returns a copy of agent acting as the variant
+func (variant *Variant) Apply(agent *agents.Agent) *agents.Agent {
+	applied := *agent
+	if variant.LLM != nil {
+		applied.LLM = *variant.LLM
+	}
+	if len(variant.Prompts) > 0 {
+		applied.Prompts = variant.Prompts
+	}
+	return &applied
+}
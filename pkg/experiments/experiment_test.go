@@ -0,0 +1,132 @@
+package experiments
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssign(t *testing.T) {
+	experiment := &Experiment{
+		ID:    "experiment",
+		Agent: "rose",
+		Name:  "Weighted",
+		Variants: []*Variant{
+			{Name: "control"},
+			{Name: "treatment", Weight: 3},
+		},
+	}
+	require.Nil(t, experiment.Validate())
+
+	// Users keep their variant, and are split by weight
+	counts := map[string]int{}
+	for index := 0; index < 4000; index++ {
+		user := fmt.Sprintf("user-%d", index)
+		variant := experiment.Assign(user)
+		require.NotNil(t, variant)
+		assert.Equal(t, variant, experiment.Assign(user))
+		counts[variant.Name]++
+	}
+	assert.InDelta(t, 1000, counts["control"], 150)
+	assert.InDelta(t, 3000, counts["treatment"], 150)
+
+	for name, variants := range map[string][]*Variant{
+		"single":   {{Name: "control"}},
+		"unnamed":  {{Name: "control"}, {}},
+		"repeated": {{Name: "control"}, {Name: "control"}},
+		"negative": {{Name: "control"}, {Name: "treatment", Weight: -1}},
+	} {
+		invalid := &Experiment{Agent: "rose", Name: name, Variants: variants}
+		assert.NotNil(t, invalid.Validate(), name)
+	}
+}
+
+func TestApply(t *testing.T) {
+	agent := &agents.Agent{ID: "rose", LLM: agents.LLMSettings{Chat: &agents.Profile{Model: "gpt-3.5-turbo"}}}
+
+	control := (&Variant{Name: "control"}).Apply(agent)
+	assert.Equal(t, agent, control)
+	assert.NotSame(t, agent, control)
+
+	treatment := (&Variant{
+		Name:    "treatment",
+		Prompts: map[string]string{"summary": "Be brief"},
+		LLM:     &agents.LLMSettings{Chat: &agents.Profile{Model: "gpt-4"}},
+	}).Apply(agent)
+	assert.Equal(t, "gpt-4", treatment.LLM.ChatProfile().Model)
+	assert.Equal(t, "Be brief", treatment.Prompts["summary"])
+	assert.Equal(t, "gpt-3.5-turbo", agent.LLM.ChatProfile().Model)
+	assert.Nil(t, agent.Prompts)
+}
+
+func TestNewReport(t *testing.T) {
+	experiment := &Experiment{
+		ID:       "experiment",
+		Variants: []*Variant{{Name: "a"}, {Name: "b"}},
+	}
+
+	message := func(id string, conversation string, variant string, from string, duration int64) *chat.Message {
+		return &chat.Message{
+			ID:           id,
+			Conversation: conversation,
+			Agent:        "rose",
+			User:         "user-" + conversation,
+			From:         from,
+			Experiment:   experiment.ID,
+			Variant:      variant,
+			Duration:     duration,
+		}
+	}
+	messages := []*chat.Message{
+		message("1", "one", "a", "user-one", 0),
+		message("2", "one", "a", "rose", 100),
+		message("3", "one", "a", "user-one", 0),
+		message("4", "one", "a", "rose", 300),
+		message("5", "two", "b", "user-two", 0),
+		message("6", "two", "b", "rose", 1000),
+	}
+	// Tool calls are steps towards a reply, not replies
+	step := message("7", "two", "b", "rose", 0)
+	step.ToolCalls = []*chat.ToolCall{{ID: "call"}}
+	messages = append(messages, step)
+
+	feedback := []*chat.Feedback{
+		{Message: "2", User: "user-one", Rating: chat.RatingUp},
+		{Message: "4", User: "user-one", Rating: chat.RatingDown},
+		{Message: "6", User: "user-two", Rating: chat.RatingUp},
+	}
+	records := []*usage.Usage{
+		{Conversation: "one", PromptTokens: 10, CompletionTokens: 5},
+		{Conversation: "two", PromptTokens: 40, CompletionTokens: 20},
+		{Conversation: "elsewhere", PromptTokens: 1000},
+	}
+
+	report := NewReport(experiment, messages, feedback, records)
+	require.Len(t, report.Variants, 2)
+
+	a, b := report.Variants[0], report.Variants[1]
+	assert.Equal(t, &VariantReport{
+		Variant:          "a",
+		Users:            1,
+		Conversations:    1,
+		Replies:          2,
+		Ratings:          2,
+		Positive:         1,
+		Negative:         1,
+		FeedbackRate:     1,
+		PositiveRate:     0.5,
+		PromptTokens:     10,
+		CompletionTokens: 5,
+		TokensPerReply:   7.5,
+		Latency:          200,
+	}, a)
+	assert.Equal(t, 1, b.Replies)
+	assert.Equal(t, 1.0, b.PositiveRate)
+	assert.Equal(t, 60.0, b.TokensPerReply)
+	assert.Equal(t, 1000.0, b.Latency)
+}
@@ -0,0 +1,122 @@
+package experiments
+
+import (
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/usage"
+)
+
+/*
+VariantReport is how a single variant of an experiment has
+performed. FeedbackRate is the share of replies that were
+rated, and PositiveRate the share of ratings that were
+positive. Tokens are those of every call made in the
+variant's conversations, and Latency is the average time in
+milliseconds taken to reply.
+*/
+type VariantReport struct {
+	Variant          string  `json:"variant"`
+	Users            int     `json:"users"`
+	Conversations    int     `json:"conversations"`
+	Replies          int     `json:"replies"`
+	Ratings          int     `json:"ratings"`
+	Positive         int     `json:"positive"`
+	Negative         int     `json:"negative"`
+	FeedbackRate     float64 `json:"feedback_rate"`
+	PositiveRate     float64 `json:"positive_rate"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TokensPerReply   float64 `json:"tokens_per_reply"`
+	Latency          float64 `json:"average_latency_ms"`
+}
+
+// Report compares the variants of an experiment
+type Report struct {
+	Experiment *Experiment      `json:"experiment"`
+	Variants   []*VariantReport `json:"variants"`
+}
+
+/*
+NewReport reports on an experiment from the messages
+recorded under it, the feedback on them, and the usage of
+its agent while it ran. Usage outside of the experiment's
+conversations is ignored.
+*/
+func NewReport(
+	experiment *Experiment,
+	messages []*chat.Message,
+	feedback []*chat.Feedback,
+	records []*usage.Usage,
+) *Report {
+	report := &Report{Experiment: experiment, Variants: []*VariantReport{}}
+
+	variants := map[string]*VariantReport{}
+	for _, variant := range experiment.Variants {
+		variants[variant.Name] = &VariantReport{Variant: variant.Name}
+		report.Variants = append(report.Variants, variants[variant.Name])
+	}
+
+	users := map[string]map[string]bool{}
+	conversations := map[string]string{}
+	replies := map[string]string{}
+	latency := map[string]int64{}
+	for _, msg := range messages {
+		variant, ok := variants[msg.Variant]
+		if !ok || msg.Experiment != experiment.ID {
+			continue
+		}
+
+		if users[msg.Variant] == nil {
+			users[msg.Variant] = map[string]bool{}
+		}
+		users[msg.Variant][msg.User] = true
+
+		if _, ok := conversations[msg.Conversation]; !ok {
+			conversations[msg.Conversation] = msg.Variant
+			variant.Conversations++
+		}
+
+		// Only the agent's final responses are replies, not
+		// the steps it took to form them
+		if msg.From == msg.Agent && len(msg.ToolCalls) == 0 {
+			replies[msg.ID] = msg.Variant
+			variant.Replies++
+			latency[msg.Variant] += msg.Duration
+		}
+	}
+
+	for _, rating := range feedback {
+		variant, ok := variants[replies[rating.Message]]
+		if !ok {
+			continue
+		}
+		variant.Ratings++
+		if rating.Rating > 0 {
+			variant.Positive++
+		} else if rating.Rating < 0 {
+			variant.Negative++
+		}
+	}
+
+	for _, record := range records {
+		variant, ok := variants[conversations[record.Conversation]]
+		if !ok {
+			continue
+		}
+		variant.PromptTokens += record.PromptTokens
+		variant.CompletionTokens += record.CompletionTokens
+	}
+
+	for name, variant := range variants {
+		variant.Users = len(users[name])
+		if variant.Replies > 0 {
+			variant.FeedbackRate = float64(variant.Ratings) / float64(variant.Replies)
+			variant.TokensPerReply = float64(variant.PromptTokens+variant.CompletionTokens) / float64(variant.Replies)
+			variant.Latency = float64(latency[name]) / float64(variant.Replies)
+		}
+		if variant.Ratings > 0 {
+			variant.PositiveRate = float64(variant.Positive) / float64(variant.Ratings)
+		}
+	}
+
+	return report
+}
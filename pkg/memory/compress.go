@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"strings"
+)
+
+/*
+Compression is an LLM's judgement of which of a set of facts
+repeat or contradict one another. Each group is a list of
+fact IDs with the fact to keep first:
+
+  - Duplicates are facts that convey the same information,
+    the first being its best expression
+  - Contradictions are facts that can not all be true, the
+    first being the one that is true now - generally the
+    most recently learned, ie a new employer
+*/
+type Compression struct {
+	Duplicates     [][]string `json:"duplicates"`
+	Contradictions [][]string `json:"contradictions"`
+}
+
+// normalizeTerm ignores case and spacing when comparing facts
+func normalizeTerm(term string) string {
+	return strings.Join(strings.Fields(strings.ToLower(term)), " ")
+}
+
+/*
+ClusterKnowledge groups facts that have not been superseded
+by their subject and predicate, ignoring case and spacing.
+Clusters are ordered by their first fact, and keep the order
+of the facts within them.
+*/
+func ClusterKnowledge(facts []*Knowledge) [][]*Knowledge {
+	clusters := [][]*Knowledge{}
+	indexes := map[string]int{}
+
+	for _, fact := range facts {
+		if fact.IsSuperseded() {
+			continue
+		}
+
+		key := normalizeTerm(fact.Subject) + "\x00" + normalizeTerm(fact.Predicate)
+		index, ok := indexes[key]
+		if !ok {
+			index = len(clusters)
+			indexes[key] = index
+			clusters = append(clusters, []*Knowledge{})
+		}
+		clusters[index] = append(clusters[index], fact)
+	}
+
+	return clusters
+}
+
+/*
+changes tracks the facts modified while compressing, once
+each, in the order they were first modified.
+*/
+type changes struct {
+	facts []*Knowledge
+	seen  map[string]bool
+}
+
+func (changed *changes) add(fact *Knowledge) {
+	if changed.seen == nil {
+		changed.seen = map[string]bool{}
+	}
+	if changed.seen[fact.ID] {
+		return
+	}
+	changed.seen[fact.ID] = true
+	changed.facts = append(changed.facts, fact)
+}
+
+/*
+merge keeps the first fact, superseding the rest by it. The
+kept fact takes on the latest expiration of those it
+replaces if extend is set, as hearing a fact again suggests
+it is still worth remembering. Pinned facts are never
+replaced.
+*/
+func merge(keep *Knowledge, replaced []*Knowledge, extend bool, changed *changes) {
+	for _, fact := range replaced {
+		if fact == keep || fact.IsSuperseded() || fact.Pinned {
+			continue
+		}
+
+		if extend && fact.ExpiresAt.After(keep.ExpiresAt) {
+			keep.ExpiresAt = fact.ExpiresAt
+			changed.add(keep)
+		}
+		fact.Supersede(keep)
+		changed.add(fact)
+	}
+}
+
+/*
+MergeDuplicates supersedes any fact that exactly repeats an
+earlier one - the same subject, predicate, and object,
+ignoring case and spacing - by the earliest. The facts that
+were changed are returned.
+*/
+func MergeDuplicates(facts []*Knowledge) []*Knowledge {
+	changed := &changes{}
+
+	for _, cluster := range ClusterKnowledge(facts) {
+		objects := map[string]*Knowledge{}
+		for _, fact := range cluster {
+			object := normalizeTerm(fact.Object)
+			if keep, ok := objects[object]; ok {
+				merge(keep, []*Knowledge{fact}, true, changed)
+			} else {
+				objects[object] = fact
+			}
+		}
+	}
+
+	return changed.facts
+}
+
+/*
+Compress applies an LLM's compression to the given facts.
+Groups naming an unknown or already superseded fact to keep
+are ignored, as are unknown facts within a group. The facts
+that were changed are returned.
+*/
+func Compress(facts []*Knowledge, compression *Compression) []*Knowledge {
+	changed := &changes{}
+	if compression == nil {
+		return changed.facts
+	}
+
+	byID := map[string]*Knowledge{}
+	for _, fact := range facts {
+		byID[fact.ID] = fact
+	}
+
+	apply := func(groups [][]string, extend bool) {
+		for _, group := range groups {
+			if len(group) < 2 {
+				continue
+			}
+			keep, ok := byID[group[0]]
+			if !ok || keep.IsSuperseded() {
+				continue
+			}
+
+			replaced := []*Knowledge{}
+			for _, id := range group[1:] {
+				if fact, ok := byID[id]; ok {
+					replaced = append(replaced, fact)
+				}
+			}
+			merge(keep, replaced, extend, changed)
+		}
+	}
+
+	apply(compression.Duplicates, true)
+	apply(compression.Contradictions, false)
+
+	return changed.facts
+}
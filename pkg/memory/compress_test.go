@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFact(id string, subject string, predicate string, object string, expiresIn time.Duration) *Knowledge {
+	return &Knowledge{
+		ID:        id,
+		Agent:     "Rose",
+		User:      "Keith",
+		Subject:   subject,
+		Predicate: predicate,
+		Object:    object,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(expiresIn),
+	}
+}
+
+func TestClusterKnowledge(t *testing.T) {
+	facts := []*Knowledge{
+		newFact("0", "Keith", "works at", "Acme", time.Hour),
+		newFact("1", "Keith", "likes", "robots", time.Hour),
+		newFact("2", "keith", "Works  at", "Initech", time.Hour),
+		newFact("3", "Rose", "works at", "Acme", time.Hour),
+		newFact("4", "Keith", "works at", "Globex", time.Hour),
+	}
+	facts[4].SupersededBy = "2"
+
+	clusters := ClusterKnowledge(facts)
+	require.Len(t, clusters, 3)
+	assert.Equal(t, []*Knowledge{facts[0], facts[2]}, clusters[0])
+	assert.Equal(t, []*Knowledge{facts[1]}, clusters[1])
+	assert.Equal(t, []*Knowledge{facts[3]}, clusters[2])
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	facts := []*Knowledge{
+		newFact("0", "Keith", "likes", "robots", time.Hour),
+		newFact("1", "Keith", "likes", "pizza", time.Hour),
+		newFact("2", "Keith", "likes", "Robots ", 48*time.Hour),
+		newFact("3", "Keith", "LIKES", "robots", time.Minute),
+		newFact("4", "Rose", "likes", "robots", time.Hour),
+	}
+
+	changed := MergeDuplicates(facts)
+	assert.Equal(t, []*Knowledge{facts[0], facts[2], facts[3]}, changed)
+
+	// The earliest is kept, with the latest expiration
+	assert.False(t, facts[0].IsSuperseded())
+	assert.True(t, facts[0].ExpiresAt.Equal(facts[2].ExpiresAt))
+	assert.Equal(t, "0", facts[2].SupersededBy)
+	assert.Equal(t, "0", facts[3].SupersededBy)
+	assert.False(t, facts[3].SupersededAt.IsZero())
+
+	// Similar facts that aren't repeats are left alone
+	assert.False(t, facts[1].IsSuperseded())
+	assert.False(t, facts[4].IsSuperseded())
+
+	// Running it again changes nothing
+	assert.Empty(t, MergeDuplicates(facts))
+}
+
+func TestCompress(t *testing.T) {
+	facts := []*Knowledge{
+		newFact("0", "Keith", "works at", "Acme", 6*30*24*time.Hour),
+		newFact("1", "Keith", "is", "a programmer", time.Hour),
+		newFact("2", "Keith", "codes", "for a living", 24*time.Hour),
+		newFact("3", "Keith", "works at", "Initech", time.Hour),
+		newFact("4", "Keith", "has", "a cold", time.Hour),
+	}
+	facts[4].SupersededBy = "1"
+
+	changed := Compress(facts, &Compression{
+		Duplicates: [][]string{
+			{"1", "2"},
+			// Superseded facts can't be kept
+			{"4", "0"},
+			// Nor can unknown ones
+			{"missing", "3"},
+		},
+		Contradictions: [][]string{
+			{"3", "0", "missing"},
+			// Nothing to replace
+			{"1"},
+		},
+	})
+	assert.Equal(t, []*Knowledge{facts[1], facts[2], facts[0]}, changed)
+
+	// Duplicates are merged into the best expression
+	assert.Equal(t, "1", facts[2].SupersededBy)
+	assert.True(t, facts[1].ExpiresAt.Equal(facts[2].ExpiresAt))
+
+	// The new employer replaces the old one, which is kept
+	// as history with its own expiration
+	assert.Equal(t, "3", facts[0].SupersededBy)
+	assert.True(t, facts[3].ExpiresAt.Before(facts[0].ExpiresAt))
+	assert.False(t, facts[3].IsSuperseded())
+
+	assert.Empty(t, Compress(facts, nil))
+}
+
+func TestCompressPinned(t *testing.T) {
+	facts := []*Knowledge{
+		newFact("0", "Keith", "works at", "Acme", time.Minute),
+		newFact("1", "Keith", "works at", "Initech", time.Hour),
+		newFact("2", "keith", "works at", "acme", time.Hour),
+	}
+	facts[0].Pinned = true
+
+	// Pinned facts are neither merged away nor contradicted,
+	// and never expire
+	assert.Empty(t, MergeDuplicates([]*Knowledge{facts[2], facts[0]}))
+	assert.Empty(t, Compress(facts, &Compression{Contradictions: [][]string{{"1", "0"}}}))
+	assert.False(t, facts[0].IsSuperseded())
+
+	facts[0].ExpiresAt = time.Now().Add(-time.Hour)
+	assert.False(t, facts[0].IsExpired())
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+forgetPattern matches requests such as "please forget about
+my old job" or "can you stop remembering that I live in
+Boston". It is anchored at both ends, and the target can't
+hold punctuation, so that a sentence that merely starts with
+"forget", or runs on past it, isn't taken as a request. The
+negated forms, which are far more often said than asked,
+must name what they are about.
+*/
+var forgetPattern = regexp.MustCompile(
+	`(?i)^(?:(?:hey|ok|okay|so|and|also|actually)[,!]?\s+)*` +
+		`(?:(?:can|could|would|will)\s+you\s+)?(?:please\s+)?` +
+		`(?:forget(?:\s+about)?|(?:stop\s+remembering|don'?t\s+remember|do\s+not\s+remember)\s+(?:that|about|(?:everything|anything)\s+about))` +
+		`\s+([^\s,;:.?!][^,;:.?!]*?)(?:,?\s+please)?[\s.!?]*$`,
+)
+
+// The targets that refer back to what was just said
+var forgetLatest = map[string]bool{
+	"what i just said":       true,
+	"what i said":            true,
+	"everything i just said": true,
+}
+
+/*
+The targets that are too vague to act on without the LLM -
+"forget it" is as often "never mind" as it is a request.
+*/
+var forgetPronouns = map[string]bool{
+	"it":         true,
+	"that":       true,
+	"this":       true,
+	"them":       true,
+	"these":      true,
+	"those":      true,
+	"him":        true,
+	"her":        true,
+	"all":        true,
+	"everything": true,
+}
+
+/*
+ParseForgetCommand detects a request to forget something,
+such as "forget about Acme" or "please forget that I live in
+Boston", returning what is to be forgotten. A target of ""
+means whatever was just said, as in "forget what I just
+said". Messages that merely mention forgetting, such as
+"don't forget to call Rose", "I forgot my keys", or "forget
+it, never mind", are not commands.
+*/
+func ParseForgetCommand(content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	if strings.Contains(content, "\n") {
+		return "", false
+	}
+
+	matches := forgetPattern.FindStringSubmatch(content)
+	if matches == nil {
+		return "", false
+	}
+
+	target := strings.TrimSpace(matches[1])
+	lower := strings.ToLower(target)
+	if strings.HasPrefix(lower, "to ") {
+		// "forget to" is about a task, not a memory
+		return "", false
+	} else if forgetPronouns[lower] {
+		return "", false
+	} else if forgetLatest[lower] {
+		return "", true
+	}
+
+	// "forget that I live in Boston" is about living in Boston
+	for _, prefix := range []string{"that ", "the fact that ", "everything about ", "all about "} {
+		if strings.HasPrefix(lower, prefix) {
+			target = strings.TrimSpace(target[len(prefix):])
+			break
+		}
+	}
+
+	return target, true
+}
+
+// containsPhrase checks for a phrase on word boundaries
+func containsPhrase(text string, phrase string) bool {
+	if phrase == "" {
+		return false
+	}
+	return strings.Contains(" "+text+" ", " "+phrase+" ")
+}
+
+/*
+sharesPredicate checks if the target mentions a fact's
+predicate, ignoring short words and plurals, so that "I live
+in Boston" mentions "lives in".
+*/
+func sharesPredicate(target string, predicate string) bool {
+	words := map[string]bool{}
+	for _, word := range strings.Fields(target) {
+		words[strings.TrimSuffix(word, "s")] = true
+	}
+	for _, word := range strings.Fields(NormalizeEntity(predicate)) {
+		if len(word) >= 3 && words[strings.TrimSuffix(word, "s")] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+MatchForgotten returns the facts a forget command's target
+refers to. A target naming just an entity matches everything
+about it, ie "Acme". Otherwise facts whose object is named
+in the target are the most specific match, ie "I work at
+Acme" matches "Keith works at Acme" - narrowed to those
+whose predicate is mentioned too, if any are - and failing
+that any fact whose subject is named.
+*/
+func MatchForgotten(facts []*Knowledge, target string) []*Knowledge {
+	target = NormalizeEntity(target)
+
+	about := []*Knowledge{}
+	byObject := []*Knowledge{}
+	bySubject := []*Knowledge{}
+	for _, fact := range facts {
+		object := NormalizeEntity(fact.Object)
+		subject := NormalizeEntity(fact.Subject)
+
+		if target == subject || target == object {
+			about = append(about, fact)
+		}
+		if containsPhrase(target, object) {
+			byObject = append(byObject, fact)
+		} else if containsPhrase(target, subject) {
+			bySubject = append(bySubject, fact)
+		}
+	}
+
+	if len(about) > 0 {
+		return about
+	} else if len(byObject) > 0 {
+		narrowed := []*Knowledge{}
+		for _, fact := range byObject {
+			if sharesPredicate(target, fact.Predicate) {
+				narrowed = append(narrowed, fact)
+			}
+		}
+		if len(narrowed) > 0 {
+			return narrowed
+		}
+		return byObject
+	}
+	return bySubject
+}
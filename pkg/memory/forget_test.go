@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseForgetCommand(t *testing.T) {
+	commands := map[string]string{
+		"Forget about Acme":                       "Acme",
+		"please forget that I live in Boston.":    "I live in Boston",
+		"Can you forget my old job, please?":      "my old job",
+		"ok, forget what I just said":             "",
+		"Could you forget about Acme please":      "Acme",
+		"stop remembering everything about Kevin": "Kevin",
+		"Don't remember that I like pizza":        "I like pizza",
+	}
+	for content, expected := range commands {
+		target, ok := ParseForgetCommand(content)
+		assert.True(t, ok, content)
+		assert.Equal(t, expected, target, content)
+	}
+
+	for _, content := range []string{
+		"don't forget to call Rose",
+		"I forgot my keys",
+		"Forget to water the plants and they die",
+		"I always forget about Acme's holidays",
+		"What do you remember about me?",
+		"forget\nabout it",
+		"Forget it!",
+		"ok, forget that",
+		"Forget it, never mind",
+		"Don't remember much about Boston honestly",
+		"Do not remember? I told you about Acme yesterday",
+		"Stop remembering things wrong!",
+		"forget. Acme is fine",
+	} {
+		_, ok := ParseForgetCommand(content)
+		assert.False(t, ok, content)
+	}
+}
+
+func TestMatchForgotten(t *testing.T) {
+	facts := []*Knowledge{
+		newFact("0", "Keith", "works at", "Acme", time.Hour),
+		newFact("1", "Acme", "is located in", "Boston", time.Hour),
+		newFact("2", "Keith", "lives in", "Boston", time.Hour),
+		newFact("3", "Keith", "likes", "robots", time.Hour),
+	}
+
+	// Everything about an entity
+	assert.Equal(t, []*Knowledge{facts[0], facts[1]}, MatchForgotten(facts, "Acme"))
+
+	// A particular fact, by its object and predicate
+	assert.Equal(t, []*Knowledge{facts[2]}, MatchForgotten(facts, "I live in Boston, actually"))
+	assert.Equal(t, []*Knowledge{facts[1], facts[2]}, MatchForgotten(facts, "anything in Boston"))
+	assert.Equal(t, []*Knowledge{facts[3]}, MatchForgotten(facts, "I like robots"))
+
+	// Or failing that its subject
+	assert.Equal(t, []*Knowledge{facts[0], facts[2], facts[3]}, MatchForgotten(facts, "what Keith does"))
+
+	assert.Empty(t, MatchForgotten(facts, "my old job"))
+}
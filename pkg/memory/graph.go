@@ -0,0 +1,337 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+Alias is another name an agent knows a user's entity by, ie
+"Keith" and "my brother". Aliases map, after normalization,
+to the canonical name of the entity.
+*/
+type Alias struct {
+	Agent     string    `json:"agent,omitempty" db:"agent"`
+	User      string    `json:"user,omitempty" db:"user"`
+	Alias     string    `json:"alias,omitempty" db:"alias"`
+	Entity    string    `json:"entity,omitempty" db:"entity"`
+	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+/*
+NormalizeEntity returns the name an entity is identified by
+in a graph - lowercased, with surrounding punctuation
+trimmed and spacing collapsed - so that "Keith", "keith" and
+" Keith. " are the same entity.
+*/
+func NormalizeEntity(name string) string {
+	fields := strings.Fields(strings.ToLower(name))
+	for index, field := range fields {
+		fields[index] = strings.TrimFunc(field, unicode.IsPunct)
+	}
+	return normalizeTerm(strings.Join(fields, " "))
+}
+
+/*
+Graph is an agent's knowledge of a user viewed as a graph;
+the subjects and objects of facts are its entities, and each
+fact an edge from its subject to its object labelled by its
+predicate.
+*/
+type Graph struct {
+	Agent string
+	User  string
+
+	facts   []*Knowledge
+	aliases map[string]string
+	names   map[string]string
+	edges   map[string][]*Knowledge
+}
+
+/*
+NewGraph builds a graph from the given facts, resolving any
+aliases of their subjects and objects to the entities they
+name. Superseded facts are left out.
+*/
+func NewGraph(agent string, user string, facts []*Knowledge, aliases []*Alias) *Graph {
+	graph := &Graph{
+		Agent:   agent,
+		User:    user,
+		facts:   []*Knowledge{},
+		aliases: map[string]string{},
+		names:   map[string]string{},
+		edges:   map[string][]*Knowledge{},
+	}
+
+	for _, alias := range aliases {
+		graph.aliases[NormalizeEntity(alias.Alias)] = NormalizeEntity(alias.Entity)
+	}
+
+	for _, fact := range facts {
+		if fact.IsSuperseded() {
+			continue
+		}
+		graph.facts = append(graph.facts, fact)
+
+		subject := graph.addEntity(fact.Subject)
+		object := graph.addEntity(fact.Object)
+
+		graph.edges[subject] = append(graph.edges[subject], fact)
+		if object != subject {
+			graph.edges[object] = append(graph.edges[object], fact)
+		}
+	}
+
+	return graph
+}
+
+/*
+addEntity records the first name an entity was seen by. An
+entity only seen by an alias is named by its normalized name
+until it is seen by its own.
+*/
+func (graph *Graph) addEntity(name string) string {
+	entity := graph.Resolve(name)
+	current, ok := graph.names[entity]
+	if NormalizeEntity(name) == entity {
+		if !ok || current == entity {
+			graph.names[entity] = strings.TrimSpace(name)
+		}
+	} else if !ok {
+		graph.names[entity] = entity
+	}
+	return entity
+}
+
+/*
+Resolve returns the normalized entity the given name refers
+to, following aliases. Aliases of aliases are followed, but
+cycles are not.
+*/
+func (graph *Graph) Resolve(name string) string {
+	entity := NormalizeEntity(name)
+	seen := map[string]bool{entity: true}
+	for {
+		next, ok := graph.aliases[entity]
+		if !ok || seen[next] {
+			return entity
+		}
+		seen[next] = true
+		entity = next
+	}
+}
+
+/*
+Entities returns the normalized names of every entity in the
+graph, sorted.
+*/
+func (graph *Graph) Entities() []string {
+	entities := []string{}
+	for entity := range graph.names {
+		entities = append(entities, entity)
+	}
+	sort.Strings(entities)
+	return entities
+}
+
+/*
+Facts returns every fact in the graph, in the order they were
+given.
+*/
+func (graph *Graph) Facts() []*Knowledge {
+	return graph.facts
+}
+
+/*
+About returns every fact the given entity is the subject or
+object of.
+*/
+func (graph *Graph) About(entity string) []*Knowledge {
+	facts := graph.edges[graph.Resolve(entity)]
+	if facts == nil {
+		return []*Knowledge{}
+	}
+	return facts
+}
+
+/*
+Neighbors returns the normalized names of every entity the
+given entity shares a fact with, sorted.
+*/
+func (graph *Graph) Neighbors(entity string) []string {
+	entity = graph.Resolve(entity)
+
+	seen := map[string]bool{}
+	neighbors := []string{}
+	for _, fact := range graph.edges[entity] {
+		neighbor := graph.other(fact, entity)
+		if neighbor == entity || seen[neighbor] {
+			continue
+		}
+		seen[neighbor] = true
+		neighbors = append(neighbors, neighbor)
+	}
+	sort.Strings(neighbors)
+
+	return neighbors
+}
+
+// other returns the entity at the other end of a fact
+func (graph *Graph) other(fact *Knowledge, entity string) string {
+	subject := graph.Resolve(fact.Subject)
+	if subject != entity {
+		return subject
+	}
+	return graph.Resolve(fact.Object)
+}
+
+/*
+Path returns the shortest chain of facts linking two
+entities, following facts in either direction, or nil if
+they are not linked within maxLength facts. A maxLength of
+0 or less places no limit on the path.
+*/
+func (graph *Graph) Path(from string, to string, maxLength int) []*Knowledge {
+	from = graph.Resolve(from)
+	to = graph.Resolve(to)
+
+	if _, ok := graph.names[from]; !ok {
+		return nil
+	} else if _, ok := graph.names[to]; !ok {
+		return nil
+	} else if from == to {
+		return []*Knowledge{}
+	}
+
+	// Breadth first, remembering the fact each entity was
+	// first reached by
+	reachedBy := map[string]*Knowledge{}
+	visited := map[string]bool{from: true}
+	frontier := []string{from}
+
+	for length := 1; len(frontier) > 0; length++ {
+		if maxLength > 0 && length > maxLength {
+			return nil
+		}
+
+		next := []string{}
+		for _, entity := range frontier {
+			for _, fact := range graph.edges[entity] {
+				neighbor := graph.other(fact, entity)
+				if visited[neighbor] {
+					continue
+				}
+				visited[neighbor] = true
+				reachedBy[neighbor] = fact
+
+				if neighbor == to {
+					return graph.walkBack(reachedBy, from, to)
+				}
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+
+	return nil
+}
+
+// walkBack rebuilds a path found by Path, from start to end
+func (graph *Graph) walkBack(reachedBy map[string]*Knowledge, from string, to string) []*Knowledge {
+	path := []*Knowledge{}
+	for entity := to; entity != from; {
+		fact := reachedBy[entity]
+		path = append([]*Knowledge{fact}, path...)
+		entity = graph.other(fact, entity)
+	}
+	return path
+}
+
+/*
+ByPredicate returns every fact with the given predicate,
+ignoring case and spacing.
+*/
+func (graph *Graph) ByPredicate(predicate string) []*Knowledge {
+	predicate = normalizeTerm(predicate)
+
+	facts := []*Knowledge{}
+	for _, fact := range graph.facts {
+		if normalizeTerm(fact.Predicate) == predicate {
+			facts = append(facts, fact)
+		}
+	}
+	return facts
+}
+
+/*
+DOT renders the graph in the Graphviz DOT language, for
+debugging.
+*/
+func (graph *Graph) DOT() string {
+	var builder strings.Builder
+
+	builder.WriteString("digraph knowledge {\n")
+	for _, entity := range graph.Entities() {
+		builder.WriteString(fmt.Sprintf("\t%q [label=%q];\n", entity, graph.names[entity]))
+	}
+	for _, fact := range graph.facts {
+		builder.WriteString(fmt.Sprintf(
+			"\t%q -> %q [label=%q];\n",
+			graph.Resolve(fact.Subject),
+			graph.Resolve(fact.Object),
+			fact.Predicate,
+		))
+	}
+	builder.WriteString("}\n")
+
+	return builder.String()
+}
+
+/*
+GraphNode and GraphEdge are the JSON form of a graph's
+entities and facts.
+*/
+type GraphNode struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type GraphEdge struct {
+	ID        string `json:"id"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Predicate string `json:"predicate"`
+}
+
+func (graph *Graph) MarshalJSON() ([]byte, error) {
+	nodes := []*GraphNode{}
+	for _, entity := range graph.Entities() {
+		nodes = append(nodes, &GraphNode{ID: entity, Name: graph.names[entity]})
+	}
+
+	edges := []*GraphEdge{}
+	for _, fact := range graph.facts {
+		edges = append(edges, &GraphEdge{
+			ID:        fact.ID,
+			Source:    graph.Resolve(fact.Subject),
+			Target:    graph.Resolve(fact.Object),
+			Predicate: fact.Predicate,
+		})
+	}
+
+	return json.Marshal(struct {
+		Agent string       `json:"agent"`
+		User  string       `json:"user"`
+		Nodes []*GraphNode `json:"nodes"`
+		Edges []*GraphEdge `json:"edges"`
+	}{
+		Agent: graph.Agent,
+		User:  graph.User,
+		Nodes: nodes,
+		Edges: edges,
+	})
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEntity(t *testing.T) {
+	assert.Equal(t, "keith", NormalizeEntity("Keith"))
+	assert.Equal(t, "keith", NormalizeEntity(" keith. "))
+	assert.Equal(t, "acme corp", NormalizeEntity("Acme  Corp!"))
+	assert.Equal(t, "o'brien", NormalizeEntity("\"O'Brien\""))
+	assert.Equal(t, "", NormalizeEntity(" - "))
+}
+
+func newGraph() (*Graph, []*Knowledge) {
+	facts := []*Knowledge{
+		newFact("0", "Keith", "works at", "Acme", time.Hour),
+		newFact("1", "keith", "has sibling", "Kevin", time.Hour),
+		newFact("2", "my brother", "lives in", "Boston", time.Hour),
+		newFact("3", "Acme", "is located in", "Boston", time.Hour),
+		newFact("4", "Rose", "likes", "robots", time.Hour),
+		newFact("5", "Keith", "works at", "Globex", time.Hour),
+	}
+	facts[5].SupersededBy = "0"
+
+	aliases := []*Alias{
+		{Alias: "My Brother", Entity: "Kevin"},
+	}
+
+	return NewGraph("Rose", "Keith", facts, aliases), facts
+}
+
+func TestGraphQueries(t *testing.T) {
+	graph, facts := newGraph()
+
+	// Superseded facts are left out, and aliases resolved
+	assert.Equal(t, []*Knowledge{facts[0], facts[1], facts[2], facts[3], facts[4]}, graph.Facts())
+	assert.Equal(t, []string{"acme", "boston", "keith", "kevin", "robots", "rose"}, graph.Entities())
+	assert.Equal(t, "kevin", graph.Resolve("My  brother"))
+
+	// About
+	assert.Equal(t, []*Knowledge{facts[0], facts[1]}, graph.About("KEITH"))
+	assert.Equal(t, []*Knowledge{facts[1], facts[2]}, graph.About("my brother"))
+	assert.Equal(t, []*Knowledge{}, graph.About("nobody"))
+
+	// Neighbors
+	assert.Equal(t, []string{"acme", "kevin"}, graph.Neighbors("Keith"))
+	assert.Equal(t, []string{"acme", "kevin"}, graph.Neighbors("Boston"))
+	assert.Equal(t, []string{}, graph.Neighbors("nobody"))
+
+	// ByPredicate
+	assert.Equal(t, []*Knowledge{facts[0]}, graph.ByPredicate("Works  At"))
+	assert.Equal(t, []*Knowledge{}, graph.ByPredicate("hates"))
+}
+
+func TestGraphPath(t *testing.T) {
+	graph, facts := newGraph()
+
+	// Facts are followed in either direction
+	assert.Equal(t, []*Knowledge{facts[0], facts[3]}, graph.Path("keith", "boston", 0))
+	assert.Equal(t, []*Knowledge{facts[2], facts[1]}, graph.Path("boston", "keith", 0))
+	assert.Equal(t, []*Knowledge{facts[1]}, graph.Path("my brother", "Keith", 0))
+	assert.Equal(t, []*Knowledge{}, graph.Path("Keith", "keith", 0))
+
+	// Too long, unlinked, or unknown
+	assert.Nil(t, graph.Path("keith", "boston", 1))
+	assert.Nil(t, graph.Path("keith", "robots", 0))
+	assert.Nil(t, graph.Path("keith", "nobody", 0))
+}
+
+func TestGraphExport(t *testing.T) {
+	graph, _ := newGraph()
+
+	dot := graph.DOT()
+	assert.Contains(t, dot, "digraph knowledge {")
+	assert.Contains(t, dot, "\t\"keith\" [label=\"Keith\"];")
+	assert.Contains(t, dot, "\t\"kevin\" -> \"boston\" [label=\"lives in\"];")
+	assert.NotContains(t, dot, "globex")
+
+	data, err := json.Marshal(graph)
+	require.Nil(t, err)
+
+	var exported struct {
+		Agent string       `json:"agent"`
+		User  string       `json:"user"`
+		Nodes []*GraphNode `json:"nodes"`
+		Edges []*GraphEdge `json:"edges"`
+	}
+	require.Nil(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, "Rose", exported.Agent)
+	require.Len(t, exported.Nodes, 6)
+	assert.Equal(t, &GraphNode{ID: "kevin", Name: "Kevin"}, exported.Nodes[3])
+	require.Len(t, exported.Edges, 5)
+	assert.Equal(t, &GraphEdge{ID: "2", Source: "kevin", Target: "boston", Predicate: "lives in"}, exported.Edges[2])
+}
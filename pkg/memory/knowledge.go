@@ -2,22 +2,44 @@ package memory
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
 
+/*
+Knowledge is a single fact learned about a subject. Facts
+that are found to repeat or contradict a newer fact are not
+deleted, but marked as superseded by it, so that the history
+of what was known is kept.
+
+Each fact records its provenance - the conversation and
+messages it was extracted from, and how confident the LLM
+was in it, from 0 to 1. A confidence of 0 means none was
+given.
+
+Pinned facts never expire, and are never superseded by
+compression.
+*/
 type Knowledge struct {
-	ID        string    `json:"id,omitempty" db:"id"`
-	Agent     string    `json:"agent,omitempty" db:"agent"`
-	User      string    `json:"user,omitempty" db:"user"`
-	Subject   string    `json:"subject,omitempty" db:"subject"`
-	Predicate string    `json:"predicate,omitempty" db:"predicate"`
-	Object    string    `json:"object,omitempty" db:"object"`
-	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
-	ExpiresAt time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	ID           string    `json:"id,omitempty" db:"id"`
+	Agent        string    `json:"agent,omitempty" db:"agent"`
+	User         string    `json:"user,omitempty" db:"user"`
+	Subject      string    `json:"subject,omitempty" db:"subject"`
+	Predicate    string    `json:"predicate,omitempty" db:"predicate"`
+	Object       string    `json:"object,omitempty" db:"object"`
+	CreatedAt    time.Time `json:"created_at,omitempty" db:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	SupersededBy string    `json:"superseded_by,omitempty" db:"superseded_by"`
+	SupersededAt time.Time `json:"superseded_at,omitempty" db:"superseded_at"`
+	Conversation string    `json:"conversation,omitempty" db:"conversation"`
+	Messages     []string  `json:"messages,omitempty" db:"messages"`
+	Confidence   float64   `json:"confidence,omitempty" db:"confidence"`
+	Pinned       bool      `json:"pinned,omitempty" db:"pinned"`
 }
 
 func (tidbit *Knowledge) Equal(other *Knowledge) bool {
@@ -37,12 +59,33 @@ func (tidbit *Knowledge) Equal(other *Knowledge) bool {
 		return false
 	}
 
+	timeDifference = tidbit.SupersededAt.Sub(other.SupersededAt)
+	if timeDifference < 0 {
+		timeDifference = -timeDifference
+	}
+	if timeDifference > time.Second {
+		return false
+	}
+
+	if len(tidbit.Messages) != len(other.Messages) {
+		return false
+	}
+	for index, message := range tidbit.Messages {
+		if other.Messages[index] != message {
+			return false
+		}
+	}
+
 	return tidbit.ID == other.ID &&
 		tidbit.Agent == other.Agent &&
 		tidbit.User == other.User &&
 		tidbit.Subject == other.Subject &&
 		tidbit.Predicate == other.Predicate &&
-		tidbit.Object == other.Object
+		tidbit.Object == other.Object &&
+		tidbit.SupersededBy == other.SupersededBy &&
+		tidbit.Conversation == other.Conversation &&
+		tidbit.Pinned == other.Pinned &&
+		math.Abs(tidbit.Confidence-other.Confidence) < 0.0001
 }
 
 func (tidbit *Knowledge) String() string {
@@ -73,7 +116,20 @@ func (tidbit *Knowledge) StringWithExpiration() string {
 }
 
 func (tidbit *Knowledge) IsExpired() bool {
-	return time.Now().After(tidbit.ExpiresAt)
+	return !tidbit.Pinned && time.Now().After(tidbit.ExpiresAt)
+}
+
+func (tidbit *Knowledge) IsSuperseded() bool {
+	return tidbit.SupersededBy != ""
+}
+
+/*
+Supersede marks the fact as replaced by the given fact, as
+either a repeat or a contradiction of it.
+*/
+func (tidbit *Knowledge) Supersede(by *Knowledge) {
+	tidbit.SupersededBy = by.ID
+	tidbit.SupersededAt = time.Now()
 }
 
 func (tidbit *Knowledge) ExpiresIn(duration time.Duration) {
@@ -88,8 +144,8 @@ func (tidbit *Knowledge) ExpirationToString() string {
 	switch {
 	case duration >= time.Hour*24*365*10:
 		return "never"
-	case duration >= time.Hour*24*7*30:
-		value = int(duration / (time.Hour * 24 * 7 * 30))
+	case duration >= time.Hour*24*30:
+		value = int(duration / (time.Hour * 24 * 30))
 		unit = pluralize(value, "month")
 	case duration >= time.Hour*24*7:
 		value = int(duration / (time.Hour * 24 * 7))
@@ -117,60 +173,132 @@ func pluralize(count int, singular string) string {
 	return singular + "s"
 }
 
+// neverExpires is the duration used for knowledge that never expires
+const neverExpires = time.Hour * 24 * 365 * 100
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour,
+	"d": time.Hour * 24, "day": time.Hour * 24,
+	"w": time.Hour * 24 * 7, "wk": time.Hour * 24 * 7, "week": time.Hour * 24 * 7,
+	"mo": time.Hour * 24 * 30, "month": time.Hour * 24 * 30,
+	"y": time.Hour * 24 * 365, "yr": time.Hour * 24 * 365, "year": time.Hour * 24 * 365,
+}
+
+var durationQuantities = map[string]float64{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4,
+	"five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+	"ten": 10, "eleven": 11, "twelve": 12,
+	"couple": 2, "few": 3, "several": 5,
+}
+
+/*
+parseDuration converts an LLM's loosely written expiration,
+such as "3 days", "a few hours", "2wks", or "never", into a
+duration. A unit with no quantity ("a day", "week") is
+assumed to be one of that unit.
+*/
 func parseDuration(input string) (time.Duration, error) {
-	if strings.ToLower(input) == "never" {
-		return time.Hour * 24 * 365 * 100, nil
+	input = strings.Trim(strings.ToLower(strings.TrimSpace(input)), ".")
+	switch input {
+	case "never", "forever", "permanent", "permanently":
+		return neverExpires, nil
+	case "":
+		return 0, fmt.Errorf("no duration given")
+	}
+
+	// Split digits from letters so that "3days" reads as "3 days"
+	spaced := strings.Builder{}
+	for index, char := range input {
+		if index > 0 && unicode.IsLetter(char) && unicode.IsDigit(rune(input[index-1])) {
+			spaced.WriteRune(' ')
+		}
+		spaced.WriteRune(char)
+	}
+
+	words := []string{}
+	for _, word := range strings.Fields(spaced.String()) {
+		if word != "of" {
+			words = append(words, word)
+		}
 	}
-	// Split the input into the number and the unit
-	parts := strings.Split(strings.TrimSpace(input), " ")
-	if len(parts) != 2 {
-		fmt.Println(">>", parts)
+	if len(words) == 0 || len(words) > 3 {
 		return 0, fmt.Errorf("invalid duration format: %s", input)
 	}
 
-	// Parse the number
-	num, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, fmt.Errorf("invalid number in duration: %s", parts[0])
-	}
-
-	// Determine the unit of time
-	unit := parts[1]
-	if unit[len(unit)-1] == 's' {
-		unit = unit[:len(unit)-1]
-	}
-
-	// Calculate the duration based on the unit
-	switch unit {
-	case "second", "seconds":
-		return time.Duration(num) * time.Second, nil
-	case "minute", "minutes":
-		return time.Duration(num) * time.Minute, nil
-	case "hour", "hours":
-		return time.Duration(num) * time.Hour, nil
-	case "day", "days":
-		return time.Duration(num) * time.Hour * 24, nil
-	case "week", "weeks":
-		return time.Duration(num) * time.Hour * 24 * 7, nil
-	case "month", "months":
-		return time.Duration(num) * time.Hour * 24 * 7 * 30, nil
-	default:
-		return 0, fmt.Errorf("invalid unit of time: %s", unit)
+	// Determine the unit of time from the final word
+	unitWord := words[len(words)-1]
+	unit, ok := durationUnits[unitWord]
+	if !ok {
+		unit, ok = durationUnits[strings.TrimSuffix(unitWord, "s")]
+	}
+	if !ok {
+		return 0, fmt.Errorf("invalid unit of time: %s", unitWord)
+	}
+
+	// Then the quantity from anything before it; "a few" is
+	// read as "few"
+	quantity := 1.0
+	for _, word := range words[:len(words)-1] {
+		if value, ok := durationQuantities[word]; ok {
+			quantity = value
+		} else if value, err := strconv.ParseFloat(word, 64); err == nil && value >= 0 && !math.IsInf(value, 0) {
+			quantity = value
+		} else {
+			return 0, fmt.Errorf("invalid number in duration: %s", word)
+		}
+	}
+
+	// Anything beyond our "never" is treated as never
+	duration := quantity * float64(unit)
+	if duration > float64(neverExpires) {
+		return neverExpires, nil
 	}
+	return time.Duration(duration), nil
 }
 
 type LearnResponse struct {
-	Subject   string `json:"subject,omitempty"`
-	Predicate string `json:"predicate,omitempty"`
-	Object    string `json:"object,omitempty"`
-	Expires   string `json:"expires,omitempty"`
+	Subject    string  `json:"subject,omitempty"`
+	Predicate  string  `json:"predicate,omitempty"`
+	Object     string  `json:"object,omitempty"`
+	Expires    string  `json:"expires,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+/*
+Valid ensures that a response has every part of a knowledge
+set, and an expiration that can be understood.
+*/
+func (response *LearnResponse) Valid() error {
+	if strings.TrimSpace(response.Subject) == "" {
+		return fmt.Errorf("subject must be set")
+	}
+	if strings.TrimSpace(response.Predicate) == "" {
+		return fmt.Errorf("predicate must be set")
+	}
+	if strings.TrimSpace(response.Object) == "" {
+		return fmt.Errorf("object must be set")
+	}
+	if _, err := parseDuration(response.Expires); err != nil {
+		return fmt.Errorf("expires: %w", err)
+	}
+	if response.Confidence < 0 || response.Confidence > 1 {
+		return fmt.Errorf("confidence must be between 0 and 1")
+	}
+	return nil
 }
 
+/*
+ToKnowledge converts a knowledge set into a fact, recording
+the conversation and messages it was extracted from.
+*/
 func ToKnowledge(
 	response *LearnResponse,
 	agent string,
 	user string,
 	conversation string,
+	messages []string,
 ) (*Knowledge, error) {
 	expiresAt, err := parseDuration(response.Expires)
 	if err != nil {
@@ -178,14 +306,17 @@ func ToKnowledge(
 	}
 
 	knowledge := &Knowledge{
-		ID:        uuid.New().String(),
-		Agent:     agent,
-		User:      user,
-		CreatedAt: time.Now(),
-		Subject:   response.Subject,
-		Predicate: response.Predicate,
-		Object:    response.Object,
-		ExpiresAt: time.Now().Add(expiresAt),
+		ID:           uuid.New().String(),
+		Agent:        agent,
+		User:         user,
+		CreatedAt:    time.Now(),
+		Subject:      response.Subject,
+		Predicate:    response.Predicate,
+		Object:       response.Object,
+		ExpiresAt:    time.Now().Add(expiresAt),
+		Conversation: conversation,
+		Messages:     messages,
+		Confidence:   response.Confidence,
 	}
 
 	return knowledge, nil
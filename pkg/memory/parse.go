@@ -0,0 +1,336 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+SummaryResponse is the structured output expected from an
+LLM when summarizing a conversation.
+*/
+type SummaryResponse struct {
+	Keywords []string `json:"keywords"`
+	Summary  string   `json:"summary"`
+}
+
+/*
+KnowledgeResponse is the structured output expected from an
+LLM when extracting knowledge from a conversation.
+*/
+type KnowledgeResponse struct {
+	Knowledge []*LearnResponse `json:"knowledge"`
+}
+
+var (
+	codeFence = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*(.*?)\\s*```")
+	// Adjacent objects missing a comma, ie }{ or } {
+	missingObjectComma = regexp.MustCompile(`}\s*{`)
+	// Adjacent key/value pairs missing a comma, ie "a" "b":
+	missingValueComma = regexp.MustCompile(`"(\s+)"([^"]*)"\s*:`)
+	trailingComma     = regexp.MustCompile(`,(\s*[}\]])`)
+)
+
+/*
+extractJSON pulls the outermost JSON object or array out of
+raw LLM output, ignoring markdown code fences and any prose
+before or after it. An empty string is returned if no JSON
+is found.
+*/
+func extractJSON(raw string) string {
+	if match := codeFence.FindStringSubmatch(raw); match != nil {
+		raw = match[1]
+	}
+
+	start := strings.IndexAny(raw, "[{")
+	if start < 0 {
+		return ""
+	}
+
+	closing := "}"
+	if raw[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(raw, closing)
+	if end < start {
+		return ""
+	}
+
+	return raw[start : end+1]
+}
+
+/*
+repairJSON fixes the common mistakes LLMs make when writing
+JSON by hand - missing commas between objects or fields,
+and trailing commas.
+*/
+func repairJSON(raw string) string {
+	raw = missingObjectComma.ReplaceAllString(raw, "},{")
+	raw = missingValueComma.ReplaceAllString(raw, `",$1"$2":`)
+	raw = trailingComma.ReplaceAllString(raw, "$1")
+	return raw
+}
+
+/*
+unmarshalTolerant attempts to unmarshal the JSON found in raw
+as is, and failing that, after it has been repaired.
+*/
+func unmarshalTolerant(raw string, target interface{}) error {
+	extracted := extractJSON(raw)
+	if extracted == "" {
+		return fmt.Errorf("no JSON found in response")
+	}
+
+	err := json.Unmarshal([]byte(extracted), target)
+	if err == nil {
+		return nil
+	}
+
+	if repairErr := json.Unmarshal([]byte(repairJSON(extracted)), target); repairErr != nil {
+		return err
+	}
+	return nil
+}
+
+func isNone(text string) bool {
+	text = strings.Trim(strings.ToLower(strings.TrimSpace(text)), `."'`)
+	return text == "" || text == "none"
+}
+
+/*
+ParseSummaryResponse parses an LLM's summary of a
+conversation. It accepts a JSON object (with keywords as
+either an array or a comma delimited string), or the older
+"keyword, keyword | summary" format. If the LLM deemed the
+conversation not worth summarizing, nil is returned with no
+error.
+*/
+func ParseSummaryResponse(raw string) (*SummaryResponse, error) {
+	var keywords []string
+	var summary string
+
+	var structured struct {
+		Keywords json.RawMessage `json:"keywords"`
+		Summary  string          `json:"summary"`
+	}
+
+	if err := unmarshalTolerant(raw, &structured); err == nil && (structured.Summary != "" || len(structured.Keywords) > 0) {
+		summary = structured.Summary
+
+		var keywordList []string
+		var keywordString string
+		if len(structured.Keywords) == 0 || string(structured.Keywords) == "null" {
+			// No keywords
+		} else if json.Unmarshal(structured.Keywords, &keywordList) == nil {
+			keywords = keywordList
+		} else if json.Unmarshal(structured.Keywords, &keywordString) == nil {
+			keywords = strings.Split(keywordString, ",")
+		} else {
+			return nil, fmt.Errorf("keywords must be a list of strings")
+		}
+	} else {
+		split := strings.SplitN(raw, "|", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf(`expected a JSON object with "keywords" and "summary"`)
+		}
+		keywords = strings.Split(split[0], ",")
+		summary = split[1]
+	}
+
+	response := &SummaryResponse{
+		Keywords: []string{},
+		Summary:  strings.TrimSpace(summary),
+	}
+	for _, keyword := range keywords {
+		if !isNone(keyword) {
+			response.Keywords = append(response.Keywords, strings.TrimSpace(keyword))
+		}
+	}
+
+	if isNone(response.Summary) {
+		if len(response.Keywords) > 0 {
+			return nil, fmt.Errorf("summary must be set if keywords are")
+		}
+		return nil, nil
+	}
+
+	return response, nil
+}
+
+/*
+ParseKnowledgeResponse parses an LLM's extracted knowledge.
+It accepts a JSON array of knowledge sets, an object with
+them under "knowledge", or a single knowledge set. Knowledge
+sets that fail validation are dropped rather than failing
+the whole batch; an error is only returned if the response
+can not be parsed at all, or if every knowledge set in it
+was invalid.
+*/
+func ParseKnowledgeResponse(raw string) ([]*LearnResponse, error) {
+	var items []json.RawMessage
+
+	var wrapped struct {
+		Knowledge []json.RawMessage `json:"knowledge"`
+	}
+	var single map[string]interface{}
+
+	if err := unmarshalTolerant(raw, &items); err == nil {
+		// Plain array
+	} else if err := unmarshalTolerant(raw, &wrapped); err == nil && wrapped.Knowledge != nil {
+		items = wrapped.Knowledge
+	} else if err := unmarshalTolerant(raw, &single); err == nil && len(single) > 0 {
+		items = []json.RawMessage{json.RawMessage(extractJSON(raw))}
+	} else {
+		return nil, fmt.Errorf("expected a JSON array of knowledge sets")
+	}
+
+	responses := []*LearnResponse{}
+	invalid := []string{}
+	for index, item := range items {
+		response, err := parseLearnResponse(item)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("knowledge set %d: %s", index, err))
+			continue
+		}
+		responses = append(responses, response)
+	}
+
+	if len(responses) == 0 && len(invalid) > 0 {
+		return nil, fmt.Errorf("no valid knowledge sets: %s", strings.Join(invalid, "; "))
+	}
+
+	return responses, nil
+}
+
+/*
+parseLearnResponse reads a single knowledge set, accepting
+non-string values (ie "expires": 3) by converting them to
+strings, and validates it.
+*/
+func parseLearnResponse(raw json.RawMessage) (*LearnResponse, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("expected an object")
+	}
+
+	field := func(name string) string {
+		value, ok := fields[name]
+		if !ok || value == nil {
+			return ""
+		}
+		if text, ok := value.(string); ok {
+			return strings.TrimSpace(text)
+		}
+		return fmt.Sprint(value)
+	}
+
+	confidence, err := parseConfidence(field("confidence"))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &LearnResponse{
+		Subject:    field("subject"),
+		Predicate:  field("predicate"),
+		Object:     field("object"),
+		Expires:    field("expires"),
+		Confidence: confidence,
+	}
+
+	if err := response.Valid(); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+/*
+parseConfidence reads an LLM's confidence in a knowledge set,
+which may be given from 0 to 1 or as a percentage, ie "85%".
+No confidence at all is read as 0, and anything outside of
+0 to 1 once read is rejected.
+*/
+func parseConfidence(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+
+	percentage := strings.HasSuffix(raw, "%")
+	confidence, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(raw, "%")), 64)
+	if err != nil || math.IsNaN(confidence) {
+		return 0, fmt.Errorf("invalid confidence: %s", raw)
+	}
+
+	if percentage || (confidence > 1 && confidence <= 100) {
+		confidence = confidence / 100
+	}
+	if confidence < 0 || confidence > 1 {
+		return 0, fmt.Errorf("confidence out of range: %s", raw)
+	}
+	return confidence, nil
+}
+
+/*
+ParseCompressionResponse parses an LLM's compression of the
+given facts, which it refers to by their position in the
+list it was shown. It accepts a JSON object with groups
+under "duplicates" and "contradictions", or a bare array of
+groups as duplicates. Positions may be numbers or numeric
+strings. A response with nothing to compress results in an
+empty Compression.
+*/
+func ParseCompressionResponse(raw string, facts []*Knowledge) (*Compression, error) {
+	compression := &Compression{
+		Duplicates:     [][]string{},
+		Contradictions: [][]string{},
+	}
+	if isNone(raw) {
+		return compression, nil
+	}
+
+	var structured struct {
+		Duplicates     [][]interface{} `json:"duplicates"`
+		Contradictions [][]interface{} `json:"contradictions"`
+	}
+	var bare [][]interface{}
+
+	if err := unmarshalTolerant(raw, &bare); err == nil {
+		structured.Duplicates = bare
+	} else if err := unmarshalTolerant(raw, &structured); err != nil {
+		return nil, fmt.Errorf(`expected a JSON object with "duplicates" and "contradictions"`)
+	}
+
+	toIDs := func(groups [][]interface{}) ([][]string, error) {
+		converted := [][]string{}
+		for _, group := range groups {
+			ids := []string{}
+			for _, position := range group {
+				index, err := strconv.Atoi(strings.TrimSpace(fmt.Sprint(position)))
+				if err != nil {
+					return nil, fmt.Errorf("%v is not a fact number", position)
+				} else if index < 0 || index >= len(facts) {
+					return nil, fmt.Errorf("there is no fact %d", index)
+				}
+				ids = append(ids, facts[index].ID)
+			}
+			converted = append(converted, ids)
+		}
+		return converted, nil
+	}
+
+	var err error
+	compression.Duplicates, err = toIDs(structured.Duplicates)
+	if err != nil {
+		return nil, err
+	}
+	compression.Contradictions, err = toIDs(structured.Contradictions)
+	if err != nil {
+		return nil, err
+	}
+
+	return compression, nil
+}
@@ -0,0 +1,289 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"3 days":            3 * time.Hour * 24,
+		"1 day":             time.Hour * 24,
+		"8 hours":           8 * time.Hour,
+		"2 weeks":           2 * time.Hour * 24 * 7,
+		"6 months":          6 * time.Hour * 24 * 30,
+		"a month":           time.Hour * 24 * 30,
+		"3mo":               3 * time.Hour * 24 * 30,
+		"never":             neverExpires,
+		"Never.":            neverExpires,
+		"a few days":        3 * time.Hour * 24,
+		"a couple of hours": 2 * time.Hour,
+		"several weeks":     5 * time.Hour * 24 * 7,
+		"an hour":           time.Hour,
+		"two days":          2 * time.Hour * 24,
+		"day":               time.Hour * 24,
+		"3d":                3 * time.Hour * 24,
+		"2hrs":              2 * time.Hour,
+		"1.5 hours":         90 * time.Minute,
+		"1000000 years":     neverExpires,
+	}
+
+	for input, expected := range tests {
+		duration, err := parseDuration(input)
+		require.Nil(t, err, input)
+		assert.Equal(t, expected, duration, input)
+	}
+
+	for _, input := range []string{"", "soon", "3", "many days", "-2 days", "nan days", "inf days", "a day or so maybe"} {
+		_, err := parseDuration(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestMonthExpiry(t *testing.T) {
+	fact, err := ToKnowledge(&LearnResponse{Subject: "Rebecca", Predicate: "is studying", Object: "biology", Expires: "1 month"}, "Rose", "Rebecca", "", nil)
+	require.Nil(t, err)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, 30), fact.ExpiresAt, time.Minute)
+	assert.Equal(t, "1 month", fact.ExpirationToString())
+}
+
+func TestParseConfidence(t *testing.T) {
+	tests := map[string]float64{
+		"":     0,
+		"0":    0,
+		"0.9":  0.9,
+		"1":    1,
+		"85%":  0.85,
+		"60":   0.6,
+		"100%": 1,
+	}
+
+	for input, expected := range tests {
+		confidence, err := parseConfidence(input)
+		require.Nil(t, err, input)
+		assert.InDelta(t, expected, confidence, 0.001, input)
+	}
+
+	for _, input := range []string{"very", "NaN", "-0.2", "150", "120%", "-5%", "inf"} {
+		_, err := parseConfidence(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestParseSummaryResponse(t *testing.T) {
+	// JSON, as returned by function calling
+	response, err := ParseSummaryResponse(`{"keywords": ["robotics", "kinematics"], "summary": "planning a robot arm's motion"}`)
+	require.Nil(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, []string{"robotics", "kinematics"}, response.Keywords)
+	assert.Equal(t, "planning a robot arm's motion", response.Summary)
+
+	// JSON in a code fence with prose, and keywords as a string
+	response, err = ParseSummaryResponse("Sure! Here you go:\n```json\n{\"keywords\": \"robotics, kinematics\", \"summary\": \"robot arms\",}\n```")
+	require.Nil(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, []string{"robotics", "kinematics"}, response.Keywords)
+	assert.Equal(t, "robot arms", response.Summary)
+
+	// The legacy format
+	response, err = ParseSummaryResponse("robotics, kinematics | robot arms")
+	require.Nil(t, err)
+	require.NotNil(t, response)
+	assert.Equal(t, []string{"robotics", "kinematics"}, response.Keywords)
+	assert.Equal(t, "robot arms", response.Summary)
+
+	// Nothing worth summarizing
+	for _, raw := range []string{"none | none", `{"keywords": [], "summary": "none"}`, `{"keywords": ["none"], "summary": "None."}`} {
+		response, err = ParseSummaryResponse(raw)
+		assert.Nil(t, err, raw)
+		assert.Nil(t, response, raw)
+	}
+
+	// Invalid responses are errors rather than panics
+	for _, raw := range []string{"", "robotics and kinematics", `{"keywords": ["robotics"]}`, `{"keywords": 12, "summary": "robots"}`} {
+		response, err = ParseSummaryResponse(raw)
+		assert.NotNil(t, err, raw)
+		assert.Nil(t, response, raw)
+	}
+}
+
+func TestParseKnowledgeResponse(t *testing.T) {
+	// Function calling output
+	responses, err := ParseKnowledgeResponse(`{"knowledge": [{"subject": "Rose", "predicate": "has", "object": "headache", "expires": "3 hours"}]}`)
+	require.Nil(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, &LearnResponse{Subject: "Rose", Predicate: "has", Object: "headache", Expires: "3 hours"}, responses[0])
+
+	// A plain array with the mistakes our own prompt examples
+	// used to make - missing commas between objects and fields
+	responses, err = ParseKnowledgeResponse(`[{"subject": "Mitch", "predicate": "is", "object": "busy", "expires": "3 hours" }{"subject": "Rebecca" "predicate": "is studying", "object": "biology", "expires": "3 months"}]`)
+	require.Nil(t, err)
+	require.Len(t, responses, 2)
+	assert.Equal(t, "Mitch", responses[0].Subject)
+	assert.Equal(t, "Rebecca", responses[1].Subject)
+	assert.Equal(t, "is studying", responses[1].Predicate)
+
+	// A single knowledge set
+	responses, err = ParseKnowledgeResponse(`{"subject": "Jane", "predicate": "is married to", "object": "Chris", "expires": "never"}`)
+	require.Nil(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, "Chris", responses[0].Object)
+
+	// Nothing to learn
+	responses, err = ParseKnowledgeResponse(`{"knowledge": []}`)
+	require.Nil(t, err)
+	assert.Len(t, responses, 0)
+	responses, err = ParseKnowledgeResponse(`[]`)
+	require.Nil(t, err)
+	assert.Len(t, responses, 0)
+
+	// Invalid knowledge sets are dropped without failing the batch
+	responses, err = ParseKnowledgeResponse(`[
+		{"subject": "Keith", "predicate": "likes", "object": "robots", "expires": "a few days"},
+		{"subject": "Keith", "predicate": "likes", "object": "", "expires": "never"},
+		{"subject": "Keith", "predicate": "is", "object": "tired", "expires": "whenever"},
+		"Keith is tired"
+	]`)
+	require.Nil(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, "robots", responses[0].Object)
+
+	// Confidence may be given from 0 to 1, or as a percentage
+	responses, err = ParseKnowledgeResponse(`[
+		{"subject": "Keith", "predicate": "likes", "object": "robots", "expires": "never", "confidence": 0.9},
+		{"subject": "Keith", "predicate": "likes", "object": "pizza", "expires": "never", "confidence": "85%"},
+		{"subject": "Keith", "predicate": "likes", "object": "pasta", "expires": "never", "confidence": 60},
+		{"subject": "Keith", "predicate": "likes", "object": "sushi", "expires": "never", "confidence": "very"},
+		{"subject": "Keith", "predicate": "likes", "object": "tacos", "expires": "never", "confidence": 150}
+	]`)
+	require.Nil(t, err)
+	require.Len(t, responses, 3)
+	assert.InDelta(t, 0.9, responses[0].Confidence, 0.001)
+	assert.InDelta(t, 0.85, responses[1].Confidence, 0.001)
+	assert.InDelta(t, 0.6, responses[2].Confidence, 0.001)
+
+	// ...unless all of them are invalid
+	responses, err = ParseKnowledgeResponse(`[{"subject": "Keith", "predicate": "is", "object": "tired", "expires": "whenever"}]`)
+	assert.NotNil(t, err)
+	assert.Nil(t, responses)
+
+	// Unparseable responses are errors
+	for _, raw := range []string{"", "Keith likes robots", `{"knowledge": "Keith likes robots"}`, `[{"subject": `} {
+		responses, err = ParseKnowledgeResponse(raw)
+		assert.NotNil(t, err, raw)
+		assert.Nil(t, responses, raw)
+	}
+}
+
+func TestParseCompressionResponse(t *testing.T) {
+	facts := []*Knowledge{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+
+	compression, err := ParseCompressionResponse(`{"duplicates": [[2, 0]], "contradictions": [["3", 1]]}`, facts)
+	require.Nil(t, err)
+	assert.Equal(t, [][]string{{"c", "a"}}, compression.Duplicates)
+	assert.Equal(t, [][]string{{"d", "b"}}, compression.Contradictions)
+
+	// The older format, with groups as a bare array
+	compression, err = ParseCompressionResponse("```\n[[1, 3], [0, 2],]\n```", facts)
+	require.Nil(t, err)
+	assert.Equal(t, [][]string{{"b", "d"}, {"a", "c"}}, compression.Duplicates)
+	assert.Empty(t, compression.Contradictions)
+
+	// Nothing to compress
+	for _, raw := range []string{"", "none", "{}", `{"duplicates": [], "contradictions": []}`} {
+		compression, err = ParseCompressionResponse(raw, facts)
+		require.Nil(t, err, raw)
+		assert.Empty(t, compression.Duplicates, raw)
+		assert.Empty(t, compression.Contradictions, raw)
+	}
+
+	// Facts that weren't given are errors
+	for _, raw := range []string{`[[0, 4]]`, `{"contradictions": [[-1, 0]]}`, `[["first", 0]]`, "Keith is tired"} {
+		compression, err = ParseCompressionResponse(raw, facts)
+		assert.NotNil(t, err, raw)
+		assert.Nil(t, compression, raw)
+	}
+}
+
+func FuzzParseDuration(f *testing.F) {
+	for _, seed := range []string{"3 days", "never", "a few hours", "2hrs", "1.5 weeks", "", "0x10 days", "1e300 years"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		duration, err := parseDuration(input)
+		if err == nil && (duration < 0 || duration > neverExpires) {
+			t.Errorf("duration %s for %q out of range", duration, input)
+		}
+	})
+}
+
+func FuzzParseSummaryResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"keywords": ["robotics"], "summary": "robot arms"}`,
+		"robotics, kinematics | robot arms",
+		"none | none",
+		"|",
+		"```json\n{\"keywords\": \"a, b\", \"summary\": \"c\"}\n```",
+		`{"keywords": [1, 2], "summary": {}}`,
+		"}{",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		response, err := ParseSummaryResponse(raw)
+		if err != nil {
+			if response != nil {
+				t.Errorf("response returned alongside error for %q", raw)
+			}
+			return
+		}
+		if response == nil {
+			return
+		}
+		if isNone(response.Summary) {
+			t.Errorf("empty summary returned for %q", raw)
+		}
+		for _, keyword := range response.Keywords {
+			if isNone(keyword) || keyword != strings.TrimSpace(keyword) {
+				t.Errorf("invalid keyword %q returned for %q", keyword, raw)
+			}
+		}
+	})
+}
+
+func FuzzParseKnowledgeResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"knowledge": [{"subject": "Rose", "predicate": "has", "object": "headache", "expires": "3 hours"}]}`,
+		`[{"subject": "Mitch", "predicate": "is", "object": "busy", "expires": "3 hours" }{"subject": "Rose" "predicate": "is", "object": "busy", "expires": "never"}]`,
+		`{"subject": "Jane", "predicate": "is married to", "object": "Chris", "expires": 3}`,
+		`[null, 1, "a", [], {}]`,
+		`{"knowledge": null}`,
+		"[",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, raw string) {
+		responses, err := ParseKnowledgeResponse(raw)
+		if err != nil {
+			if responses != nil {
+				t.Errorf("responses returned alongside error for %q", raw)
+			}
+			return
+		}
+		for _, response := range responses {
+			if err := response.Valid(); err != nil {
+				t.Errorf("invalid response %+v returned for %q: %s", response, raw, err)
+			}
+			if _, err := ToKnowledge(response, "agent", "user", "conversation", []string{"message"}); err != nil {
+				t.Errorf("response %+v can not become knowledge for %q: %s", response, raw, err)
+			}
+		}
+	})
+}
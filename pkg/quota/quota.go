@@ -0,0 +1,122 @@
+package quota
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+These are the periods that quotas are counted over.
+*/
+const (
+	PeriodMinute = "minute"
+	PeriodDay    = "day"
+)
+
+/*
+These are the scopes quotas can be applied to. The
+tenant, agent and user scopes are suffixed with their
+IDs to form the counter's key.
+*/
+const (
+	ScopeGlobal = "global"
+	ScopeTenant = "tenant"
+	ScopeAgent  = "agent"
+	ScopeUser   = "user"
+)
+
+/*
+Limits is a set of request and token limits. Any limit
+that is <= 0 is considered unlimited.
+*/
+type Limits struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	RequestsPerDay    int `json:"requests_per_day,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"`
+	TokensPerDay      int `json:"tokens_per_day,omitempty"`
+}
+
+// Unlimited is true if no limits are set
+func (limits Limits) Unlimited() bool {
+	return limits.RequestsPerMinute <= 0 &&
+		limits.RequestsPerDay <= 0 &&
+		limits.TokensPerMinute <= 0 &&
+		limits.TokensPerDay <= 0
+}
+
+// Requests returns the request limit for the given period
+func (limits Limits) Requests(period string) int {
+	if period == PeriodMinute {
+		return limits.RequestsPerMinute
+	}
+	return limits.RequestsPerDay
+}
+
+// Tokens returns the token limit for the given period
+func (limits Limits) Tokens(period string) int {
+	if period == PeriodMinute {
+		return limits.TokensPerMinute
+	}
+	return limits.TokensPerDay
+}
+
+/*
+Counter is the count of requests and tokens consumed by
+a given scope within a single period, starting at
+PeriodStart.
+*/
+type Counter struct {
+	Scope       string    `json:"scope,omitempty" db:"scope"`
+	Period      string    `json:"period,omitempty" db:"period"`
+	PeriodStart time.Time `json:"period_start,omitempty" db:"period_start"`
+	Requests    int       `json:"requests" db:"requests"`
+	Tokens      int       `json:"tokens" db:"tokens"`
+}
+
+/*
+Key generates the counter key for a given scope and ID.
+The global scope ignores the ID.
+*/
+func Key(scope string, id string) string {
+	if scope == ScopeGlobal {
+		return ScopeGlobal
+	}
+	return fmt.Sprintf("%s:%s", scope, id)
+}
+
+/*
+PeriodStart returns the start of the period that the
+given time falls within. All periods are in UTC so that
+multiple instances agree on their boundaries.
+*/
+func PeriodStart(period string, at time.Time) time.Time {
+	at = at.UTC()
+	if period == PeriodMinute {
+		return at.Truncate(time.Minute)
+	}
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+/*
+QuotaExceededError is returned when a request would exceed
+a configured quota. RetryAfter is when the period the quota
+was exceeded in ends.
+*/
+type QuotaExceededError struct {
+	Scope      string
+	Period     string
+	Limit      string
+	RetryAfter time.Time
+}
+
+func (err *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s per %s for %s", err.Limit, err.Period, err.Scope)
+}
+
+// PeriodEnd returns the end of the period that started at start
+func PeriodEnd(period string, start time.Time) time.Time {
+	if period == PeriodMinute {
+		return start.Add(time.Minute)
+	}
+	return start.Add(24 * time.Hour)
+}
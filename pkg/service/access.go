@@ -0,0 +1,201 @@
+package service
+
+import (
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/users"
+)
+
+/*
+authorizeChat ensures that a user may talk with an agent.
+Beyond sharing the agent's organization, admins may reach
+any agent, anyone may reach a public agent, and only members
+may reach an invite-only or private one. Users that don't
+exist are members with no memberships.
+*/
+func authorizeChat(db store.Store, agent *agents.Agent, userId string) error {
+	err := checkUserTenancy(db, agent.ID, agent.Tenant, userId)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := canReach(db, agent, userId)
+	if err != nil {
+		return err
+	} else if !allowed {
+		return &PermissionError{User: userId, Action: "chat with", Resource: "agent " + agent.ID}
+	}
+	return nil
+}
+
+/*
+authorizeChatWith is authorizeChat for an agent not yet
+loaded. Agents that don't exist are public agents of the
+default tenant.
+*/
+func authorizeChatWith(db store.Store, agentId string, userId string) error {
+	agent, err := db.GetAgent(agentId)
+	if err != nil {
+		return err
+	} else if agent == nil {
+		return checkUserTenancy(db, agentId, "", userId)
+	}
+	return authorizeChat(db, agent, userId)
+}
+
+// canReach is whether a user within an agent's organization
+// may talk with it
+func canReach(db store.Store, agent *agents.Agent, userId string) (bool, error) {
+	if agent.Visibility == "" || agent.Visibility == agents.VisibilityPublic {
+		return true, nil
+	}
+
+	user, err := db.GetUser(userId)
+	if err != nil {
+		return false, err
+	} else if user != nil && user.Role == users.RoleAdmin {
+		return true, nil
+	}
+
+	return isMember(db, agent.ID, userId)
+}
+
+func isMember(db store.Store, agentId string, userId string) (bool, error) {
+	members, err := db.ListAgentMembers(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+			{
+				Attribute: "user",
+				Value:     userId,
+				Operation: store.EQ,
+			},
+		},
+		Limit: 1,
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(members) > 0, nil
+}
+
+/*
+authorizeOwner ensures that an actor may act on what was
+said between a user and an agent - a message, conversation,
+or summary of kind and id - that it is that user, or an
+admin of the agent's organization. Anything of another
+organization is reported as not found.
+*/
+func authorizeOwner(db store.Store, actorId string, agentId string, ownerId string, action string, kind string, id string) error {
+	err := checkTenancy(db, agentId, actorId)
+	if _, ok := err.(*NotFoundError); ok {
+		return &NotFoundError{Kind: kind, ID: id}
+	} else if err != nil {
+		return err
+	}
+
+	if actorId != "" && actorId == ownerId {
+		return nil
+	}
+
+	agent, err := db.GetAgent(agentId)
+	if err != nil {
+		return err
+	}
+	tenant := ""
+	if agent != nil {
+		tenant = agent.Tenant
+	}
+
+	_, err = authorizeRole(db, actorId, tenant, action, kind+" "+id, users.RoleAdmin)
+	return err
+}
+
+/*
+authorizeUserData ensures that an actor may act on everything
+stored about a user - that it is that user, or an admin of
+their organization. A user whose profile is gone is found
+through the audits of their erasures instead.
+*/
+func authorizeUserData(db store.Store, actorId string, userId string, action string) error {
+	if actorId != "" && actorId == userId {
+		return nil
+	}
+
+	tenant := ""
+	user, err := db.GetUser(userId)
+	if err != nil {
+		return err
+	} else if user != nil {
+		tenant = user.Tenant
+	} else {
+		erasures, err := db.ListErasures(store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{
+					Attribute: "user",
+					Value:     userId,
+					Operation: store.EQ,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		} else if len(erasures) > 0 {
+			tenant = erasures[len(erasures)-1].Tenant
+		}
+	}
+
+	_, err = authorizeRole(db, actorId, tenant, action, "user "+userId, users.RoleAdmin)
+	return err
+}
+
+/*
+authorizeRole ensures that an actor exists within a tenant
+and holds one of the given roles, returning the actor.
+*/
+func authorizeRole(db store.Store, actorId string, tenant string, action string, resource string, roles ...string) (*users.User, error) {
+	actor, err := db.GetUser(actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	denied := &PermissionError{User: actorId, Action: action, Resource: resource}
+	if actor == nil || actor.Tenant != tenant {
+		return nil, denied
+	}
+	for _, role := range roles {
+		if actor.Role == role {
+			return actor, nil
+		}
+	}
+	return nil, denied
+}
+
+/*
+authorizeManage ensures that an actor may change an agent -
+that it is an admin or agent editor of the agent's
+organization. An agent of another organization is reported
+as not found.
+*/
+func authorizeManage(db store.Store, actorId string, agentId string, action string) (*agents.Agent, error) {
+	agent, err := db.GetAgent(agentId)
+	if err != nil {
+		return nil, err
+	} else if agent == nil {
+		return nil, &NotFoundError{Kind: "agent", ID: agentId}
+	}
+
+	err = checkUserTenancy(db, agentId, agent.Tenant, actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = authorizeRole(db, actorId, agent.Tenant, action, "agent "+agentId, users.RoleAdmin, users.RoleAgentEditor)
+	if err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentAccess(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, _, err := createMockService(llm)
+	require.Nil(t, err)
+
+	require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, &tenants.Organization{ID: "acme", Name: "Acme"}))
+	for _, user := range []*users.User{
+		{ID: "admin", Name: "Admin", Role: users.RoleAdmin},
+		{ID: "editor", Name: "Editor", Role: users.RoleAgentEditor},
+		{ID: "keith", Name: "Keith"},
+		{ID: "abby", Name: "Abby"},
+	} {
+		user.Tenant = "acme"
+		user.CreatedAt = time.Now()
+		require.Nil(t, service.Users.CreateUser(user, "supersecret"))
+	}
+
+	// Users are members unless told otherwise
+	keith, err := service.db.GetUser("keith")
+	require.Nil(t, err)
+	assert.Equal(t, users.RoleMember, keith.Role)
+
+	var permission *PermissionError
+	var invalid *InvalidRequestError
+
+	// Only admins and agent editors manage agents
+	err = service.Agents.CreateAgent("keith", &agents.Agent{ID: "rose", Name: "Rose"})
+	require.True(t, errors.As(err, &permission))
+	assert.Equal(t, "keith", permission.User)
+	err = service.Agents.CreateAgent("editor", &agents.Agent{ID: "rose", Name: "Rose", Visibility: "secret"})
+	require.True(t, errors.As(err, &invalid))
+
+	public := &agents.Agent{ID: "public", Name: "Public"}
+	rose := &agents.Agent{ID: "rose", Name: "Rose", Visibility: agents.VisibilityInviteOnly}
+	secret := &agents.Agent{ID: "secret", Name: "Secret", Visibility: agents.VisibilityPrivate}
+	for _, agent := range []*agents.Agent{public, rose, secret} {
+		require.Nil(t, service.Agents.CreateAgent("editor", agent))
+		assert.Equal(t, "acme", agent.Tenant)
+	}
+	assert.Equal(t, agents.VisibilityPublic, public.Visibility)
+
+	send := func(agent string, user string) error {
+		llm.AddSendMessageResponse(&chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     agent,
+			From:      agent,
+			Content:   "Hello",
+			CreatedAt: time.Now(),
+		}, nil)
+		_, err := service.SendMessage(&chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: uuid.New().String(),
+			Agent:        agent,
+			User:         user,
+			From:         user,
+			Content:      "Hi",
+			CreatedAt:    time.Now(),
+		})
+		return err
+	}
+
+	// Anyone may talk to a public agent, but only members to
+	// the others
+	require.Nil(t, send(public.ID, "keith"))
+	err = send(rose.ID, "keith")
+	require.True(t, errors.As(err, &permission))
+	assert.Equal(t, "agent rose", permission.Resource)
+
+	err = service.Agents.AddMember("keith", rose.ID, "keith")
+	require.True(t, errors.As(err, &permission))
+	require.Nil(t, service.Agents.AddMember("editor", rose.ID, "keith"))
+	require.Nil(t, service.Agents.AddMember("editor", secret.ID, "keith"))
+	require.Nil(t, send(rose.ID, "keith"))
+
+	members, err := service.Agents.ListMembers("admin", rose.ID)
+	require.Nil(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "keith", members[0].User)
+
+	// Admins may reach every agent
+	require.Nil(t, send(secret.ID, "admin"))
+
+	// Non-members can't read an agent's history either
+	_, err = service.Messages.GetMessages(&GetMessagesRequest{Agent: rose.ID, User: "abby", Time: time.Now().Add(-time.Hour)})
+	require.True(t, errors.As(err, &permission))
+	_, err = service.Messages.GetConversations(&GetConversationsRequest{Agent: rose.ID, User: "abby", Time: time.Now().Add(-time.Hour)})
+	require.True(t, errors.As(err, &permission))
+	_, err = service.Summary.GetSummaries(&GetSummariesRequest{Agent: rose.ID, User: "abby", Time: time.Now().Add(-time.Hour)})
+	require.True(t, errors.As(err, &permission))
+	messages, err := service.Messages.GetMessages(&GetMessagesRequest{Agent: rose.ID, User: "keith", Time: time.Now().Add(-time.Hour)})
+	require.Nil(t, err)
+	assert.Len(t, messages, 2)
+
+	// Private agents are only listed to their members
+	ids := func(user string) []string {
+		listed, err := service.Agents.ListVisibleAgents(user)
+		require.Nil(t, err)
+		found := []string{}
+		for _, agent := range listed {
+			found = append(found, agent.ID)
+		}
+		return found
+	}
+	assert.ElementsMatch(t, []string{public.ID, rose.ID}, ids("abby"))
+	assert.ElementsMatch(t, []string{public.ID, rose.ID, secret.ID}, ids("keith"))
+	assert.ElementsMatch(t, []string{public.ID, rose.ID, secret.ID}, ids("admin"))
+
+	require.Nil(t, service.Agents.RemoveMember("editor", rose.ID, "keith"))
+	err = send(rose.ID, "keith")
+	require.True(t, errors.As(err, &permission))
+
+	// Only admins change roles
+	err = service.Users.SetRole("editor", "keith", users.RoleAgentEditor)
+	require.True(t, errors.As(err, &permission))
+	err = service.Users.SetRole("admin", "keith", "owner")
+	require.True(t, errors.As(err, &invalid))
+	require.Nil(t, service.Users.SetRole("admin", "keith", users.RoleAgentEditor))
+	require.Nil(t, service.Agents.AddMember("keith", rose.ID, "abby"))
+	require.Nil(t, send(rose.ID, "abby"))
+}
@@ -1,8 +1,12 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/hlfshell/coppermind/internal/store"
 	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/users"
 )
 
 type AgentService struct {
@@ -15,10 +19,152 @@ func NewAgentService(db store.Store) *AgentService {
 	}
 }
 
-func (service *AgentService) CreateAgent(agent *agents.Agent) error {
+/*
+CreateAgent saves an agent on behalf of an actor, who must be
+an admin or agent editor of the agent's organization. Agents
+default to the actor's organization, and to being public. An
+existing agent is replaced, and a change of its identity is
+kept as a new version authored by the actor.
+*/
+func (service *AgentService) CreateAgent(actorId string, agent *agents.Agent) error {
+	if agent.Visibility == "" {
+		agent.Visibility = agents.VisibilityPublic
+	} else if !agents.ValidVisibility(agent.Visibility) {
+		return &InvalidRequestError{Err: fmt.Errorf("unknown visibility %s", agent.Visibility)}
+	}
+
+	actor, err := service.db.GetUser(actorId)
+	if err != nil {
+		return err
+	} else if actor != nil && agent.Tenant == "" {
+		agent.Tenant = actor.Tenant
+	}
+
+	err = checkOrganization(service.db, agent.Tenant)
+	if err != nil {
+		return err
+	}
+
+	_, err = authorizeRole(service.db, actorId, agent.Tenant, "create", "agent "+agent.ID, users.RoleAdmin, users.RoleAgentEditor)
+	if err != nil {
+		return err
+	}
+
+	// Another organization's agent can't be replaced
+	existing, err := service.db.GetAgent(agent.ID)
+	if err != nil {
+		return err
+	} else if existing != nil && existing.Tenant != agent.Tenant {
+		return &PermissionError{User: actorId, Action: "replace", Resource: "agent " + agent.ID}
+	}
+
+	return service.saveVersioned(actorId, agent, existing)
+}
+
+/*
+saveVersioned saves an agent, first recording its identity as
+a new revision if it differs from that of the existing agent
+*/
+func (service *AgentService) saveVersioned(actorId string, agent *agents.Agent, existing *agents.Agent) error {
+	agent.Version = 0
+	if existing != nil {
+		agent.Version = existing.Version
+	}
+
+	if existing == nil || existing.Identity != agent.Identity {
+		agent.Version++
+		err := service.db.SaveAgentRevision(&agents.Revision{
+			Agent:     agent.ID,
+			Version:   agent.Version,
+			Identity:  agent.Identity,
+			Author:    actorId,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return service.db.SaveAgent(agent)
 }
 
+// ListRevisions lists every version of an agent's identity,
+// oldest first
+func (service *AgentService) ListRevisions(actorId string, agentId string) ([]*agents.Revision, error) {
+	_, err := authorizeManage(service.db, actorId, agentId, "view the history of")
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.ListAgentRevisions(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+		},
+	})
+}
+
+/*
+DiffRevisions compares two versions of an agent's identity
+line by line
+*/
+func (service *AgentService) DiffRevisions(actorId string, agentId string, from int, to int) ([]agents.DiffLine, error) {
+	_, err := authorizeManage(service.db, actorId, agentId, "view the history of")
+	if err != nil {
+		return nil, err
+	}
+
+	fromRevision, err := service.getRevision(agentId, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := service.getRevision(agentId, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return agents.DiffIdentities(fromRevision.Identity, toRevision.Identity), nil
+}
+
+/*
+Rollback restores an earlier version of an agent's identity.
+The restored identity becomes a new version, so that the
+versions rolled back from are kept.
+*/
+func (service *AgentService) Rollback(actorId string, agentId string, version int) (*agents.Agent, error) {
+	agent, err := authorizeManage(service.db, actorId, agentId, "roll back")
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := service.getRevision(agentId, version)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := *agent
+	agent.Identity = revision.Identity
+	err = service.saveVersioned(actorId, agent, &existing)
+	if err != nil {
+		return nil, err
+	}
+
+	return agent, nil
+}
+
+func (service *AgentService) getRevision(agentId string, version int) (*agents.Revision, error) {
+	revision, err := service.db.GetAgentRevision(agentId, version)
+	if err != nil {
+		return nil, err
+	} else if revision == nil {
+		return nil, &NotFoundError{Kind: "revision", ID: fmt.Sprintf("%s v%d", agentId, version)}
+	}
+	return revision, nil
+}
+
 func (service *AgentService) GetAgent(id string) (*agents.Agent, error) {
 	return service.db.GetAgent(id)
 }
@@ -26,3 +172,109 @@ func (service *AgentService) GetAgent(id string) (*agents.Agent, error) {
 func (service *AgentService) GetAgents() ([]*agents.Agent, error) {
 	return service.db.ListAgents()
 }
+
+/*
+ListAgents returns the agents of a single organization, or
+of the default tenant if tenant is "", to an actor within
+it or the server's operator. Those within it see only the
+agents listed to them, as with ListVisibleAgents.
+*/
+func (service *AgentService) ListAgents(actorId string, tenant string) ([]*agents.Agent, error) {
+	actor, err := authorizeMembership(service.db, actorId, tenant, "list the agents of")
+	if err != nil {
+		return nil, err
+	} else if actor.Tenant == tenant {
+		return service.ListVisibleAgents(actorId)
+	}
+
+	err = checkOrganization(service.db, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return listTenantAgents(service.db, tenant)
+}
+
+/*
+ListVisibleAgents returns the agents of a user's organization
+that are listed to them - every agent but the private ones
+they aren't a member of.
+*/
+func (service *AgentService) ListVisibleAgents(userId string) ([]*agents.Agent, error) {
+	user, err := service.db.GetUser(userId)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, &NotFoundError{Kind: "user", ID: userId}
+	}
+
+	all, err := listTenantAgents(service.db, user.Tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []*agents.Agent{}
+	for _, agent := range all {
+		if agent.Visibility == agents.VisibilityPrivate {
+			allowed, err := canReach(service.db, agent, userId)
+			if err != nil {
+				return nil, err
+			} else if !allowed {
+				continue
+			}
+		}
+		visible = append(visible, agent)
+	}
+	return visible, nil
+}
+
+/*
+AddMember lets a user reach an invite-only or private agent.
+The actor must be an admin or agent editor, and the user
+must be of the agent's organization.
+*/
+func (service *AgentService) AddMember(actorId string, agentId string, userId string) error {
+	agent, err := authorizeManage(service.db, actorId, agentId, "add members to")
+	if err != nil {
+		return err
+	}
+
+	user, err := service.db.GetUser(userId)
+	if err != nil {
+		return err
+	} else if user == nil || user.Tenant != agent.Tenant {
+		return &NotFoundError{Kind: "user", ID: userId}
+	}
+
+	return service.db.SaveAgentMember(&agents.Member{
+		Agent:     agentId,
+		User:      userId,
+		CreatedAt: time.Now(),
+	})
+}
+
+// RemoveMember removes a user's membership of an agent
+func (service *AgentService) RemoveMember(actorId string, agentId string, userId string) error {
+	_, err := authorizeManage(service.db, actorId, agentId, "remove members from")
+	if err != nil {
+		return err
+	}
+	return service.db.DeleteAgentMember(agentId, userId)
+}
+
+// ListMembers lists an agent's members, oldest first
+func (service *AgentService) ListMembers(actorId string, agentId string) ([]*agents.Member, error) {
+	_, err := authorizeManage(service.db, actorId, agentId, "list members of")
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.ListAgentMembers(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRevisions(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, _, err := createMockService(llm)
+	require.Nil(t, err)
+
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "admin", Name: "Admin", CreatedAt: time.Now(), Role: users.RoleAdmin}, "supersecret"))
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "editor", Name: "Editor", CreatedAt: time.Now(), Role: users.RoleAgentEditor}, "supersecret"))
+
+	first := "You are Rose.\nYou like gardening."
+	second := "You are Rose.\nYou like baking.\nYou are cheerful."
+
+	rose := &agents.Agent{ID: "rose", Name: "Rose", Identity: first}
+	require.Nil(t, service.Agents.CreateAgent("admin", rose))
+	assert.Equal(t, 1, rose.Version)
+
+	send := func() *chat.Message {
+		llm.AddSendMessageResponse(&chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     rose.ID,
+			From:      rose.ID,
+			Content:   "Hello",
+			CreatedAt: time.Now(),
+		}, nil)
+		response, err := service.SendMessage(&chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: uuid.New().String(),
+			Agent:        rose.ID,
+			User:         testUser.ID,
+			From:         testUser.ID,
+			Content:      "Hi",
+			CreatedAt:    time.Now(),
+		})
+		require.Nil(t, err)
+		saved, err := service.db.GetMessage(response.ID)
+		require.Nil(t, err)
+		return saved
+	}
+
+	// Replies record the version of the identity that wrote them
+	assert.Equal(t, 1, send().AgentVersion)
+
+	// Only a change of identity makes a new version
+	rose = &agents.Agent{ID: "rose", Name: "Rose", Identity: second}
+	require.Nil(t, service.Agents.CreateAgent("editor", rose))
+	assert.Equal(t, 2, rose.Version)
+	rose = &agents.Agent{ID: "rose", Name: "Rose the Baker", Identity: second}
+	require.Nil(t, service.Agents.CreateAgent("editor", rose))
+	assert.Equal(t, 2, rose.Version)
+	assert.Equal(t, 2, send().AgentVersion)
+
+	revisions, err := service.Agents.ListRevisions("editor", rose.ID)
+	require.Nil(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, "admin", revisions[0].Author)
+	assert.Equal(t, first, revisions[0].Identity)
+	assert.Equal(t, "editor", revisions[1].Author)
+
+	diff, err := service.Agents.DiffRevisions("editor", rose.ID, 1, 2)
+	require.Nil(t, err)
+	assert.Equal(t, []agents.DiffLine{
+		{Op: agents.DiffEqual, Text: "You are Rose."},
+		{Op: agents.DiffDelete, Text: "You like gardening."},
+		{Op: agents.DiffInsert, Text: "You like baking."},
+		{Op: agents.DiffInsert, Text: "You are cheerful."},
+	}, diff)
+
+	_, err = service.Agents.DiffRevisions("editor", rose.ID, 1, 5)
+	var notFound *NotFoundError
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "revision", notFound.Kind)
+
+	// Rolling back restores the identity as a new version
+	rolledBack, err := service.Agents.Rollback("admin", rose.ID, 1)
+	require.Nil(t, err)
+	assert.Equal(t, 3, rolledBack.Version)
+	assert.Equal(t, first, rolledBack.Identity)
+	assert.Equal(t, "Rose the Baker", rolledBack.Name)
+	agent, err := service.Agents.GetAgent(rose.ID)
+	require.Nil(t, err)
+	assert.Equal(t, first, agent.Identity)
+	assert.Equal(t, 3, send().AgentVersion)
+
+	revisions, err = service.Agents.ListRevisions("admin", rose.ID)
+	require.Nil(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, first, revisions[2].Identity)
+
+	// Members can see neither the history nor change it
+	var permission *PermissionError
+	_, err = service.Agents.ListRevisions(testUser.ID, rose.ID)
+	require.True(t, errors.As(err, &permission))
+	_, err = service.Agents.Rollback(testUser.ID, rose.ID, 2)
+	require.True(t, errors.As(err, &permission))
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/documents"
+)
+
+type ArtifactService struct {
+	db        store.Store
+	documents *DocumentService
+}
+
+func NewArtifactService(db store.Store, documents *DocumentService) *ArtifactService {
+	return &ArtifactService{
+		db:        db,
+		documents: documents,
+	}
+}
+
+/*
+UploadArtifactRequest is a new artifact for an existing
+message. If Type is not set it is guessed from the MIME
+type, which in turn is detected from the data if not set.
+Documents are indexed for retrieval in the message's
+conversation, or the user's library if their metadata sets
+documents.LibraryKey.
+*/
+type UploadArtifactRequest struct {
+	Message  string
+	Type     string
+	MimeType string
+	Filename string
+	Metadata map[string]string
+	Data     []byte
+}
+
+/*
+Upload saves an artifact to a message on behalf of an actor,
+who must be the message's user or an admin of the agent's
+organization.
+*/
+func (service *ArtifactService) Upload(actorId string, request *UploadArtifactRequest) (*artifacts.ArtifactData, error) {
+	if request.Message == "" {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("message must be set")}
+	}
+
+	message, err := service.db.GetMessage(request.Message)
+	if err != nil {
+		return nil, err
+	} else if message == nil {
+		return nil, &NotFoundError{Kind: "message", ID: request.Message}
+	}
+
+	err = authorizeOwner(service.db, actorId, message.Agent, message.User, "upload artifacts to", "message", message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactType := request.Type
+	if artifactType == "" {
+		mimeType := request.MimeType
+		if mimeType == "" {
+			mimeType = http.DetectContentType(request.Data)
+		}
+		artifactType = artifacts.TypeForMimeType(mimeType)
+		if artifactType == "" {
+			return nil, &InvalidRequestError{Err: fmt.Errorf("the artifact type can not be determined from %s", mimeType)}
+		}
+	}
+
+	artifact := &artifacts.ArtifactData{
+		ID:        uuid.New().String(),
+		Message:   message.ID,
+		Type:      artifactType,
+		MimeType:  request.MimeType,
+		Filename:  request.Filename,
+		Metadata:  request.Metadata,
+		CreatedAt: time.Now(),
+		Data:      request.Data,
+	}
+
+	// Validate here so that bad uploads can be told apart from
+	// failures to save them
+	if err := artifact.Validate(); err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	if documents.IsDocument(artifact) {
+		if _, err := documents.ExtractText(artifact); err != nil {
+			return nil, &InvalidRequestError{Err: err}
+		}
+	}
+
+	err = service.db.SaveArtifact(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	if documents.IsDocument(artifact) {
+		agent, err := service.db.GetAgent(message.Agent)
+		if err != nil {
+			return nil, err
+		} else if agent == nil {
+			return nil, &NotFoundError{Kind: "agent", ID: message.Agent}
+		}
+
+		_, err = service.documents.Index(agent, message, artifact)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return artifact, nil
+}
+
+/*
+Download returns a message's artifact to the message's user
+or an admin of the agent's organization, or nil if the
+message has no such artifact.
+*/
+func (service *ArtifactService) Download(actorId string, messageId string, id string) (*artifacts.ArtifactData, error) {
+	artifact, err := service.db.GetArtifact(id)
+	if err != nil {
+		return nil, err
+	} else if artifact == nil || artifact.Message != messageId {
+		return nil, nil
+	}
+
+	message, err := service.db.GetMessage(messageId)
+	if err != nil {
+		return nil, err
+	} else if message == nil {
+		return nil, nil
+	}
+
+	err = authorizeOwner(service.db, actorId, message.Agent, message.User, "download", "artifact", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifact, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifacts(t *testing.T) {
+	service, store, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Content:      "Here's a picture of my dog",
+		CreatedAt:    time.Now(),
+	}
+	require.Nil(t, store.SaveMessage(msg))
+
+	// The type is detected from the data if not given
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	artifact, err := service.Artifacts.Upload(testUser.ID, &UploadArtifactRequest{
+		Message:  msg.ID,
+		Filename: "cooper.png",
+		Data:     png,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, artifacts.TypeImage, artifact.Type)
+	assert.Equal(t, "image/png", artifact.MimeType)
+	assert.Equal(t, int64(len(png)), artifact.Size)
+
+	downloaded, err := service.Artifacts.Download(testUser.ID, msg.ID, artifact.ID)
+	require.Nil(t, err)
+	require.NotNil(t, downloaded)
+	assert.True(t, artifact.Equal(downloaded))
+
+	// ...or from the MIME type
+	artifact, err = service.Artifacts.Upload(testUser.ID, &UploadArtifactRequest{
+		Message:  msg.ID,
+		MimeType: "application/json",
+		Data:     []byte(`{"breed": "corgi"}`),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, artifacts.TypeJSON, artifact.Type)
+
+	updated, err := store.GetMessage(msg.ID)
+	require.Nil(t, err)
+	assert.Len(t, updated.Artifacts, 2)
+
+	// Artifacts are only downloaded through their own message
+	downloaded, err = service.Artifacts.Download(testUser.ID, uuid.New().String(), artifact.ID)
+	require.Nil(t, err)
+	assert.Nil(t, downloaded)
+
+	// Only the message's user or an admin may upload to it or
+	// download from it
+	require.Nil(t, store.CreateUser(&users.User{ID: "abby", Name: "Abby", CreatedAt: time.Now()}, "supersecret"))
+	var permissionErr *PermissionError
+	_, err = service.Artifacts.Download("abby", msg.ID, artifact.ID)
+	assert.True(t, errors.As(err, &permissionErr))
+	_, err = service.Artifacts.Upload("abby", &UploadArtifactRequest{
+		Message: msg.ID,
+		Type:    artifacts.TypeText,
+		Data:    []byte("Hello"),
+	})
+	assert.True(t, errors.As(err, &permissionErr))
+	downloaded, err = service.Artifacts.Download(testOperator.ID, msg.ID, artifact.ID)
+	require.Nil(t, err)
+	assert.NotNil(t, downloaded)
+
+	// Uploads to unknown messages are not found
+	_, err = service.Artifacts.Upload(testUser.ID, &UploadArtifactRequest{
+		Message: uuid.New().String(),
+		Type:    artifacts.TypeText,
+		Data:    []byte("Hello"),
+	})
+	var notFoundErr *NotFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	// Invalid artifacts are rejected as such
+	var invalidErr *InvalidRequestError
+	_, err = service.Artifacts.Upload(testUser.ID, &UploadArtifactRequest{
+		Message: msg.ID,
+		Type:    artifacts.TypeLink,
+		Data:    []byte("not a link"),
+	})
+	assert.True(t, errors.As(err, &invalidErr))
+
+	_, err = service.Artifacts.Upload(testUser.ID, &UploadArtifactRequest{
+		Message:  msg.ID,
+		MimeType: "application/pdf",
+		Data:     []byte("%PDF-1.4"),
+	})
+	assert.True(t, errors.As(err, &invalidErr))
+
+	// Documents are indexed for retrieval, here into the
+	// user's library
+	artifact, err = service.Artifacts.Upload(testUser.ID, &UploadArtifactRequest{
+		Message:  msg.ID,
+		Type:     artifacts.TypeDocument,
+		MimeType: "text/markdown",
+		Filename: "cooper.md",
+		Metadata: map[string]string{documents.LibraryKey: "true"},
+		Data:     []byte("# Cooper\nCooper is a corgi who loves tennis balls."),
+	})
+	require.Nil(t, err)
+
+	// ...so it's available in other conversations too
+	chunks, err := service.Documents.Retrieve(&testAgent, testUser.ID, uuid.New().String(), "What does Cooper love?")
+	require.Nil(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, artifact.ID, chunks[0].Document)
+	assert.Equal(t, "", chunks[0].Conversation)
+	assert.Equal(t, "cooper.md (part 1)", chunks[0].Source())
+
+	// ...though only with the agent it was shared with
+	other := testAgent
+	other.ID = uuid.New().String()
+	chunks, err = service.Documents.Retrieve(&other, testUser.ID, uuid.New().String(), "What does Cooper love?")
+	require.Nil(t, err)
+	assert.Empty(t, chunks)
+}
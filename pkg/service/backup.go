@@ -0,0 +1,26 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/hlfshell/coppermind/internal/store"
+)
+
+/*
+BackupDaemon snapshots the store into the configured backup
+directory, pruning the oldest snapshots beyond those to be
+retained. It returns the new snapshot's path.
+*/
+func (service *Service) BackupDaemon() (string, error) {
+	snapshotter, ok := service.db.(store.Snapshotter)
+	if !ok {
+		return "", fmt.Errorf("store does not support snapshots")
+	}
+
+	cfg := service.config.Backup
+	if cfg.Directory == "" {
+		return "", fmt.Errorf("no backup directory set")
+	}
+
+	return snapshotter.Snapshot(cfg.Directory, cfg.Retain)
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/store/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupDaemon(t *testing.T) {
+	service, _, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	dir := t.TempDir()
+	service.config.Backup.Directory = dir
+	service.config.Backup.Retain = 2
+
+	for index := 0; index < 3; index++ {
+		_, err := service.BackupDaemon()
+		require.Nil(t, err)
+	}
+
+	snapshots, err := sqlite.ListSnapshots(dir)
+	require.Nil(t, err)
+	require.Len(t, snapshots, 2)
+
+	// Snapshots hold the store's data
+	snapshot, err := sqlite.NewSqliteStore(snapshots[1])
+	require.Nil(t, err)
+	defer snapshot.Close()
+
+	user, err := snapshot.GetUser(testUser.ID)
+	require.Nil(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, testUser.Name, user.Name)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hlfshell/coppermind/internal/store/blob"
+	"github.com/hlfshell/coppermind/pkg/config"
+)
+
+/*
+NewBlobStoreFromConfig creates the blob store artifact data
+is kept in. If artifact data is kept in the database, nil is
+returned.
+*/
+func NewBlobStoreFromConfig(config *config.Config) (blob.BlobStore, error) {
+	switch config.Blob.Type {
+	case "", "database":
+		return nil, nil
+	case "local":
+		if config.Blob.Path == "" {
+			return nil, fmt.Errorf("no path set for local blob store")
+		}
+		return blob.NewLocalBlobStore(config.Blob.Path)
+	case "s3":
+		s3 := config.Blob.S3
+
+		accessKey := s3.AccessKey
+		if accessKey == "" && s3.AccessKeyEnv != "" {
+			accessKey = os.Getenv(s3.AccessKeyEnv)
+		}
+		secretKey := s3.SecretKey
+		if secretKey == "" && s3.SecretKeyEnv != "" {
+			secretKey = os.Getenv(s3.SecretKeyEnv)
+		}
+		if accessKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("no keys set for s3 blob store")
+		}
+
+		return blob.NewS3BlobStore(blob.S3Config{
+			Endpoint:  s3.Endpoint,
+			Bucket:    s3.Bucket,
+			Region:    s3.Region,
+			Prefix:    s3.Prefix,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob store type %s", config.Blob.Type)
+	}
+}
@@ -2,12 +2,15 @@ package service
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/chat"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
 )
 
 func (service *Service) SendMessage(msg *chat.Message) (*chat.Message, error) {
@@ -16,14 +19,29 @@ func (service *Service) SendMessage(msg *chat.Message) (*chat.Message, error) {
 	if err != nil {
 		return nil, err
 	} else if agent == nil {
-		return nil, fmt.Errorf("agent %s not found", msg.Agent)
+		return nil, &NotFoundError{Kind: "agent", ID: msg.Agent}
+	}
+
+	// Users may only message agents of their own organization
+	// that they may reach
+	err = authorizeChat(service.db, agent, msg.User)
+	if err != nil {
+		return nil, err
+	}
+
+	// If the agent is running an experiment, the user talks
+	// with the variant they are assigned, and their messages
+	// record which it was
+	agent, msg.Experiment, msg.Variant, err = service.Experiments.apply(agent, msg.User)
+	if err != nil {
+		return nil, err
 	}
 
 	// If no conversation is set, lookup to see if we have an old conversation
 	// that we can load up and join (based on how long since it's been) the
 	// last message in that conversation
 	if msg.Conversation == "" {
-		conversationId, err := service.generateOrFindConversation(msg)
+		conversationId, err := service.generateOrFindConversation(agent, msg)
 		if err != nil {
 			return nil, err
 		}
@@ -35,6 +53,10 @@ func (service *Service) SendMessage(msg *chat.Message) (*chat.Message, error) {
 	conversation, err := service.db.GetConversation(msg.Conversation)
 	if err != nil {
 		return nil, err
+	} else if conversation != nil && (conversation.Agent != agent.ID || conversation.User != msg.User) {
+		// Only a user's own conversations with this agent may be
+		// joined; the rest aren't revealed to exist
+		return nil, &NotFoundError{Kind: "conversation", ID: msg.Conversation}
 	} else if conversation == nil {
 		conversation = &chat.Conversation{
 			ID:        msg.Conversation,
@@ -45,32 +67,200 @@ func (service *Service) SendMessage(msg *chat.Message) (*chat.Message, error) {
 		}
 	}
 
+	// Requests to forget something are acted on directly
+	// rather than sent to the LLM
+	if target, ok := memory.ParseForgetCommand(msg.Content); ok {
+		return service.forget(agent, conversation, msg, target)
+	}
+
 	// Find the summaries of prior conversations if any exist
-	pastSummaries, err := service.previousSummaries(msg.Agent, msg.User)
+	pastSummaries, err := service.previousSummaries(agent, msg.User)
 	if err != nil {
 		return nil, err
 	}
 
+	// Agents that can search their memory do so on demand,
+	// so only the summary of this conversation is included
+	if agent.HasTool(tools.MemoryLookup) {
+		pastSummaries = currentSummary(pastSummaries, msg.Conversation)
+	}
+
 	// Placeholder for anything to do with injected knowledge
 	// here
 	knowledge := []*memory.Knowledge{}
 
-	// Now we have the LLM deal with the message
-	response, err := service.llm.SendMessage(
+	// Ensure that the message is within quota before we
+	// send anything to the LLM
+	err = service.consumeQuota(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index any documents shared with the message, then find
+	// the passages of the conversation's documents relevant to
+	// it
+	for _, artifact := range msg.Artifacts {
+		if artifact.ID == "" {
+			artifact.ID = uuid.New().String()
+		}
+		artifact.Message = msg.ID
+		if err := artifact.Validate(); err != nil {
+			return nil, &InvalidRequestError{Err: err}
+		}
+
+		_, err = service.Documents.Index(agent, msg, artifact)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sources, err := service.Documents.Retrieve(agent, msg.User, msg.Conversation, msg.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the toolbox of tools the agent has enabled,
+	// scoped to this conversation
+	toolbox := service.Tools.Toolbox(
+		agent,
+		tools.Scope{
+			Agent:        agent.ID,
+			User:         msg.User,
+			Conversation: msg.Conversation,
+		},
+		service.config.Chat.MaxToolSteps,
+		time.Duration(service.config.Chat.ToolTimeoutSeconds)*time.Second,
+	)
+
+	// Now we have the LLM deal with the message, using the
+	// organization's own provider keys if it has any
+	model, err := service.modelFor(agent.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	response, err := model.SendMessage(
 		agent,
 		conversation,
 		pastSummaries,
 		knowledge,
+		sources,
 		msg,
+		toolbox,
+	)
+
+	// Tool calls made along the way are kept in the conversation,
+	// even if the LLM failed to give a final response
+	for _, step := range toolbox.Steps() {
+		step.AgentVersion = agent.Version
+		step.Experiment = msg.Experiment
+		step.Variant = msg.Variant
+		if saveErr := service.db.SaveMessage(step); saveErr != nil {
+			return nil, saveErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Both the message and the agent's response are kept in
+	// the conversation, along with any artifacts either carry
+	err = service.db.SaveMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, nil
+	}
+
+	// Any sources the response cites are attached to it
+	err = service.Documents.Cite(response, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response records which identity and variant of the
+	// agent wrote it, and how long it took
+	response.Conversation = msg.Conversation
+	response.User = msg.User
+	response.AgentVersion = agent.Version
+	response.Experiment = msg.Experiment
+	response.Variant = msg.Variant
+	response.Duration = time.Since(started).Milliseconds()
+	for _, artifact := range response.Artifacts {
+		if artifact.ID == "" {
+			artifact.ID = uuid.New().String()
+		}
+		artifact.Message = response.ID
+	}
+	err = service.db.SaveMessage(response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+/*
+forget acts on a user's request to forget something, and
+replies with what was forgotten. Both the request and the
+reply are kept in the conversation.
+*/
+func (service *Service) forget(
+	agent *agents.Agent,
+	conversation *chat.Conversation,
+	msg *chat.Message,
+	target string,
+) (*chat.Message, error) {
+	// The request itself is not part of what was said before
+	history := &chat.Conversation{
+		ID:       conversation.ID,
+		Agent:    conversation.Agent,
+		User:     conversation.User,
+		Messages: []*chat.Message{},
+	}
+	for _, message := range conversation.Messages {
+		if message.ID != msg.ID {
+			history.Messages = append(history.Messages, message)
+		}
+	}
+
+	forgotten, err := service.Memory.Forget(
+		&MemoryRequest{Agent: agent.ID, User: msg.User},
+		history,
+		target,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	var content string
+	if len(forgotten) == 0 {
+		content = "I don't remember anything about that."
+	} else {
+		facts := make([]string, len(forgotten))
+		for index, fact := range forgotten {
+			facts[index] = fact.String()
+		}
+		content = fmt.Sprintf("Okay, I've forgotten that %s.", strings.Join(facts, "; "))
+	}
+
+	err = service.db.SaveMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	response := (&chat.Response{Content: content}).ToMessage(msg.User, agent.ID, msg.Conversation)
+	response.AgentVersion = agent.Version
+	err = service.db.SaveMessage(response)
+	if err != nil {
+		return nil, err
+	}
+
 	return response, nil
 }
 
-func (service *Service) generateOrFindConversation(msg *chat.Message) (string, error) {
+func (service *Service) generateOrFindConversation(agent *agents.Agent, msg *chat.Message) (string, error) {
 	conversation, timestamp, err := service.db.GetLatestConversation(msg.Agent, msg.User)
 	if err != nil {
 		return "", err
@@ -115,7 +305,12 @@ func (service *Service) generateOrFindConversation(msg *chat.Message) (string, e
 			return uuid.New().String(), nil
 		}
 
-		shouldContinue, err := service.llm.ConversationContinuance(
+		model, err := service.modelFor(agent.Tenant)
+		if err != nil {
+			return "", err
+		}
+		shouldContinue, err := model.ConversationContinuance(
+			agent,
 			msg,
 			retrievedConversation,
 			summary,
@@ -131,12 +326,19 @@ func (service *Service) generateOrFindConversation(msg *chat.Message) (string, e
 	return conversation, nil
 }
 
-func (service Service) previousSummaries(agent string, user string) ([]*memory.Summary, error) {
+// previousSummaries lists the summaries included when chatting,
+// as many as the agent's memory settings allow
+func (service Service) previousSummaries(agent *agents.Agent, user string) ([]*memory.Summary, error) {
+	limit := service.config.Chat.MaxSummariesToInclude
+	if agent.Memory.MaxSummaries > 0 {
+		limit = agent.Memory.MaxSummaries
+	}
+
 	return service.db.ListSummaries(store.Filter{
 		Attributes: []*store.FilterAttribute{
 			{
 				Attribute: "agent",
-				Value:     agent,
+				Value:     agent.ID,
 				Operation: store.EQ,
 			},
 			{
@@ -145,10 +347,20 @@ func (service Service) previousSummaries(agent string, user string) ([]*memory.S
 				Operation: store.EQ,
 			},
 		},
-		Limit: service.config.Chat.MaxSummariesToInclude,
+		Limit: limit,
 		OrderBy: store.OrderBy{
 			Attribute: "conversation_started_at",
 			Ascending: false,
 		},
 	})
 }
+
+func currentSummary(summaries []*memory.Summary, conversation string) []*memory.Summary {
+	current := []*memory.Summary{}
+	for _, summary := range summaries {
+		if summary.Conversation == conversation {
+			current = append(current, summary)
+		}
+	}
+	return current
+}
@@ -1,14 +1,20 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/agents"
 	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/documents"
 	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tools"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,20 +31,37 @@ func TestSendMessage(t *testing.T) {
 	require.NotNil(t, service)
 	require.NotNil(t, store)
 
-	returnMsg := &chat.Message{
-		ID:        uuid.New().String(),
-		Agent:     testAgent.ID,
-		User:      testUser.ID,
-		From:      testAgent.ID,
-		Content:   "Hello, world!",
-		Artifacts: []*artifacts.ArtifactData{},
-		CreatedAt: time.Now(),
+	newMessage := func(conversation string) *chat.Message {
+		return &chat.Message{
+			ID:           uuid.New().String(),
+			Agent:        testAgent.ID,
+			User:         testUser.ID,
+			From:         testUser.ID,
+			Conversation: conversation,
+			Content:      "Hello, world!",
+			Artifacts:    []*artifacts.ArtifactData{},
+			CreatedAt:    time.Now(),
+		}
 	}
-	conversationId := returnMsg.Conversation
+	newReply := func() *chat.Message {
+		return &chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     testAgent.ID,
+			User:      testUser.ID,
+			From:      testAgent.ID,
+			Content:   "Hello to you too!",
+			Artifacts: []*artifacts.ArtifactData{},
+			CreatedAt: time.Now(),
+		}
+	}
+
+	sentMsg := newMessage("")
+	returnMsg := newReply()
+	conversationId := sentMsg.Conversation
 
 	llm.AddSendMessageResponse(returnMsg, nil)
 
-	msg, err := service.SendMessage(returnMsg)
+	msg, err := service.SendMessage(sentMsg)
 	require.Nil(t, err)
 	require.NotNil(t, msg)
 
@@ -47,13 +70,22 @@ func TestSendMessage(t *testing.T) {
 	assert.NotEqual(t, conversationId, msg.Conversation)
 	assert.True(t, returnMsg.Equal(msg))
 
+	// Both the message and its response are kept in the
+	// conversation
+	conversation, err := store.GetConversation(msg.Conversation)
+	require.Nil(t, err)
+	require.Len(t, conversation.Messages, 2)
+	assert.True(t, sentMsg.Equal(conversation.Messages[0]))
+	assert.True(t, returnMsg.Equal(conversation.Messages[1]))
+
 	// -- No conversation history, conversation specified -- //
 
 	llm.ClearMemory()
-	returnMsg.Conversation = uuid.New().String()
-	conversationId = returnMsg.Conversation
+	sentMsg = newMessage(uuid.New().String())
+	returnMsg = newReply()
+	conversationId = sentMsg.Conversation
 	llm.AddSendMessageResponse(returnMsg, nil)
-	msg, err = service.SendMessage(returnMsg)
+	msg, err = service.SendMessage(sentMsg)
 	require.Nil(t, err)
 	require.NotNil(t, msg)
 
@@ -64,12 +96,12 @@ func TestSendMessage(t *testing.T) {
 
 	llm.ClearMemory()
 	llm.AddConversationContinuanceResponse(false, nil)
-	// Reset the conversation id
-	returnMsg.Conversation = ""
-	conversationId = returnMsg.Conversation
+	sentMsg = newMessage("")
+	returnMsg = newReply()
+	conversationId = sentMsg.Conversation
 	llm.AddSendMessageResponse(returnMsg, nil)
 
-	msg, err = service.SendMessage(returnMsg)
+	msg, err = service.SendMessage(sentMsg)
 	require.Nil(t, err)
 	require.NotNil(t, msg)
 
@@ -84,15 +116,15 @@ func TestSendMessage(t *testing.T) {
 
 	llm.ClearMemory()
 	llm.AddConversationContinuanceResponse(true, nil)
-	// Save the old message as a prior conversation
-	store.SaveMessage(returnMsg)
-	// Reset the conversation id
-	oldConversationId := returnMsg.Conversation
-	returnMsg.Conversation = ""
-	conversationId = returnMsg.Conversation
+	// The prior exchange was saved, so its conversation
+	// should be continued
+	oldConversationId := msg.Conversation
+	sentMsg = newMessage("")
+	returnMsg = newReply()
+	conversationId = sentMsg.Conversation
 	llm.AddSendMessageResponse(returnMsg, nil)
 
-	msg, err = service.SendMessage(returnMsg)
+	msg, err = service.SendMessage(sentMsg)
 	require.Nil(t, err)
 	require.NotNil(t, msg)
 
@@ -106,9 +138,9 @@ func TestSendMessage(t *testing.T) {
 	// Create a summary for the existing message conversation
 	summary := &memory.Summary{
 		ID:                    uuid.New().String(),
-		Conversation:          returnMsg.Conversation,
-		Agent:                 returnMsg.Agent,
-		User:                  returnMsg.User,
+		Conversation:          msg.Conversation,
+		Agent:                 msg.Agent,
+		User:                  msg.User,
 		Keywords:              []string{"hello", "world"},
 		Summary:               "A fake summary for a fake conversation",
 		UpdatedAt:             time.Now(),
@@ -118,9 +150,11 @@ func TestSendMessage(t *testing.T) {
 	require.Nil(t, err)
 
 	llm.AddConversationContinuanceResponse(true, nil)
+	sentMsg = newMessage(msg.Conversation)
+	returnMsg = newReply()
 	llm.AddSendMessageResponse(returnMsg, nil)
 
-	msg, err = service.SendMessage(returnMsg)
+	msg, err = service.SendMessage(sentMsg)
 	require.Nil(t, err)
 	require.NotNil(t, msg)
 
@@ -129,7 +163,7 @@ func TestSendMessage(t *testing.T) {
 
 	// Let's look at the incoming summaries and confirm that the
 	// summary was included and passed.
-	_, _, pastSummaries, _, _ := llm.GetSendMessageInputs()
+	_, _, pastSummaries, _, _, _, _ := llm.GetSendMessageInputs()
 	require.Equal(t, 1, len(pastSummaries))
 	assert.True(t, summary.Equal(pastSummaries[0]))
 
@@ -138,8 +172,296 @@ func TestSendMessage(t *testing.T) {
 	// -- Agent does not exist -- //
 
 	llm.ClearMemory()
-	returnMsg.Agent = uuid.New().String()
-	msg, err = service.SendMessage(returnMsg)
+	sentMsg = newMessage("")
+	sentMsg.Agent = uuid.New().String()
+	msg, err = service.SendMessage(sentMsg)
 	require.NotNil(t, err)
 	assert.Nil(t, msg)
 }
+
+func TestSendMessageWithArtifacts(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Conversation: uuid.New().String(),
+		Content:      "Where can I read more?",
+		CreatedAt:    time.Now(),
+		Artifacts: []*artifacts.ArtifactData{
+			{
+				Type:      artifacts.TypeText,
+				CreatedAt: time.Now(),
+				Data:      []byte("My notes so far"),
+			},
+		},
+	}
+
+	// The LLM's response, with the artifacts it generated
+	generated := &chat.Response{
+		Content: "The [docs](https://coppermind.dev/docs) cover it.",
+		Artifacts: append(
+			artifacts.ExtractLinks("The [docs](https://coppermind.dev/docs) cover it."),
+			&artifacts.ArtifactData{
+				Type:      artifacts.TypeJSON,
+				CreatedAt: time.Now(),
+				Data:      []byte(`{"pages": 12}`),
+			},
+		),
+	}
+	reply := generated.ToMessage(testUser.ID, testAgent.ID, msg.Conversation)
+	llm.AddSendMessageResponse(reply, nil)
+
+	response, err := service.SendMessage(msg)
+	require.Nil(t, err)
+	require.Len(t, response.Artifacts, 2)
+	for _, artifact := range response.Artifacts {
+		assert.NotEmpty(t, artifact.ID)
+		assert.Equal(t, response.ID, artifact.Message)
+	}
+
+	// Each message is stored with its own artifacts
+	conversation, err := store.GetConversation(msg.Conversation)
+	require.Nil(t, err)
+	require.Len(t, conversation.Messages, 2)
+	assert.Len(t, conversation.Messages[0].Artifacts, 1)
+	assert.Len(t, conversation.Messages[1].Artifacts, 2)
+
+	for _, artifact := range response.Artifacts {
+		stored, err := store.GetArtifact(artifact.ID)
+		require.Nil(t, err)
+		require.NotNil(t, stored)
+		assert.True(t, artifact.Equal(stored))
+	}
+
+	// Invalid artifacts from the LLM are not stored
+	llm.ClearMemory()
+	msg.ID = uuid.New().String()
+	msg.Artifacts = nil
+	reply = (&chat.Response{
+		Content: "Here you go",
+		Artifacts: []*artifacts.ArtifactData{
+			{Type: artifacts.TypeJSON, CreatedAt: time.Now(), Data: []byte(`{"pages": }`)},
+		},
+	}).ToMessage(testUser.ID, testAgent.ID, msg.Conversation)
+	llm.AddSendMessageResponse(reply, nil)
+
+	_, err = service.SendMessage(msg)
+	assert.NotNil(t, err)
+
+	stored, err := store.GetMessage(reply.ID)
+	require.Nil(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestSendMessageWithTools(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+
+	agent := &agents.Agent{
+		ID:       uuid.New().String(),
+		Name:     "Hal",
+		Identity: "Super helpful, nothing but",
+		Tools:    []string{"calculator", "current_time"},
+	}
+	require.Nil(t, store.SaveAgent(agent))
+
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Agent:        agent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Conversation: uuid.New().String(),
+		Content:      "What is 6 times 7?",
+		CreatedAt:    time.Now(),
+	}
+
+	// The model asks for the calculator, then an unknown
+	// tool, before responding
+	llm.AddSendMessageResponse(&chat.Message{
+		ToolCalls: []*chat.ToolCall{
+			{ID: "call_1", Name: "calculator", Arguments: json.RawMessage(`{"expression": "6 * 7"}`)},
+		},
+	}, nil)
+	llm.AddSendMessageResponse(&chat.Message{
+		ToolCalls: []*chat.ToolCall{
+			{ID: "call_2", Name: "launch_pod_bay_doors", Arguments: json.RawMessage(`{}`)},
+		},
+	}, nil)
+	reply := &chat.Message{
+		ID:           uuid.New().String(),
+		Agent:        agent.ID,
+		User:         testUser.ID,
+		From:         agent.ID,
+		Conversation: msg.Conversation,
+		Content:      "It's 42",
+		CreatedAt:    time.Now(),
+	}
+	llm.AddSendMessageResponse(reply, nil)
+
+	response, err := service.SendMessage(msg)
+	require.Nil(t, err)
+	assert.Equal(t, reply, response)
+
+	_, _, _, _, _, _, toolbox := llm.GetSendMessageInputs()
+	require.NotNil(t, toolbox)
+	assert.Len(t, toolbox.Tools(), 2)
+
+	// Each round of tool calls is kept in the conversation,
+	// alongside the message and its response
+	conversation, err := store.GetConversation(msg.Conversation)
+	require.Nil(t, err)
+	require.NotNil(t, conversation)
+	require.Len(t, conversation.Messages, 4)
+
+	steps := []*chat.Message{}
+	for _, message := range conversation.Messages {
+		if len(message.ToolCalls) > 0 {
+			steps = append(steps, message)
+		}
+	}
+	require.Len(t, steps, 2)
+
+	first := steps[0]
+	assert.Equal(t, agent.ID, first.From)
+	require.Len(t, first.ToolCalls, 1)
+	assert.Equal(t, "42", first.ToolCalls[0].Result)
+
+	second := steps[1]
+	require.Len(t, second.ToolCalls, 1)
+	assert.Contains(t, second.ToolCalls[0].Error, "no tool named launch_pod_bay_doors")
+
+	// Agents without tools are given nothing to run
+	llm.ClearMemory()
+	halConversation := msg.Conversation
+	msg.ID = uuid.New().String()
+	msg.Agent = testAgent.ID
+	msg.Conversation = uuid.New().String()
+	reply.ID = uuid.New().String()
+	llm.AddSendMessageResponse(reply, nil)
+
+	_, err = service.SendMessage(msg)
+	require.Nil(t, err)
+	_, _, _, _, _, _, toolbox = llm.GetSendMessageInputs()
+	assert.False(t, toolbox.CanRun())
+
+	// Agents that can search their memory are only given the
+	// summary of the current conversation
+	agent.Tools = append(agent.Tools, tools.MemoryLookup)
+	require.Nil(t, store.SaveAgent(agent))
+	msg.Conversation = halConversation
+
+	for _, conversation := range []string{msg.Conversation, uuid.New().String()} {
+		require.Nil(t, store.SaveSummary(&memory.Summary{
+			ID:                    uuid.New().String(),
+			Agent:                 agent.ID,
+			User:                  testUser.ID,
+			Conversation:          conversation,
+			Keywords:              []string{"math"},
+			Summary:               "Keith asked about multiplication",
+			ConversationStartedAt: time.Now(),
+			UpdatedAt:             time.Now(),
+		}))
+	}
+
+	llm.ClearMemory()
+	msg.ID = uuid.New().String()
+	msg.Agent = agent.ID
+	reply.ID = uuid.New().String()
+	llm.AddSendMessageResponse(reply, nil)
+
+	_, err = service.SendMessage(msg)
+	require.Nil(t, err)
+	_, _, pastSummaries, _, _, _, _ := llm.GetSendMessageInputs()
+	require.Len(t, pastSummaries, 1)
+	assert.Equal(t, msg.Conversation, pastSummaries[0].Conversation)
+}
+
+func TestSendMessageWithDocuments(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, _, err := createMockService(llm)
+	require.Nil(t, err)
+
+	itinerary := strings.Join([]string{
+		"# Day 1\nFly into Reykjavik and pick up the rental car.",
+		"# Day 2\nDrive the golden circle and see the geysers.",
+		"# Day 3\nSoak in the blue lagoon before the flight home.",
+	}, "\n\n")
+
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Conversation: uuid.New().String(),
+		Content:      "Which day do we see the geysers?",
+		CreatedAt:    time.Now(),
+		Artifacts: []*artifacts.ArtifactData{
+			{
+				Type:      artifacts.TypeDocument,
+				MimeType:  "text/markdown",
+				Filename:  "itinerary.md",
+				CreatedAt: time.Now(),
+				Data:      []byte(itinerary),
+			},
+		},
+	}
+
+	reply := &chat.Message{
+		ID:           uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testAgent.ID,
+		Conversation: msg.Conversation,
+		Content:      "Day 2, along the golden circle [1].",
+		CreatedAt:    time.Now(),
+	}
+	llm.AddSendMessageResponse(reply, nil)
+
+	// Small chunks, so that each day is its own
+	service.Documents.config.ChunkSize = 60
+	service.Documents.config.ChunkOverlap = 0
+	service.Documents.config.MaxChunks = 2
+
+	response, err := service.SendMessage(msg)
+	require.Nil(t, err)
+	require.NotNil(t, response)
+
+	// The most relevant passage was given to the LLM first
+	_, _, _, _, sources, _, _ := llm.GetSendMessageInputs()
+	require.Len(t, sources, 2)
+	assert.Contains(t, sources[0].Content, "geysers")
+	assert.Equal(t, msg.Artifacts[0].ID, sources[0].Document)
+
+	// ...and its citation attached to the response
+	require.Len(t, response.Artifacts, 1)
+	assert.Equal(t, artifacts.TypeJSON, response.Artifacts[0].Type)
+	citations := []documents.Citation{}
+	require.Nil(t, json.Unmarshal(response.Artifacts[0].Data, &citations))
+	require.Len(t, citations, 1)
+	assert.Equal(t, sources[0].ID, citations[0].Chunk)
+	assert.Equal(t, "itinerary.md", citations[0].Filename)
+
+	// Documents that can't be read are rejected
+	llm.ClearMemory()
+	llm.AddSendMessageResponse(reply, nil)
+	msg.ID = uuid.New().String()
+	msg.Artifacts = []*artifacts.ArtifactData{
+		{
+			Type:      artifacts.TypeDocument,
+			CreatedAt: time.Now(),
+			Data:      []byte("%PDF-1.4\n%%EOF"),
+		},
+	}
+	_, err = service.SendMessage(msg)
+	var invalidErr *InvalidRequestError
+	assert.True(t, errors.As(err, &invalidErr))
+}
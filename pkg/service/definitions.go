@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/tools"
+)
+
+// The outcomes of applying a definition
+const (
+	DefinitionCreated   = "created"
+	DefinitionUpdated   = "updated"
+	DefinitionUnchanged = "unchanged"
+	DefinitionSkipped   = "skipped"
+)
+
+// DefinitionResult is what applying a single definition did
+type DefinitionResult struct {
+	Agent   string `json:"agent"`
+	Source  string `json:"source"`
+	Outcome string `json:"outcome"`
+	Version int    `json:"version"`
+}
+
+/*
+ValidateDefinitions checks loaded definitions against what
+coppermind offers - that each only enables tools it has
+*/
+func ValidateDefinitions(definitions []*agents.Definition) error {
+	return validateDefinitionTools(definitions, defaultTools(nil))
+}
+
+func validateDefinitionTools(definitions []*agents.Definition, registry *tools.Registry) error {
+	for _, definition := range definitions {
+		for _, name := range definition.Tools {
+			if registry.Get(name) == nil {
+				return &InvalidRequestError{Err: fmt.Errorf("%s: unknown tool %s", definition.Source, name)}
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ApplyAgentDefinitions saves the agents that definitions
+declare. Unless overwrite is set, agents that already exist
+are skipped, so that changes made to them since are kept. A
+change of identity is versioned as any other, authored by
+the definition's file within origin. Every definition is
+checked before any are applied.
+*/
+func (service *Service) ApplyAgentDefinitions(definitions []*agents.Definition, origin string, overwrite bool) ([]*DefinitionResult, error) {
+	err := validateDefinitionTools(definitions, service.Tools)
+	if err != nil {
+		return nil, err
+	}
+	// Everything is checked before anything is saved, so
+	// that a failure doesn't leave the definitions half applied
+	for _, definition := range definitions {
+		err = checkOrganization(service.db, definition.Tenant)
+		if err != nil {
+			return nil, err
+		}
+
+		agent := definition.Agent()
+		existing, err := service.db.GetAgent(agent.ID)
+		if err != nil {
+			return nil, err
+		} else if overwrite && existing != nil && existing.Tenant != agent.Tenant {
+			return nil, &InvalidRequestError{Err: fmt.Errorf("%s: agent %s belongs to another organization", definition.Source, agent.ID)}
+		}
+	}
+
+	results := []*DefinitionResult{}
+	for _, definition := range definitions {
+		agent := definition.Agent()
+		result := &DefinitionResult{Agent: agent.ID, Source: definition.Source}
+		results = append(results, result)
+
+		existing, err := service.db.GetAgent(agent.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing == nil {
+			result.Outcome = DefinitionCreated
+		} else if !overwrite {
+			result.Outcome = DefinitionSkipped
+			result.Version = existing.Version
+			continue
+		} else if sameAgent(existing, agent) {
+			result.Outcome = DefinitionUnchanged
+			result.Version = existing.Version
+			continue
+		} else {
+			result.Outcome = DefinitionUpdated
+		}
+
+		err = service.Agents.saveVersioned(path.Join(origin, definition.Source), agent, existing)
+		if err != nil {
+			return nil, err
+		}
+		result.Version = agent.Version
+	}
+
+	return results, nil
+}
+
+// sameAgent is whether saving agent over existing would change
+// anything but its version
+func sameAgent(existing *agents.Agent, agent *agents.Agent) bool {
+	compared := *agent
+	compared.Version = existing.Version
+
+	a, errA := json.Marshal(existing)
+	b, errB := json.Marshal(&compared)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentDefinitions(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, db, err := createMockService(llm)
+	require.Nil(t, err)
+
+	revisionsOf := func(agent string) []*agents.Revision {
+		revisions, err := db.ListAgentRevisions(store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{Attribute: "agent", Operation: store.EQ, Value: agent},
+			},
+		})
+		require.Nil(t, err)
+		return revisions
+	}
+
+	// The bundled agents are seeded once, and left alone after
+	seeds, err := agents.LoadDefinitions(prompts.Seeds())
+	require.Nil(t, err)
+	require.Len(t, seeds, 4)
+	require.Nil(t, ValidateDefinitions(seeds))
+
+	results, err := service.ApplyAgentDefinitions(seeds, "seed", false)
+	require.Nil(t, err)
+	for _, result := range results {
+		assert.Equal(t, DefinitionCreated, result.Outcome, result.Agent)
+	}
+	rose, err := service.Agents.GetAgent("rose")
+	require.Nil(t, err)
+	assert.Equal(t, prompts.Rose, rose.Identity)
+	assert.Equal(t, agents.VisibilityPublic, rose.Visibility)
+
+	revisions := revisionsOf("rose")
+	require.Len(t, revisions, 1)
+	assert.Equal(t, "seed/rose.yaml", revisions[0].Author)
+
+	results, err = service.ApplyAgentDefinitions(seeds, "seed", false)
+	require.Nil(t, err)
+	assert.Equal(t, DefinitionSkipped, results[0].Outcome)
+
+	// A directory of definitions is applied over what's stored,
+	// in either format
+	dir := fstest.MapFS{
+		"rose.yaml": {Data: []byte(`
+id: rose
+name: Rose
+identity_file: prompts/rose.txt
+tools: [calculator]
+llm:
+  chat:
+    model: gpt-4
+    max_tokens: 200
+memory:
+  max_summaries: 3
+`)},
+		"winston.json":     {Data: []byte(`{"id": "winston", "name": "Winston", "identity": "You are Winston", "visibility": "private"}`)},
+		"prompts/rose.txt": {Data: []byte("You are Rose, and you like maths")},
+		"notes.md":         {Data: []byte("Not a definition")},
+	}
+	definitions, err := agents.LoadDefinitions(dir)
+	require.Nil(t, err)
+	require.Len(t, definitions, 2)
+
+	results, err = service.ApplyAgentDefinitions(definitions, "agents", true)
+	require.Nil(t, err)
+	assert.Equal(t, &DefinitionResult{Agent: "rose", Source: "rose.yaml", Outcome: DefinitionUpdated, Version: 2}, results[0])
+	assert.Equal(t, DefinitionUpdated, results[1].Outcome)
+
+	rose, err = service.Agents.GetAgent("rose")
+	require.Nil(t, err)
+	assert.Equal(t, "You are Rose, and you like maths", rose.Identity)
+	assert.Equal(t, []string{"calculator"}, rose.Tools)
+	assert.Equal(t, "gpt-4", rose.LLM.ChatProfile().Model)
+	assert.Equal(t, 200, rose.LLM.ChatProfile().MaxTokens)
+	assert.Equal(t, 3, rose.Memory.MaxSummaries)
+	winston, err := service.Agents.GetAgent("winston")
+	require.Nil(t, err)
+	assert.Equal(t, agents.VisibilityPrivate, winston.Visibility)
+
+	// Applying again changes nothing
+	results, err = service.ApplyAgentDefinitions(definitions, "agents", true)
+	require.Nil(t, err)
+	for _, result := range results {
+		assert.Equal(t, DefinitionUnchanged, result.Outcome, result.Agent)
+	}
+	assert.Len(t, revisionsOf("rose"), 2)
+
+	// Bad definitions are caught before anything is applied
+	for name, data := range map[string]string{
+		"misspelled.yaml": "id: rose\nname: Rose\nidentity: Hi\ntoools: [calculator]",
+		"missing.yaml":    "id: rose\nname: Rose",
+		"both.yaml":       "id: rose\nname: Rose\nidentity: Hi\nidentity_file: rose.txt",
+		"visibility.json": `{"id": "rose", "name": "Rose", "identity": "Hi", "visibility": "secret"}`,
+	} {
+		_, err := agents.LoadDefinitions(fstest.MapFS{name: {Data: []byte(data)}, "rose.txt": {Data: []byte("Hi")}})
+		assert.NotNil(t, err, name)
+	}
+	_, err = agents.LoadDefinitions(fstest.MapFS{
+		"a.yaml": {Data: []byte("id: rose\nname: Rose\nidentity: Hi")},
+		"b.yaml": {Data: []byte("id: rose\nname: Rose\nidentity: Hi")},
+	})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "already defined by a.yaml")
+
+	unknown, err := agents.LoadDefinitions(fstest.MapFS{
+		"rose.yaml": {Data: []byte("id: rose\nname: Rose\nidentity: Hi\ntools: [teleport]")},
+	})
+	require.Nil(t, err)
+	var invalid *InvalidRequestError
+	require.True(t, errors.As(ValidateDefinitions(unknown), &invalid))
+	_, err = service.ApplyAgentDefinitions(unknown, "agents", true)
+	require.True(t, errors.As(err, &invalid))
+	rose, err = service.Agents.GetAgent("rose")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"calculator"}, rose.Tools)
+
+	// ...as are agents that would move to another
+	// organization
+	require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, &tenants.Organization{ID: "initech", Name: "Initech"}))
+	moved, err := agents.LoadDefinitions(fstest.MapFS{
+		"a.yaml": {Data: []byte("id: rose\nname: Rose\nidentity: You are Rose, and you like poetry")},
+		"b.yaml": {Data: []byte("id: winston\nname: Winston\nidentity: You are Winston\ntenant: initech")},
+	})
+	require.Nil(t, err)
+	_, err = service.ApplyAgentDefinitions(moved, "agents", true)
+	require.True(t, errors.As(err, &invalid))
+	rose, err = service.Agents.GetAgent("rose")
+	require.Nil(t, err)
+	assert.Equal(t, "You are Rose, and you like maths", rose.Identity)
+}
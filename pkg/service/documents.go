@@ -0,0 +1,224 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/documents"
+)
+
+// The most texts embedded in a single call
+const embedBatchSize = 100
+
+// The filename given to the citations attached to responses
+const citationsFilename = "citations.json"
+
+/*
+DocumentService indexes document artifacts into embedded
+chunks, and retrieves the chunks relevant to a message.
+*/
+type DocumentService struct {
+	db     store.Store
+	config config.DocumentsConfig
+
+	// modelFor resolves the LLM of an agent's organization,
+	// which embeds its documents
+	modelFor func(tenant string) (llm.LLM, error)
+}
+
+func NewDocumentService(db store.Store, modelFor func(tenant string) (llm.LLM, error), config config.DocumentsConfig) *DocumentService {
+	return &DocumentService{
+		db:       db,
+		config:   config,
+		modelFor: modelFor,
+	}
+}
+
+/*
+embed embeds the texts with the LLM of the agent's
+organization if it can, falling back to
+documents.HashEmbedder if it can not.
+*/
+func (service *DocumentService) embed(
+	agent *agents.Agent,
+	user string,
+	conversation string,
+	texts []string,
+) ([]documents.Embedding, error) {
+	model, err := service.modelFor(agent.Tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if embedder, ok := model.(llm.Embedder); ok {
+		embeddings, err := embedder.Embed(agent, user, conversation, texts)
+		if err == nil {
+			return embeddings, nil
+		} else if !errors.Is(err, llm.ErrEmbeddingsUnsupported) {
+			return nil, err
+		}
+	}
+
+	return documents.NewHashEmbedder().Embed(texts), nil
+}
+
+/*
+Index splits a document artifact of a message into chunks
+and saves them embedded for retrieval, in the message's
+conversation or, if the artifact asks for it, the user's
+library. Artifacts that are not documents are ignored.
+*/
+func (service *DocumentService) Index(
+	agent *agents.Agent,
+	message *chat.Message,
+	artifact *artifacts.ArtifactData,
+) ([]*documents.Chunk, error) {
+	if !documents.IsDocument(artifact) {
+		return []*documents.Chunk{}, nil
+	}
+
+	text, err := documents.ExtractText(artifact)
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	pieces := documents.Split(text, service.config.ChunkSize, service.config.ChunkOverlap)
+
+	embeddings := []documents.Embedding{}
+	for start := 0; start < len(pieces); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(pieces) {
+			end = len(pieces)
+		}
+
+		batch, err := service.embed(agent, message.User, message.Conversation, pieces[start:end])
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	conversation := message.Conversation
+	if documents.InLibrary(artifact) {
+		conversation = ""
+	}
+
+	chunks := []*documents.Chunk{}
+	for index, piece := range pieces {
+		chunks = append(chunks, &documents.Chunk{
+			ID:           uuid.New().String(),
+			Document:     artifact.ID,
+			Filename:     artifact.Filename,
+			Agent:        agent.ID,
+			User:         message.User,
+			Conversation: conversation,
+			Index:        index,
+			Content:      piece,
+			Model:        embeddings[index].Model,
+			Embedding:    embeddings[index].Vector,
+			CreatedAt:    time.Now(),
+		})
+	}
+
+	err = service.db.SaveChunks(chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+/*
+Retrieve returns the chunks of the conversation's documents
+and the user's library most relevant to the query, most
+relevant first. Only documents shared with the agent are
+searched.
+*/
+func (service *DocumentService) Retrieve(
+	agent *agents.Agent,
+	user string,
+	conversation string,
+	query string,
+) ([]*documents.Chunk, error) {
+	candidates := []*documents.Chunk{}
+	for _, scope := range []string{conversation, ""} {
+		chunks, err := service.db.ListChunks(store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{
+					Attribute: "agent",
+					Value:     agent.ID,
+					Operation: store.EQ,
+				},
+				{
+					Attribute: "user",
+					Value:     user,
+					Operation: store.EQ,
+				},
+				{
+					Attribute: "conversation",
+					Value:     scope,
+					Operation: store.EQ,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, chunks...)
+	}
+
+	// Don't spend an embedding on a conversation without
+	// any documents
+	if len(candidates) == 0 {
+		return []*documents.Chunk{}, nil
+	}
+
+	embeddings, err := service.embed(agent, user, conversation, []string{query})
+	if err != nil {
+		return nil, err
+	} else if len(embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding of the query, got %d", len(embeddings))
+	}
+
+	return documents.Rank(candidates, embeddings[0], service.config.MaxChunks), nil
+}
+
+/*
+Cite attaches the sources cited by a response to it, as a
+JSON artifact listing each citation.
+*/
+func (service *DocumentService) Cite(response *chat.Message, sources []*documents.Chunk) error {
+	citations := documents.Cite(response.Content, sources)
+	if len(citations) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(citations)
+	if err != nil {
+		return err
+	}
+
+	artifact := &artifacts.ArtifactData{
+		ID:        uuid.New().String(),
+		Message:   response.ID,
+		Type:      artifacts.TypeJSON,
+		Filename:  citationsFilename,
+		CreatedAt: time.Now(),
+		Data:      encoded,
+	}
+	err = artifact.Validate()
+	if err != nil {
+		return err
+	}
+
+	response.Artifacts = append(response.Artifacts, artifact)
+	return nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import "fmt"
+
+/*
+NotFoundError is returned when a request refers to
+something that does not exist, ie uploading an artifact to
+a message that was never sent.
+*/
+type NotFoundError struct {
+	Kind string
+	ID   string
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", err.Kind, err.ID)
+}
+
+/*
+InvalidRequestError is returned when a request is malformed,
+as opposed to failing while it was being carried out.
+*/
+type InvalidRequestError struct {
+	Err error
+}
+
+func (err *InvalidRequestError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *InvalidRequestError) Unwrap() error {
+	return err.Err
+}
+
+/*
+PermissionError is returned when a user isn't allowed to do
+what they asked, ie chatting with a private agent they
+aren't a member of.
+*/
+type PermissionError struct {
+	User     string
+	Action   string
+	Resource string
+}
+
+func (err *PermissionError) Error() string {
+	return fmt.Sprintf("user %s may not %s %s", err.User, err.Action, err.Resource)
+}
+
+/*
+AuthenticationError is returned when a user can't prove who
+they are, ie giving the wrong password.
+*/
+type AuthenticationError struct {
+	User string
+}
+
+func (err *AuthenticationError) Error() string {
+	return fmt.Sprintf("unable to authenticate user %s", err.User)
+}
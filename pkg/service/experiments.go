@@ -0,0 +1,244 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/hlfshell/coppermind/pkg/usage"
+)
+
+type ExperimentService struct {
+	db store.Store
+}
+
+func NewExperimentService(db store.Store) *ExperimentService {
+	return &ExperimentService{
+		db: db,
+	}
+}
+
+/*
+CreateExperiment starts an experiment on an agent, which
+must not already be running one. Only the agent's editors
+may experiment on it, and every variant's prompts are
+validated before it starts.
+*/
+func (service *ExperimentService) CreateExperiment(actorId string, experiment *experiments.Experiment) error {
+	err := experiment.Validate()
+	if err != nil {
+		return &InvalidRequestError{Err: err}
+	}
+	for _, variant := range experiment.Variants {
+		for name, text := range variant.Prompts {
+			if _, err := prompts.ParseTemplate(name, text); err != nil {
+				return &InvalidRequestError{Err: fmt.Errorf("variant %s: %s: %w", variant.Name, name, err)}
+			}
+		}
+	}
+
+	_, err = authorizeManage(service.db, actorId, experiment.Agent, "experiment on")
+	if err != nil {
+		return err
+	}
+
+	running, err := service.active(experiment.Agent)
+	if err != nil {
+		return err
+	} else if running != nil {
+		return &InvalidRequestError{Err: fmt.Errorf("agent %s is already running experiment %s", experiment.Agent, running.ID)}
+	}
+
+	experiment.ID = uuid.New().String()
+	experiment.Active = true
+	experiment.CreatedAt = time.Now()
+	experiment.EndedAt = time.Time{}
+
+	return service.db.SaveExperiment(experiment)
+}
+
+/*
+EndExperiment stops an experiment, so that its agent's users
+are no longer assigned its variants. It can still be
+reported on.
+*/
+func (service *ExperimentService) EndExperiment(actorId string, id string) (*experiments.Experiment, error) {
+	experiment, err := service.get(actorId, id, "experiment on")
+	if err != nil {
+		return nil, err
+	} else if !experiment.Active {
+		return experiment, nil
+	}
+
+	experiment.Active = false
+	experiment.EndedAt = time.Now()
+	return experiment, service.db.SaveExperiment(experiment)
+}
+
+// ListExperiments lists an agent's experiments, oldest first
+func (service *ExperimentService) ListExperiments(actorId string, agentId string) ([]*experiments.Experiment, error) {
+	_, err := authorizeManage(service.db, actorId, agentId, "view the experiments of")
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.ListExperiments(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+		},
+	})
+}
+
+/*
+Report compares how each of an experiment's variants has
+performed - the feedback its replies were given, the tokens
+used in its conversations, and how long it took to reply.
+*/
+func (service *ExperimentService) Report(actorId string, id string) (*experiments.Report, error) {
+	experiment, err := service.get(actorId, id, "view the experiments of")
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := service.db.ListMessages(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "experiment",
+				Value:     experiment.ID,
+				Operation: store.EQ,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := []*chat.Feedback{}
+	records := []*usage.Usage{}
+	if len(messages) > 0 {
+		ids := []string{}
+		conversations := []string{}
+		seen := map[string]bool{}
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+			if !seen[msg.Conversation] {
+				seen[msg.Conversation] = true
+				conversations = append(conversations, msg.Conversation)
+			}
+		}
+
+		feedback, err = service.db.ListFeedback(store.Filter{
+			Attributes: []*store.FilterAttribute{
+				{
+					Attribute: "message",
+					Value:     ids,
+					Operation: store.IN,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the calls made while the experiment ran count
+		// towards it
+		attributes := []*store.FilterAttribute{
+			{
+				Attribute: "conversation",
+				Value:     conversations,
+				Operation: store.IN,
+			},
+			{
+				Attribute: "created_at",
+				Value:     experiment.CreatedAt,
+				Operation: store.GTE,
+			},
+		}
+		if !experiment.EndedAt.IsZero() {
+			attributes = append(attributes, &store.FilterAttribute{
+				Attribute: "created_at",
+				Value:     experiment.EndedAt,
+				Operation: store.LTE,
+			})
+		}
+		records, err = service.db.ListUsage(store.Filter{Attributes: attributes})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return experiments.NewReport(experiment, messages, feedback, records), nil
+}
+
+// get loads an experiment the actor may manage
+func (service *ExperimentService) get(actorId string, id string, action string) (*experiments.Experiment, error) {
+	experiment, err := service.db.GetExperiment(id)
+	if err != nil {
+		return nil, err
+	} else if experiment == nil {
+		return nil, &NotFoundError{Kind: "experiment", ID: id}
+	}
+
+	// Experiments of another organization's agents aren't
+	// revealed to exist
+	_, err = authorizeManage(service.db, actorId, experiment.Agent, action)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, &NotFoundError{Kind: "experiment", ID: id}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return experiment, nil
+}
+
+// active returns the experiment an agent is running, if any
+func (service *ExperimentService) active(agentId string) (*experiments.Experiment, error) {
+	running, err := service.db.ListExperiments(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+			{
+				Attribute: "active",
+				Value:     true,
+				Operation: store.EQ,
+			},
+		},
+	})
+	if err != nil || len(running) == 0 {
+		return nil, err
+	}
+	return running[0], nil
+}
+
+/*
+apply assigns a user the variant of the experiment the agent
+is running, if any, and returns the agent acting as that
+variant along with the experiment and variant names. The
+agent is returned as is if it isn't running an experiment.
+*/
+func (service *ExperimentService) apply(agent *agents.Agent, userId string) (*agents.Agent, string, string, error) {
+	experiment, err := service.active(agent.ID)
+	if err != nil {
+		return nil, "", "", err
+	} else if experiment == nil {
+		return agent, "", "", nil
+	}
+
+	variant := experiment.Assign(userId)
+	if variant == nil {
+		return agent, "", "", nil
+	}
+	return variant.Apply(agent), experiment.ID, variant.Name, nil
+}
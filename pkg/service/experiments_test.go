@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExperiments(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, _, err := createMockService(llm)
+	require.Nil(t, err)
+
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "admin", Name: "Admin", CreatedAt: time.Now(), Role: users.RoleAdmin}, "supersecret"))
+
+	concise := "{identity}\nKeep it short.\n{message_history}{message}"
+	newExperiment := func() *experiments.Experiment {
+		return &experiments.Experiment{
+			Agent: testAgent.ID,
+			Name:  "Concise replies",
+			Variants: []*experiments.Variant{
+				{Name: "control"},
+				{
+					Name:    "concise",
+					Prompts: map[string]string{prompts.TemplateChat: concise},
+					LLM:     &agents.LLMSettings{Chat: &agents.Profile{Model: "gpt-4"}},
+				},
+			},
+		}
+	}
+
+	// Experiments are checked before they start
+	var invalid *InvalidRequestError
+	single := newExperiment()
+	single.Variants = single.Variants[:1]
+	err = service.Experiments.CreateExperiment("admin", single)
+	require.True(t, errors.As(err, &invalid))
+
+	broken := newExperiment()
+	broken.Variants[1].Prompts[prompts.TemplateChat] = "{identity}"
+	err = service.Experiments.CreateExperiment("admin", broken)
+	require.True(t, errors.As(err, &invalid))
+	assert.Contains(t, err.Error(), "variant concise")
+
+	var permission *PermissionError
+	err = service.Experiments.CreateExperiment(testUser.ID, newExperiment())
+	require.True(t, errors.As(err, &permission))
+
+	experiment := newExperiment()
+	require.Nil(t, service.Experiments.CreateExperiment("admin", experiment))
+	assert.NotEmpty(t, experiment.ID)
+	assert.True(t, experiment.Active)
+
+	// ...and an agent runs one at a time
+	err = service.Experiments.CreateExperiment("admin", newExperiment())
+	require.True(t, errors.As(err, &invalid))
+
+	send := func(user string) *chat.Message {
+		llm.AddSendMessageResponse(&chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     testAgent.ID,
+			User:      user,
+			From:      testAgent.ID,
+			Content:   "Hello to you too!",
+			CreatedAt: time.Now(),
+		}, nil)
+		response, err := service.SendMessage(&chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     testAgent.ID,
+			User:      user,
+			From:      user,
+			Content:   "Hello, world!",
+			CreatedAt: time.Now(),
+		})
+		require.Nil(t, err)
+		return response
+	}
+
+	// Each user talks with the variant they're assigned, in
+	// every conversation
+	replies := map[string]*chat.Message{}
+	for index := 0; index < 6; index++ {
+		user := fmt.Sprintf("user-%d", index)
+		variant := experiment.Assign(user)
+
+		reply := send(user)
+		replies[user] = reply
+		assert.Equal(t, experiment.ID, reply.Experiment)
+		assert.Equal(t, variant.Name, reply.Variant)
+
+		agent, _, _, _, _, msg, _ := llm.GetSendMessageInputs()
+		assert.Equal(t, variant.Name, msg.Variant)
+		tmpl, err := service.Prompts.Template(agent, prompts.TemplateChat)
+		require.Nil(t, err)
+		if variant.Name == "concise" {
+			assert.Equal(t, "gpt-4", agent.LLM.ChatProfile().Model)
+			assert.Equal(t, concise, tmpl.Text)
+		} else {
+			assert.Empty(t, agent.LLM.ChatProfile().Model)
+			assert.Equal(t, prompts.Instructions, tmpl.Text)
+		}
+	}
+	again := send("user-0")
+	llm.GetSendMessageInputs()
+	assert.Equal(t, replies["user-0"].Variant, again.Variant)
+
+	// Users rate the replies they were given
+	for user, reply := range replies {
+		rating := chat.RatingDown
+		if user == "user-0" {
+			rating = chat.RatingUp
+		}
+		require.Nil(t, service.Feedback.SubmitFeedback(&chat.Feedback{Message: reply.ID, User: user, Rating: rating}))
+	}
+
+	err = service.Feedback.SubmitFeedback(&chat.Feedback{Message: again.ID, User: "user-0", Rating: 0})
+	require.True(t, errors.As(err, &invalid))
+	var notFound *NotFoundError
+	err = service.Feedback.SubmitFeedback(&chat.Feedback{Message: uuid.New().String(), User: "user-0", Rating: chat.RatingUp})
+	require.True(t, errors.As(err, &notFound))
+	conversation, err := service.Messages.GetConversation("user-0", again.Conversation)
+	require.Nil(t, err)
+	for _, msg := range conversation.Messages {
+		if msg.From == "user-0" {
+			err = service.Feedback.SubmitFeedback(&chat.Feedback{Message: msg.ID, User: "user-0", Rating: chat.RatingUp})
+			require.True(t, errors.As(err, &invalid))
+		}
+	}
+
+	// The report compares the variants by what was recorded
+	// under each
+	report, err := service.Experiments.Report("admin", experiment.ID)
+	require.Nil(t, err)
+	require.Len(t, report.Variants, 2)
+	totalReplies, totalRatings, totalTokens := 0, 0, 0
+	for _, variant := range report.Variants {
+		expected := 0
+		for user := range replies {
+			if experiment.Assign(user).Name == variant.Variant {
+				expected++
+			}
+		}
+		assert.Equal(t, expected, variant.Users, variant.Variant)
+		assert.Equal(t, expected, variant.Ratings, variant.Variant)
+
+		totalReplies += variant.Replies
+		totalRatings += variant.Ratings
+		totalTokens += variant.PromptTokens + variant.CompletionTokens
+		if variant.Replies > 0 {
+			assert.Equal(t, float64(variant.Ratings)/float64(variant.Replies), variant.FeedbackRate)
+		}
+	}
+	assert.Equal(t, 7, totalReplies)
+	assert.Equal(t, 6, totalRatings)
+	assert.Greater(t, totalTokens, 0)
+
+	// Only the agent's editors may see how it's going
+	_, err = service.Experiments.Report(testUser.ID, experiment.ID)
+	require.True(t, errors.As(err, &permission))
+
+	// Once ended, users are back to the agent as it is
+	ended, err := service.Experiments.EndExperiment("admin", experiment.ID)
+	require.Nil(t, err)
+	assert.False(t, ended.Active)
+	assert.False(t, ended.EndedAt.IsZero())
+
+	after := send("user-1")
+	llm.GetSendMessageInputs()
+	assert.Empty(t, after.Experiment)
+	assert.Empty(t, after.Variant)
+
+	listed, err := service.Experiments.ListExperiments("admin", testAgent.ID)
+	require.Nil(t, err)
+	require.Len(t, listed, 1)
+	assert.False(t, listed[0].Active)
+
+	// ...and another experiment may start
+	require.Nil(t, service.Experiments.CreateExperiment("admin", newExperiment()))
+}
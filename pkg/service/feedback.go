@@ -0,0 +1,239 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+)
+
+type FeedbackService struct {
+	db store.Store
+}
+
+func NewFeedbackService(db store.Store) *FeedbackService {
+	return &FeedbackService{
+		db: db,
+	}
+}
+
+/*
+SubmitFeedback records a user's rating of an agent's reply,
+along with any comment and tags, replacing whatever feedback
+they gave it before. Users may only rate the replies they
+were given, by agents they may still chat with.
+*/
+func (service *FeedbackService) SubmitFeedback(feedback *chat.Feedback) error {
+	err := feedback.Validate()
+	if err != nil {
+		return &InvalidRequestError{Err: err}
+	}
+
+	msg, err := service.db.GetMessage(feedback.Message)
+	if err != nil {
+		return err
+	} else if msg == nil || msg.User != feedback.User {
+		// Replies to others aren't revealed to exist
+		return &NotFoundError{Kind: "message", ID: feedback.Message}
+	} else if msg.From != msg.Agent {
+		return &InvalidRequestError{Err: fmt.Errorf("only an agent's replies can be rated")}
+	}
+
+	err = authorizeChatWith(service.db, msg.Agent, feedback.User)
+	if _, ok := err.(*NotFoundError); ok {
+		return &NotFoundError{Kind: "message", ID: feedback.Message}
+	} else if err != nil {
+		return err
+	}
+
+	feedback.Agent = msg.Agent
+	feedback.CreatedAt = time.Now()
+	return service.db.SaveFeedback(feedback)
+}
+
+/*
+ListFeedbackRequest narrows the feedback listed to that of
+an agent, a user, or a message, optionally of a single
+rating or tag, and given within a time range. Either Agent
+or User must be set.
+*/
+type ListFeedbackRequest struct {
+	Agent   string
+	User    string
+	Message string
+	Rating  int
+	Tag     string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+func (request *ListFeedbackRequest) Valid() error {
+	if request.Agent == "" && request.User == "" {
+		return fmt.Errorf("agent or user must be set")
+	}
+	if request.Rating != 0 && !chat.ValidRating(request.Rating) {
+		return fmt.Errorf("rating must be %d or %d", chat.RatingUp, chat.RatingDown)
+	}
+	if !request.To.IsZero() && request.To.Before(request.From) {
+		return fmt.Errorf("to must be after from")
+	}
+	return nil
+}
+
+func (request *ListFeedbackRequest) getFilters() []*store.FilterAttribute {
+	attributes := []*store.FilterAttribute{}
+
+	if request.Agent != "" {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "agent",
+			Value:     request.Agent,
+			Operation: store.EQ,
+		})
+	}
+	if request.User != "" {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "user",
+			Value:     request.User,
+			Operation: store.EQ,
+		})
+	}
+	if request.Message != "" {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "message",
+			Value:     request.Message,
+			Operation: store.EQ,
+		})
+	}
+	if request.Rating != 0 {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "rating",
+			Value:     request.Rating,
+			Operation: store.EQ,
+		})
+	}
+	if !request.From.IsZero() {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "created_at",
+			Value:     request.From,
+			Operation: store.GTE,
+		})
+	}
+	if !request.To.IsZero() {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "created_at",
+			Value:     request.To,
+			Operation: store.LTE,
+		})
+	}
+
+	return attributes
+}
+
+/*
+ListFeedback lists feedback, oldest first. Users may list
+the feedback they gave, and an agent's editors all of the
+feedback it was given.
+*/
+func (service *FeedbackService) ListFeedback(actorId string, request *ListFeedbackRequest) ([]*chat.Feedback, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+
+	if request.User == "" || request.User != actorId {
+		if request.Agent == "" {
+			return nil, &PermissionError{User: actorId, Action: "view the feedback of", Resource: "user " + request.User}
+		}
+		_, err = authorizeManage(service.db, actorId, request.Agent, "view the feedback of")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tags are kept together with the feedback, so are
+	// matched once it's listed
+	filter := store.Filter{Attributes: request.getFilters()}
+	if request.Tag == "" {
+		filter.Limit = request.Limit
+	}
+	found, err := service.db.ListFeedback(filter)
+	if err != nil || request.Tag == "" {
+		return found, err
+	}
+
+	tagged := []*chat.Feedback{}
+	for _, feedback := range found {
+		if feedback.HasTag(request.Tag) {
+			tagged = append(tagged, feedback)
+		}
+		if request.Limit > 0 && len(tagged) == request.Limit {
+			break
+		}
+	}
+	return tagged, nil
+}
+
+/*
+FeedbackReportRequest is the agent and time range to
+aggregate feedback over, and the period to break it down
+by - a day if not set. If To is not set, it is assumed to
+be now.
+*/
+type FeedbackReportRequest struct {
+	Agent    string
+	From     time.Time
+	To       time.Time
+	Interval string
+}
+
+func (request *FeedbackReportRequest) Valid() error {
+	if request.Agent == "" {
+		return fmt.Errorf("agent must be set")
+	}
+	if request.From.IsZero() {
+		return fmt.Errorf("from must be set")
+	}
+	if !request.To.IsZero() && request.To.Before(request.From) {
+		return fmt.Errorf("to must be after from")
+	}
+	if request.Interval != "" && !chat.ValidInterval(request.Interval) {
+		return fmt.Errorf("unknown interval %s", request.Interval)
+	}
+	return nil
+}
+
+/*
+Report aggregates the feedback an agent was given over a
+time range, per period and per tag, for its editors.
+*/
+func (service *FeedbackService) Report(actorId string, request *FeedbackReportRequest) (*chat.FeedbackReport, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	if request.To.IsZero() {
+		request.To = time.Now()
+	}
+	if request.Interval == "" {
+		request.Interval = chat.IntervalDay
+	}
+
+	_, err = authorizeManage(service.db, actorId, request.Agent, "view the feedback of")
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ListFeedbackRequest{
+		Agent: request.Agent,
+		From:  request.From,
+		To:    request.To,
+	}
+	feedback, err := service.db.ListFeedback(store.Filter{Attributes: list.getFilters()})
+	if err != nil {
+		return nil, err
+	}
+
+	return chat.NewFeedbackReport(request.Agent, request.From, request.To, request.Interval, feedback), nil
+}
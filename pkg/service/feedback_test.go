@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedback(t *testing.T) {
+	service, db, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "editor", Name: "Editor", CreatedAt: time.Now(), Role: users.RoleAgentEditor}, "supersecret"))
+
+	conversation := uuid.New().String()
+	reply := func(user string) *chat.Message {
+		msg := &chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: conversation,
+			Agent:        testAgent.ID,
+			User:         user,
+			From:         testAgent.ID,
+			Content:      "Hello to you too!",
+			CreatedAt:    time.Now(),
+		}
+		require.Nil(t, db.SaveMessage(msg))
+		return msg
+	}
+	first, second := reply(testUser.ID), reply(testUser.ID)
+	other := reply("abby")
+
+	// Comments are kept, and tags tidied
+	feedback := &chat.Feedback{
+		Message: first.ID,
+		User:    testUser.ID,
+		Rating:  chat.RatingDown,
+		Comment: "  Not what I asked ",
+		Tags:    []string{"Inaccurate", "inaccurate ", "too long"},
+	}
+	require.Nil(t, service.Feedback.SubmitFeedback(feedback))
+	assert.Equal(t, testAgent.ID, feedback.Agent)
+	assert.Equal(t, "Not what I asked", feedback.Comment)
+	assert.Equal(t, []string{"inaccurate", "too long"}, feedback.Tags)
+
+	require.Nil(t, service.Feedback.SubmitFeedback(&chat.Feedback{Message: second.ID, User: testUser.ID, Rating: chat.RatingUp, Tags: []string{"helpful"}}))
+	require.Nil(t, service.Feedback.SubmitFeedback(&chat.Feedback{Message: other.ID, User: "abby", Rating: chat.RatingDown, Tags: []string{"inaccurate"}}))
+
+	// Only the user a reply was given to may rate it
+	var notFound *NotFoundError
+	err = service.Feedback.SubmitFeedback(&chat.Feedback{Message: other.ID, User: testUser.ID, Rating: chat.RatingDown})
+	require.True(t, errors.As(err, &notFound))
+
+	var invalid *InvalidRequestError
+	for name, bad := range map[string]*chat.Feedback{
+		"rating":  {Message: first.ID, User: testUser.ID, Rating: 5},
+		"comment": {Message: first.ID, User: testUser.ID, Rating: chat.RatingUp, Comment: strings.Repeat("a", chat.MaxFeedbackCommentLength+1)},
+		"tag":     {Message: first.ID, User: testUser.ID, Rating: chat.RatingUp, Tags: []string{" "}},
+		"tags":    {Message: first.ID, User: testUser.ID, Rating: chat.RatingUp, Tags: strings.Split("a b c d e f g h i j k", " ")},
+	} {
+		err = service.Feedback.SubmitFeedback(bad)
+		assert.True(t, errors.As(err, &invalid), name)
+	}
+
+	// Users may list what they gave...
+	mine, err := service.Feedback.ListFeedback(testUser.ID, &ListFeedbackRequest{User: testUser.ID})
+	require.Nil(t, err)
+	require.Len(t, mine, 2)
+	assert.Equal(t, "Not what I asked", mine[0].Comment)
+
+	var permission *PermissionError
+	_, err = service.Feedback.ListFeedback(testUser.ID, &ListFeedbackRequest{User: "abby"})
+	require.True(t, errors.As(err, &permission))
+	_, err = service.Feedback.ListFeedback(testUser.ID, &ListFeedbackRequest{Agent: testAgent.ID})
+	require.True(t, errors.As(err, &permission))
+
+	// ...and an agent's editors what it was given
+	given, err := service.Feedback.ListFeedback("editor", &ListFeedbackRequest{Agent: testAgent.ID})
+	require.Nil(t, err)
+	assert.Len(t, given, 3)
+
+	given, err = service.Feedback.ListFeedback("editor", &ListFeedbackRequest{Agent: testAgent.ID, Tag: "inaccurate"})
+	require.Nil(t, err)
+	assert.Len(t, given, 2)
+
+	given, err = service.Feedback.ListFeedback("editor", &ListFeedbackRequest{Agent: testAgent.ID, Rating: chat.RatingUp})
+	require.Nil(t, err)
+	require.Len(t, given, 1)
+	assert.Equal(t, second.ID, given[0].Message)
+
+	// Feedback is aggregated per period, with every period in
+	// the range reported
+	require.Nil(t, db.SaveFeedback(&chat.Feedback{
+		Message:   reply("winston").ID,
+		Agent:     testAgent.ID,
+		User:      "winston",
+		Rating:    chat.RatingUp,
+		CreatedAt: time.Now().AddDate(0, 0, -2),
+	}))
+
+	report, err := service.Feedback.Report("editor", &FeedbackReportRequest{
+		Agent: testAgent.ID,
+		From:  time.Now().AddDate(0, 0, -3),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, chat.IntervalDay, report.Interval)
+	assert.Equal(t, 4, report.Totals.Ratings)
+	assert.Equal(t, 2, report.Totals.Positive)
+	assert.Equal(t, 0.5, report.Totals.PositiveRate)
+	assert.Equal(t, 1, report.Totals.Comments)
+	require.Len(t, report.Periods, 4)
+	assert.Equal(t, 0, report.Periods[0].Ratings)
+	assert.Equal(t, 1, report.Periods[1].Ratings)
+	assert.Equal(t, 3, report.Periods[3].Ratings)
+	assert.Equal(t, 2, report.Tags["inaccurate"].Negative)
+	assert.Equal(t, 1.0, report.Tags["helpful"].PositiveRate)
+
+	weekly, err := service.Feedback.Report("editor", &FeedbackReportRequest{
+		Agent:    testAgent.ID,
+		From:     time.Now().AddDate(0, 0, -3),
+		Interval: chat.IntervalWeek,
+	})
+	require.Nil(t, err)
+	total := 0
+	for _, period := range weekly.Periods {
+		assert.Equal(t, time.Monday, period.Start.Weekday())
+		total += period.Ratings
+	}
+	assert.Equal(t, 4, total)
+
+	_, err = service.Feedback.Report("editor", &FeedbackReportRequest{Agent: testAgent.ID, From: time.Now(), Interval: "fortnight"})
+	require.True(t, errors.As(err, &invalid))
+	_, err = service.Feedback.Report(testUser.ID, &FeedbackReportRequest{Agent: testAgent.ID, From: time.Now()})
+	require.True(t, errors.As(err, &permission))
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+)
+
+// The longest chain of facts searched for between entities
+const maxGraphPathLength = 6
+
+// The formats a knowledge graph can be exported in
+const (
+	GraphFormatJSON = "json"
+	GraphFormatDOT  = "dot"
+)
+
+/*
+KnowledgeService answers queries over what an agent has
+learned about a user, viewed as a graph of entities.
+*/
+type KnowledgeService struct {
+	db store.Store
+}
+
+func NewKnowledgeService(db store.Store) *KnowledgeService {
+	return &KnowledgeService{
+		db: db,
+	}
+}
+
+/*
+GraphRequest names the agent and user whose knowledge graph
+is being queried.
+*/
+type GraphRequest struct {
+	Agent string
+	User  string
+}
+
+func (request *GraphRequest) Valid() error {
+	if request.Agent == "" {
+		return fmt.Errorf("agent must be set")
+	}
+	if request.User == "" {
+		return fmt.Errorf("user must be set")
+	}
+	return nil
+}
+
+/*
+GetGraph returns the agent's current knowledge of the user
+as a graph.
+*/
+func (service *KnowledgeService) GetGraph(request *GraphRequest) (*memory.Graph, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.GetKnowledgeGraph(request.Agent, request.User)
+}
+
+/*
+About returns everything known about an entity - every fact
+it is the subject or object of.
+*/
+func (service *KnowledgeService) About(request *GraphRequest, entity string) ([]*memory.Knowledge, error) {
+	if memory.NormalizeEntity(entity) == "" {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("entity must be set")}
+	}
+
+	graph, err := service.GetGraph(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.About(entity), nil
+}
+
+/*
+Neighbors returns the entities that share a fact with the
+given entity.
+*/
+func (service *KnowledgeService) Neighbors(request *GraphRequest, entity string) ([]string, error) {
+	if memory.NormalizeEntity(entity) == "" {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("entity must be set")}
+	}
+
+	graph, err := service.GetGraph(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.Neighbors(entity), nil
+}
+
+/*
+Path returns the shortest chain of facts linking two
+entities. If they are not linked, an empty path is
+returned.
+*/
+func (service *KnowledgeService) Path(request *GraphRequest, from string, to string) ([]*memory.Knowledge, error) {
+	if memory.NormalizeEntity(from) == "" || memory.NormalizeEntity(to) == "" {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("from and to must be set")}
+	}
+
+	graph, err := service.GetGraph(request)
+	if err != nil {
+		return nil, err
+	}
+
+	path := graph.Path(from, to, maxGraphPathLength)
+	if path == nil {
+		path = []*memory.Knowledge{}
+	}
+
+	return path, nil
+}
+
+/*
+ByPredicate returns every fact with the given predicate, ie
+everything the user "works at".
+*/
+func (service *KnowledgeService) ByPredicate(request *GraphRequest, predicate string) ([]*memory.Knowledge, error) {
+	if predicate == "" {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("predicate must be set")}
+	}
+
+	graph, err := service.GetGraph(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.ByPredicate(predicate), nil
+}
+
+/*
+Export renders the knowledge graph in the given format - JSON
+or Graphviz DOT - for debugging tools.
+*/
+func (service *KnowledgeService) Export(request *GraphRequest, format string) ([]byte, error) {
+	if format != GraphFormatJSON && format != GraphFormatDOT {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("unknown graph format %s", format)}
+	}
+
+	graph, err := service.GetGraph(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == GraphFormatDOT {
+		return []byte(graph.DOT()), nil
+	}
+	return json.Marshal(graph)
+}
+
+/*
+SaveAlias records another name for an entity, ie that "my
+brother" is "Kevin". Saving an existing alias points it at
+the new entity.
+*/
+func (service *KnowledgeService) SaveAlias(alias *memory.Alias) error {
+	err := (&GraphRequest{Agent: alias.Agent, User: alias.User}).Valid()
+	if err != nil {
+		return &InvalidRequestError{Err: err}
+	}
+
+	name := memory.NormalizeEntity(alias.Alias)
+	entity := memory.NormalizeEntity(alias.Entity)
+	if name == "" || entity == "" {
+		return &InvalidRequestError{Err: fmt.Errorf("alias and entity must be set")}
+	} else if name == entity {
+		return &InvalidRequestError{Err: fmt.Errorf("an entity can not be an alias of itself")}
+	}
+
+	agent, err := service.db.GetAgent(alias.Agent)
+	if err != nil {
+		return err
+	} else if agent == nil {
+		return &NotFoundError{Kind: "agent", ID: alias.Agent}
+	}
+	err = checkUserTenancy(service.db, agent.ID, agent.Tenant, alias.User)
+	if err != nil {
+		return err
+	}
+
+	alias.CreatedAt = time.Now()
+
+	return service.db.SaveAlias(alias)
+}
+
+/*
+DeleteAlias forgets an alias, so that it names an entity of
+its own again.
+*/
+func (service *KnowledgeService) DeleteAlias(request *GraphRequest, alias string) error {
+	err := request.Valid()
+	if err != nil {
+		return &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return err
+	}
+
+	return service.db.DeleteAlias(request.Agent, request.User, alias)
+}
+
+/*
+Provenance is where a fact came from - the messages it was
+extracted from. Messages that have since been deleted are
+left out.
+*/
+type Provenance struct {
+	Fact     *memory.Knowledge `json:"fact"`
+	Messages []*chat.Message   `json:"messages"`
+}
+
+/*
+GetProvenance returns a fact the agent learned about the
+user, along with the messages it was learned from. Facts of
+anyone else are reported as not found.
+*/
+func (service *KnowledgeService) GetProvenance(request *GraphRequest, id string) (*Provenance, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
+
+	fact, err := service.db.GetKnowledge(id)
+	if err != nil {
+		return nil, err
+	} else if fact == nil || fact.Agent != request.Agent || fact.User != request.User {
+		return nil, &NotFoundError{Kind: "fact", ID: id}
+	}
+
+	provenance := &Provenance{
+		Fact:     fact,
+		Messages: []*chat.Message{},
+	}
+	for _, source := range fact.Messages {
+		message, err := service.db.GetMessage(source)
+		if err != nil {
+			return nil, err
+		} else if message != nil {
+			provenance.Messages = append(provenance.Messages, message)
+		}
+	}
+
+	return provenance, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKnowledgeGraph(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, db, err := createMockService(llm)
+	require.Nil(t, err)
+
+	learn := func(subject string, predicate string, object string) *memory.Knowledge {
+		fact := &memory.Knowledge{
+			ID:        uuid.New().String(),
+			Agent:     testAgent.ID,
+			User:      testUser.ID,
+			Subject:   subject,
+			Predicate: predicate,
+			Object:    object,
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+		require.Nil(t, db.SaveKnowledge(fact))
+		return fact
+	}
+
+	employer := learn("Keith", "works at", "Acme")
+	sibling := learn("Keith", "has sibling", "Kevin")
+	home := learn("my brother", "lives in", "Boston")
+
+	request := &GraphRequest{Agent: testAgent.ID, User: testUser.ID}
+
+	// ==== Invalid requests ====
+	var invalidErr *InvalidRequestError
+	_, err = service.Knowledge.About(&GraphRequest{Agent: testAgent.ID}, "Keith")
+	assert.True(t, errors.As(err, &invalidErr))
+	_, err = service.Knowledge.About(request, " ")
+	assert.True(t, errors.As(err, &invalidErr))
+	_, err = service.Knowledge.Export(request, "png")
+	assert.True(t, errors.As(err, &invalidErr))
+
+	err = service.Knowledge.SaveAlias(&memory.Alias{Agent: testAgent.ID, User: testUser.ID, Alias: "Kevin", Entity: "kevin"})
+	assert.True(t, errors.As(err, &invalidErr))
+
+	var notFoundErr *NotFoundError
+	err = service.Knowledge.SaveAlias(&memory.Alias{Agent: uuid.New().String(), User: testUser.ID, Alias: "bro", Entity: "Kevin"})
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	// ==== Without aliases ====
+	path, err := service.Knowledge.Path(request, "Keith", "Boston")
+	require.Nil(t, err)
+	assert.Empty(t, path)
+
+	// ==== With aliases ====
+	require.Nil(t, service.Knowledge.SaveAlias(&memory.Alias{
+		Agent:  testAgent.ID,
+		User:   testUser.ID,
+		Alias:  "My Brother",
+		Entity: "Kevin",
+	}))
+
+	about, err := service.Knowledge.About(request, "kevin")
+	require.Nil(t, err)
+	require.Len(t, about, 2)
+	assert.Equal(t, sibling.ID, about[0].ID)
+	assert.Equal(t, home.ID, about[1].ID)
+
+	neighbors, err := service.Knowledge.Neighbors(request, "KEITH")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"acme", "kevin"}, neighbors)
+
+	path, err = service.Knowledge.Path(request, "Keith", "Boston")
+	require.Nil(t, err)
+	require.Len(t, path, 2)
+	assert.Equal(t, sibling.ID, path[0].ID)
+	assert.Equal(t, home.ID, path[1].ID)
+
+	facts, err := service.Knowledge.ByPredicate(request, "Works At")
+	require.Nil(t, err)
+	require.Len(t, facts, 1)
+	assert.Equal(t, employer.ID, facts[0].ID)
+
+	// ==== Export ====
+	dot, err := service.Knowledge.Export(request, GraphFormatDOT)
+	require.Nil(t, err)
+	assert.Contains(t, string(dot), "\"kevin\" -> \"boston\"")
+
+	data, err := service.Knowledge.Export(request, GraphFormatJSON)
+	require.Nil(t, err)
+	var exported map[string]interface{}
+	require.Nil(t, json.Unmarshal(data, &exported))
+	assert.Len(t, exported["nodes"], 4)
+	assert.Len(t, exported["edges"], 3)
+
+	// ==== Forgetting an alias ====
+	require.Nil(t, service.Knowledge.DeleteAlias(request, "my brother"))
+
+	about, err = service.Knowledge.About(request, "kevin")
+	require.Nil(t, err)
+	require.Len(t, about, 1)
+	assert.Equal(t, sibling.ID, about[0].ID)
+}
+
+func TestKnowledgeProvenance(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, db, err := createMockService(llm)
+	require.Nil(t, err)
+
+	conversation := uuid.New().String()
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: conversation,
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Content:      "I just started at Initech",
+		CreatedAt:    time.Now(),
+	}
+	require.Nil(t, db.SaveMessage(msg))
+
+	employer := &memory.Knowledge{
+		ID:           uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		Subject:      "Keith",
+		Predicate:    "works at",
+		Object:       "Initech",
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Conversation: conversation,
+		Messages:     []string{msg.ID, uuid.New().String()},
+		Confidence:   0.9,
+	}
+	require.Nil(t, db.SaveKnowledge(employer))
+
+	// Messages that no longer exist are left out
+	request := &GraphRequest{Agent: testAgent.ID, User: testUser.ID}
+	provenance, err := service.Knowledge.GetProvenance(request, employer.ID)
+	require.Nil(t, err)
+	assert.True(t, employer.Equal(provenance.Fact))
+	require.Len(t, provenance.Messages, 1)
+	assert.Equal(t, msg.ID, provenance.Messages[0].ID)
+
+	var notFoundErr *NotFoundError
+	_, err = service.Knowledge.GetProvenance(request, uuid.New().String())
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	// Other users can't read where the user's facts came from
+	_, err = service.Knowledge.GetProvenance(&GraphRequest{Agent: testAgent.ID, User: "abby"}, employer.ID)
+	require.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, "fact", notFoundErr.Kind)
+
+	// Only those in the conversation may delete it
+	var permissionErr *PermissionError
+	err = service.Messages.DeleteConversation("abby", conversation, false)
+	assert.True(t, errors.As(err, &permissionErr))
+
+	// Deleting the conversation alone keeps what was learned
+	require.Nil(t, service.Messages.DeleteConversation(testUser.ID, conversation, false))
+
+	provenance, err = service.Knowledge.GetProvenance(request, employer.ID)
+	require.Nil(t, err)
+	assert.Empty(t, provenance.Messages)
+
+	// ...unless its knowledge is forgotten with it
+	require.Nil(t, db.SaveMessage(msg))
+	require.Nil(t, service.Messages.DeleteConversation(testUser.ID, conversation, true))
+
+	_, err = service.Knowledge.GetProvenance(request, employer.ID)
+	assert.True(t, errors.As(err, &notFoundErr))
+}
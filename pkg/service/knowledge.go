@@ -0,0 +1,153 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/memory"
+)
+
+/*
+currentKnowledge is the filter attribute for facts that have
+not been superseded
+*/
+var currentKnowledge = &store.FilterAttribute{
+	Attribute: "superseded_by",
+	Value:     "",
+	Operation: store.EQ,
+}
+
+/*
+KnowledgeDaemon maintains the knowledge of every agent and
+user pair. A pair that fails is logged and skipped, so that
+it doesn't hold up the rest.
+*/
+func (service *Service) KnowledgeDaemon() error {
+	knowledge, err := service.db.ListKnowledge(store.Filter{
+		Attributes: []*store.FilterAttribute{currentKnowledge},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Maintain each agent and user pair once, in the order
+	// they first learned something
+	type pair struct {
+		agent string
+		user  string
+	}
+	pairs := []pair{}
+	seen := map[pair]bool{}
+	for _, fact := range knowledge {
+		key := pair{agent: fact.Agent, user: fact.User}
+		if !seen[key] {
+			seen[key] = true
+			pairs = append(pairs, key)
+		}
+	}
+
+	for _, key := range pairs {
+		_, err := service.MaintainKnowledge(key.agent, key.user)
+		if err != nil {
+			log.Printf("maintaining the knowledge of agent %s and user %s: %v", key.agent, key.user, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+MaintainKnowledge compresses what an agent has learned about
+a user. Facts that exactly repeat an earlier one are always
+merged; once the number of current facts passes the agent's
+threshold, or the configured one, the LLM is also asked to
+find facts that repeat one another in other words, or that
+have been contradicted by a newer fact. Replaced facts are
+kept as history, marked as superseded. The facts that were
+changed are returned.
+*/
+func (service *Service) MaintainKnowledge(agentId string, user string) ([]*memory.Knowledge, error) {
+	agent, err := service.db.GetAgent(agentId)
+	if err != nil {
+		return nil, err
+	} else if agent == nil {
+		return nil, fmt.Errorf("agent %s not found", agentId)
+	}
+
+	knowledge, err := service.db.ListKnowledge(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+			{
+				Attribute: "user",
+				Value:     user,
+				Operation: store.EQ,
+			},
+			currentKnowledge,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Expired facts are no longer worth compressing
+	current := []*memory.Knowledge{}
+	for _, fact := range knowledge {
+		if !fact.IsExpired() {
+			current = append(current, fact)
+		}
+	}
+
+	changed := memory.MergeDuplicates(current)
+
+	remaining := []*memory.Knowledge{}
+	for _, fact := range current {
+		if !fact.IsSuperseded() {
+			remaining = append(remaining, fact)
+		}
+	}
+
+	threshold := service.config.Knowledge.CompressionThreshold
+	if agent.Memory.CompressionThreshold > 0 {
+		threshold = agent.Memory.CompressionThreshold
+	}
+	if threshold > 0 && len(remaining) > threshold {
+		model, err := service.modelFor(agent.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		compression, err := model.CompressKnowledge(agent, user, remaining)
+		if err != nil {
+			return nil, err
+		}
+
+		// A fact may have been changed by both passes
+		for _, fact := range memory.Compress(remaining, compression) {
+			alreadyChanged := false
+			for _, other := range changed {
+				if other == fact {
+					alreadyChanged = true
+					break
+				}
+			}
+			if !alreadyChanged {
+				changed = append(changed, fact)
+			}
+		}
+	}
+
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	err = service.db.CompressKnowledge(agentId, user, changed)
+	if err != nil {
+		return nil, err
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintainKnowledge(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, db, err := createMockService(llm)
+	require.Nil(t, err)
+
+	learn := func(user string, predicate string, object string, age time.Duration) *memory.Knowledge {
+		fact := &memory.Knowledge{
+			ID:        uuid.New().String(),
+			Agent:     testAgent.ID,
+			User:      user,
+			Subject:   "Keith",
+			Predicate: predicate,
+			Object:    object,
+			CreatedAt: time.Now().Add(-age),
+			ExpiresAt: time.Now().Add(24 * time.Hour),
+		}
+		require.Nil(t, db.SaveKnowledge(fact))
+		return fact
+	}
+
+	robots := learn(testUser.ID, "likes", "robots", 3*time.Hour)
+	acme := learn(testUser.ID, "works at", "Acme", 2*time.Hour)
+	repeat := learn(testUser.ID, "Likes", "Robots", time.Hour)
+	initech := learn(testUser.ID, "works at", "Initech", time.Minute)
+	other := learn(uuid.New().String(), "likes", "robots", time.Minute)
+
+	// ==== Below the threshold ====
+
+	// Exact repeats are merged without asking the LLM, the
+	// original taking on the repeat's later expiration
+	service.config.Knowledge.CompressionThreshold = 10
+
+	changed, err := service.MaintainKnowledge(testAgent.ID, testUser.ID)
+	require.Nil(t, err)
+	require.Len(t, changed, 2)
+	assert.Equal(t, robots.ID, changed[0].ID)
+	assert.Equal(t, repeat.ID, changed[1].ID)
+
+	stored, err := db.GetKnowledge(repeat.ID)
+	require.Nil(t, err)
+	assert.Equal(t, robots.ID, stored.SupersededBy)
+
+	stored, err = db.GetKnowledge(robots.ID)
+	require.Nil(t, err)
+	assert.False(t, stored.IsSuperseded())
+	assert.WithinDuration(t, repeat.ExpiresAt, stored.ExpiresAt, time.Second)
+
+	compressAgent, _, _ := llm.GetCompressKnowledgeInputs()
+	assert.Nil(t, compressAgent)
+
+	// ==== Above the threshold ====
+
+	// The LLM finds the new employer contradicts the old
+	service.config.Knowledge.CompressionThreshold = 2
+	llm.AddCompressKnowledgeResponse(&memory.Compression{
+		Contradictions: [][]string{{initech.ID, acme.ID}},
+	}, nil)
+
+	changed, err = service.MaintainKnowledge(testAgent.ID, testUser.ID)
+	require.Nil(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, acme.ID, changed[0].ID)
+
+	// Only the current facts were sent to be compressed
+	_, user, sent := llm.GetCompressKnowledgeInputs()
+	assert.Equal(t, testUser.ID, user)
+	require.Len(t, sent, 3)
+	assert.Equal(t, robots.ID, sent[0].ID)
+	assert.Equal(t, acme.ID, sent[1].ID)
+	assert.Equal(t, initech.ID, sent[2].ID)
+
+	// The old employer is kept as history
+	knowledge, err := db.ListKnowledge(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "user", Value: testUser.ID, Operation: store.EQ},
+		},
+	})
+	require.Nil(t, err)
+	require.Len(t, knowledge, 4)
+	assert.Equal(t, initech.ID, knowledge[1].SupersededBy)
+
+	// Other users' knowledge is left alone
+	stored, err = db.GetKnowledge(other.ID)
+	require.Nil(t, err)
+	assert.False(t, stored.IsSuperseded())
+
+	// ==== Daemon ====
+
+	// With two current facts left, the daemon finds nothing
+	// to compress for either user
+	llm.ClearMemory()
+	require.Nil(t, service.KnowledgeDaemon())
+	compressAgent, _, _ = llm.GetCompressKnowledgeInputs()
+	assert.Nil(t, compressAgent)
+
+	// Unknown agents can't be maintained
+	_, err = service.MaintainKnowledge(uuid.New().String(), testUser.ID)
+	assert.NotNil(t, err)
+
+	// ...nor stop the daemon from maintaining the rest
+	orphan := *robots
+	orphan.ID = uuid.New().String()
+	orphan.Agent = uuid.New().String()
+	orphan.CreatedAt = robots.CreatedAt.Add(-time.Hour)
+	require.Nil(t, db.SaveKnowledge(&orphan))
+
+	service.config.Knowledge.CompressionThreshold = 1
+	llm.AddCompressKnowledgeResponse(&memory.Compression{}, nil)
+	require.Nil(t, service.KnowledgeDaemon())
+	compressAgent, _, _ = llm.GetCompressKnowledgeInputs()
+	assert.NotNil(t, compressAgent)
+}
@@ -1,10 +1,57 @@
 package service
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/hlfshell/coppermind/internal/llm"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/llm/openai"
+	"github.com/hlfshell/coppermind/internal/llm/router"
 	"github.com/hlfshell/coppermind/pkg/config"
 )
 
+/*
+NewLLMFromConfig creates each configured LLM provider and
+wraps them in a router per the configured routes.
+*/
 func NewLLMFromConfig(config *config.Config) (llm.LLM, error) {
-	return nil, nil
+	if len(config.LLM.Providers) == 0 {
+		return nil, fmt.Errorf("no llm providers configured")
+	}
+
+	backends := map[string]llm.LLM{}
+	for name, provider := range config.LLM.Providers {
+		switch provider.Type {
+		case "openai":
+			apiKey := provider.APIKey
+			if apiKey == "" && provider.APIKeyEnv != "" {
+				apiKey = os.Getenv(provider.APIKeyEnv)
+			}
+			if apiKey == "" {
+				return nil, fmt.Errorf("no api key set for llm provider %s", name)
+			}
+			backends[name] = openai.NewOpenAI(apiKey)
+		case "mock":
+			backends[name] = mock.NewMockLLM()
+		default:
+			return nil, fmt.Errorf("unknown type %s for llm provider %s", provider.Type, name)
+		}
+	}
+
+	routes := map[string]router.Route{}
+	for callType, route := range config.LLM.Routes {
+		routes[callType] = router.Route{
+			Backends: route.Providers,
+			Timeout:  time.Duration(route.TimeoutSeconds) * time.Second,
+		}
+	}
+
+	llmRouter, err := router.NewRouter(backends, routes)
+	if err != nil {
+		return nil, err
+	}
+
+	return llmRouter, nil
 }
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hlfshell/coppermind/internal/llm/router"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLLMFromConfig(t *testing.T) {
+	cfg := config.DefaultConfig
+
+	// ==== Invalid configs ====
+	cfg.LLM = config.LLMConfig{}
+	model, err := NewLLMFromConfig(&cfg)
+	assert.NotNil(t, err)
+	assert.Nil(t, model)
+
+	cfg.LLM = config.LLMConfig{
+		Providers: map[string]config.ProviderConfig{
+			"primary": {Type: "carrier-pigeon"},
+		},
+		Routes: map[string]config.RouteConfig{
+			"default": {Providers: []string{"primary"}},
+		},
+	}
+	model, err = NewLLMFromConfig(&cfg)
+	assert.NotNil(t, err)
+	assert.Nil(t, model)
+
+	cfg.LLM = config.LLMConfig{
+		Providers: map[string]config.ProviderConfig{
+			"primary": {Type: "openai", APIKeyEnv: "COPPERMIND_TEST_UNSET_KEY"},
+		},
+		Routes: map[string]config.RouteConfig{
+			"default": {Providers: []string{"primary"}},
+		},
+	}
+	model, err = NewLLMFromConfig(&cfg)
+	assert.NotNil(t, err)
+	assert.Nil(t, model)
+
+	// Routes must refer to configured providers
+	cfg.LLM = config.LLMConfig{
+		Providers: map[string]config.ProviderConfig{
+			"primary": {Type: "mock"},
+		},
+		Routes: map[string]config.RouteConfig{
+			"default": {Providers: []string{"primary", "secondary"}},
+		},
+	}
+	model, err = NewLLMFromConfig(&cfg)
+	assert.NotNil(t, err)
+	assert.Nil(t, model)
+
+	// ==== Valid config ====
+	cfg.LLM = config.LLMConfig{
+		Providers: map[string]config.ProviderConfig{
+			"primary":   {Type: "mock"},
+			"secondary": {Type: "mock"},
+		},
+		Routes: map[string]config.RouteConfig{
+			"default": {Providers: []string{"primary", "secondary"}, TimeoutSeconds: 5},
+			"chat":    {Providers: []string{"secondary"}},
+		},
+	}
+	model, err = NewLLMFromConfig(&cfg)
+	require.Nil(t, err)
+	require.NotNil(t, model)
+	_, ok := model.(*router.Router)
+	assert.True(t, ok)
+}
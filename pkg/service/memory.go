@@ -0,0 +1,344 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+)
+
+/*
+MemoryService lets a user see, correct, and forget what an
+agent remembers about them.
+*/
+type MemoryService struct {
+	db store.Store
+}
+
+func NewMemoryService(db store.Store) *MemoryService {
+	return &MemoryService{
+		db: db,
+	}
+}
+
+/*
+MemoryRequest names the agent and the user whose memories
+are being managed. Facts and conversations belonging to
+anyone else are treated as not found.
+*/
+type MemoryRequest struct {
+	Agent string
+	User  string
+}
+
+func (request *MemoryRequest) Valid() error {
+	if request.Agent == "" {
+		return fmt.Errorf("agent must be set")
+	}
+	if request.User == "" {
+		return fmt.Errorf("user must be set")
+	}
+	return nil
+}
+
+func (request *MemoryRequest) getFilters() []*store.FilterAttribute {
+	return []*store.FilterAttribute{
+		{
+			Attribute: "agent",
+			Value:     request.Agent,
+			Operation: store.EQ,
+		},
+		{
+			Attribute: "user",
+			Value:     request.User,
+			Operation: store.EQ,
+		},
+	}
+}
+
+/*
+Memory is everything an agent remembers about a user - the
+summaries of their past conversations and the facts it has
+learned about them.
+*/
+type Memory struct {
+	Summaries []*memory.Summary   `json:"summaries"`
+	Knowledge []*memory.Knowledge `json:"knowledge"`
+}
+
+/*
+GetMemory returns what the agent remembers about the user.
+Facts that have expired or been superseded are not
+included.
+*/
+func (service *MemoryService) GetMemory(request *MemoryRequest) (*Memory, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := service.db.ListSummaries(store.Filter{
+		Attributes: request.getFilters(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	knowledge, err := service.currentKnowledge(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Memory{
+		Summaries: summaries,
+		Knowledge: knowledge,
+	}, nil
+}
+
+// currentKnowledge lists the user's unexpired, current facts
+func (service *MemoryService) currentKnowledge(request *MemoryRequest) ([]*memory.Knowledge, error) {
+	knowledge, err := service.db.ListKnowledge(store.Filter{
+		Attributes: append(request.getFilters(), currentKnowledge),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	current := []*memory.Knowledge{}
+	for _, fact := range knowledge {
+		if !fact.IsExpired() {
+			current = append(current, fact)
+		}
+	}
+	return current, nil
+}
+
+// getFact returns a fact only if it belongs to the agent and user
+func (service *MemoryService) getFact(request *MemoryRequest, id string) (*memory.Knowledge, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
+
+	fact, err := service.db.GetKnowledge(id)
+	if err != nil {
+		return nil, err
+	} else if fact == nil || fact.Agent != request.Agent || fact.User != request.User {
+		return nil, &NotFoundError{Kind: "fact", ID: id}
+	}
+
+	return fact, nil
+}
+
+/*
+FactCorrection is a user's correction to a fact. Only the
+parts that are set are changed.
+*/
+type FactCorrection struct {
+	Subject   string    `json:"subject,omitempty"`
+	Predicate string    `json:"predicate,omitempty"`
+	Object    string    `json:"object,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+/*
+CorrectFact changes what a fact says, or when it expires,
+and returns the corrected fact.
+*/
+func (service *MemoryService) CorrectFact(request *MemoryRequest, id string, correction *FactCorrection) (*memory.Knowledge, error) {
+	fact, err := service.getFact(request, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if correction.Subject != "" {
+		fact.Subject = correction.Subject
+	}
+	if correction.Predicate != "" {
+		fact.Predicate = correction.Predicate
+	}
+	if correction.Object != "" {
+		fact.Object = correction.Object
+	}
+	if !correction.ExpiresAt.IsZero() {
+		fact.ExpiresAt = correction.ExpiresAt
+	}
+
+	err = service.db.UpdateKnowledge(fact)
+	if err != nil {
+		return nil, err
+	}
+
+	return fact, nil
+}
+
+/*
+PinFact sets whether a fact is pinned; pinned facts are
+never forgotten by expiring or by compression.
+*/
+func (service *MemoryService) PinFact(request *MemoryRequest, id string, pinned bool) (*memory.Knowledge, error) {
+	fact, err := service.getFact(request, id)
+	if err != nil {
+		return nil, err
+	}
+
+	fact.Pinned = pinned
+	err = service.db.UpdateKnowledge(fact)
+	if err != nil {
+		return nil, err
+	}
+
+	return fact, nil
+}
+
+// DeleteFact forgets a single fact
+func (service *MemoryService) DeleteFact(request *MemoryRequest, id string) error {
+	_, err := service.getFact(request, id)
+	if err != nil {
+		return err
+	}
+
+	return service.db.DeleteKnowledge(id)
+}
+
+/*
+ExcludeConversation removes a conversation from the agent's
+memory: it is excluded from summarization, its summary is
+deleted, and the facts learned from it are forgotten. The
+conversation's messages are kept.
+*/
+func (service *MemoryService) ExcludeConversation(request *MemoryRequest, id string) error {
+	err := request.Valid()
+	if err != nil {
+		return &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return err
+	}
+
+	conversation, err := service.db.GetConversation(id)
+	if err != nil {
+		return err
+	} else if conversation == nil || conversation.Agent != request.Agent || conversation.User != request.User {
+		return &NotFoundError{Kind: "conversation", ID: id}
+	}
+
+	err = service.db.ExcludeConversationFromSummary(id)
+	if err != nil {
+		return err
+	}
+
+	summaries, err := service.db.ListSummaries(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "conversation",
+				Value:     id,
+				Operation: store.EQ,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, summary := range summaries {
+		err = service.db.DeleteSummary(summary.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return service.db.DeleteConversationKnowledge(id)
+}
+
+/*
+Forget acts on a request to forget something said in a
+conversation. A target of "" forgets what was learned from
+the latest exchange - everything since the user's last
+message - as in "forget what I just said". Otherwise the
+facts the target refers to are forgotten. Pinned facts are
+kept, as the request is matched without the LLM; they are
+only forgotten by deleting them outright. The forgotten
+facts are returned.
+*/
+func (service *MemoryService) Forget(request *MemoryRequest, conversation *chat.Conversation, target string) ([]*memory.Knowledge, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, &InvalidRequestError{Err: err}
+	}
+	err = checkTenancy(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
+
+	knowledge, err := service.currentKnowledge(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*memory.Knowledge
+	if target != "" {
+		matched = memory.MatchForgotten(knowledge, target)
+	} else {
+		matched = learnedFrom(knowledge, latestExchange(conversation, request.User))
+	}
+
+	forgotten := []*memory.Knowledge{}
+	for _, fact := range matched {
+		if fact.Pinned {
+			continue
+		}
+		forgotten = append(forgotten, fact)
+
+		err = service.db.DeleteKnowledge(fact.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return forgotten, nil
+}
+
+/*
+latestExchange returns the IDs of the saved messages of a
+conversation back to, and including, the user's last one.
+*/
+func latestExchange(conversation *chat.Conversation, user string) map[string]bool {
+	messages := map[string]bool{}
+	if conversation == nil {
+		return messages
+	}
+
+	for index := len(conversation.Messages) - 1; index >= 0; index-- {
+		msg := conversation.Messages[index]
+		messages[msg.ID] = true
+		if msg.From == user {
+			break
+		}
+	}
+	return messages
+}
+
+// learnedFrom returns the facts extracted from any of the messages
+func learnedFrom(knowledge []*memory.Knowledge, messages map[string]bool) []*memory.Knowledge {
+	facts := []*memory.Knowledge{}
+	for _, fact := range knowledge {
+		for _, source := range fact.Messages {
+			if messages[source] {
+				facts = append(facts, fact)
+				break
+			}
+		}
+	}
+	return facts
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManageMemory(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, db, err := createMockService(llm)
+	require.Nil(t, err)
+
+	conversation := uuid.New().String()
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: conversation,
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Content:      "I work at Acme, and I like robots",
+		CreatedAt:    time.Now(),
+	}
+	require.Nil(t, db.SaveMessage(msg))
+
+	summary := &memory.Summary{
+		ID:                    uuid.New().String(),
+		Agent:                 testAgent.ID,
+		User:                  testUser.ID,
+		Conversation:          conversation,
+		Keywords:              []string{"work"},
+		Summary:               "Keith talked about work",
+		UpdatedAt:             time.Now(),
+		ConversationStartedAt: msg.CreatedAt,
+	}
+	require.Nil(t, db.SaveSummary(summary))
+
+	learn := func(user string, object string, expiresIn time.Duration) *memory.Knowledge {
+		source := conversation
+		if user != testUser.ID {
+			source = uuid.New().String()
+		}
+		fact := &memory.Knowledge{
+			ID:           uuid.New().String(),
+			Agent:        testAgent.ID,
+			User:         user,
+			Subject:      "Keith",
+			Predicate:    "works at",
+			Object:       object,
+			CreatedAt:    time.Now(),
+			ExpiresAt:    time.Now().Add(expiresIn),
+			Conversation: source,
+			Messages:     []string{msg.ID},
+		}
+		require.Nil(t, db.SaveKnowledge(fact))
+		return fact
+	}
+
+	employer := learn(testUser.ID, "Acme", time.Hour)
+	learn(testUser.ID, "Globex", -time.Hour)
+	other := learn(uuid.New().String(), "Initech", time.Hour)
+
+	request := &MemoryRequest{Agent: testAgent.ID, User: testUser.ID}
+
+	// ==== Viewing ====
+	_, err = service.Memory.GetMemory(&MemoryRequest{Agent: testAgent.ID})
+	var invalidErr *InvalidRequestError
+	assert.True(t, errors.As(err, &invalidErr))
+
+	remembered, err := service.Memory.GetMemory(request)
+	require.Nil(t, err)
+	require.Len(t, remembered.Summaries, 1)
+	assert.Equal(t, summary.ID, remembered.Summaries[0].ID)
+	require.Len(t, remembered.Knowledge, 1)
+	assert.Equal(t, employer.ID, remembered.Knowledge[0].ID)
+
+	// ==== Correcting and pinning ====
+	corrected, err := service.Memory.CorrectFact(request, employer.ID, &FactCorrection{Object: "Acme Robotics"})
+	require.Nil(t, err)
+	assert.Equal(t, "Acme Robotics", corrected.Object)
+	assert.Equal(t, "works at", corrected.Predicate)
+
+	pinned, err := service.Memory.PinFact(request, employer.ID, true)
+	require.Nil(t, err)
+	assert.True(t, pinned.Pinned)
+
+	stored, err := db.GetKnowledge(employer.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "Acme Robotics", stored.Object)
+	assert.True(t, stored.Pinned)
+
+	// Other users' facts can't be touched
+	var notFoundErr *NotFoundError
+	_, err = service.Memory.PinFact(request, other.ID, true)
+	assert.True(t, errors.As(err, &notFoundErr))
+	err = service.Memory.DeleteFact(request, other.ID)
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	// ==== Excluding a conversation ====
+	err = service.Memory.ExcludeConversation(&MemoryRequest{Agent: testAgent.ID, User: other.User}, conversation)
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	require.Nil(t, service.Memory.ExcludeConversation(request, conversation))
+
+	remembered, err = service.Memory.GetMemory(request)
+	require.Nil(t, err)
+	assert.Empty(t, remembered.Summaries)
+	assert.Empty(t, remembered.Knowledge)
+
+	stored, err = db.GetKnowledge(other.ID)
+	require.Nil(t, err)
+	assert.NotNil(t, stored)
+
+	// The messages themselves are kept, but won't be
+	// summarized again
+	kept, err := db.GetMessage(msg.ID)
+	require.Nil(t, err)
+	assert.NotNil(t, kept)
+
+	summaries, err := db.GetConversationsToSummarize(0, 0, 0)
+	require.Nil(t, err)
+	assert.NotContains(t, summaries, conversation)
+}
+
+func TestForgetCommand(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, db, err := createMockService(llm)
+	require.Nil(t, err)
+
+	conversation := uuid.New().String()
+	say := func(from string, content string) *chat.Message {
+		msg := &chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: conversation,
+			Agent:        testAgent.ID,
+			User:         testUser.ID,
+			From:         from,
+			Content:      content,
+			CreatedAt:    time.Now(),
+		}
+		require.Nil(t, db.SaveMessage(msg))
+		return msg
+	}
+	learn := func(predicate string, object string, source *chat.Message, pinned bool) *memory.Knowledge {
+		fact := &memory.Knowledge{
+			ID:           uuid.New().String(),
+			Agent:        testAgent.ID,
+			User:         testUser.ID,
+			Subject:      "Keith",
+			Predicate:    predicate,
+			Object:       object,
+			CreatedAt:    time.Now(),
+			ExpiresAt:    time.Now().Add(time.Hour),
+			Conversation: conversation,
+			Messages:     []string{source.ID},
+			Pinned:       pinned,
+		}
+		require.Nil(t, db.SaveKnowledge(fact))
+		return fact
+	}
+
+	work := say(testUser.ID, "I work at Acme")
+	say(testAgent.ID, "Neat!")
+	hobby := say(testUser.ID, "I like robots")
+	reply := say(testAgent.ID, "Me too!")
+
+	employer := learn("works at", "Acme", work, false)
+	pinned := learn("interned at", "Acme", work, true)
+	likes := learn("likes", "robots", hobby, false)
+	alsoLikes := learn("likes", "chatting", reply, false)
+
+	forget := func(content string) *chat.Message {
+		response, err := service.SendMessage(&chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: conversation,
+			Agent:        testAgent.ID,
+			User:         testUser.ID,
+			From:         testUser.ID,
+			Content:      content,
+			CreatedAt:    time.Now(),
+		})
+		require.Nil(t, err)
+		require.NotNil(t, response)
+		return response
+	}
+
+	// "Forget what I just said" forgets what was learned from
+	// the latest exchange, without asking the LLM
+	response := forget("Please forget what I just said.")
+	assert.Equal(t, "Okay, I've forgotten that Keith likes robots; Keith likes chatting.", response.Content)
+	assert.Equal(t, testAgent.ID, response.From)
+	sentAgent, _, _, _, _, _, _ := llm.GetSendMessageInputs()
+	assert.Nil(t, sentAgent)
+
+	for _, fact := range []*memory.Knowledge{likes, alsoLikes} {
+		stored, err := db.GetKnowledge(fact.ID)
+		require.Nil(t, err)
+		assert.Nil(t, stored)
+	}
+
+	// Nothing is left to forget from the latest exchange
+	response = forget("forget everything I just said")
+	assert.Equal(t, "I don't remember anything about that.", response.Content)
+
+	// A particular fact can be named, though pinned facts
+	// are kept
+	response = forget("Can you forget about Acme?")
+	assert.Equal(t, "Okay, I've forgotten that Keith works at Acme.", response.Content)
+	stored, err := db.GetKnowledge(employer.ID)
+	require.Nil(t, err)
+	assert.Nil(t, stored)
+	stored, err = db.GetKnowledge(pinned.ID)
+	require.Nil(t, err)
+	assert.NotNil(t, stored)
+
+	// Anything else goes to the LLM as usual
+	llm.AddSendMessageResponse(&chat.Message{
+		ID:        uuid.New().String(),
+		Agent:     testAgent.ID,
+		From:      testAgent.ID,
+		Content:   "No worries.",
+		CreatedAt: time.Now(),
+	}, nil)
+	response = forget("Forget it, never mind")
+	assert.Equal(t, "No worries.", response.Content)
+	sentAgent, _, _, _, _, _, _ = llm.GetSendMessageInputs()
+	assert.NotNil(t, sentAgent)
+
+	// The requests and replies are kept in the conversation
+	messages, err := db.ListMessages(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{Attribute: "conversation", Value: conversation, Operation: store.EQ},
+		},
+	})
+	require.Nil(t, err)
+	assert.Len(t, messages, 12)
+}
@@ -18,8 +18,29 @@ func NewMessageService(db store.Store) *MessageService {
 	}
 }
 
-func (service *MessageService) GetMessage(id string) (*chat.Message, error) {
-	return service.db.GetMessage(id)
+/*
+GetMessage returns a message on behalf of an actor, who must
+be the user it was exchanged with or an admin of its agent's
+organization.
+*/
+func (service *MessageService) GetMessage(actorId string, id string) (*chat.Message, error) {
+	return service.ownedMessage(actorId, id, "view")
+}
+
+// ownedMessage loads a message the actor may act on
+func (service *MessageService) ownedMessage(actorId string, id string, action string) (*chat.Message, error) {
+	msg, err := service.db.GetMessage(id)
+	if err != nil {
+		return nil, err
+	} else if msg == nil {
+		return nil, &NotFoundError{Kind: "message", ID: id}
+	}
+
+	err = authorizeOwner(service.db, actorId, msg.Agent, msg.User, action, "message", id)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
 type GetMessagesRequest struct {
@@ -86,6 +107,10 @@ func (service *MessageService) GetMessages(request *GetMessagesRequest) ([]*chat
 	if err != nil {
 		return nil, err
 	}
+	err = authorizeChatWith(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
 
 	return service.db.ListMessages(store.Filter{
 		Attributes: filters,
@@ -97,12 +122,39 @@ func (service *MessageService) GetMessages(request *GetMessagesRequest) ([]*chat
 	})
 }
 
-func (service *MessageService) DeleteMessage(id string) error {
+// DeleteMessage deletes a message the actor may act on
+func (service *MessageService) DeleteMessage(actorId string, id string) error {
+	_, err := service.ownedMessage(actorId, id, "delete")
+	if err != nil {
+		return err
+	}
+
 	return service.db.DeleteMessage(id)
 }
 
-func (service *MessageService) GetConversation(conversationId string) (*chat.Conversation, error) {
-	return service.db.GetConversation(conversationId)
+/*
+GetConversation returns a conversation on behalf of an actor,
+who must be the user who had it or an admin of its agent's
+organization.
+*/
+func (service *MessageService) GetConversation(actorId string, conversationId string) (*chat.Conversation, error) {
+	return service.ownedConversation(actorId, conversationId, "view")
+}
+
+// ownedConversation loads a conversation the actor may act on
+func (service *MessageService) ownedConversation(actorId string, id string, action string) (*chat.Conversation, error) {
+	conversation, err := service.db.GetConversation(id)
+	if err != nil {
+		return nil, err
+	} else if conversation == nil {
+		return nil, &NotFoundError{Kind: "conversation", ID: id}
+	}
+
+	err = authorizeOwner(service.db, actorId, conversation.Agent, conversation.User, action, "conversation", id)
+	if err != nil {
+		return nil, err
+	}
+	return conversation, nil
 }
 
 type GetConversationsRequest struct {
@@ -169,6 +221,10 @@ func (service *MessageService) GetConversations(request *GetConversationsRequest
 	if err != nil {
 		return nil, err
 	}
+	err = authorizeChatWith(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
 
 	return service.db.ListConversations(store.Filter{
 		Attributes: filters,
@@ -180,6 +236,24 @@ func (service *MessageService) GetConversations(request *GetConversationsRequest
 	})
 }
 
-func (service *MessageService) DeleteConversation(id string) error {
+/*
+DeleteConversation deletes a conversation's messages on
+behalf of an actor, who must be the user who had it or an
+admin of its agent's organization. If forgetKnowledge is
+set, the facts learned from it are deleted as well.
+*/
+func (service *MessageService) DeleteConversation(actorId string, id string, forgetKnowledge bool) error {
+	_, err := service.ownedConversation(actorId, id, "delete")
+	if err != nil {
+		return err
+	}
+
+	if forgetKnowledge {
+		err := service.db.DeleteConversationKnowledge(id)
+		if err != nil {
+			return err
+		}
+	}
+
 	return service.db.DeleteConversation(id)
 }
@@ -1,12 +1,15 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/llm/mock"
 	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/users"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -172,3 +175,56 @@ func TestGetMessages(t *testing.T) {
 
 	assert.True(t, msg1.Equal(messages[0]))
 }
+
+func TestMessageOwnership(t *testing.T) {
+	service, store, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "admin", Name: "Admin", CreatedAt: time.Now(), Role: users.RoleAdmin}, "supersecret"))
+	require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, &tenants.Organization{ID: "initech", Name: "Initech"}))
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "carol", Name: "Carol", CreatedAt: time.Now(), Tenant: "initech", Role: users.RoleAdmin}, "supersecret"))
+
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		User:         testUser.ID,
+		Agent:        testAgent.ID,
+		From:         testUser.ID,
+		Content:      "This is the song that never ends",
+		CreatedAt:    time.Now(),
+	}
+	require.Nil(t, store.SaveMessage(msg))
+
+	// The user may see their own messages and conversations...
+	found, err := service.Messages.GetMessage(testUser.ID, msg.ID)
+	require.Nil(t, err)
+	assert.True(t, msg.Equal(found))
+
+	conversation, err := service.Messages.GetConversation(testUser.ID, msg.Conversation)
+	require.Nil(t, err)
+	require.Len(t, conversation.Messages, 1)
+
+	// ...as may admins of the agent's organization...
+	_, err = service.Messages.GetConversation("admin", msg.Conversation)
+	require.Nil(t, err)
+
+	// ...but no other user, and other organizations aren't
+	// told they exist
+	var permission *PermissionError
+	_, err = service.Messages.GetMessage("abby", msg.ID)
+	assert.True(t, errors.As(err, &permission))
+	err = service.Messages.DeleteConversation("abby", msg.Conversation, true)
+	assert.True(t, errors.As(err, &permission))
+
+	var notFound *NotFoundError
+	_, err = service.Messages.GetConversation("carol", msg.Conversation)
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "conversation", notFound.Kind)
+	err = service.Messages.DeleteMessage("carol", msg.ID)
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "message", notFound.Kind)
+
+	require.Nil(t, service.Messages.DeleteMessage("admin", msg.ID))
+	_, err = service.Messages.GetMessage(testUser.ID, msg.ID)
+	assert.True(t, errors.As(err, &notFound))
+}
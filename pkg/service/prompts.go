@@ -0,0 +1,162 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/users"
+)
+
+/*
+PromptService manages the instruction prompt templates given
+to the LLM. A template is resolved for an agent from, in
+order; the agent's own prompts for this call, such as those
+of an experiment's variant, its stored override, its
+override in the templates directory, the stored shared
+template, the directory's shared template, and lastly the
+bundled template.
+*/
+type PromptService struct {
+	db store.Store
+
+	lock      sync.RWMutex
+	directory *prompts.Templates
+}
+
+func NewPromptService(db store.Store) *PromptService {
+	return &PromptService{
+		db: db,
+	}
+}
+
+// SetDirectory sets the templates loaded from a directory
+func (service *PromptService) SetDirectory(templates *prompts.Templates) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+	service.directory = templates
+}
+
+/*
+Template returns the template of the given name to use for
+an agent, as resolved from its overrides and the shared
+templates.
+*/
+func (service *PromptService) Template(agent *agents.Agent, name string) (*prompts.Template, error) {
+	if agent != nil && agent.Prompts[name] != "" {
+		return prompts.ParseTemplate(name, agent.Prompts[name])
+	}
+
+	layers := []string{""}
+	if agent != nil && agent.ID != "" {
+		layers = []string{agent.ID, ""}
+	}
+
+	for _, agentId := range layers {
+		stored, err := service.db.GetPromptTemplate(agentId, name)
+		if err != nil {
+			return nil, err
+		} else if stored != nil {
+			return prompts.ParseTemplate(name, stored.Template)
+		}
+
+		if tmpl := service.fromDirectory(agentId, name); tmpl != nil {
+			return tmpl, nil
+		}
+	}
+
+	tmpl := prompts.DefaultTemplate(name)
+	if tmpl == nil {
+		return nil, fmt.Errorf("unknown prompt template %s", name)
+	}
+	return tmpl, nil
+}
+
+func (service *PromptService) fromDirectory(agentId string, name string) *prompts.Template {
+	service.lock.RLock()
+	defer service.lock.RUnlock()
+
+	if service.directory == nil {
+		return nil
+	} else if agentId == "" {
+		return service.directory.Shared[name]
+	}
+	return service.directory.Agents[agentId][name]
+}
+
+/*
+SaveTemplate validates and stores a template, overriding the
+shared template of its name for its agent, or for every
+agent if it has none. Only an agent's editors may override
+its templates, and only admins may change shared ones.
+*/
+func (service *PromptService) SaveTemplate(actorId string, prompt *agents.PromptTemplate) error {
+	_, err := prompts.ParseTemplate(prompt.Name, prompt.Template)
+	if err != nil {
+		return &InvalidRequestError{Err: fmt.Errorf("%s: %w", prompt.Name, err)}
+	}
+
+	err = service.authorize(actorId, prompt.Agent, "change the prompts of")
+	if err != nil {
+		return err
+	}
+
+	prompt.UpdatedAt = time.Now()
+	return service.db.SavePromptTemplate(prompt)
+}
+
+/*
+DeleteTemplate removes a stored template, so that the next
+in order is used in its place.
+*/
+func (service *PromptService) DeleteTemplate(actorId string, agentId string, name string) error {
+	err := service.authorize(actorId, agentId, "change the prompts of")
+	if err != nil {
+		return err
+	}
+
+	existing, err := service.db.GetPromptTemplate(agentId, name)
+	if err != nil {
+		return err
+	} else if existing == nil {
+		return &NotFoundError{Kind: "prompt template", ID: name}
+	}
+
+	return service.db.DeletePromptTemplate(agentId, name)
+}
+
+/*
+ListTemplates returns the templates stored for an agent, or
+the stored shared templates if agentId is empty.
+*/
+func (service *PromptService) ListTemplates(actorId string, agentId string) ([]*agents.PromptTemplate, error) {
+	err := service.authorize(actorId, agentId, "view the prompts of")
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.ListPromptTemplates(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "agent",
+				Value:     agentId,
+				Operation: store.EQ,
+			},
+		},
+	})
+}
+
+// authorize checks that the actor may manage the agent's
+// templates, or the shared ones if agentId is empty
+func (service *PromptService) authorize(actorId string, agentId string, action string) error {
+	if agentId != "" {
+		_, err := authorizeManage(service.db, actorId, agentId, action)
+		return err
+	}
+
+	_, err := authorizeRole(service.db, actorId, "", action, "every agent", users.RoleAdmin)
+	return err
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptTemplates(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, _, err := createMockService(llm)
+	require.Nil(t, err)
+
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "admin", Name: "Admin", CreatedAt: time.Now(), Role: users.RoleAdmin}, "supersecret"))
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "editor", Name: "Editor", CreatedAt: time.Now(), Role: users.RoleAgentEditor}, "supersecret"))
+	rose := &agents.Agent{ID: "rose", Name: "Rose", Identity: "You are Rose"}
+	require.Nil(t, service.Agents.CreateAgent("admin", rose))
+	winston := &agents.Agent{ID: "winston", Name: "Winston", Identity: "You are Winston"}
+	require.Nil(t, service.Agents.CreateAgent("admin", winston))
+
+	resolve := func(agent *agents.Agent, name string) string {
+		tmpl, err := service.Prompts.Template(agent, name)
+		require.Nil(t, err)
+		return tmpl.Text
+	}
+
+	// The bundled templates are used until overridden
+	assert.Equal(t, prompts.Instructions, resolve(rose, prompts.TemplateChat))
+	assert.Equal(t, prompts.Summary, resolve(rose, prompts.TemplateSummary))
+
+	// Templates accept placeholders in either form, and
+	// optional sections may be left out
+	chat := "{identity}\n{{if .knowledge}}Facts:\n{{.knowledge}}\n{{end}}{{.message_history}}{message}"
+	tmpl, err := prompts.ParseTemplate(prompts.TemplateChat, chat)
+	require.Nil(t, err)
+	filled, err := tmpl.Execute(map[string]string{"identity": "You are Rose", "message_history": "Keith | Hi\n", "message": "Keith | Hello"})
+	require.Nil(t, err)
+	assert.Equal(t, "You are Rose\nKeith | Hi\nKeith | Hello", filled)
+	filled, err = tmpl.Execute(map[string]string{"identity": "You are Rose", "knowledge": "Keith is a programmer", "message": "Keith | Hello"})
+	require.Nil(t, err)
+	assert.Equal(t, "You are Rose\nFacts:\nKeith is a programmer\nKeith | Hello", filled)
+
+	// ...and are checked for their placeholders as they load
+	for name, text := range map[string]string{
+		"missing":  "{{.identity}} {{.message}}",
+		"unknown":  "{identity} {message_history} {message} {{.mood}}",
+		"syntax":   "{{if .identity}} {message_history} {message}",
+		"template": "A template that doesn't exist",
+	} {
+		templateName := prompts.TemplateChat
+		if name == "template" {
+			templateName = "welcome"
+		}
+		_, err := prompts.ParseTemplate(templateName, text)
+		assert.NotNil(t, err, name)
+	}
+
+	// A directory's templates override the bundled ones,
+	// and its subdirectories override them per agent
+	dir, err := prompts.LoadTemplates(fstest.MapFS{
+		"summary.prompt":        {Data: []byte("Summarize from the directory")},
+		"rose/knowledge.prompt": {Data: []byte("Learn as Rose")},
+		"README.md":             {Data: []byte("Not a template")},
+	})
+	require.Nil(t, err)
+	service.Prompts.SetDirectory(dir)
+
+	assert.Equal(t, "Summarize from the directory", resolve(rose, prompts.TemplateSummary))
+	assert.Equal(t, "Learn as Rose", resolve(rose, prompts.TemplateKnowledge))
+	assert.Equal(t, prompts.Knowledge, resolve(winston, prompts.TemplateKnowledge))
+
+	_, err = prompts.LoadTemplates(fstest.MapFS{
+		"rose/chat.prompt": {Data: []byte("You are {name}")},
+	})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "rose/chat.prompt")
+
+	// Stored templates override the directory's, and an
+	// agent's own override the shared ones
+	require.Nil(t, service.Prompts.SaveTemplate("admin", &agents.PromptTemplate{Name: prompts.TemplateSummary, Template: "Summarize from the store"}))
+	require.Nil(t, service.Prompts.SaveTemplate("editor", &agents.PromptTemplate{Agent: rose.ID, Name: prompts.TemplateSummary, Template: "Summarize as Rose"}))
+	require.Nil(t, service.Prompts.SaveTemplate("editor", &agents.PromptTemplate{Agent: rose.ID, Name: prompts.TemplateChat, Template: chat}))
+
+	assert.Equal(t, "Summarize as Rose", resolve(rose, prompts.TemplateSummary))
+	assert.Equal(t, "Summarize from the store", resolve(winston, prompts.TemplateSummary))
+	assert.Equal(t, chat, resolve(rose, prompts.TemplateChat))
+	assert.Equal(t, prompts.Instructions, resolve(winston, prompts.TemplateChat))
+
+	stored, err := service.Prompts.ListTemplates("editor", rose.ID)
+	require.Nil(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, prompts.TemplateChat, stored[0].Name)
+
+	// Deleting an override falls back to the next in order
+	require.Nil(t, service.Prompts.DeleteTemplate("editor", rose.ID, prompts.TemplateSummary))
+	assert.Equal(t, "Summarize from the store", resolve(rose, prompts.TemplateSummary))
+	require.Nil(t, service.Prompts.DeleteTemplate("admin", "", prompts.TemplateSummary))
+	assert.Equal(t, "Summarize from the directory", resolve(rose, prompts.TemplateSummary))
+
+	var notFound *NotFoundError
+	err = service.Prompts.DeleteTemplate("admin", "", prompts.TemplateSummary)
+	require.True(t, errors.As(err, &notFound))
+
+	// Invalid templates are never stored
+	var invalid *InvalidRequestError
+	err = service.Prompts.SaveTemplate("editor", &agents.PromptTemplate{Agent: rose.ID, Name: prompts.TemplateContinuance, Template: "{message_history}"})
+	require.True(t, errors.As(err, &invalid))
+	assert.Contains(t, err.Error(), "missing placeholder new_message")
+
+	// Editors may override an agent's templates, but only
+	// admins may change those of every agent
+	var permission *PermissionError
+	err = service.Prompts.SaveTemplate("editor", &agents.PromptTemplate{Name: prompts.TemplateSummary, Template: "Summarize"})
+	require.True(t, errors.As(err, &permission))
+	err = service.Prompts.SaveTemplate(testUser.ID, &agents.PromptTemplate{Agent: rose.ID, Name: prompts.TemplateSummary, Template: "Summarize"})
+	require.True(t, errors.As(err, &permission))
+	_, err = service.Prompts.ListTemplates(testUser.ID, rose.ID)
+	require.True(t, errors.As(err, &permission))
+}
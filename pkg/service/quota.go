@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/usage"
+)
+
+// quotaRetention is how long quota counters are kept for
+const quotaRetention = 48 * time.Hour
+
+var quotaPeriods = []string{quota.PeriodMinute, quota.PeriodDay}
+
+type quotaScope struct {
+	key    string
+	limits quota.Limits
+}
+
+/*
+quotaScopes returns each scope that applies to a message
+between the given agent and user, skipping those without
+any limits set. Agents of an organization are also limited
+by its quota, and its users by its user quota unless they
+have limits of their own configured.
+*/
+func (service *Service) quotaScopes(agent string, user string) ([]quotaScope, error) {
+	userLimits := service.config.Quota.UserLimits(user)
+
+	scopes := []quotaScope{
+		{
+			key:    quota.Key(quota.ScopeGlobal, ""),
+			limits: service.config.Quota.Global,
+		},
+	}
+
+	org, err := service.agentOrganization(agent)
+	if err != nil {
+		return nil, err
+	} else if org != nil {
+		scopes = append(scopes, quotaScope{
+			key:    quota.Key(quota.ScopeTenant, org.ID),
+			limits: org.Settings.Quota,
+		})
+		if _, ok := service.config.Quota.Users[user]; !ok && !org.Settings.UserQuota.Unlimited() {
+			userLimits = org.Settings.UserQuota
+		}
+	}
+
+	scopes = append(
+		scopes,
+		quotaScope{
+			key:    quota.Key(quota.ScopeAgent, agent),
+			limits: service.config.Quota.AgentLimits(agent),
+		},
+		quotaScope{
+			key:    quota.Key(quota.ScopeUser, user),
+			limits: userLimits,
+		},
+	)
+
+	limited := []quotaScope{}
+	for _, scope := range scopes {
+		if !scope.limits.Unlimited() {
+			limited = append(limited, scope)
+		}
+	}
+	return limited, nil
+}
+
+// agentOrganization returns the organization an agent belongs
+// to, or nil for the default tenant
+func (service *Service) agentOrganization(agentId string) (*tenants.Organization, error) {
+	agent, err := service.db.GetAgent(agentId)
+	if err != nil || agent == nil || agent.Tenant == "" {
+		return nil, err
+	}
+	return service.db.GetOrganization(agent.Tenant)
+}
+
+/*
+consumeQuota counts a request against every quota that
+applies to the message. If any quota has been exceeded,
+the request is rolled back from the counters and a
+*quota.QuotaExceededError is returned.
+*/
+func (service *Service) consumeQuota(msg *chat.Message) error {
+	now := time.Now()
+
+	type increment struct {
+		key         string
+		period      string
+		periodStart time.Time
+	}
+	incremented := []increment{}
+
+	rollback := func() {
+		for _, inc := range incremented {
+			service.db.IncrementQuota(inc.key, inc.period, inc.periodStart, -1, 0)
+		}
+	}
+
+	scopes, err := service.quotaScopes(msg.Agent, msg.User)
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		for _, period := range quotaPeriods {
+			requestLimit := scope.limits.Requests(period)
+			tokenLimit := scope.limits.Tokens(period)
+			if requestLimit <= 0 && tokenLimit <= 0 {
+				continue
+			}
+
+			periodStart := quota.PeriodStart(period, now)
+
+			counter, err := service.db.IncrementQuota(scope.key, period, periodStart, 1, 0)
+			if err != nil {
+				rollback()
+				return err
+			}
+			incremented = append(incremented, increment{scope.key, period, periodStart})
+
+			var limit string
+			if requestLimit > 0 && counter.Requests > requestLimit {
+				limit = fmt.Sprintf("%d requests", requestLimit)
+			} else if tokenLimit > 0 && counter.Tokens >= tokenLimit {
+				limit = fmt.Sprintf("%d tokens", tokenLimit)
+			}
+
+			if limit != "" {
+				rollback()
+				return &quota.QuotaExceededError{
+					Scope:      scope.key,
+					Period:     period,
+					Limit:      limit,
+					RetryAfter: quota.PeriodEnd(period, periodStart),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+consumeQuotaTokens counts the tokens of a usage record
+against every quota that applies to it.
+*/
+func (service *Service) consumeQuotaTokens(record *usage.Usage) error {
+	scopes, err := service.quotaScopes(record.Agent, record.User)
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range scopes {
+		for _, period := range quotaPeriods {
+			if scope.limits.Tokens(period) <= 0 {
+				continue
+			}
+			_, err := service.db.IncrementQuota(
+				scope.key,
+				period,
+				quota.PeriodStart(period, record.CreatedAt),
+				0,
+				record.TotalTokens(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+QuotaDaemon clears out quota counters that are old enough
+to no longer matter to any period.
+*/
+func (service *Service) QuotaDaemon() error {
+	return service.db.ExpireQuotas(time.Now().Add(-1 * quotaRetention))
+}
+
+/*
+usageRecorder is handed to LLMs that track their usage. It
+saves each usage record and counts its tokens towards any
+applicable quotas.
+*/
+type usageRecorder struct {
+	service *Service
+}
+
+func (recorder *usageRecorder) SaveUsage(record *usage.Usage) error {
+	err := recorder.service.db.SaveUsage(record)
+	if err != nil {
+		return err
+	}
+	return recorder.service.consumeQuotaTokens(record)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotas(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+	require.NotNil(t, service)
+	require.NotNil(t, store)
+
+	newMessage := func(user string) *chat.Message {
+		return &chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: uuid.New().String(),
+			Agent:        testAgent.ID,
+			User:         user,
+			From:         user,
+			Content:      "Are we there yet?",
+			CreatedAt:    time.Now(),
+		}
+	}
+	newResponse := func() *chat.Message {
+		return &chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     testAgent.ID,
+			From:      testAgent.ID,
+			Content:   "No. And if you ask again I'm turning this car around.",
+			CreatedAt: time.Now(),
+		}
+	}
+	response := newResponse()
+
+	// ==== Request limits per user ====
+	service.config.Quota = config.QuotaConfig{
+		Users: map[string]quota.Limits{
+			testUser.ID: {RequestsPerMinute: 2},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		llm.AddSendMessageResponse(newResponse(), nil)
+		_, err = service.SendMessage(newMessage(testUser.ID))
+		require.Nil(t, err)
+	}
+
+	// The third should fail before the LLM is ever called
+	llm.ClearMemory()
+	llm.AddSendMessageResponse(newResponse(), nil)
+	msg, err := service.SendMessage(newMessage(testUser.ID))
+	require.NotNil(t, err)
+	assert.Nil(t, msg)
+
+	var quotaErr *quota.QuotaExceededError
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, quota.Key(quota.ScopeUser, testUser.ID), quotaErr.Scope)
+	assert.Equal(t, quota.PeriodMinute, quotaErr.Period)
+	assert.True(t, quotaErr.RetryAfter.After(time.Now()))
+
+	agent, _, _, _, _, _, _ := llm.GetSendMessageInputs()
+	assert.Nil(t, agent)
+
+	// Rejected requests are not counted against the quota
+	counter, err := store.GetQuota(
+		quota.Key(quota.ScopeUser, testUser.ID),
+		quota.PeriodMinute,
+		quota.PeriodStart(quota.PeriodMinute, time.Now()),
+	)
+	require.Nil(t, err)
+	assert.Equal(t, 2, counter.Requests)
+
+	// Another user is unaffected
+	_, err = service.SendMessage(newMessage(uuid.New().String()))
+	require.Nil(t, err)
+
+	// ==== Token limits per agent ====
+	llm.ClearMemory()
+	service.config.Quota = config.QuotaConfig{
+		DefaultAgent: quota.Limits{TokensPerDay: llm.EstimateTokens(response.Content)},
+	}
+
+	// The first message consumes the agent's tokens for the
+	// day, so the second is rejected
+	llm.AddSendMessageResponse(newResponse(), nil)
+	_, err = service.SendMessage(newMessage(testUser.ID))
+	require.Nil(t, err)
+
+	llm.AddSendMessageResponse(newResponse(), nil)
+	_, err = service.SendMessage(newMessage(testUser.ID))
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, quota.Key(quota.ScopeAgent, testAgent.ID), quotaErr.Scope)
+	assert.Equal(t, quota.PeriodDay, quotaErr.Period)
+
+	// ==== Global limits ====
+	llm.ClearMemory()
+	service.config.Quota = config.QuotaConfig{
+		Global: quota.Limits{RequestsPerDay: 1},
+	}
+
+	llm.AddSendMessageResponse(newResponse(), nil)
+	_, err = service.SendMessage(newMessage(uuid.New().String()))
+	require.Nil(t, err)
+
+	_, err = service.SendMessage(newMessage(uuid.New().String()))
+	require.True(t, errors.As(err, &quotaErr))
+	assert.Equal(t, quota.ScopeGlobal, quotaErr.Scope)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/hlfshell/coppermind/internal/llm"
 	"github.com/hlfshell/coppermind/internal/store"
 	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/tools"
 )
 
 type Service struct {
@@ -13,35 +14,97 @@ type Service struct {
 	llm    llm.LLM
 	config config.Config
 
+	// Tools available for agents to enable
+	Tools *tools.Registry
+
 	// Services
-	Messages *MessageService
-	Summary  *SummaryService
-	Agents   *AgentService
-	Users    *UserService
+	Messages    *MessageService
+	Summary     *SummaryService
+	Agents      *AgentService
+	Users       *UserService
+	Usage       *UsageService
+	Artifacts   *ArtifactService
+	Documents   *DocumentService
+	Knowledge   *KnowledgeService
+	Memory      *MemoryService
+	Tenants     *TenantService
+	Prompts     *PromptService
+	Experiments *ExperimentService
+	Feedback    *FeedbackService
+
+	// LLMs of organizations using their own provider keys
+	tenantModels *tenantModels
 
 	// Daemon services
 	summarizationTicker *time.Ticker
 	knowledgeTicker     *time.Ticker
+	quotaTicker         *time.Ticker
 }
 
-func NewService(db store.Store, llm llm.LLM, config *config.Config) *Service {
-	service := &Service{
+func NewService(db store.Store, model llm.LLM, config *config.Config) *Service {
+	// Documents are embedded by the LLM of their agent's
+	// organization, so need the service to resolve it
+	service := &Service{}
+	documents := NewDocumentService(db, service.modelFor, config.Documents)
+
+	*service = Service{
 		db:     db,
-		llm:    llm,
+		llm:    model,
 		config: *config,
 
-		Messages: NewMessageService(db),
-		Summary:  NewSummaryService(db),
-		Agents:   NewAgentService(db),
-		Users:    NewUserService(db),
+		Tools: defaultTools(db),
+
+		Messages:    NewMessageService(db),
+		Summary:     NewSummaryService(db),
+		Agents:      NewAgentService(db),
+		Users:       NewUserService(db),
+		Usage:       NewUsageService(db, config.Usage.Prices),
+		Artifacts:   NewArtifactService(db, documents),
+		Documents:   documents,
+		Knowledge:   NewKnowledgeService(db),
+		Memory:      NewMemoryService(db),
+		Tenants:     NewTenantService(db),
+		Prompts:     NewPromptService(db),
+		Experiments: NewExperimentService(db),
+		Feedback:    NewFeedbackService(db),
+
+		tenantModels: &tenantModels{models: map[string]tenantModel{}},
 
 		summarizationTicker: time.NewTicker(time.Duration(config.Summary.SummaryDaemonIntervalSeconds) * time.Second),
-		knowledgeTicker:     time.NewTicker(60 * time.Second),
+		quotaTicker:         time.NewTicker(time.Hour),
+	}
+
+	// Knowledge maintenance is off unless an interval is set
+	if config.Knowledge.MaintenanceIntervalSeconds > 0 {
+		service.knowledgeTicker = time.NewTicker(time.Duration(config.Knowledge.MaintenanceIntervalSeconds) * time.Second)
 	}
 
+	service.trackLLM(model)
+
 	return service
 }
 
+/*
+trackLLM has an LLM record its token usage to the store, to
+be counted against quotas, if it can report it, record
+which backend served each call if it routes across backends,
+and fill the prompt templates resolved for each agent if its
+prompts are templated
+*/
+func (service *Service) trackLLM(model llm.LLM) {
+	if tracker, ok := model.(llm.UsageTracker); ok {
+		tracker.SetUsageRecorder(&usageRecorder{service: service})
+	}
+
+	if tracker, ok := model.(llm.RouteTracker); ok {
+		tracker.SetRouteRecorder(service.db)
+	}
+
+	if user, ok := model.(llm.TemplateUser); ok {
+		user.SetTemplateSource(service.Prompts)
+	}
+}
+
 func NewServiceFromConfig(config *config.Config) (*Service, error) {
 	db, err := NewStoreFromConfig(config)
 	if err != nil {
@@ -65,4 +128,51 @@ func (service *Service) LaunchDaemons() {
 			}
 		}()
 	}
+
+	if service.knowledgeTicker != nil {
+		go func() {
+			for {
+				<-service.knowledgeTicker.C
+				service.KnowledgeDaemon()
+			}
+		}()
+	}
+
+	go func() {
+		for {
+			<-service.quotaTicker.C
+			service.QuotaDaemon()
+		}
+	}()
+
+	// Snapshots are off by default, and only taken of stores
+	// that support them
+	if _, ok := service.db.(store.Snapshotter); ok && service.config.Backup.IntervalSeconds > 0 {
+		backupTicker := time.NewTicker(time.Duration(service.config.Backup.IntervalSeconds) * time.Second)
+		go func() {
+			for {
+				<-backupTicker.C
+				service.BackupDaemon()
+			}
+		}()
+	}
+}
+
+/*
+defaultTools is the registry of built in tools, which
+agents may enable by name.
+*/
+func defaultTools(db store.Store) *tools.Registry {
+	registry := tools.NewRegistry()
+	for _, tool := range []tools.Tool{
+		tools.NewCurrentTimeTool(),
+		tools.NewCalculatorTool(),
+		tools.NewMemoryLookupTool(db),
+		tools.NewRecallConversationTool(db),
+		tools.NewKnowledgeLookupTool(db),
+	} {
+		// Names are fixed, so registration can't fail
+		registry.Register(tool)
+	}
+	return registry
 }
@@ -25,6 +25,16 @@ var testUser users.User = users.User{
 	UpdatedAt: time.Now(),
 }
 
+// testOperator is an admin of the default tenant, who may
+// manage organizations
+var testOperator users.User = users.User{
+	ID:        uuid.New().String(),
+	Name:      "Operator",
+	Role:      users.RoleAdmin,
+	CreatedAt: time.Now(),
+	UpdatedAt: time.Now(),
+}
+
 func createMockService(llm llm.LLM) (*Service, store.Store, error) {
 	// Create a new sqlite store in-memory for tests
 	store, err := sqlite.NewSqliteStore(":memory:")
@@ -44,6 +54,10 @@ func createMockService(llm llm.LLM) (*Service, store.Store, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	err = store.CreateUser(&testOperator, "super duper secret shhh")
+	if err != nil {
+		return nil, nil, err
+	}
 
 	return NewService(store, llm, &config.DefaultConfig), store, nil
 }
@@ -38,6 +38,14 @@ func (service *Service) Summarize(conversationId string) (*memory.Summary, error
 		return nil, fmt.Errorf("conversation %s not found", conversationId)
 	}
 
+	// Get the agent so its summary settings are respected
+	agent, err := service.db.GetAgent(conversation.Agent)
+	if err != nil {
+		return nil, err
+	} else if agent == nil {
+		return nil, fmt.Errorf("agent %s not found", conversation.Agent)
+	}
+
 	//Determine if a summary already exists for this conversation
 	var existingSummary *memory.Summary
 	summaries, err := service.db.ListSummaries(store.Filter{
@@ -57,7 +65,11 @@ func (service *Service) Summarize(conversationId string) (*memory.Summary, error
 	}
 
 	// Ask the llm to generate the summaries
-	summary, err := service.llm.Summarize(conversation, existingSummary)
+	model, err := service.modelFor(agent.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := model.Summarize(agent, conversation, existingSummary)
 	if err != nil {
 		return nil, err
 	} else if summary == nil {
@@ -83,8 +95,29 @@ func NewSummaryService(db store.Store) *SummaryService {
 	}
 }
 
-func (service *SummaryService) GetSummary(id string) (*memory.Summary, error) {
-	return service.db.GetSummary(id)
+/*
+GetSummary returns a summary on behalf of an actor, who must
+be the user whose conversation it summarizes or an admin of
+its agent's organization.
+*/
+func (service *SummaryService) GetSummary(actorId string, id string) (*memory.Summary, error) {
+	return service.ownedSummary(actorId, id, "view")
+}
+
+// ownedSummary loads a summary the actor may act on
+func (service *SummaryService) ownedSummary(actorId string, id string, action string) (*memory.Summary, error) {
+	summary, err := service.db.GetSummary(id)
+	if err != nil {
+		return nil, err
+	} else if summary == nil {
+		return nil, &NotFoundError{Kind: "summary", ID: id}
+	}
+
+	err = authorizeOwner(service.db, actorId, summary.Agent, summary.User, action, "summary", id)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
 }
 
 type GetSummariesRequest struct {
@@ -149,6 +182,10 @@ func (service *SummaryService) GetSummaries(request *GetSummariesRequest) ([]*me
 	if err != nil {
 		return nil, err
 	}
+	err = authorizeChatWith(service.db, request.Agent, request.User)
+	if err != nil {
+		return nil, err
+	}
 
 	return service.db.ListSummaries(store.Filter{
 		Attributes: filters,
@@ -160,6 +197,12 @@ func (service *SummaryService) GetSummaries(request *GetSummariesRequest) ([]*me
 	})
 }
 
-func (service *SummaryService) DeleteSummary(id string) error {
+// DeleteSummary deletes a summary the actor may act on
+func (service *SummaryService) DeleteSummary(actorId string, id string) error {
+	_, err := service.ownedSummary(actorId, id, "delete")
+	if err != nil {
+		return err
+	}
+
 	return service.db.DeleteSummary(id)
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -76,7 +77,9 @@ func TestSummarize(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, summary)
 	assert.True(t, returnedSummary.Equal(summary))
-	conversation, existingSummary := llm.GetSummarizeInputs()
+	agent, conversation, existingSummary := llm.GetSummarizeInputs()
+	require.NotNil(t, agent)
+	assert.Equal(t, testAgent.ID, agent.ID)
 	require.NotNil(t, conversation)
 	assert.Nil(t, existingSummary)
 
@@ -138,7 +141,7 @@ func TestSummarize(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, summary)
 	assert.True(t, returnedSummary.Equal(summary))
-	conversation, existingSummary = llm.GetSummarizeInputs()
+	_, conversation, existingSummary = llm.GetSummarizeInputs()
 	require.NotNil(t, conversation)
 	require.NotNil(t, existingSummary)
 	assert.Equal(t, msg1.Conversation, conversation.ID)
@@ -282,4 +285,21 @@ func TestGetSummaries(t *testing.T) {
 	require.Len(t, summaries, 1)
 
 	assert.True(t, summary1.Equal(summaries[0]))
+
+	// A summary is only for the user whose conversation it
+	// summarizes
+	found, err := service.Summary.GetSummary(testUser.ID, summary1.ID)
+	require.Nil(t, err)
+	assert.True(t, summary1.Equal(found))
+
+	var permission *PermissionError
+	_, err = service.Summary.GetSummary(summary3.User, summary1.ID)
+	assert.True(t, errors.As(err, &permission))
+	err = service.Summary.DeleteSummary(summary3.User, summary1.ID)
+	assert.True(t, errors.As(err, &permission))
+
+	require.Nil(t, service.Summary.DeleteSummary(testUser.ID, summary1.ID))
+	var notFound *NotFoundError
+	_, err = service.Summary.GetSummary(testUser.ID, summary1.ID)
+	assert.True(t, errors.As(err, &notFound))
 }
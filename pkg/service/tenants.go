@@ -0,0 +1,327 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm"
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/users"
+)
+
+/*
+TenantService manages organizations - the tenants that own
+agents, users, and everything their agents remember.
+
+Tenancy is enforced here in the service rather than in the
+store. Everything an agent keeps is stored against the agent
+and the user it is about, and each belongs to exactly one
+organization, so every read checks the actor against the
+agent's or user's organization before asking the store -
+see checkTenancy, authorizeOwner, and authorizeRole. The
+tenant columns of the store are a denormalized copy, kept
+for reports across an organization such as usage, which do
+filter on them.
+*/
+type TenantService struct {
+	db store.Store
+}
+
+func NewTenantService(db store.Store) *TenantService {
+	return &TenantService{
+		db: db,
+	}
+}
+
+/*
+CreateOrganization saves a new organization on behalf of an
+actor, who must be an admin of the default tenant - the
+operator of the server - generating its ID if one isn't set.
+*/
+func (service *TenantService) CreateOrganization(actorId string, org *tenants.Organization) error {
+	_, err := authorizeRole(service.db, actorId, "", "create", "organization "+org.ID, users.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if org.Name == "" {
+		return &InvalidRequestError{Err: fmt.Errorf("name must be set")}
+	}
+
+	if org.ID == "" {
+		org.ID = uuid.New().String()
+	} else {
+		existing, err := service.db.GetOrganization(org.ID)
+		if err != nil {
+			return err
+		} else if existing != nil {
+			return &InvalidRequestError{Err: fmt.Errorf("organization %s already exists", org.ID)}
+		}
+	}
+
+	return service.db.SaveOrganization(org)
+}
+
+/*
+GetOrganization returns an organization to an actor within
+it, or the server's operator
+*/
+func (service *TenantService) GetOrganization(actorId string, id string) (*tenants.Organization, error) {
+	_, err := authorizeMembership(service.db, actorId, id, "view")
+	if err != nil {
+		return nil, err
+	}
+
+	return service.getOrganization(id)
+}
+
+func (service *TenantService) getOrganization(id string) (*tenants.Organization, error) {
+	org, err := service.db.GetOrganization(id)
+	if err != nil {
+		return nil, err
+	} else if org == nil {
+		return nil, &NotFoundError{Kind: "organization", ID: id}
+	}
+	return org, nil
+}
+
+func (service *TenantService) ListOrganizations() ([]*tenants.Organization, error) {
+	return service.db.ListOrganizations()
+}
+
+/*
+UpdateOrganization saves a change to an existing
+organization's name or settings on behalf of one of its
+admins or the server's operator. LLM keys are merged into
+those it already has, rather than replacing them.
+*/
+func (service *TenantService) UpdateOrganization(actorId string, org *tenants.Organization) error {
+	err := authorizeOrganization(service.db, actorId, org.ID, "update")
+	if err != nil {
+		return err
+	}
+
+	existing, err := service.getOrganization(org.ID)
+	if err != nil {
+		return err
+	}
+	if org.Name == "" {
+		org.Name = existing.Name
+	}
+	org.CreatedAt = existing.CreatedAt
+	org.Settings.LLMKeys = org.Settings.MergeLLMKeys(existing.Settings.LLMKeys)
+
+	return service.db.SaveOrganization(org)
+}
+
+/*
+DeleteOrganization deletes an organization on behalf of one
+of its admins or the server's operator, once it no longer
+owns any agents or users, so that none are left in a tenant
+that doesn't exist.
+*/
+func (service *TenantService) DeleteOrganization(actorId string, id string) error {
+	err := authorizeOrganization(service.db, actorId, id, "delete")
+	if err != nil {
+		return err
+	}
+
+	_, err = service.getOrganization(id)
+	if err != nil {
+		return err
+	}
+
+	agents, err := listTenantAgents(service.db, id)
+	if err != nil {
+		return err
+	} else if len(agents) > 0 {
+		return &InvalidRequestError{Err: fmt.Errorf("organization %s still has %d agents", id, len(agents))}
+	}
+
+	users, err := service.db.ListUsers()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Tenant == id {
+			return &InvalidRequestError{Err: fmt.Errorf("organization %s still has users", id)}
+		}
+	}
+
+	return service.db.DeleteOrganization(id)
+}
+
+/*
+authorizeOrganization ensures that an actor may change an
+organization - that they are an admin of it, or an admin of
+the default tenant, who operates the server.
+*/
+func authorizeOrganization(db store.Store, actorId string, id string, action string) error {
+	_, err := authorizeRole(db, actorId, id, action, "organization "+id, users.RoleAdmin)
+	if _, ok := err.(*PermissionError); ok && id != "" {
+		_, err = authorizeRole(db, actorId, "", action, "organization "+id, users.RoleAdmin)
+	}
+	return err
+}
+
+/*
+authorizeMembership ensures that an actor may see into an
+organization - that they are within it, whatever their
+role, or are the server's operator - returning the actor.
+*/
+func authorizeMembership(db store.Store, actorId string, id string, action string) (*users.User, error) {
+	actor, err := db.GetUser(actorId)
+	if err != nil {
+		return nil, err
+	} else if actor != nil && actor.Tenant == id {
+		return actor, nil
+	}
+
+	return authorizeRole(db, actorId, "", action, "organization "+id, users.RoleAdmin)
+}
+
+// listTenantAgents lists the agents belonging to a tenant
+func listTenantAgents(db store.Store, tenant string) ([]*agents.Agent, error) {
+	all, err := db.ListAgents()
+	if err != nil {
+		return nil, err
+	}
+
+	found := []*agents.Agent{}
+	for _, agent := range all {
+		if agent.Tenant == tenant {
+			found = append(found, agent)
+		}
+	}
+	return found, nil
+}
+
+// checkOrganization ensures that a tenant being assigned exists
+func checkOrganization(db store.Store, tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+
+	org, err := db.GetOrganization(tenant)
+	if err != nil {
+		return err
+	} else if org == nil {
+		return &NotFoundError{Kind: "organization", ID: tenant}
+	}
+	return nil
+}
+
+/*
+checkTenancy ensures that a user may reach an agent - that
+both belong to the same organization. Agents and users that
+don't exist belong to the default tenant. An agent outside
+the user's organization is reported as not found, so that
+one tenant can't discover another's agents.
+*/
+func checkTenancy(db store.Store, agentId string, userId string) error {
+	agent, err := db.GetAgent(agentId)
+	if err != nil {
+		return err
+	}
+
+	tenant := ""
+	if agent != nil {
+		tenant = agent.Tenant
+	}
+
+	return checkUserTenancy(db, agentId, tenant, userId)
+}
+
+// checkUserTenancy is checkTenancy for an agent already loaded
+func checkUserTenancy(db store.Store, agentId string, tenant string, userId string) error {
+	user, err := db.GetUser(userId)
+	if err != nil {
+		return err
+	}
+
+	userTenant := ""
+	if user != nil {
+		userTenant = user.Tenant
+	}
+
+	if userTenant != tenant {
+		return &NotFoundError{Kind: "agent", ID: agentId}
+	}
+	return nil
+}
+
+/*
+tenantModels caches the LLM built for each organization
+with its own provider keys, along with the keys it was
+built with so that it is rebuilt when they change.
+*/
+type tenantModels struct {
+	lock   sync.Mutex
+	models map[string]tenantModel
+}
+
+type tenantModel struct {
+	keys  string
+	model llm.LLM
+}
+
+/*
+modelFor returns the LLM to call on behalf of a tenant's
+agents. Organizations that set their own provider keys get
+an LLM of their own, routed the same way as the server's;
+every other tenant shares the server's.
+*/
+func (service *Service) modelFor(tenant string) (llm.LLM, error) {
+	if tenant == "" {
+		return service.llm, nil
+	}
+
+	org, err := service.db.GetOrganization(tenant)
+	if err != nil {
+		return nil, err
+	} else if org == nil || len(org.Settings.LLMKeys) == 0 {
+		return service.llm, nil
+	}
+
+	// Maps are printed in key order, so this identifies the
+	// set of keys
+	keys := fmt.Sprint(org.Settings.LLMKeys)
+
+	service.tenantModels.lock.Lock()
+	defer service.tenantModels.lock.Unlock()
+
+	if cached, ok := service.tenantModels.models[tenant]; ok && cached.keys == keys {
+		return cached.model, nil
+	}
+
+	model, err := newTenantLLM(&service.config, org.Settings.LLMKeys)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create llm for organization %s: %w", tenant, err)
+	}
+	service.trackLLM(model)
+
+	service.tenantModels.models[tenant] = tenantModel{keys: keys, model: model}
+	return model, nil
+}
+
+/*
+newTenantLLM creates the configured LLM providers with the
+given API keys in place of the configured ones. Keys for
+providers that aren't configured are ignored.
+*/
+func newTenantLLM(cfg *config.Config, keys map[string]string) (llm.LLM, error) {
+	tenantConfig := *cfg
+	tenantConfig.LLM.Providers = map[string]config.ProviderConfig{}
+	for name, provider := range cfg.LLM.Providers {
+		if key, ok := keys[name]; ok {
+			provider.APIKey = key
+		}
+		tenantConfig.LLM.Providers[name] = provider
+	}
+
+	return NewLLMFromConfig(&tenantConfig)
+}
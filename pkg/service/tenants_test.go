@@ -0,0 +1,506 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/internal/prompts"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/config"
+	"github.com/hlfshell/coppermind/pkg/experiments"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/quota"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+
+	// Agents and users can't be put in an organization that
+	// doesn't exist
+	err = service.Agents.CreateAgent("", &agents.Agent{ID: "rose", Name: "Rose", Tenant: "acme"})
+	var notFound *NotFoundError
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "organization", notFound.Kind)
+
+	for _, org := range []*tenants.Organization{{ID: "acme", Name: "Acme"}, {ID: "initech", Name: "Initech"}} {
+		require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, org))
+	}
+
+	rose := &agents.Agent{ID: "rose", Name: "Rose", Tenant: "acme"}
+	winston := &agents.Agent{ID: "winston", Name: "Winston", Tenant: "initech"}
+	keith := &users.User{ID: "keith", Name: "Keith", CreatedAt: time.Now(), Tenant: "acme", Role: users.RoleAgentEditor}
+	abby := &users.User{ID: "abby", Name: "Abby", CreatedAt: time.Now(), Tenant: "initech", Role: users.RoleAgentEditor}
+	for _, user := range []*users.User{keith, abby} {
+		require.Nil(t, service.Users.CreateUser(user, "supersecret"))
+	}
+	require.Nil(t, service.Agents.CreateAgent(keith.ID, rose))
+	require.Nil(t, service.Agents.CreateAgent(abby.ID, winston))
+
+	// Agents are listed per organization
+	listed, err := service.Agents.ListAgents(testOperator.ID, "acme")
+	require.Nil(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "rose", listed[0].ID)
+	listed, err = service.Agents.ListAgents(testOperator.ID, "")
+	require.Nil(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, testAgent.ID, listed[0].ID)
+
+	// Each user talks to their own organization's agent, and
+	// each agent learns something about them
+	conversations := map[string]string{}
+	for _, pair := range []struct {
+		agent *agents.Agent
+		user  *users.User
+	}{{rose, keith}, {winston, abby}} {
+		msg := &chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: uuid.New().String(),
+			Agent:        pair.agent.ID,
+			User:         pair.user.ID,
+			From:         pair.user.ID,
+			Content:      "My password is hunter2",
+			CreatedAt:    time.Now(),
+		}
+		llm.AddSendMessageResponse(&chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     pair.agent.ID,
+			From:      pair.agent.ID,
+			Content:   "I'll remember that",
+			CreatedAt: time.Now(),
+		}, nil)
+		_, err = service.SendMessage(msg)
+		require.Nil(t, err)
+		conversations[pair.user.ID] = msg.Conversation
+
+		require.Nil(t, store.SaveSummary(&memory.Summary{
+			ID:                    uuid.New().String(),
+			Agent:                 pair.agent.ID,
+			User:                  pair.user.ID,
+			Conversation:          msg.Conversation,
+			Summary:               pair.user.Name + " shared their password",
+			ConversationStartedAt: time.Now(),
+		}))
+		require.Nil(t, store.SaveKnowledge(&memory.Knowledge{
+			ID:        uuid.New().String(),
+			Agent:     pair.agent.ID,
+			User:      pair.user.ID,
+			Subject:   pair.user.Name,
+			Predicate: "has password",
+			Object:    "hunter2",
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}))
+	}
+
+	// Another organization's agent can't be messaged...
+	llm.ClearMemory()
+	_, err = service.SendMessage(&chat.Message{
+		ID:        uuid.New().String(),
+		Agent:     rose.ID,
+		User:      abby.ID,
+		From:      abby.ID,
+		Content:   "What's Keith's password?",
+		CreatedAt: time.Now(),
+	})
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "agent", notFound.Kind)
+	agent, _, _, _, _, _, _ := llm.GetSendMessageInputs()
+	assert.Nil(t, agent)
+
+	// ...nor its conversations joined through one's own agent
+	_, err = service.SendMessage(&chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: conversations[keith.ID],
+		Agent:        winston.ID,
+		User:         abby.ID,
+		From:         abby.ID,
+		Content:      "What did Keith say?",
+		CreatedAt:    time.Now(),
+	})
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "conversation", notFound.Kind)
+
+	// ...nor another user's conversation with the same agent
+	carol := &users.User{ID: "carol", Name: "Carol", CreatedAt: time.Now(), Tenant: "initech"}
+	require.Nil(t, service.Users.CreateUser(carol, "supersecret"))
+	_, err = service.SendMessage(&chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: conversations[abby.ID],
+		Agent:        winston.ID,
+		User:         carol.ID,
+		From:         carol.ID,
+		Content:      "What did Abby say?",
+		CreatedAt:    time.Now(),
+	})
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "conversation", notFound.Kind)
+
+	// Messages, summaries and knowledge can't be read across
+	// organizations
+	_, err = service.Messages.GetMessages(&GetMessagesRequest{Agent: rose.ID, User: abby.ID, Time: time.Now().Add(-time.Hour)})
+	require.True(t, errors.As(err, &notFound))
+	_, err = service.Summary.GetSummaries(&GetSummariesRequest{Agent: rose.ID, User: abby.ID, Time: time.Now().Add(-time.Hour)})
+	require.True(t, errors.As(err, &notFound))
+	_, err = service.Memory.GetMemory(&MemoryRequest{Agent: rose.ID, User: abby.ID})
+	require.True(t, errors.As(err, &notFound))
+	_, err = service.Knowledge.GetGraph(&GraphRequest{Agent: rose.ID, User: abby.ID})
+	require.True(t, errors.As(err, &notFound))
+	_, err = service.Knowledge.About(&GraphRequest{Agent: rose.ID, User: abby.ID}, "Keith")
+	require.True(t, errors.As(err, &notFound))
+
+	// ...while each organization sees only its own
+	messages, err := service.Messages.GetMessages(&GetMessagesRequest{Agent: winston.ID, User: abby.ID, Time: time.Now().Add(-time.Hour)})
+	require.Nil(t, err)
+	require.Len(t, messages, 2)
+	for _, message := range messages {
+		assert.Equal(t, conversations[abby.ID], message.Conversation)
+	}
+
+	remembered, err := service.Memory.GetMemory(&MemoryRequest{Agent: winston.ID, User: abby.ID})
+	require.Nil(t, err)
+	require.Len(t, remembered.Summaries, 1)
+	assert.Equal(t, "Abby shared their password", remembered.Summaries[0].Summary)
+	require.Len(t, remembered.Knowledge, 1)
+	assert.Equal(t, "Abby", remembered.Knowledge[0].Subject)
+
+	// An organization can't be deleted while it owns anything
+	var invalid *InvalidRequestError
+	err = service.Tenants.DeleteOrganization(testOperator.ID, "acme")
+	require.True(t, errors.As(err, &invalid))
+}
+
+func TestTenantSettings(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+
+	org := &tenants.Organization{
+		ID:   "acme",
+		Name: "Acme",
+		Settings: tenants.Settings{
+			Quota:     quota.Limits{RequestsPerMinute: 3},
+			UserQuota: quota.Limits{RequestsPerMinute: 1},
+		},
+	}
+	require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, org))
+	require.Nil(t, service.Users.CreateUser(&users.User{ID: "admin", Name: "Admin", CreatedAt: time.Now(), Tenant: "acme", Role: users.RoleAdmin}, "supersecret"))
+	require.Nil(t, service.Agents.CreateAgent("admin", &agents.Agent{ID: "rose", Name: "Rose", Tenant: "acme"}))
+
+	send := func(user string) error {
+		llm.AddSendMessageResponse(&chat.Message{
+			ID:        uuid.New().String(),
+			Agent:     "rose",
+			From:      "rose",
+			Content:   "Hello",
+			CreatedAt: time.Now(),
+		}, nil)
+		_, err := service.SendMessage(&chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: uuid.New().String(),
+			Agent:        "rose",
+			User:         user,
+			From:         user,
+			Content:      "Hi",
+			CreatedAt:    time.Now(),
+		})
+		return err
+	}
+
+	names := []string{"keith", "abby", "winston", "jessica"}
+	for _, name := range names {
+		require.Nil(t, service.Users.CreateUser(&users.User{ID: name, Name: name, CreatedAt: time.Now(), Tenant: "acme"}, "supersecret"))
+	}
+
+	// Each user is held to the organization's user quota
+	// unless configured otherwise...
+	service.config.Quota = config.QuotaConfig{
+		Users: map[string]quota.Limits{"abby": {RequestsPerMinute: 5}},
+	}
+	require.Nil(t, send("keith"))
+	var quotaErr *quota.QuotaExceededError
+	require.True(t, errors.As(send("keith"), &quotaErr))
+	assert.Equal(t, quota.Key(quota.ScopeUser, "keith"), quotaErr.Scope)
+	require.Nil(t, send("abby"))
+	require.Nil(t, send("abby"))
+
+	// ...and the organization as a whole to its own
+	require.True(t, errors.As(send("winston"), &quotaErr))
+	assert.Equal(t, quota.Key(quota.ScopeTenant, "acme"), quotaErr.Scope)
+
+	counter, err := store.GetQuota(
+		quota.Key(quota.ScopeTenant, "acme"),
+		quota.PeriodMinute,
+		quota.PeriodStart(quota.PeriodMinute, time.Now()),
+	)
+	require.Nil(t, err)
+	assert.Equal(t, 3, counter.Requests)
+
+	// Organizations without keys of their own share the
+	// server's LLM
+	model, err := service.modelFor("acme")
+	require.Nil(t, err)
+	assert.Equal(t, llm, model)
+
+	// ...otherwise they get their own, built once per set of
+	// keys
+	org.Settings.LLMKeys = map[string]string{"openai": "sk-acme"}
+	require.Nil(t, service.Tenants.UpdateOrganization(testOperator.ID, org))
+	model, err = service.modelFor("acme")
+	require.Nil(t, err)
+	assert.NotEqual(t, llm, model)
+	again, err := service.modelFor("acme")
+	require.Nil(t, err)
+	assert.True(t, model == again)
+
+	org.Settings.LLMKeys["openai"] = "sk-acme-rotated"
+	require.Nil(t, service.Tenants.UpdateOrganization(testOperator.ID, org))
+	again, err = service.modelFor("acme")
+	require.Nil(t, err)
+	assert.False(t, model == again)
+
+	// Keys left out, or sent back masked, are kept
+	org.Settings = org.Settings.Redacted()
+	org.Settings.LLMKeys["anthropic"] = ""
+	require.Nil(t, service.Tenants.UpdateOrganization(testOperator.ID, org))
+	org.Settings.LLMKeys = nil
+	require.Nil(t, service.Tenants.UpdateOrganization(testOperator.ID, org))
+
+	saved, err := service.Tenants.GetOrganization("admin", "acme")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"openai": "sk-acme-rotated"}, saved.Settings.LLMKeys)
+}
+
+func TestOrganizationAccess(t *testing.T) {
+	service, _, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	// Only the server's operator creates organizations
+	var permission *PermissionError
+	for _, actor := range []string{"", testUser.ID} {
+		err = service.Tenants.CreateOrganization(actor, &tenants.Organization{ID: "acme", Name: "Acme"})
+		require.True(t, errors.As(err, &permission), actor)
+	}
+	for _, org := range []*tenants.Organization{{ID: "acme", Name: "Acme"}, {ID: "initech", Name: "Initech"}} {
+		require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, org))
+	}
+	for _, user := range []*users.User{
+		{ID: "admin", Name: "Admin", Tenant: "acme", Role: users.RoleAdmin},
+		{ID: "keith", Name: "Keith", Tenant: "acme"},
+		{ID: "abby", Name: "Abby", Tenant: "initech", Role: users.RoleAdmin},
+	} {
+		user.CreatedAt = time.Now()
+		require.Nil(t, service.Users.CreateUser(user, "supersecret"))
+	}
+	err = service.Tenants.CreateOrganization("admin", &tenants.Organization{ID: "hooli", Name: "Hooli"})
+	require.True(t, errors.As(err, &permission))
+
+	// ...and only it or the organization's own admins change
+	// or delete it
+	for _, actor := range []string{"", "keith", "abby", testUser.ID} {
+		err = service.Tenants.UpdateOrganization(actor, &tenants.Organization{ID: "acme", Name: "Acme Corp"})
+		require.True(t, errors.As(err, &permission), actor)
+		err = service.Tenants.DeleteOrganization(actor, "acme")
+		require.True(t, errors.As(err, &permission), actor)
+	}
+	require.Nil(t, service.Tenants.UpdateOrganization("admin", &tenants.Organization{ID: "acme", Name: "Acme Corp"}))
+	require.Nil(t, service.Tenants.UpdateOrganization(testOperator.ID, &tenants.Organization{ID: "initech", Name: "Initech LLC"}))
+
+	// Anyone within an organization can see it, but no one
+	// outside of it but the operator
+	org, err := service.Tenants.GetOrganization("keith", "acme")
+	require.Nil(t, err)
+	assert.Equal(t, "Acme Corp", org.Name)
+	_, err = service.Tenants.GetOrganization("abby", "acme")
+	require.True(t, errors.As(err, &permission))
+	org, err = service.Tenants.GetOrganization(testOperator.ID, "initech")
+	require.Nil(t, err)
+	assert.Equal(t, "Initech LLC", org.Name)
+
+	require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, &tenants.Organization{ID: "hooli", Name: "Hooli"}))
+	require.Nil(t, service.Tenants.DeleteOrganization(testOperator.ID, "hooli"))
+}
+
+/*
+TestTenantReadPaths reads everything kept about one
+organization's agent and user through every read path of
+the service as an admin of another organization, which must
+find nothing. Stored records are scoped by agent and user
+rather than by tenant, so this is what keeps them apart.
+*/
+func TestTenantReadPaths(t *testing.T) {
+	llm := mock.NewMockLLM()
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+
+	for _, org := range []*tenants.Organization{{ID: "acme", Name: "Acme"}, {ID: "initech", Name: "Initech"}} {
+		require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, org))
+	}
+	for _, user := range []*users.User{
+		{ID: "admin", Name: "Admin", Tenant: "acme", Role: users.RoleAdmin},
+		{ID: "keith", Name: "Keith", Tenant: "acme"},
+		{ID: "abby", Name: "Abby", Tenant: "initech", Role: users.RoleAdmin},
+	} {
+		user.CreatedAt = time.Now()
+		require.Nil(t, service.Users.CreateUser(user, "supersecret"))
+	}
+
+	rose := &agents.Agent{ID: "rose", Name: "Rose", Tenant: "acme", Visibility: agents.VisibilityPrivate}
+	require.Nil(t, service.Agents.CreateAgent("admin", rose))
+	require.Nil(t, service.Agents.AddMember("admin", rose.ID, "keith"))
+	require.Nil(t, service.Prompts.SaveTemplate("admin", &agents.PromptTemplate{Agent: rose.ID, Name: prompts.TemplateSummary, Template: "Summarize as Rose"}))
+	experiment := &experiments.Experiment{
+		Agent: rose.ID,
+		Name:  "Bigger model",
+		Variants: []*experiments.Variant{
+			{Name: "control"},
+			{Name: "gpt-4", LLM: &agents.LLMSettings{Chat: &agents.Profile{Model: "gpt-4"}}},
+		},
+	}
+	require.Nil(t, service.Experiments.CreateExperiment("admin", experiment))
+
+	// Keith tells Rose something, which she remembers in
+	// every way she can
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        rose.ID,
+		User:         "keith",
+		From:         "keith",
+		Content:      "My password is hunter2",
+		CreatedAt:    time.Now(),
+	}
+	llm.AddSendMessageResponse(&chat.Message{
+		ID:        uuid.New().String(),
+		Agent:     rose.ID,
+		From:      rose.ID,
+		Content:   "I'll remember that",
+		CreatedAt: time.Now(),
+	}, nil)
+	response, err := service.SendMessage(msg)
+	require.Nil(t, err)
+	require.Nil(t, service.Feedback.SubmitFeedback(&chat.Feedback{Message: response.ID, User: "keith", Rating: chat.RatingUp}))
+	artifact, err := service.Artifacts.Upload("keith", &UploadArtifactRequest{
+		Message: msg.ID,
+		Type:    artifacts.TypeText,
+		Data:    []byte("hunter2"),
+	})
+	require.Nil(t, err)
+
+	summary := &memory.Summary{
+		ID:                    uuid.New().String(),
+		Agent:                 rose.ID,
+		User:                  "keith",
+		Conversation:          msg.Conversation,
+		Summary:               "Keith shared his password",
+		ConversationStartedAt: time.Now(),
+	}
+	require.Nil(t, store.SaveSummary(summary))
+	fact := &memory.Knowledge{
+		ID:           uuid.New().String(),
+		Agent:        rose.ID,
+		User:         "keith",
+		Subject:      "Keith",
+		Predicate:    "has password",
+		Object:       "hunter2",
+		Conversation: msg.Conversation,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+	require.Nil(t, store.SaveKnowledge(fact))
+
+	// Abby is an admin, but of another organization
+	since := time.Now().Add(-time.Hour)
+	graph := &GraphRequest{Agent: rose.ID, User: "abby"}
+	reads := map[string]func() (interface{}, error){
+		"message": func() (interface{}, error) { return service.Messages.GetMessage("abby", msg.ID) },
+		"messages": func() (interface{}, error) {
+			return service.Messages.GetMessages(&GetMessagesRequest{Agent: rose.ID, User: "abby", Time: since})
+		},
+		"conversation": func() (interface{}, error) { return service.Messages.GetConversation("abby", msg.Conversation) },
+		"conversations": func() (interface{}, error) {
+			return service.Messages.GetConversations(&GetConversationsRequest{Agent: rose.ID, User: "abby", Time: since})
+		},
+		"summary": func() (interface{}, error) { return service.Summary.GetSummary("abby", summary.ID) },
+		"summaries": func() (interface{}, error) {
+			return service.Summary.GetSummaries(&GetSummariesRequest{Agent: rose.ID, User: "abby", Time: since})
+		},
+		"memory": func() (interface{}, error) {
+			return service.Memory.GetMemory(&MemoryRequest{Agent: rose.ID, User: "abby"})
+		},
+		"graph":        func() (interface{}, error) { return service.Knowledge.GetGraph(graph) },
+		"about":        func() (interface{}, error) { return service.Knowledge.About(graph, "Keith") },
+		"neighbors":    func() (interface{}, error) { return service.Knowledge.Neighbors(graph, "Keith") },
+		"path":         func() (interface{}, error) { return service.Knowledge.Path(graph, "Keith", "hunter2") },
+		"predicate":    func() (interface{}, error) { return service.Knowledge.ByPredicate(graph, "has password") },
+		"export graph": func() (interface{}, error) { return service.Knowledge.Export(graph, GraphFormatJSON) },
+		"provenance":   func() (interface{}, error) { return service.Knowledge.GetProvenance(graph, fact.ID) },
+		"artifact":     func() (interface{}, error) { return service.Artifacts.Download("abby", msg.ID, artifact.ID) },
+		"feedback of the agent": func() (interface{}, error) {
+			return service.Feedback.ListFeedback("abby", &ListFeedbackRequest{Agent: rose.ID})
+		},
+		"feedback of the user": func() (interface{}, error) {
+			return service.Feedback.ListFeedback("abby", &ListFeedbackRequest{User: "keith"})
+		},
+		"feedback report": func() (interface{}, error) {
+			return service.Feedback.Report("abby", &FeedbackReportRequest{Agent: rose.ID, From: since})
+		},
+		"usage": func() (interface{}, error) {
+			return service.Usage.GetUsage("abby", &GetUsageRequest{Agent: rose.ID, From: since})
+		},
+		"routes": func() (interface{}, error) {
+			return service.Usage.ListRoutes("abby", &ListRoutesRequest{GetUsageRequest: GetUsageRequest{Agent: rose.ID, From: since}})
+		},
+		"agents":            func() (interface{}, error) { return service.Agents.ListAgents("abby", "acme") },
+		"revisions":         func() (interface{}, error) { return service.Agents.ListRevisions("abby", rose.ID) },
+		"revision diff":     func() (interface{}, error) { return service.Agents.DiffRevisions("abby", rose.ID, 1, 1) },
+		"members":           func() (interface{}, error) { return service.Agents.ListMembers("abby", rose.ID) },
+		"prompt templates":  func() (interface{}, error) { return service.Prompts.ListTemplates("abby", rose.ID) },
+		"experiments":       func() (interface{}, error) { return service.Experiments.ListExperiments("abby", rose.ID) },
+		"experiment report": func() (interface{}, error) { return service.Experiments.Report("abby", experiment.ID) },
+		"organization":      func() (interface{}, error) { return service.Tenants.GetOrganization("abby", "acme") },
+		"user export":       func() (interface{}, error) { return nil, service.Users.ExportUserData("abby", "keith", io.Discard) },
+		"erasures":          func() (interface{}, error) { return service.Users.ListErasures("abby", "keith") },
+	}
+	for name, read := range reads {
+		_, err := read()
+		var notFound *NotFoundError
+		var permission *PermissionError
+		assert.True(t, errors.As(err, &notFound) || errors.As(err, &permission), "%s: %v", name, err)
+	}
+
+	// Usage across every agent is limited to her own
+	// organization's
+	report, err := service.Usage.GetUsage("abby", &GetUsageRequest{From: since})
+	require.Nil(t, err)
+	assert.Zero(t, report.Totals.Calls)
+	routes, err := service.Usage.ListRoutes("abby", &ListRoutesRequest{GetUsageRequest: GetUsageRequest{From: since}})
+	require.Nil(t, err)
+	assert.Empty(t, routes)
+
+	// ...nor is Rose among the agents listed to her
+	visible, err := service.Agents.ListVisibleAgents("abby")
+	require.Nil(t, err)
+	assert.Empty(t, visible)
+
+	// The same reads work for Rose's own organization
+	report, err = service.Usage.GetUsage("admin", &GetUsageRequest{From: since})
+	require.Nil(t, err)
+	assert.NotZero(t, report.Totals.Calls)
+	_, err = service.Artifacts.Download("admin", msg.ID, artifact.ID)
+	require.Nil(t, err)
+	_, err = service.Knowledge.GetProvenance(&GraphRequest{Agent: rose.ID, User: "keith"}, fact.ID)
+	require.Nil(t, err)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/hlfshell/coppermind/pkg/users"
+)
+
+type UsageService struct {
+	db     store.Store
+	prices map[string]usage.Price
+}
+
+func NewUsageService(db store.Store, prices map[string]usage.Price) *UsageService {
+	return &UsageService{
+		db:     db,
+		prices: prices,
+	}
+}
+
+/*
+GetUsageRequest is the time range and optional agent and
+user to aggregate usage over. If To is not set, it is
+assumed to be now.
+*/
+type GetUsageRequest struct {
+	Agent string
+	User  string
+	From  time.Time
+	To    time.Time
+}
+
+func (request *GetUsageRequest) Valid() error {
+	if request.From.IsZero() {
+		return fmt.Errorf("from must be set")
+	}
+	if !request.To.IsZero() && request.To.Before(request.From) {
+		return fmt.Errorf("to must be after from")
+	}
+	return nil
+}
+
+func (request *GetUsageRequest) getFilters() ([]*store.FilterAttribute, error) {
+	err := request.Valid()
+	if err != nil {
+		return nil, err
+	}
+
+	if request.To.IsZero() {
+		request.To = time.Now()
+	}
+
+	attributes := []*store.FilterAttribute{
+		{
+			Attribute: "created_at",
+			Value:     request.From,
+			Operation: store.GTE,
+		},
+		{
+			Attribute: "created_at",
+			Value:     request.To,
+			Operation: store.LTE,
+		},
+	}
+
+	if request.Agent != "" {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "agent",
+			Value:     request.Agent,
+			Operation: store.EQ,
+		})
+	}
+	if request.User != "" {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "user",
+			Value:     request.User,
+			Operation: store.EQ,
+		})
+	}
+
+	return attributes, nil
+}
+
+/*
+authorizeUsage ensures that an actor may see the usage asked
+for - their own, or any within their organization if they
+are an admin of it - returning the filter that limits it to
+their organization.
+*/
+func authorizeUsage(db store.Store, actorId string, request *GetUsageRequest) (*store.FilterAttribute, error) {
+	actor, err := db.GetUser(actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := "usage of " + request.User
+	if request.User == "" {
+		resource = "usage of every user"
+	}
+	if actor == nil || (actor.Role != users.RoleAdmin && request.User != actor.ID) {
+		return nil, &PermissionError{User: actorId, Action: "view", Resource: resource}
+	}
+
+	if request.Agent != "" {
+		err = checkTenancy(db, request.Agent, actorId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &store.FilterAttribute{
+		Attribute: "tenant",
+		Value:     actor.Tenant,
+		Operation: store.EQ,
+	}, nil
+}
+
+/*
+GetUsage returns the aggregated token usage and cost for
+the given time range, priced per the configured model
+prices. Only the actor's own usage is reported unless they
+are an admin, and never that of another organization.
+*/
+func (service *UsageService) GetUsage(actorId string, request *GetUsageRequest) (*usage.Report, error) {
+	filters, err := request.getFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := authorizeUsage(service.db, actorId, request)
+	if err != nil {
+		return nil, err
+	}
+	filters = append(filters, tenant)
+
+	records, err := service.db.ListUsage(store.Filter{
+		Attributes: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage.NewReport(
+		request.Agent,
+		request.User,
+		request.From,
+		request.To,
+		records,
+		service.prices,
+	), nil
+}
+
+/*
+ListRoutesRequest is the time range and optional agent, user,
+and conversation to list LLM routing records for. If To is
+not set, it is assumed to be now.
+*/
+type ListRoutesRequest struct {
+	GetUsageRequest
+	Conversation string
+}
+
+func (request *ListRoutesRequest) getFilters() ([]*store.FilterAttribute, error) {
+	attributes, err := request.GetUsageRequest.getFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Conversation != "" {
+		attributes = append(attributes, &store.FilterAttribute{
+			Attribute: "conversation",
+			Value:     request.Conversation,
+			Operation: store.EQ,
+		})
+	}
+
+	return attributes, nil
+}
+
+/*
+ListRoutes returns which LLM backend was attempted for each
+call in the given time range, and whether it served the
+call or was fallen back from. It is limited the same way as
+GetUsage.
+*/
+func (service *UsageService) ListRoutes(actorId string, request *ListRoutesRequest) ([]*usage.Route, error) {
+	filters, err := request.getFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant, err := authorizeUsage(service.db, actorId, &request.GetUsageRequest)
+	if err != nil {
+		return nil, err
+	}
+	filters = append(filters, tenant)
+
+	return service.db.ListRoutes(store.Filter{
+		Attributes: filters,
+	})
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUsage(t *testing.T) {
+	llm := mock.NewMockLLM()
+
+	service, store, err := createMockService(llm)
+	require.Nil(t, err)
+	require.NotNil(t, service)
+	require.NotNil(t, store)
+
+	start := time.Now().Add(-1 * time.Minute)
+
+	// ==== Invalid requests ====
+	report, err := service.Usage.GetUsage(testUser.ID, &GetUsageRequest{})
+	assert.NotNil(t, err)
+	assert.Nil(t, report)
+
+	report, err = service.Usage.GetUsage(testUser.ID, &GetUsageRequest{
+		From: time.Now(),
+		To:   time.Now().Add(-1 * time.Hour),
+	})
+	assert.NotNil(t, err)
+	assert.Nil(t, report)
+
+	// ==== Usage is recorded through the LLM calls ====
+	msg := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Content:      "How many tokens is this message?",
+		CreatedAt:    time.Now(),
+	}
+	response := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: msg.Conversation,
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testAgent.ID,
+		Content:      "Not nearly enough to matter",
+		CreatedAt:    time.Now(),
+	}
+	llm.AddSendMessageResponse(response, nil)
+
+	_, err = service.SendMessage(msg)
+	require.Nil(t, err)
+
+	report, err = service.Usage.GetUsage(testUser.ID, &GetUsageRequest{
+		Agent: testAgent.ID,
+		User:  testUser.ID,
+		From:  start,
+	})
+	require.Nil(t, err)
+	require.NotNil(t, report)
+
+	assert.Equal(t, 1, report.Totals.Calls)
+	assert.Equal(t, llm.EstimateTokens(msg.Content), report.Totals.PromptTokens)
+	assert.Equal(t, llm.EstimateTokens(response.Content), report.Totals.CompletionTokens)
+	require.Contains(t, report.Models, mock.MockModel)
+	require.Contains(t, report.Types, usage.CallChat)
+	assert.Equal(t, 1, report.Types[usage.CallChat].Calls)
+
+	// The mock model has no price, so it should cost nothing
+	assert.Equal(t, 0.0, report.Totals.Cost)
+
+	// ==== Priced models are costed ====
+	err = store.SaveUsage(&usage.Usage{
+		ID:               uuid.New().String(),
+		Agent:            testAgent.ID,
+		User:             testUser.ID,
+		Conversation:     msg.Conversation,
+		Type:             usage.CallSummarize,
+		Model:            "gpt-4",
+		PromptTokens:     1000,
+		CompletionTokens: 500,
+		CreatedAt:        time.Now(),
+	})
+	require.Nil(t, err)
+
+	report, err = service.Usage.GetUsage(testUser.ID, &GetUsageRequest{
+		Agent: testAgent.ID,
+		User:  testUser.ID,
+		From:  start,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 2, report.Totals.Calls)
+	assert.InDelta(t, 0.06, report.Totals.Cost, 0.0001)
+	assert.InDelta(t, 0.06, report.Models["gpt-4"].Cost, 0.0001)
+	assert.Equal(t, 1500, report.Models["gpt-4"].TotalTokens)
+
+	// ==== Only admins see the usage of others ====
+	var permissionErr *PermissionError
+	_, err = service.Usage.GetUsage(testUser.ID, &GetUsageRequest{From: start})
+	assert.True(t, errors.As(err, &permissionErr))
+	_, err = service.Usage.ListRoutes(testUser.ID, &ListRoutesRequest{GetUsageRequest: GetUsageRequest{From: start}})
+	assert.True(t, errors.As(err, &permissionErr))
+
+	report, err = service.Usage.GetUsage(testOperator.ID, &GetUsageRequest{From: start})
+	require.Nil(t, err)
+	assert.Equal(t, 2, report.Totals.Calls)
+
+	// ==== Other users are not included ====
+	report, err = service.Usage.GetUsage(testOperator.ID, &GetUsageRequest{
+		User: uuid.New().String(),
+		From: start,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 0, report.Totals.Calls)
+
+	// ==== Outside of the time range is not included ====
+	report, err = service.Usage.GetUsage(testOperator.ID, &GetUsageRequest{
+		From: start.Add(-1 * time.Hour),
+		To:   start,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 0, report.Totals.Calls)
+}
@@ -1,7 +1,16 @@
 package service
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
 	"github.com/hlfshell/coppermind/pkg/users"
 )
 
@@ -16,9 +25,294 @@ func NewUserService(db store.Store) *UserService {
 }
 
 func (service *UserService) CreateUser(user *users.User, password string) error {
+	if user.Role == "" {
+		user.Role = users.RoleMember
+	} else if !users.ValidRole(user.Role) {
+		return &InvalidRequestError{Err: fmt.Errorf("unknown role %s", user.Role)}
+	}
+
+	err := checkOrganization(service.db, user.Tenant)
+	if err != nil {
+		return err
+	}
 	return service.db.CreateUser(user, password)
 }
 
+/*
+SetRole changes a user's role on behalf of an actor, who must
+be an admin of the user's organization
+*/
+func (service *UserService) SetRole(actorId string, userId string, role string) error {
+	if !users.ValidRole(role) {
+		return &InvalidRequestError{Err: fmt.Errorf("unknown role %s", role)}
+	}
+
+	user, err := service.db.GetUser(userId)
+	if err != nil {
+		return err
+	} else if user == nil {
+		return &NotFoundError{Kind: "user", ID: userId}
+	}
+
+	_, err = authorizeRole(service.db, actorId, user.Tenant, "change the role of", "user "+userId, users.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	return service.db.SetUserRole(userId, role)
+}
+
+/*
+Authenticate returns the user whose ID and password are
+given. A user that doesn't exist fails the same way as a
+wrong password, so that which users exist isn't revealed.
+*/
+func (service *UserService) Authenticate(userId string, password string) (*users.User, error) {
+	auth, err := service.db.GetUserAuth(userId)
+	if err != nil {
+		return nil, err
+	} else if auth == nil || !auth.CheckPassword(password) {
+		return nil, &AuthenticationError{User: userId}
+	}
+
+	user, err := service.db.GetUser(userId)
+	if err != nil {
+		return nil, err
+	} else if user == nil {
+		return nil, &AuthenticationError{User: userId}
+	}
+	return user, nil
+}
+
 func (service *UserService) ResetPassword(userId string, token string, password string) error {
 	return service.db.ResetPassword(userId, token, password)
 }
+
+/*
+ExportUserData writes a zip archive of everything stored about
+a user: their profile, conversations with their messages,
+the feedback they gave on replies, summaries, knowledge,
+entity aliases, and the agent revisions they authored as
+JSON files, and the data of each of their
+messages' artifacts under artifacts/. The user need not
+still have a profile, as DeleteUser leaves the rest of their
+data behind. Only the user themself or an admin of their
+organization may export it.
+*/
+func (service *UserService) ExportUserData(actorId string, userId string, w io.Writer) error {
+	if userId == "" {
+		return &InvalidRequestError{Err: fmt.Errorf("user must be set")}
+	}
+
+	err := authorizeUserData(service.db, actorId, userId, "export")
+	if err != nil {
+		return err
+	}
+
+	user, err := service.db.GetUser(userId)
+	if err != nil {
+		return err
+	}
+
+	filter := store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "user",
+				Value:     userId,
+				Operation: store.EQ,
+			},
+		},
+	}
+
+	conversations, err := service.db.ListConversations(filter)
+	if err != nil {
+		return err
+	}
+	feedback, err := service.db.ListFeedback(filter)
+	if err != nil {
+		return err
+	}
+	summaries, err := service.db.ListSummaries(filter)
+	if err != nil {
+		return err
+	}
+	knowledge, err := service.db.ListKnowledge(filter)
+	if err != nil {
+		return err
+	}
+	aliases, err := service.db.ListAliases(filter)
+	if err != nil {
+		return err
+	}
+	revisions, err := service.db.ListAgentRevisions(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "author",
+				Value:     userId,
+				Operation: store.EQ,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if user == nil && len(conversations) == 0 && len(feedback) == 0 && len(summaries) == 0 && len(knowledge) == 0 && len(aliases) == 0 && len(revisions) == 0 {
+		return &NotFoundError{Kind: "user", ID: userId}
+	}
+
+	// Listed messages carry their artifacts without the
+	// data, which is loaded one at a time into the archive
+	listed := []*artifacts.ArtifactData{}
+	for _, conversation := range conversations {
+		for _, msg := range conversation.Messages {
+			listed = append(listed, msg.Artifacts...)
+		}
+	}
+
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		value interface{}
+	}{
+		{"user.json", user},
+		{"conversations.json", conversations},
+		{"feedback.json", feedback},
+		{"summaries.json", summaries},
+		{"knowledge.json", knowledge},
+		{"aliases.json", aliases},
+		{"agent_revisions.json", revisions},
+		{"artifacts.json", listed},
+	}
+	for _, file := range files {
+		err = writeArchiveJSON(archive, file.name, file.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, metadata := range listed {
+		artifact, err := service.db.GetArtifact(metadata.ID)
+		if err != nil {
+			return err
+		} else if artifact == nil {
+			continue
+		}
+
+		file, err := archive.Create(artifactArchivePath(artifact))
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(artifact.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func writeArchiveJSON(archive *zip.Writer, name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
+
+/*
+artifactArchivePath names an artifact's file within an
+export. Filenames are set by users, so only their base name
+is kept.
+*/
+func artifactArchivePath(artifact *artifacts.ArtifactData) string {
+	filename := path.Base(path.Clean("/" + artifact.Filename))
+	if filename == "/" || filename == "." {
+		filename = artifact.Type
+	}
+	return path.Join("artifacts", artifact.ID, filename)
+}
+
+/*
+EraseUser removes everything stored about a user across
+every table, unlike DeleteUser, blanking them as the author
+of any agent revisions, and records an audit of the erasure
+that keeps only how much of each kind of data was removed. A dry run counts what would be erased, changing
+nothing and recording nothing. Only the user themself or an
+admin of their organization may erase it, and the audit
+records which of them did.
+*/
+func (service *UserService) EraseUser(actorId string, userId string, dryRun bool) (*users.Erasure, error) {
+	if userId == "" {
+		return nil, &InvalidRequestError{Err: fmt.Errorf("user must be set")}
+	}
+
+	err := authorizeUserData(service.db, actorId, userId, "erase")
+	if err != nil {
+		return nil, err
+	}
+
+	erasure := &users.Erasure{
+		User:      userId,
+		Actor:     actorId,
+		DryRun:    dryRun,
+		CreatedAt: time.Now(),
+	}
+
+	// The audit is kept by the user's organization
+	user, err := service.db.GetUser(userId)
+	if err != nil {
+		return nil, err
+	} else if user != nil {
+		erasure.Tenant = user.Tenant
+	}
+
+	if dryRun {
+		erasure.Counts, err = service.db.CountUserData(userId)
+		if err != nil {
+			return nil, err
+		}
+		return erasure, nil
+	}
+
+	erasure.Counts, err = service.db.EraseUser(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	erasure.ID = uuid.New().String()
+	err = service.db.SaveErasure(erasure)
+	if err != nil {
+		return nil, err
+	}
+
+	return erasure, nil
+}
+
+/*
+ListErasures returns the audit records of a user's erasures
+to the user themself or an admin of their organization
+*/
+func (service *UserService) ListErasures(actorId string, userId string) ([]*users.Erasure, error) {
+	err := authorizeUserData(service.db, actorId, userId, "list the erasures of")
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.ListErasures(store.Filter{
+		Attributes: []*store.FilterAttribute{
+			{
+				Attribute: "user",
+				Value:     userId,
+				Operation: store.EQ,
+			},
+		},
+	})
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/llm/mock"
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/artifacts"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/hlfshell/coppermind/pkg/tenants"
+	"github.com/hlfshell/coppermind/pkg/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportAndEraseUser(t *testing.T) {
+	service, db, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	msgID := uuid.New().String()
+	msg := &chat.Message{
+		ID:           msgID,
+		Conversation: uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		From:         testUser.ID,
+		Content:      "Here's a picture of my dog",
+		CreatedAt:    time.Now(),
+		Artifacts: []*artifacts.ArtifactData{
+			{
+				ID:        uuid.New().String(),
+				Message:   msgID,
+				Type:      artifacts.TypeImage,
+				MimeType:  "image/png",
+				Filename:  "../../cooper.png",
+				CreatedAt: time.Now(),
+				Data:      png,
+			},
+		},
+	}
+	require.Nil(t, db.SaveMessage(msg))
+	require.Nil(t, db.SaveFeedback(&chat.Feedback{Message: msgID, Agent: testAgent.ID, User: testUser.ID, Rating: chat.RatingDown, Comment: "Wrong dog"}))
+
+	fact := &memory.Knowledge{
+		ID:           uuid.New().String(),
+		Agent:        testAgent.ID,
+		User:         testUser.ID,
+		Subject:      "Keith",
+		Predicate:    "has dog",
+		Object:       "Cooper",
+		CreatedAt:    time.Now(),
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Conversation: msg.Conversation,
+	}
+	require.Nil(t, db.SaveKnowledge(fact))
+	require.Nil(t, db.SaveAgentRevision(&agents.Revision{
+		Agent:     testAgent.ID,
+		Version:   1,
+		Identity:  testAgent.Identity,
+		Author:    testUser.ID,
+		CreatedAt: time.Now(),
+	}))
+
+	// Another user of the same organization, an admin of it,
+	// and an admin of another
+	require.Nil(t, service.Tenants.CreateOrganization(testOperator.ID, &tenants.Organization{ID: "initech", Name: "Initech"}))
+	for _, user := range []*users.User{
+		{ID: "abby", Name: "Abby"},
+		{ID: "admin", Name: "Admin", Role: users.RoleAdmin},
+		{ID: "initech-admin", Name: "Initech Admin", Tenant: "initech", Role: users.RoleAdmin},
+	} {
+		user.CreatedAt = time.Now()
+		require.Nil(t, service.Users.CreateUser(user, "supersecret"))
+	}
+
+	// ==== Export ====
+	var invalidErr *InvalidRequestError
+	err = service.Users.ExportUserData(testUser.ID, "", io.Discard)
+	assert.True(t, errors.As(err, &invalidErr))
+
+	var notFoundErr *NotFoundError
+	missing := uuid.New().String()
+	err = service.Users.ExportUserData(missing, missing, io.Discard)
+	assert.True(t, errors.As(err, &notFoundErr))
+
+	// Only the user or an admin of their organization may
+	// export their data
+	var permissionErr *PermissionError
+	for _, actor := range []string{"", "abby", "initech-admin"} {
+		err = service.Users.ExportUserData(actor, testUser.ID, io.Discard)
+		assert.True(t, errors.As(err, &permissionErr), actor)
+	}
+	require.Nil(t, service.Users.ExportUserData("admin", testUser.ID, io.Discard))
+
+	var buffer bytes.Buffer
+	require.Nil(t, service.Users.ExportUserData(testUser.ID, testUser.ID, &buffer))
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.Nil(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.Nil(t, err)
+		data, err := io.ReadAll(reader)
+		require.Nil(t, err)
+		reader.Close()
+		files[file.Name] = data
+	}
+
+	var user users.User
+	require.Nil(t, json.Unmarshal(files["user.json"], &user))
+	assert.Equal(t, testUser.ID, user.ID)
+
+	var conversations []*chat.Conversation
+	require.Nil(t, json.Unmarshal(files["conversations.json"], &conversations))
+	require.Len(t, conversations, 1)
+	require.Len(t, conversations[0].Messages, 1)
+	assert.Equal(t, msg.Content, conversations[0].Messages[0].Content)
+
+	var feedback []*chat.Feedback
+	require.Nil(t, json.Unmarshal(files["feedback.json"], &feedback))
+	require.Len(t, feedback, 1)
+	assert.Equal(t, "Wrong dog", feedback[0].Comment)
+
+	var knowledge []*memory.Knowledge
+	require.Nil(t, json.Unmarshal(files["knowledge.json"], &knowledge))
+	require.Len(t, knowledge, 1)
+	assert.True(t, fact.Equal(knowledge[0]))
+
+	var revisions []*agents.Revision
+	require.Nil(t, json.Unmarshal(files["agent_revisions.json"], &revisions))
+	require.Len(t, revisions, 1)
+	assert.Equal(t, testAgent.ID, revisions[0].Agent)
+
+	// Artifact filenames can't escape their directory
+	assert.Equal(t, png, files["artifacts/"+msg.Artifacts[0].ID+"/cooper.png"])
+
+	// ==== Erasure ====
+	_, err = service.Users.EraseUser(testUser.ID, "", false)
+	assert.True(t, errors.As(err, &invalidErr))
+
+	// Only the user or an admin of their organization may
+	// erase their data, or see that it was
+	for _, actor := range []string{"", "abby", "initech-admin"} {
+		_, err = service.Users.EraseUser(actor, testUser.ID, true)
+		assert.True(t, errors.As(err, &permissionErr), actor)
+		_, err = service.Users.EraseUser(actor, testUser.ID, false)
+		assert.True(t, errors.As(err, &permissionErr), actor)
+		_, err = service.Users.ListErasures(actor, testUser.ID)
+		assert.True(t, errors.As(err, &permissionErr), actor)
+	}
+
+	dryRun, err := service.Users.EraseUser(testUser.ID, testUser.ID, true)
+	require.Nil(t, err)
+	assert.True(t, dryRun.DryRun)
+	assert.Equal(t, 1, dryRun.Counts[users.DataMessages])
+	assert.Equal(t, 1, dryRun.Counts[users.DataArtifacts])
+	assert.Equal(t, 1, dryRun.Counts[users.DataKnowledge])
+	assert.Equal(t, 1, dryRun.Counts[users.DataFeedback])
+	assert.Equal(t, 1, dryRun.Counts[users.DataProfile])
+	assert.Equal(t, 1, dryRun.Counts[users.DataAgentRevisions])
+
+	// A dry run leaves everything, and no audit record
+	erasures, err := service.Users.ListErasures(testUser.ID, testUser.ID)
+	require.Nil(t, err)
+	assert.Empty(t, erasures)
+	stored, err := db.GetMessage(msg.ID)
+	require.Nil(t, err)
+	assert.NotNil(t, stored)
+
+	erasure, err := service.Users.EraseUser("admin", testUser.ID, false)
+	require.Nil(t, err)
+	assert.False(t, erasure.DryRun)
+	assert.Equal(t, "admin", erasure.Actor)
+	assert.Equal(t, dryRun.Counts, erasure.Counts)
+
+	// The user's organization is still known from the audit
+	// once their profile is gone
+	_, err = service.Users.ListErasures("initech-admin", testUser.ID)
+	assert.True(t, errors.As(err, &permissionErr))
+	erasures, err = service.Users.ListErasures("admin", testUser.ID)
+	require.Nil(t, err)
+	require.Len(t, erasures, 1)
+	assert.Equal(t, erasure.ID, erasures[0].ID)
+	assert.Equal(t, "admin", erasures[0].Actor)
+
+	stored, err = db.GetMessage(msg.ID)
+	require.Nil(t, err)
+	assert.Nil(t, stored)
+
+	// The agent keeps the revision the user wrote, without
+	// them as its author
+	revision, err := db.GetAgentRevision(testAgent.ID, 1)
+	require.Nil(t, err)
+	require.NotNil(t, revision)
+	assert.Equal(t, "", revision.Author)
+
+	// Nothing is left to export
+	err = service.Users.ExportUserData(testUser.ID, testUser.ID, io.Discard)
+	assert.True(t, errors.As(err, &notFoundErr))
+}
+
+func TestAuthenticate(t *testing.T) {
+	service, _, err := createMockService(mock.NewMockLLM())
+	require.Nil(t, err)
+
+	user, err := service.Users.Authenticate(testUser.ID, "super duper secret shhh")
+	require.Nil(t, err)
+	assert.Equal(t, testUser.ID, user.ID)
+
+	// A wrong password and a user that doesn't exist fail
+	// alike
+	var authErr *AuthenticationError
+	_, err = service.Users.Authenticate(testUser.ID, "super duper wrong")
+	assert.True(t, errors.As(err, &authErr))
+	_, err = service.Users.Authenticate(uuid.New().String(), "super duper secret shhh")
+	assert.True(t, errors.As(err, &authErr))
+}
@@ -0,0 +1,78 @@
+package tenants
+
+import (
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/quota"
+)
+
+/*
+Organization is a tenant - it owns agents and users, and
+through its agents all of the messages, memory and usage
+they produce. Agents and users without an organization
+belong to the default tenant, which is identified by the
+empty ID.
+*/
+type Organization struct {
+	ID        string    `json:"id,omitempty" db:"id"`
+	Name      string    `json:"name,omitempty" db:"name"`
+	Settings  Settings  `json:"settings" db:"settings"`
+	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+/*
+Settings are an organization's overrides of the server's
+configuration. LLMKeys replaces the API key of each named
+provider for the organization's agents. Quota limits the
+organization as a whole, and UserQuota replaces the
+default limits for each of its users.
+*/
+type Settings struct {
+	LLMKeys   map[string]string `json:"llm_keys,omitempty"`
+	Quota     quota.Limits      `json:"quota"`
+	UserQuota quota.Limits      `json:"user_quota"`
+}
+
+// RedactedKey is what an LLM key is masked as when shown
+const RedactedKey = "********"
+
+/*
+Redacted returns a copy of the settings with the LLM keys
+masked, for settings that are being shown rather than used
+*/
+func (settings Settings) Redacted() Settings {
+	if len(settings.LLMKeys) == 0 {
+		return settings
+	}
+
+	redacted := settings
+	redacted.LLMKeys = map[string]string{}
+	for provider := range settings.LLMKeys {
+		redacted.LLMKeys[provider] = RedactedKey
+	}
+	return redacted
+}
+
+/*
+MergeLLMKeys returns existing keys with those of the settings
+laid over them. Empty and masked keys are skipped, so that
+settings sent back as they were shown keep the real keys.
+*/
+func (settings Settings) MergeLLMKeys(existing map[string]string) map[string]string {
+	keys := map[string]string{}
+	for provider, key := range existing {
+		keys[provider] = key
+	}
+	for provider, key := range settings.LLMKeys {
+		if key == "" || key == RedactedKey {
+			continue
+		}
+		keys[provider] = key
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	return keys
+}
@@ -0,0 +1,271 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// Limits to keep the calculator from being abused
+const (
+	maxExpressionLength = 1000
+	maxExpressionDepth  = 100
+)
+
+/*
+CalculatorTool evaluates arithmetic expressions, as LLMs are
+notoriously poor at arithmetic. It supports + - * / % ^,
+parentheses, and unary minus.
+*/
+type CalculatorTool struct{}
+
+func NewCalculatorTool() *CalculatorTool {
+	return &CalculatorTool{}
+}
+
+func (tool *CalculatorTool) Name() string {
+	return "calculator"
+}
+
+func (tool *CalculatorTool) Description() string {
+	return "Evaluate an arithmetic expression, ie (2 + 3) * 4 ^ 2"
+}
+
+func (tool *CalculatorTool) Schema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"expression": {
+				"type": "string",
+				"description": "The arithmetic expression to evaluate"
+			}
+		},
+		"required": ["expression"]
+	}`)
+}
+
+func (tool *CalculatorTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var input struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	result, err := Evaluate(input.Expression)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatFloat(result, 'f', -1, 64), nil
+}
+
+/*
+Evaluate calculates the value of an arithmetic expression.
+*/
+func Evaluate(expression string) (float64, error) {
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("expression is too long")
+	}
+
+	parser := &expressionParser{input: []rune(expression)}
+	result, err := parser.parseExpression()
+	if err != nil {
+		return 0, err
+	}
+
+	parser.skipSpaces()
+	if parser.position < len(parser.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", parser.input[parser.position], parser.position)
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+
+	return result, nil
+}
+
+/*
+expressionParser is a recursive descent parser for the
+grammar:
+
+	expression = term { ("+" | "-") term }
+	term       = power { ("*" | "/" | "%") power }
+	power      = unary [ "^" power ]
+	unary      = "-" unary | "+" unary | primary
+	primary    = number | "(" expression ")"
+*/
+type expressionParser struct {
+	input    []rune
+	position int
+	depth    int
+}
+
+func (parser *expressionParser) skipSpaces() {
+	for parser.position < len(parser.input) && unicode.IsSpace(parser.input[parser.position]) {
+		parser.position++
+	}
+}
+
+func (parser *expressionParser) peek() rune {
+	parser.skipSpaces()
+	if parser.position >= len(parser.input) {
+		return 0
+	}
+	return parser.input[parser.position]
+}
+
+func (parser *expressionParser) enter() error {
+	parser.depth++
+	if parser.depth > maxExpressionDepth {
+		return fmt.Errorf("expression is nested too deeply")
+	}
+	return nil
+}
+
+func (parser *expressionParser) parseExpression() (float64, error) {
+	if err := parser.enter(); err != nil {
+		return 0, err
+	}
+	defer func() { parser.depth-- }()
+
+	left, err := parser.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		operator := parser.peek()
+		if operator != '+' && operator != '-' {
+			return left, nil
+		}
+		parser.position++
+
+		right, err := parser.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if operator == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (parser *expressionParser) parseTerm() (float64, error) {
+	left, err := parser.parsePower()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		operator := parser.peek()
+		if operator != '*' && operator != '/' && operator != '%' {
+			return left, nil
+		}
+		parser.position++
+
+		right, err := parser.parsePower()
+		if err != nil {
+			return 0, err
+		}
+		switch operator {
+		case '*':
+			left *= right
+		case '/':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case '%':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (parser *expressionParser) parsePower() (float64, error) {
+	if err := parser.enter(); err != nil {
+		return 0, err
+	}
+	defer func() { parser.depth-- }()
+
+	base, err := parser.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+
+	if parser.peek() != '^' {
+		return base, nil
+	}
+	parser.position++
+
+	// Exponents are right associative, so 2^3^2 is 2^(3^2)
+	exponent, err := parser.parsePower()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (parser *expressionParser) parseUnary() (float64, error) {
+	if err := parser.enter(); err != nil {
+		return 0, err
+	}
+	defer func() { parser.depth-- }()
+
+	switch parser.peek() {
+	case '-':
+		parser.position++
+		value, err := parser.parseUnary()
+		return -value, err
+	case '+':
+		parser.position++
+		return parser.parseUnary()
+	}
+	return parser.parsePrimary()
+}
+
+func (parser *expressionParser) parsePrimary() (float64, error) {
+	char := parser.peek()
+
+	if char == '(' {
+		parser.position++
+		value, err := parser.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		if parser.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		parser.position++
+		return value, nil
+	}
+
+	start := parser.position
+	for parser.position < len(parser.input) {
+		char := parser.input[parser.position]
+		if !unicode.IsDigit(char) && char != '.' {
+			break
+		}
+		parser.position++
+	}
+	if start == parser.position {
+		if start >= len(parser.input) {
+			return 0, fmt.Errorf("unexpected end of expression")
+		}
+		return 0, fmt.Errorf("unexpected %q at position %d", parser.input[start], start)
+	}
+
+	value, err := strconv.ParseFloat(string(parser.input[start:parser.position]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", string(parser.input[start:parser.position]))
+	}
+	return value, nil
+}
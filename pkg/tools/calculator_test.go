@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := map[string]float64{
+		"2 + 2":             4,
+		"2 + 3 * 4":         14,
+		"(2 + 3) * 4":       20,
+		"10 / 4":            2.5,
+		"10 % 4":            2,
+		"2 ^ 3 ^ 2":         512,
+		"-3 + 5":            2,
+		"-(2 + 3)":          -5,
+		"--4":               4,
+		"1.5 * 2":           3,
+		" ( ( 1 ) ) ":       1,
+		"2 * -3":            -6,
+		"100 - 20 - 30 - 5": 45,
+	}
+
+	for expression, expected := range tests {
+		result, err := Evaluate(expression)
+		require.Nil(t, err, expression)
+		assert.InDelta(t, expected, result, 1e-9, expression)
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"2 +",
+		"(2 + 3",
+		"2 + 3)",
+		"1 / 0",
+		"5 % 0",
+		"two plus two",
+		"1..2",
+		"10 ^ 400",
+	}
+
+	for _, expression := range tests {
+		_, err := Evaluate(expression)
+		assert.NotNil(t, err, expression)
+	}
+
+	// Deeply nested expressions are refused rather than
+	// exhausting the stack
+	nested := ""
+	for i := 0; i < 200; i++ {
+		nested += "("
+	}
+	_, err := Evaluate(nested + "1")
+	assert.NotNil(t, err)
+}
+
+func TestCalculatorTool(t *testing.T) {
+	tool := NewCalculatorTool()
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{"expression": "(1 + 2) * 3"}`))
+	require.Nil(t, err)
+	assert.Equal(t, "9", result)
+
+	_, err = tool.Execute(context.Background(), json.RawMessage(`{"expression": 12}`))
+	assert.NotNil(t, err)
+}
+
+func FuzzEvaluate(f *testing.F) {
+	f.Add("2 + 2")
+	f.Add("(1 + 2) * 3 ^ 2 % 5")
+	f.Add("-(-(-1))")
+	f.Add("1 / 0")
+
+	f.Fuzz(func(t *testing.T, expression string) {
+		// Any input must either evaluate or error, never panic
+		_, _ = Evaluate(expression)
+	})
+}
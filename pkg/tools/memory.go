@@ -0,0 +1,317 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hlfshell/coppermind/internal/store"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+)
+
+// Limits on how much memory a single tool call can return
+const (
+	maxMemoryResults       = 5
+	maxConversationResults = 50
+	maxKnowledgeResults    = 20
+)
+
+// MemoryLookup is the name of the tool searching past
+// conversations
+const MemoryLookup = "memory_lookup"
+
+/*
+MemoryStore is the part of the store the memory tools need.
+*/
+type MemoryStore interface {
+	ListSummaries(filter store.Filter) ([]*memory.Summary, error)
+	ListMessages(filter store.Filter) ([]*chat.Message, error)
+	ListKnowledge(filter store.Filter) ([]*memory.Knowledge, error)
+}
+
+/*
+memoryScope returns the agent and user the memory tools are
+limited to. Memory is never available outside of a
+conversation, as there would be no way to limit it to a
+single agent and user.
+*/
+func memoryScope(ctx context.Context) (Scope, error) {
+	scope, ok := ScopeFromContext(ctx)
+	if !ok || scope.Agent == "" || scope.User == "" {
+		return Scope{}, fmt.Errorf("memory is not available outside of a conversation")
+	}
+	return scope, nil
+}
+
+/*
+scopeFilter returns the filter attributes restricting a
+query to the scope's agent and user, plus any additional
+attributes.
+*/
+func scopeFilter(scope Scope, attributes ...*store.FilterAttribute) store.Filter {
+	return store.Filter{
+		Attributes: append(
+			[]*store.FilterAttribute{
+				{
+					Attribute: "agent",
+					Value:     scope.Agent,
+					Operation: store.EQ,
+				},
+				{
+					Attribute: "user",
+					Value:     scope.User,
+					Operation: store.EQ,
+				},
+			},
+			attributes...,
+		),
+	}
+}
+
+/*
+MemoryLookupTool searches the summaries of past conversations
+between the agent and user for a keyword, most recent first.
+Each result includes its conversation ID so that the whole
+conversation can be recalled with RecallConversationTool.
+*/
+type MemoryLookupTool struct {
+	db MemoryStore
+}
+
+func NewMemoryLookupTool(db MemoryStore) *MemoryLookupTool {
+	return &MemoryLookupTool{
+		db: db,
+	}
+}
+
+func (tool *MemoryLookupTool) Name() string {
+	return MemoryLookup
+}
+
+func (tool *MemoryLookupTool) Description() string {
+	return "Search your past conversations with this user for a keyword"
+}
+
+func (tool *MemoryLookupTool) Schema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"keyword": {
+				"type": "string",
+				"description": "A keyword or topic to search past conversations for"
+			}
+		},
+		"required": ["keyword"]
+	}`)
+}
+
+func (tool *MemoryLookupTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var input struct {
+		Keyword string `json:"keyword"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	keyword := strings.ToLower(strings.TrimSpace(input.Keyword))
+	if keyword == "" {
+		return "", fmt.Errorf("keyword must be set")
+	}
+
+	scope, err := memoryScope(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	summaries, err := tool.db.ListSummaries(scopeFilter(scope))
+	if err != nil {
+		return "", err
+	}
+
+	// Summaries are returned oldest first
+	found := []string{}
+	for index := len(summaries) - 1; index >= 0 && len(found) < maxMemoryResults; index-- {
+		summary := summaries[index]
+		if summaryMatches(summary, keyword) {
+			found = append(found, fmt.Sprintf(
+				"conversation %s on %s about %s: %s",
+				summary.Conversation,
+				summary.ConversationStartedAt.Format("January 2 2006"),
+				summary.KeywordsToString(),
+				summary.Summary,
+			))
+		}
+	}
+
+	if len(found) == 0 {
+		return fmt.Sprintf("No past conversations about %s were found", input.Keyword), nil
+	}
+	return strings.Join(found, "\n"), nil
+}
+
+func summaryMatches(summary *memory.Summary, keyword string) bool {
+	for _, candidate := range summary.Keywords {
+		if strings.Contains(strings.ToLower(candidate), keyword) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(summary.Summary), keyword)
+}
+
+/*
+RecallConversationTool fetches the messages of a past
+conversation between the agent and user, such as one found
+with MemoryLookupTool. Only the most recent messages of long
+conversations are returned.
+*/
+type RecallConversationTool struct {
+	db MemoryStore
+}
+
+func NewRecallConversationTool(db MemoryStore) *RecallConversationTool {
+	return &RecallConversationTool{
+		db: db,
+	}
+}
+
+func (tool *RecallConversationTool) Name() string {
+	return "recall_conversation"
+}
+
+func (tool *RecallConversationTool) Description() string {
+	return "Recall the messages of a past conversation with this user by its conversation ID"
+}
+
+func (tool *RecallConversationTool) Schema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"conversation": {
+				"type": "string",
+				"description": "The ID of the conversation to recall"
+			}
+		},
+		"required": ["conversation"]
+	}`)
+}
+
+func (tool *RecallConversationTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var input struct {
+		Conversation string `json:"conversation"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	conversation := strings.TrimSpace(input.Conversation)
+	if conversation == "" {
+		return "", fmt.Errorf("conversation must be set")
+	}
+
+	scope, err := memoryScope(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The conversation is filtered by agent and user as well,
+	// so that conversations with anyone else read as missing
+	messages, err := tool.db.ListMessages(scopeFilter(scope, &store.FilterAttribute{
+		Attribute: "conversation",
+		Value:     conversation,
+		Operation: store.EQ,
+	}))
+	if err != nil {
+		return "", err
+	}
+
+	if len(messages) == 0 {
+		return fmt.Sprintf("No conversation %s was found", conversation), nil
+	}
+
+	// Messages are returned oldest first
+	if len(messages) > maxConversationResults {
+		messages = messages[len(messages)-maxConversationResults:]
+	}
+
+	lines := []string{}
+	for _, message := range messages {
+		lines = append(lines, message.DatedString())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+/*
+KnowledgeLookupTool looks up the unexpired facts the agent
+has learned about a subject from its conversations with the
+user.
+*/
+type KnowledgeLookupTool struct {
+	db MemoryStore
+}
+
+func NewKnowledgeLookupTool(db MemoryStore) *KnowledgeLookupTool {
+	return &KnowledgeLookupTool{
+		db: db,
+	}
+}
+
+func (tool *KnowledgeLookupTool) Name() string {
+	return "knowledge_lookup"
+}
+
+func (tool *KnowledgeLookupTool) Description() string {
+	return "Look up what you have learned about a subject from your conversations with this user"
+}
+
+func (tool *KnowledgeLookupTool) Schema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"subject": {
+				"type": "string",
+				"description": "The person, place, or thing to look up, ie Keith or Keith's dog"
+			}
+		},
+		"required": ["subject"]
+	}`)
+}
+
+func (tool *KnowledgeLookupTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var input struct {
+		Subject string `json:"subject"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	subject := strings.ToLower(strings.TrimSpace(input.Subject))
+	if subject == "" {
+		return "", fmt.Errorf("subject must be set")
+	}
+
+	scope, err := memoryScope(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	knowledge, err := tool.db.ListKnowledge(scopeFilter(scope))
+	if err != nil {
+		return "", err
+	}
+
+	// Knowledge is returned oldest first
+	found := []string{}
+	for index := len(knowledge) - 1; index >= 0 && len(found) < maxKnowledgeResults; index-- {
+		fact := knowledge[index]
+		if fact.IsExpired() || fact.IsSuperseded() {
+			continue
+		}
+		if strings.Contains(strings.ToLower(fact.Subject), subject) {
+			found = append(found, fact.String())
+		}
+	}
+
+	if len(found) == 0 {
+		return fmt.Sprintf("Nothing is known about %s", input.Subject), nil
+	}
+	return strings.Join(found, "\n"), nil
+}
@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/internal/store/sqlite"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/hlfshell/coppermind/pkg/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createMemoryStore(t *testing.T) *sqlite.SqliteStore {
+	db, err := sqlite.NewSqliteStore(":memory:")
+	require.Nil(t, err)
+	require.Nil(t, db.Migrate())
+	return db
+}
+
+func TestMemoryLookupTool(t *testing.T) {
+	db := createMemoryStore(t)
+	for i := 0; i < 8; i++ {
+		require.Nil(t, db.SaveSummary(&memory.Summary{
+			ID:                    uuid.New().String(),
+			Agent:                 testScope.Agent,
+			User:                  testScope.User,
+			Conversation:          fmt.Sprintf("conversation-%d", i),
+			Keywords:              []string{"hiking"},
+			Summary:               fmt.Sprintf("Keith went on hike %d", i),
+			ConversationStartedAt: time.Now().Add(time.Duration(i-10) * time.Hour),
+			UpdatedAt:             time.Now(),
+		}))
+	}
+	require.Nil(t, db.SaveSummary(&memory.Summary{
+		ID:                    uuid.New().String(),
+		Agent:                 testScope.Agent,
+		User:                  "abby",
+		Conversation:          "abbys-conversation",
+		Keywords:              []string{"hiking"},
+		Summary:               "Abby went on a secret hike",
+		ConversationStartedAt: time.Now(),
+		UpdatedAt:             time.Now(),
+	}))
+	tool := NewMemoryLookupTool(db)
+
+	ctx := WithScope(context.Background(), testScope)
+
+	// Results are limited, most recent first, include the
+	// conversation to recall, and never include other users'
+	// conversations
+	result, err := tool.Execute(ctx, json.RawMessage(`{"keyword": "Hiking"}`))
+	require.Nil(t, err)
+	assert.Contains(t, result, "conversation conversation-7")
+	assert.Contains(t, result, "hike 3")
+	assert.NotContains(t, result, "hike 2")
+	assert.NotContains(t, result, "secret")
+
+	result, err = tool.Execute(ctx, json.RawMessage(`{"keyword": "sailing"}`))
+	require.Nil(t, err)
+	assert.Contains(t, result, "No past conversations")
+
+	_, err = tool.Execute(ctx, json.RawMessage(`{"keyword": ""}`))
+	assert.NotNil(t, err)
+
+	// Memory is not available without a scope
+	_, err = tool.Execute(context.Background(), json.RawMessage(`{"keyword": "hiking"}`))
+	assert.NotNil(t, err)
+}
+
+func TestRecallConversationTool(t *testing.T) {
+	db := createMemoryStore(t)
+
+	conversation := uuid.New().String()
+	for i := 0; i < maxConversationResults+5; i++ {
+		require.Nil(t, db.SaveMessage(&chat.Message{
+			ID:           uuid.New().String(),
+			Conversation: conversation,
+			Agent:        testScope.Agent,
+			User:         testScope.User,
+			From:         testScope.User,
+			Content:      fmt.Sprintf("message %d", i),
+			CreatedAt:    time.Now().Add(time.Duration(i-100) * time.Minute),
+		}))
+	}
+	otherConversation := uuid.New().String()
+	require.Nil(t, db.SaveMessage(&chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: otherConversation,
+		Agent:        testScope.Agent,
+		User:         "abby",
+		From:         "abby",
+		Content:      "A secret",
+		CreatedAt:    time.Now(),
+	}))
+	tool := NewRecallConversationTool(db)
+
+	ctx := WithScope(context.Background(), testScope)
+
+	// Long conversations are cut to their latest messages
+	result, err := tool.Execute(ctx, json.RawMessage(fmt.Sprintf(`{"conversation": "%s"}`, conversation)))
+	require.Nil(t, err)
+	assert.Contains(t, result, "message 54")
+	assert.Contains(t, result, "message 5\n")
+	assert.NotContains(t, result, "message 4\n")
+
+	// Other users' conversations can't be recalled
+	result, err = tool.Execute(ctx, json.RawMessage(fmt.Sprintf(`{"conversation": "%s"}`, otherConversation)))
+	require.Nil(t, err)
+	assert.NotContains(t, result, "secret")
+	assert.Contains(t, result, "No conversation")
+
+	_, err = tool.Execute(ctx, json.RawMessage(`{"conversation": " "}`))
+	assert.NotNil(t, err)
+
+	_, err = tool.Execute(context.Background(), json.RawMessage(fmt.Sprintf(`{"conversation": "%s"}`, conversation)))
+	assert.NotNil(t, err)
+}
+
+func TestKnowledgeLookupTool(t *testing.T) {
+	db := createMemoryStore(t)
+
+	facts := []*memory.Knowledge{
+		{Agent: testScope.Agent, User: testScope.User, Subject: "Keith", Predicate: "likes", Object: "hiking", ExpiresAt: time.Now().Add(time.Hour)},
+		{Agent: testScope.Agent, User: testScope.User, Subject: "Keith's dog", Predicate: "is named", Object: "Cooper", ExpiresAt: time.Now().Add(time.Hour)},
+		{Agent: testScope.Agent, User: testScope.User, Subject: "Keith", Predicate: "is at", Object: "the dentist", ExpiresAt: time.Now().Add(-time.Hour)},
+		{Agent: testScope.Agent, User: "abby", Subject: "Keith", Predicate: "owes", Object: "Abby money", ExpiresAt: time.Now().Add(time.Hour)},
+		{Agent: "hal", User: testScope.User, Subject: "Keith", Predicate: "fears", Object: "Hal", ExpiresAt: time.Now().Add(time.Hour)},
+		{Agent: testScope.Agent, User: testScope.User, Subject: "Keith", Predicate: "works at", Object: "Acme", ExpiresAt: time.Now().Add(time.Hour), SupersededBy: "newer"},
+	}
+	for _, fact := range facts {
+		fact.ID = uuid.New().String()
+		fact.CreatedAt = time.Now()
+		require.Nil(t, db.SaveKnowledge(fact))
+	}
+	tool := NewKnowledgeLookupTool(db)
+
+	ctx := WithScope(context.Background(), testScope)
+
+	// Only current knowledge from this agent and user is
+	// returned
+	result, err := tool.Execute(ctx, json.RawMessage(`{"subject": "keith"}`))
+	require.Nil(t, err)
+	assert.Contains(t, result, "Keith likes hiking")
+	assert.Contains(t, result, "Keith's dog is named Cooper")
+	assert.NotContains(t, result, "dentist")
+	assert.NotContains(t, result, "Abby")
+	assert.NotContains(t, result, "Hal")
+	assert.NotContains(t, result, "Acme")
+
+	result, err = tool.Execute(ctx, json.RawMessage(`{"subject": "Cooper"}`))
+	require.Nil(t, err)
+	assert.Contains(t, result, "Nothing is known")
+
+	_, err = tool.Execute(ctx, json.RawMessage(`{}`))
+	assert.NotNil(t, err)
+
+	_, err = tool.Execute(context.Background(), json.RawMessage(`{"subject": "keith"}`))
+	assert.NotNil(t, err)
+}
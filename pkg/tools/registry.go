@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/agents"
+)
+
+/*
+Registry is the set of all tools coppermind knows of. Agents
+opt into the tools they may use by name (agents.Agent.Tools).
+*/
+type Registry struct {
+	tools map[string]Tool
+	lock  sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		tools: map[string]Tool{},
+	}
+}
+
+func (registry *Registry) Register(tool Tool) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if _, ok := registry.tools[tool.Name()]; ok {
+		return fmt.Errorf("tool %s is already registered", tool.Name())
+	}
+	registry.tools[tool.Name()] = tool
+	return nil
+}
+
+func (registry *Registry) Get(name string) Tool {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	return registry.tools[name]
+}
+
+func (registry *Registry) Names() []string {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	names := []string{}
+	for name := range registry.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+Toolbox creates a toolbox of the tools the given agent has
+enabled, for a single message. Enabled tools that are not
+registered are ignored.
+*/
+func (registry *Registry) Toolbox(
+	agent *agents.Agent,
+	scope Scope,
+	maxSteps int,
+	timeout time.Duration,
+) *Toolbox {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	enabled := []Tool{}
+	for _, name := range agent.Tools {
+		if tool, ok := registry.tools[name]; ok {
+			enabled = append(enabled, tool)
+		}
+	}
+
+	return NewToolbox(enabled, scope, maxSteps, timeout)
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/*
+CurrentTimeTool reports the current date and time, in UTC
+or a requested IANA timezone.
+*/
+type CurrentTimeTool struct {
+	now func() time.Time
+}
+
+func NewCurrentTimeTool() *CurrentTimeTool {
+	return &CurrentTimeTool{
+		now: time.Now,
+	}
+}
+
+func (tool *CurrentTimeTool) Name() string {
+	return "current_time"
+}
+
+func (tool *CurrentTimeTool) Description() string {
+	return "Get the current date and time"
+}
+
+func (tool *CurrentTimeTool) Schema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"timezone": {
+				"type": "string",
+				"description": "An IANA timezone such as America/New_York; defaults to UTC"
+			}
+		}
+	}`)
+}
+
+func (tool *CurrentTimeTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var input struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	location := time.UTC
+	if input.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(input.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown timezone %s", input.Timezone)
+		}
+	}
+
+	return tool.now().In(location).Format("Monday, January 2 2006 15:04:05 MST"), nil
+}
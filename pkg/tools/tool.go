@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+)
+
+/*
+Tool is a capability an agent can call upon while forming
+its response, such as checking the time or looking up a
+past conversation. Schema is the JSON schema of the
+arguments the tool accepts, and Execute is handed those
+arguments as the LLM wrote them - tools are expected to
+validate them and return an error the LLM can understand
+if they are invalid.
+*/
+type Tool interface {
+	Name() string
+	Description() string
+	Schema() json.RawMessage
+	Execute(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+/*
+Scope is the agent, user, and conversation a tool is being
+executed for. Tools that access stored data must restrict
+themselves to their scope.
+*/
+type Scope struct {
+	Agent        string
+	User         string
+	Conversation string
+}
+
+type scopeKey struct{}
+
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+func ScopeFromContext(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey{}).(Scope)
+	return scope, ok
+}
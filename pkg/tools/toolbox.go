@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hlfshell/coppermind/pkg/chat"
+)
+
+/*
+Toolbox is the set of tools available while responding to a
+single message. LLMs hand the toolbox the tool calls the
+model requests, and the toolbox executes them and keeps
+each step as a message so that they can be saved as part
+of the conversation. A nil toolbox has no tools.
+*/
+type Toolbox struct {
+	tools    []Tool
+	byName   map[string]Tool
+	scope    Scope
+	maxSteps int
+	timeout  time.Duration
+
+	// Cancelled once the attempt using the toolbox is
+	// abandoned, stopping any tools it would run
+	ctx context.Context
+
+	// An abandoned attempt may still be running while its
+	// steps are read
+	lock  sync.Mutex
+	steps []*chat.Message
+}
+
+/*
+NewToolbox creates a toolbox that will execute at most
+maxSteps rounds of tool calls, each tool call limited to
+timeout (0 for no limit).
+*/
+func NewToolbox(tools []Tool, scope Scope, maxSteps int, timeout time.Duration) *Toolbox {
+	byName := map[string]Tool{}
+	for _, tool := range tools {
+		byName[tool.Name()] = tool
+	}
+
+	return &Toolbox{
+		tools:    tools,
+		byName:   byName,
+		scope:    scope,
+		maxSteps: maxSteps,
+		timeout:  timeout,
+		ctx:      context.Background(),
+		steps:    []*chat.Message{},
+	}
+}
+
+/*
+Attempt returns a toolbox for a single attempt at a
+response, ie against one of several backends. It has the
+same tools and the steps taken so far, but keeps its own
+steps until they are kept with Keep. Once cancelled, it
+runs no more tools, and any it is running are cancelled.
+*/
+func (toolbox *Toolbox) Attempt() (*Toolbox, context.CancelFunc) {
+	if toolbox == nil {
+		return nil, func() {}
+	}
+
+	ctx, cancel := context.WithCancel(toolbox.ctx)
+	return &Toolbox{
+		tools:    toolbox.tools,
+		byName:   toolbox.byName,
+		scope:    toolbox.scope,
+		maxSteps: toolbox.maxSteps,
+		timeout:  toolbox.timeout,
+		ctx:      ctx,
+		steps:    toolbox.Steps(),
+	}, cancel
+}
+
+/*
+Keep takes on the steps of an attempt, as the attempt whose
+response is used.
+*/
+func (toolbox *Toolbox) Keep(attempt *Toolbox) {
+	if toolbox == nil || attempt == nil {
+		return
+	}
+
+	steps := attempt.Steps()
+	toolbox.lock.Lock()
+	toolbox.steps = steps
+	toolbox.lock.Unlock()
+}
+
+func (toolbox *Toolbox) Tools() []Tool {
+	if toolbox == nil {
+		return nil
+	}
+	return toolbox.tools
+}
+
+/*
+CanRun is whether the model may be offered tools; false if
+there are no tools or the maximum number of steps have been
+taken, at which point the model must answer without them.
+*/
+func (toolbox *Toolbox) CanRun() bool {
+	if toolbox == nil {
+		return false
+	}
+	if toolbox.ctx.Err() != nil {
+		return false
+	}
+	toolbox.lock.Lock()
+	defer toolbox.lock.Unlock()
+	return len(toolbox.tools) > 0 && len(toolbox.steps) < toolbox.maxSteps
+}
+
+/*
+Steps are the messages recording each round of tool calls
+that were run, in order.
+*/
+func (toolbox *Toolbox) Steps() []*chat.Message {
+	if toolbox == nil {
+		return nil
+	}
+	toolbox.lock.Lock()
+	defer toolbox.lock.Unlock()
+	return append([]*chat.Message{}, toolbox.steps...)
+}
+
+/*
+Run executes a round of tool calls requested by the model,
+setting each call's Result or Error, and returns the step
+message recording them. Failing tools do not fail the
+round; their error is reported back to the model instead.
+*/
+func (toolbox *Toolbox) Run(calls []*chat.ToolCall) *chat.Message {
+	content := []string{}
+	for _, call := range calls {
+		result, err := toolbox.execute(call)
+		if err != nil {
+			call.Error = err.Error()
+		} else {
+			call.Result = result
+		}
+		content = append(content, call.String())
+	}
+
+	step := &chat.Message{
+		ID:           uuid.New().String(),
+		Conversation: toolbox.scope.Conversation,
+		User:         toolbox.scope.User,
+		Agent:        toolbox.scope.Agent,
+		From:         toolbox.scope.Agent,
+		Content:      strings.Join(content, "\n"),
+		ToolCalls:    calls,
+		CreatedAt:    time.Now(),
+	}
+	toolbox.lock.Lock()
+	toolbox.steps = append(toolbox.steps, step)
+	toolbox.lock.Unlock()
+
+	return step
+}
+
+func (toolbox *Toolbox) execute(call *chat.ToolCall) (string, error) {
+	if err := toolbox.ctx.Err(); err != nil {
+		return "", err
+	}
+
+	tool, ok := toolbox.byName[call.Name]
+	if !ok {
+		return "", fmt.Errorf("no tool named %s is available", call.Name)
+	}
+
+	args := call.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	} else if !json.Valid(args) {
+		return "", fmt.Errorf("arguments must be a JSON object")
+	}
+
+	ctx := WithScope(toolbox.ctx, toolbox.scope)
+	if toolbox.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, toolbox.timeout)
+		defer cancel()
+	}
+
+	return tool.Execute(ctx, args)
+}
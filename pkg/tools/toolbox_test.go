@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hlfshell/coppermind/pkg/agents"
+	"github.com/hlfshell/coppermind/pkg/chat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testScope = Scope{
+	Agent:        "rose",
+	User:         "keith",
+	Conversation: "conversation",
+}
+
+// waitTool blocks until its context is done
+type waitTool struct{}
+
+func (tool *waitTool) Name() string            { return "wait" }
+func (tool *waitTool) Description() string     { return "Waits forever" }
+func (tool *waitTool) Schema() json.RawMessage { return json.RawMessage(`{"type": "object"}`) }
+func (tool *waitTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	require.Nil(t, registry.Register(NewCalculatorTool()))
+	require.Nil(t, registry.Register(NewCurrentTimeTool()))
+
+	// Names must be unique
+	assert.NotNil(t, registry.Register(NewCalculatorTool()))
+
+	assert.Equal(t, []string{"calculator", "current_time"}, registry.Names())
+	assert.NotNil(t, registry.Get("calculator"))
+	assert.Nil(t, registry.Get("unknown"))
+
+	// Only the tools the agent enables, and that exist, are
+	// placed in its toolbox
+	agent := &agents.Agent{
+		ID:    "rose",
+		Tools: []string{"calculator", "unknown"},
+	}
+	toolbox := registry.Toolbox(agent, testScope, 5, time.Second)
+	require.Len(t, toolbox.Tools(), 1)
+	assert.Equal(t, "calculator", toolbox.Tools()[0].Name())
+	assert.True(t, toolbox.CanRun())
+
+	// Agents without tools can't run any
+	toolbox = registry.Toolbox(&agents.Agent{ID: "hal"}, testScope, 5, time.Second)
+	assert.False(t, toolbox.CanRun())
+}
+
+func TestToolboxRun(t *testing.T) {
+	toolbox := NewToolbox(
+		[]Tool{NewCalculatorTool(), &waitTool{}},
+		testScope,
+		2,
+		10*time.Millisecond,
+	)
+
+	calls := []*chat.ToolCall{
+		{ID: "1", Name: "calculator", Arguments: json.RawMessage(`{"expression": "6 * 7"}`)},
+		{ID: "2", Name: "unknown", Arguments: json.RawMessage(`{}`)},
+		{ID: "3", Name: "calculator", Arguments: json.RawMessage(`{"expression": `)},
+		{ID: "4", Name: "wait"},
+	}
+	step := toolbox.Run(calls)
+
+	assert.Equal(t, "42", calls[0].Result)
+	assert.Empty(t, calls[0].Error)
+	assert.Contains(t, calls[1].Error, "no tool named unknown")
+	assert.NotEmpty(t, calls[2].Error)
+	assert.Contains(t, calls[3].Error, "deadline exceeded")
+
+	// The step is recorded as a message from the agent in
+	// the toolbox's scope
+	assert.NotEmpty(t, step.ID)
+	assert.Equal(t, testScope.Agent, step.From)
+	assert.Equal(t, testScope.User, step.User)
+	assert.Equal(t, testScope.Conversation, step.Conversation)
+	assert.Equal(t, calls, step.ToolCalls)
+	assert.Contains(t, step.Content, "calculator({\"expression\": \"6 * 7\"}) -> 42")
+
+	// Steps are limited
+	assert.True(t, toolbox.CanRun())
+	toolbox.Run([]*chat.ToolCall{{ID: "5", Name: "calculator", Arguments: json.RawMessage(`{"expression": "1"}`)}})
+	assert.False(t, toolbox.CanRun())
+	assert.Len(t, toolbox.Steps(), 2)
+
+	// A nil toolbox has nothing to run
+	var empty *Toolbox
+	assert.False(t, empty.CanRun())
+	assert.Empty(t, empty.Tools())
+	assert.Empty(t, empty.Steps())
+}
+
+func TestToolboxAttempt(t *testing.T) {
+	toolbox := NewToolbox([]Tool{NewCalculatorTool()}, testScope, 3, time.Second)
+	toolbox.Run([]*chat.ToolCall{{ID: "1", Name: "calculator", Arguments: json.RawMessage(`{"expression": "1"}`)}})
+
+	// Attempts start from the steps taken so far, and keep
+	// their own until kept
+	attempt, cancel := toolbox.Attempt()
+	assert.Len(t, attempt.Steps(), 1)
+	attempt.Run([]*chat.ToolCall{{ID: "2", Name: "calculator", Arguments: json.RawMessage(`{"expression": "2"}`)}})
+	assert.Len(t, toolbox.Steps(), 1)
+
+	toolbox.Keep(attempt)
+	assert.Len(t, toolbox.Steps(), 2)
+
+	// Once cancelled, an attempt runs nothing more
+	cancel()
+	assert.False(t, attempt.CanRun())
+	calls := []*chat.ToolCall{{ID: "3", Name: "calculator", Arguments: json.RawMessage(`{"expression": "3"}`)}}
+	attempt.Run(calls)
+	assert.Empty(t, calls[0].Result)
+	assert.Contains(t, calls[0].Error, "canceled")
+	assert.True(t, toolbox.CanRun())
+
+	// A nil toolbox has nil attempts
+	var empty *Toolbox
+	attempt, cancel = empty.Attempt()
+	cancel()
+	assert.Nil(t, attempt)
+	empty.Keep(attempt)
+}
+
+func TestCurrentTimeTool(t *testing.T) {
+	tool := NewCurrentTimeTool()
+	tool.now = func() time.Time {
+		return time.Date(2023, 10, 5, 14, 30, 0, 0, time.UTC)
+	}
+
+	result, err := tool.Execute(context.Background(), json.RawMessage(`{}`))
+	require.Nil(t, err)
+	assert.Equal(t, "Thursday, October 5 2023 14:30:00 UTC", result)
+
+	result, err = tool.Execute(context.Background(), json.RawMessage(`{"timezone": "America/New_York"}`))
+	require.Nil(t, err)
+	assert.Equal(t, "Thursday, October 5 2023 10:30:00 EDT", result)
+
+	_, err = tool.Execute(context.Background(), json.RawMessage(`{"timezone": "Mars/Olympus"}`))
+	assert.NotNil(t, err)
+}
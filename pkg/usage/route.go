@@ -0,0 +1,42 @@
+package usage
+
+import "time"
+
+/*
+Route is a record of a single attempt by the LLM router to
+serve a call with a given backend. A call that falls back
+will have one Route per backend tried, in order of Attempt;
+only the last of them can be a Success.
+*/
+type Route struct {
+	ID           string    `json:"id,omitempty" db:"id"`
+	Agent        string    `json:"agent,omitempty" db:"agent"`
+	User         string    `json:"user,omitempty" db:"user"`
+	Conversation string    `json:"conversation,omitempty" db:"conversation"`
+	Type         string    `json:"type,omitempty" db:"type"`
+	Backend      string    `json:"backend,omitempty" db:"backend"`
+	Attempt      int       `json:"attempt" db:"attempt"`
+	Success      bool      `json:"success" db:"success"`
+	Error        string    `json:"error,omitempty" db:"error"`
+	Duration     int64     `json:"duration_ms" db:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+func (route *Route) Equal(other *Route) bool {
+	timeDifference := route.CreatedAt.Sub(other.CreatedAt)
+	if timeDifference < 0 {
+		timeDifference = -timeDifference
+	}
+
+	return route.ID == other.ID &&
+		route.Agent == other.Agent &&
+		route.User == other.User &&
+		route.Conversation == other.Conversation &&
+		route.Type == other.Type &&
+		route.Backend == other.Backend &&
+		route.Attempt == other.Attempt &&
+		route.Success == other.Success &&
+		route.Error == other.Error &&
+		route.Duration == other.Duration &&
+		timeDifference < time.Second
+}
@@ -0,0 +1,145 @@
+package usage
+
+import (
+	"time"
+)
+
+/*
+These are the types of LLM calls that coppermind makes,
+and are used to categorize usage records.
+*/
+const (
+	CallChat        = "chat"
+	CallContinuance = "continuance"
+	CallSummarize   = "summarize"
+	CallLearn       = "learn"
+	CallCompress    = "compress"
+	CallEmbed       = "embed"
+)
+
+// Prices are expressed per this many tokens
+const tokensPerPricing = 1000
+
+/*
+Usage is a record of the tokens consumed by a single call
+to an LLM, keyed by the agent, user, and conversation that
+triggered it. Agent, User, and Conversation may be blank
+if the call was not tied to one of them.
+*/
+type Usage struct {
+	ID               string    `json:"id,omitempty" db:"id"`
+	Agent            string    `json:"agent,omitempty" db:"agent"`
+	User             string    `json:"user,omitempty" db:"user"`
+	Conversation     string    `json:"conversation,omitempty" db:"conversation"`
+	Type             string    `json:"type,omitempty" db:"type"`
+	Model            string    `json:"model,omitempty" db:"model"`
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+	CreatedAt        time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+func (usage *Usage) TotalTokens() int {
+	return usage.PromptTokens + usage.CompletionTokens
+}
+
+func (usage *Usage) Equal(other *Usage) bool {
+	timeDifference := usage.CreatedAt.Sub(other.CreatedAt)
+	if timeDifference < 0 {
+		timeDifference = -timeDifference
+	}
+
+	return usage.ID == other.ID &&
+		usage.Agent == other.Agent &&
+		usage.User == other.User &&
+		usage.Conversation == other.Conversation &&
+		usage.Type == other.Type &&
+		usage.Model == other.Model &&
+		usage.PromptTokens == other.PromptTokens &&
+		usage.CompletionTokens == other.CompletionTokens &&
+		timeDifference < time.Second
+}
+
+/*
+Price is the cost of a given model, expressed in cost per
+1,000 tokens for both the prompt and the completion, as
+that is how most providers bill.
+*/
+type Price struct {
+	PromptPer1K     float64 `json:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k"`
+}
+
+func (price Price) Cost(usage *Usage) float64 {
+	return float64(usage.PromptTokens)/tokensPerPricing*price.PromptPer1K +
+		float64(usage.CompletionTokens)/tokensPerPricing*price.CompletionPer1K
+}
+
+/*
+Totals is an aggregated sum of usage records
+*/
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (totals *Totals) add(usage *Usage, cost float64) {
+	totals.Calls++
+	totals.PromptTokens += usage.PromptTokens
+	totals.CompletionTokens += usage.CompletionTokens
+	totals.TotalTokens += usage.TotalTokens()
+	totals.Cost += cost
+}
+
+/*
+Report is the aggregated usage and cost over a given time
+range, broken down by model and by call type. Models that
+have no price set are counted towards tokens but at no cost.
+*/
+type Report struct {
+	Agent  string             `json:"agent,omitempty"`
+	User   string             `json:"user,omitempty"`
+	From   time.Time          `json:"from"`
+	To     time.Time          `json:"to"`
+	Totals Totals             `json:"totals"`
+	Models map[string]*Totals `json:"models"`
+	Types  map[string]*Totals `json:"types"`
+}
+
+func NewReport(
+	agent string,
+	user string,
+	from time.Time,
+	to time.Time,
+	records []*Usage,
+	prices map[string]Price,
+) *Report {
+	report := &Report{
+		Agent:  agent,
+		User:   user,
+		From:   from,
+		To:     to,
+		Models: map[string]*Totals{},
+		Types:  map[string]*Totals{},
+	}
+
+	for _, record := range records {
+		cost := prices[record.Model].Cost(record)
+
+		report.Totals.add(record, cost)
+
+		if _, ok := report.Models[record.Model]; !ok {
+			report.Models[record.Model] = &Totals{}
+		}
+		report.Models[record.Model].add(record, cost)
+
+		if _, ok := report.Types[record.Type]; !ok {
+			report.Types[record.Type] = &Totals{}
+		}
+		report.Types[record.Type].add(record, cost)
+	}
+
+	return report
+}
@@ -0,0 +1,47 @@
+package users
+
+import "time"
+
+// The kinds of a user's data, as counted by an erasure
+const (
+	DataProfile             = "profile"
+	DataMessages            = "messages"
+	DataArtifacts           = "artifacts"
+	DataSummaries           = "summaries"
+	DataSummaryExclusions   = "summary_exclusions"
+	DataKnowledge           = "knowledge"
+	DataKnowledgeExtraction = "knowledge_extractions"
+	DataAliases             = "aliases"
+	DataDocumentChunks      = "document_chunks"
+	DataUsage               = "usage"
+	DataRoutes              = "routes"
+	DataQuotas              = "quotas"
+	DataMemberships         = "memberships"
+	DataFeedback            = "feedback"
+	DataAgentRevisions      = "agent_revisions"
+)
+
+/*
+Erasure is the audit record of a user's data being erased.
+Only the user's ID and organization, who erased them, and
+how many records of each kind were removed, are kept. A dry run counts what would be erased
+without erasing it, and is not recorded.
+*/
+type Erasure struct {
+	ID        string         `json:"id,omitempty" db:"id"`
+	User      string         `json:"user,omitempty" db:"user"`
+	Actor     string         `json:"actor,omitempty" db:"actor"`
+	Tenant    string         `json:"tenant,omitempty" db:"tenant"`
+	Counts    map[string]int `json:"counts" db:"counts"`
+	DryRun    bool           `json:"dry_run,omitempty"`
+	CreatedAt time.Time      `json:"created_at,omitempty" db:"created_at"`
+}
+
+// Total is the number of records erased across every kind
+func (erasure *Erasure) Total() int {
+	total := 0
+	for _, count := range erasure.Counts {
+		total += count
+	}
+	return total
+}
@@ -9,6 +9,30 @@ type User struct {
 	Name      string    `json:"name,omitempty" db:"name"`
 	CreatedAt time.Time `json:"created_at,omitempty" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Tenant    string    `json:"tenant,omitempty" db:"tenant"`
+	Role      string    `json:"role,omitempty" db:"role"`
+}
+
+/*
+These are the roles a user may have within their
+organization. Admins manage everything, including others'
+roles, and may reach every agent; agent editors manage
+agents and who their members are; members may only talk
+to the agents available to them.
+*/
+const (
+	RoleAdmin       = "admin"
+	RoleAgentEditor = "agent-editor"
+	RoleMember      = "member"
+)
+
+// ValidRole is whether role is a known role
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleAgentEditor, RoleMember:
+		return true
+	}
+	return false
 }
 
 func (user *User) Equal(other *User) bool {
@@ -24,6 +48,8 @@ func (user *User) Equal(other *User) bool {
 
 	return user.ID == other.ID &&
 		user.Name == other.Name &&
+		user.Tenant == other.Tenant &&
+		user.Role == other.Role &&
 		timeDiffereneceCreatedAt < time.Second &&
 		timeDifferenceUpdatedAt < time.Second
 }